@@ -0,0 +1,219 @@
+package merkledag
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// archiveMagic 是归档文件开头的标识，最后一个字节为格式的版本
+const archiveMagic = "MDAR\x01"
+
+// maxArchiveField 是没有设置WithMaxBlockSize时归档中单个键值或数据块的最大长度
+const maxArchiveField = 1 << 30
+
+var errMalformedArchive = errors.New("malformed archive")
+
+// ExportArchive 将root可达的所有数据块写入w，得到一个自包含的归档。
+// 格式为：archiveMagic，根节点的键值和哈希函数的名字，然后每个数据块依次为键值和内容，
+// 字符串和内容前都写入其uvarint长度。每个数据块只写入一次，内嵌文件随其父目录写入
+func ExportArchive(service *DagService, root string, w io.Writer) error {
+	objType, err := rootType(root)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	bw.WriteString(archiveMagic)
+	writeArchiveField(bw, []byte(root))
+	writeArchiveField(bw, []byte(service.hashAlgorithm()))
+	visited := make(map[string]bool)
+	stack := []blockRef{{key: root, objType: objType}}
+	for len(stack) > 0 {
+		ref := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if visited[ref.key] {
+			continue
+		}
+		visited[ref.key] = true
+		if _, ok := inlineData(ref.key); ok {
+			continue
+		}
+		data, err := service.getBlock(ref.key)
+		if err != nil {
+			return err
+		}
+		writeArchiveField(bw, []byte(ref.key))
+		writeArchiveField(bw, data)
+		obj, err := service.blockLinks(ref, data)
+		if err != nil {
+			return corruptBlock(ref.key, err)
+		}
+		if obj == nil {
+			continue
+		}
+		for i, link := range obj.Links {
+			stack = append(stack, blockRef{key: string(link.Hash), objType: obj.linkType(i)})
+		}
+	}
+	return bw.Flush()
+}
+
+// ImportArchive 读取ExportArchive写出的归档，将其中的数据块写入service，返回归档的根节点。
+// 归档的哈希函数与service的不同时返回ErrHashAlgorithmMismatch。归档来自不可信的来源，
+// 因此先读入全部数据块并逐个验证其键值，再检查根节点可达的数据块都在归档或service中，
+// 有任何一个不符时返回ErrCorruptArchive，不写入任何数据块
+func ImportArchive(service *DagService, r io.Reader) (string, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(archiveMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != archiveMagic {
+		return "", errMalformedArchive
+	}
+	limit := uint64(maxArchiveField)
+	if service.maxBlockSize > 0 {
+		limit = uint64(service.maxBlockSize)
+	}
+	root, err := readArchiveField(br, limit)
+	if err != nil {
+		return "", err
+	}
+	alg, err := readArchiveField(br, limit)
+	if err != nil {
+		return "", err
+	}
+	if want := service.hashAlgorithm(); string(alg) != want {
+		return "", fmt.Errorf("archive uses %s, service uses %s: %w", alg, want, ErrHashAlgorithmMismatch)
+	}
+	if _, err := rootType(string(root)); err != nil {
+		return "", err
+	}
+	var keys []string
+	blocks := make(map[string][]byte)
+	for {
+		key, err := readArchiveField(br, limit)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		data, err := readArchiveField(br, limit)
+		if err == io.EOF {
+			return "", errMalformedArchive
+		}
+		if err != nil {
+			return "", err
+		}
+		if _, ok := blocks[string(key)]; !ok {
+			keys = append(keys, string(key))
+		}
+		blocks[string(key)] = data
+	}
+	// 读完后再验证：设置了WithContentNormalization时验证LIST需要读取它链接的块，它们在归档中位于LIST之后
+	fetch := func(key string) ([]byte, error) {
+		if data, ok := blocks[key]; ok {
+			return data, nil
+		}
+		return service.getBlock(key)
+	}
+	for _, key := range keys {
+		if err := service.verifyBlockWith(key, blocks[key], fetch); err != nil {
+			return "", &ErrCorruptArchive{Key: key}
+		}
+	}
+	if err := service.checkArchiveRoot(string(root), blocks); err != nil {
+		return "", err
+	}
+	_, _, err = service.run(context.Background(), false, func(a *adder) (string, error) {
+		for _, key := range keys {
+			if err := a.putBlock(key, blocks[key]); err != nil {
+				return "", err
+			}
+		}
+		return "", nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(root), nil
+}
+
+// checkArchiveRoot 检查root可达的每个数据块都在blocks或KVStore中。
+// KVStore中已经存在的数据块视为其子树完整，不再检查
+func (s *DagService) checkArchiveRoot(root string, blocks map[string][]byte) error {
+	objType, err := rootType(root)
+	if err != nil {
+		return err
+	}
+	visited := make(map[string]bool)
+	stack := []blockRef{{key: root, objType: objType}}
+	for len(stack) > 0 {
+		ref := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if visited[ref.key] {
+			continue
+		}
+		visited[ref.key] = true
+		if _, ok := inlineData(ref.key); ok {
+			continue
+		}
+		data, ok := blocks[ref.key]
+		if !ok {
+			exists, err := s.store.Has(ref.key)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				return &ErrCorruptArchive{Key: ref.key}
+			}
+			continue
+		}
+		obj, err := s.blockLinks(ref, data)
+		if err != nil {
+			return &ErrCorruptArchive{Key: ref.key}
+		}
+		if obj == nil {
+			continue
+		}
+		for i, link := range obj.Links {
+			stack = append(stack, blockRef{key: string(link.Hash), objType: obj.linkType(i)})
+		}
+	}
+	return nil
+}
+
+// writeArchiveField 写入uvarint长度和data。bufio.Writer在出错后忽略之后的写入，错误由Flush返回
+func writeArchiveField(w *bufio.Writer, data []byte) {
+	w.Write(binary.AppendUvarint(nil, uint64(len(data))))
+	w.Write(data)
+}
+
+// readArchiveField 读取writeArchiveField写入的一个字段，在字段开始处到达结尾时返回io.EOF，
+// 长度超过limit时返回errMalformedArchive。缓冲区随读到的内容增长，而不是按长度前缀一次分配，
+// 只有几个字节的损坏归档不会导致分配大量内存
+func readArchiveField(r *bufio.Reader, limit uint64) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, errMalformedArchive
+		}
+		return nil, err
+	}
+	if n > limit {
+		return nil, errMalformedArchive
+	}
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, r, int64(n)); err != nil {
+		if err == io.EOF {
+			return nil, errMalformedArchive
+		}
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,163 @@
+package merkledag
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"runtime"
+	"testing"
+)
+
+func archiveTree() Dir {
+	return NewDirBuilder().
+		AddFile("a.txt", bytes.Repeat([]byte("a"), 100)).
+		AddDir("sub", NewDirBuilder().AddFile("b.txt", bytes.Repeat([]byte("b"), 100)).Build()).
+		Build()
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+	src := NewDagService(NewMemStore())
+	root, err := src.Add(archiveTree())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := ExportArchive(src, root, &buf); err != nil {
+		t.Fatal(err)
+	}
+	dst := NewDagService(NewMemStore())
+	got, err := ImportArchive(dst, bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != root {
+		t.Fatalf("imported root %s, want %s", got, root)
+	}
+	if rep, err := VerifyStore(dst, []string{root}); err != nil || !rep.OK() {
+		t.Fatalf("verify: %v, %v", rep, err)
+	}
+}
+
+func TestImportArchiveTamperedBlock(t *testing.T) {
+	src := NewDagService(NewMemStore())
+	root, err := src.Add(archiveTree())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := ExportArchive(src, root, &buf); err != nil {
+		t.Fatal(err)
+	}
+	// 把b.txt的内容改为同样长度的其他内容，长度前缀仍然正确
+	tampered := bytes.Replace(buf.Bytes(), bytes.Repeat([]byte("b"), 100), bytes.Repeat([]byte("c"), 100), 1)
+	st := NewMemStore()
+	_, err = ImportArchive(NewDagService(st), bytes.NewReader(tampered))
+	var corrupt *ErrCorruptArchive
+	if !errors.As(err, &corrupt) {
+		t.Fatalf("got %v, want ErrCorruptArchive", err)
+	}
+	if keys, _ := st.Keys(); len(keys) != 0 {
+		t.Fatalf("%d keys written after a failed import", len(keys))
+	}
+}
+
+func TestImportArchiveMissingRoot(t *testing.T) {
+	src := NewDagService(NewMemStore())
+	root, err := src.Add(archiveTree())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	buf.WriteString(archiveMagic)
+	w := bufio.NewWriter(&buf)
+	writeArchiveField(w, []byte(root))
+	writeArchiveField(w, []byte(src.hashAlgorithm()))
+	w.Flush()
+	_, err = ImportArchive(NewDagService(NewMemStore()), &buf)
+	var corrupt *ErrCorruptArchive
+	if !errors.As(err, &corrupt) || corrupt.Key != root {
+		t.Fatalf("got %v, want ErrCorruptArchive for the root", err)
+	}
+}
+
+func TestImportArchiveHugeLengthPrefix(t *testing.T) {
+	s := NewDagService(NewMemStore())
+	// 只有长度前缀的字段声称有1GiB
+	header := append([]byte(archiveMagic), binary.AppendUvarint(nil, 1<<30)...)
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	_, err := ImportArchive(s, bytes.NewReader(header))
+	runtime.ReadMemStats(&after)
+	if !errors.Is(err, errMalformedArchive) {
+		t.Fatalf("got %v, want errMalformedArchive", err)
+	}
+	if alloc := after.TotalAlloc - before.TotalAlloc; alloc > 1<<20 {
+		t.Fatalf("allocated %d bytes for a truncated field", alloc)
+	}
+
+	// 超过WithMaxBlockSize的字段在读取前就被拒绝
+	limited := NewDagService(NewMemStore(), WithMaxBlockSize(4096))
+	header = append([]byte(archiveMagic), binary.AppendUvarint(nil, 8192)...)
+	header = append(header, make([]byte, 8192)...)
+	if _, err := ImportArchive(limited, bytes.NewReader(header)); !errors.Is(err, errMalformedArchive) {
+		t.Fatalf("got %v, want errMalformedArchive", err)
+	}
+}
+
+func TestArchiveRoundTripChunkedAndCommits(t *testing.T) {
+	src := NewDagService(NewMemStore(), WithInlineThreshold(4))
+	big := bytes.Repeat([]byte("b"), 400*K)
+	tree := NewDirBuilder().
+		AddFile("a", []byte("hello world")).
+		AddFile("t", []byte("x")).
+		AddDir("d", NewDirBuilder().AddFile("big", big).Build()).
+		Build()
+	root, err := src.Add(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, err := src.Commit(root, "", "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []string{root, commit} {
+		var buf bytes.Buffer
+		if err := ExportArchive(src, r, &buf); err != nil {
+			t.Fatal(err)
+		}
+		dst := NewDagService(NewMemStore())
+		got, err := ImportArchive(dst, bytes.NewReader(buf.Bytes()))
+		if err != nil || got != r {
+			t.Fatalf("imported %s, %v; want %s", got, err, r)
+		}
+		if ok, err := dst.HasComplete(r); !ok || err != nil {
+			t.Fatalf("HasComplete: %v, %v", ok, err)
+		}
+		if got := readPath(t, dst, root, "d/big"); !bytes.Equal(got, big) {
+			t.Fatalf("d/big: got %d bytes", len(got))
+		}
+		// 截断的归档不能导入
+		if _, err := ImportArchive(NewDagService(NewMemStore()), bytes.NewReader(buf.Bytes()[:buf.Len()-3])); err == nil {
+			t.Fatal("truncated archive imported")
+		}
+	}
+}
+
+func TestImportArchiveHashMismatch(t *testing.T) {
+	src := NewDagService(NewMemStore())
+	root, err := src.Add(archiveTree())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := ExportArchive(src, root, &buf); err != nil {
+		t.Fatal(err)
+	}
+	dst := NewDagService(NewMemStore(), WithHasher(sha512.New))
+	if _, err := ImportArchive(dst, &buf); !errors.Is(err, ErrHashAlgorithmMismatch) {
+		t.Fatalf("got %v, want ErrHashAlgorithmMismatch", err)
+	}
+}
@@ -0,0 +1,84 @@
+package merkledag
+
+import (
+	"sync"
+	"testing"
+)
+
+// batchingStore 是记录提交次数的BatchStore，Batch中的写入在Commit时才写入MemStore
+type batchingStore struct {
+	*countingStore
+	commits int
+}
+
+func (b *batchingStore) Begin() Batch {
+	return &memBatch{store: b, pending: make(map[string][]byte)}
+}
+
+type memBatch struct {
+	store   *batchingStore
+	mu      sync.Mutex
+	pending map[string][]byte
+}
+
+func (m *memBatch) Put(key string, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pending[key] = append([]byte(nil), value...)
+	return nil
+}
+
+func (m *memBatch) Commit() error {
+	m.store.commits++
+	for key, value := range m.pending {
+		if err := m.store.MemStore.Put(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestAddCommitsOnce(t *testing.T) {
+	st := &batchingStore{countingStore: newCountingStore()}
+	s := NewDagService(st)
+	root, err := s.Add(walkTree())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.commits != 1 || st.puts != 0 {
+		t.Fatalf("%d commits and %d direct puts, want 1 and 0", st.commits, st.puts)
+	}
+	n, err := s.Get(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, msg := sameTree(n, walkTree()); !ok {
+		t.Fatal(msg)
+	}
+}
+
+func BenchmarkAddBatched(b *testing.B) {
+	tree := flatDir(1000)
+	b.Run("batch", func(b *testing.B) {
+		var commits int
+		for i := 0; i < b.N; i++ {
+			st := &batchingStore{countingStore: newCountingStore()}
+			if _, err := NewDagService(st).Add(tree); err != nil {
+				b.Fatal(err)
+			}
+			commits += st.commits
+		}
+		b.ReportMetric(float64(commits)/float64(b.N), "commits/op")
+	})
+	b.Run("put", func(b *testing.B) {
+		var puts int
+		for i := 0; i < b.N; i++ {
+			st := newCountingStore()
+			if _, err := NewDagService(st).Add(tree); err != nil {
+				b.Fatal(err)
+			}
+			puts += st.puts
+		}
+		b.ReportMetric(float64(puts)/float64(b.N), "puts/op")
+	})
+}
@@ -0,0 +1,114 @@
+package merkledag
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// bloomPrefix 是根节点的Bloom过滤器的键值前缀，后面为根节点的键值。它不是数据块，
+// GC在根节点被删除时一起删除它
+const bloomPrefix = "bloom_"
+
+// bloomFilter 是一组键值的Bloom过滤器，bits的长度为m位，每个键值设置k位
+type bloomFilter struct {
+	k    uint64
+	m    uint64
+	bits []byte
+}
+
+// newBloomFilter 创建可以容纳n个键值、误判率不超过p的过滤器
+func newBloomFilter(n int, p float64) *bloomFilter {
+	if n < 1 {
+		n = 1
+	}
+	m := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	k := uint64(math.Max(1, math.Round(float64(m)/float64(n)*math.Ln2)))
+	return &bloomFilter{k: k, m: m, bits: make([]byte, (m+7)/8)}
+}
+
+// positions 返回key在过滤器中的k个位置，由键值的SHA-256得到的两个哈希组合而成
+func (f *bloomFilter) positions(key string) []uint64 {
+	sum := sha256.Sum256([]byte(key))
+	h1 := binary.LittleEndian.Uint64(sum[0:8])
+	h2 := binary.LittleEndian.Uint64(sum[8:16]) | 1
+	pos := make([]uint64, f.k)
+	for i := range pos {
+		pos[i] = (h1 + uint64(i)*h2) % f.m
+	}
+	return pos
+}
+
+func (f *bloomFilter) add(key string) {
+	for _, p := range f.positions(key) {
+		f.bits[p/8] |= 1 << (p % 8)
+	}
+}
+
+func (f *bloomFilter) has(key string) bool {
+	for _, p := range f.positions(key) {
+		if f.bits[p/8]&(1<<(p%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// encode 依次写入k、m和所有位
+func (f *bloomFilter) encode() []byte {
+	buf := binary.AppendUvarint(nil, f.k)
+	buf = binary.AppendUvarint(buf, f.m)
+	return append(buf, f.bits...)
+}
+
+func decodeBloomFilter(data []byte) (*bloomFilter, error) {
+	r := &blockReader{data: data}
+	k := r.uvarint()
+	m := r.uvarint()
+	if r.err != nil {
+		return nil, r.err
+	}
+	if k == 0 || m == 0 || uint64(len(r.data)) != (m+7)/8 {
+		return nil, errMalformedObject
+	}
+	return &bloomFilter{k: k, m: m, bits: r.data}, nil
+}
+
+// storeBloomFilter 为root可达的所有数据块的键值建立Bloom过滤器并保存
+func (s *DagService) storeBloomFilter(root string) error {
+	marked := make(map[string]bool)
+	if err := s.markReachable(root, marked); err != nil {
+		return err
+	}
+	f := newBloomFilter(len(marked), s.bloomFPRate)
+	for key := range marked {
+		f.add(key)
+	}
+	return s.store.Put(bloomPrefix+root, f.encode())
+}
+
+// MightContain 使用保存的Bloom过滤器判断键值为key的数据块是否可能属于root，不需要遍历DAG。
+// 返回false时key一定不属于root，返回true时有不超过WithBloomFilter指定的误判率的概率不属于root。
+// root没有保存过滤器时返回ErrNotFound
+func (s *DagService) MightContain(root string, key string) (bool, error) {
+	if _, err := rootType(root); err != nil {
+		return false, err
+	}
+	if err := s.checkOpen(); err != nil {
+		return false, err
+	}
+	data, err := s.store.Get(bloomPrefix + root)
+	if errors.Is(err, ErrNotFound) {
+		return false, fmt.Errorf("bloom filter of %s: %w", root, ErrNotFound)
+	}
+	if err != nil {
+		return false, err
+	}
+	f, err := decodeBloomFilter(data)
+	if err != nil {
+		return false, corruptBlock(bloomPrefix+root, err)
+	}
+	return f.has(key), nil
+}
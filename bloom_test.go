@@ -0,0 +1,55 @@
+package merkledag
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestBloomFilterMembership(t *testing.T) {
+	const fpRate = 0.01
+	st := NewMemStore()
+	s := NewDagService(st, WithBloomFilter(fpRate), WithPersistInternalNodes(true))
+	b := NewDirBuilder()
+	for i := 0; i < 300; i++ {
+		b.AddFile(fmt.Sprint("f", i), []byte(fmt.Sprint("content ", i)))
+	}
+	snap, err := s.AddSnapshot(b.Build(), "s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	members := make(map[string]bool)
+	if err := s.markReachable(snap, members); err != nil {
+		t.Fatal(err)
+	}
+	for key := range members {
+		if ok, err := s.MightContain(snap, key); !ok || err != nil {
+			t.Fatalf("member %s: %v, %v", key, ok, err)
+		}
+	}
+	const trials = 20000
+	falsePositives := 0
+	for i := 0; i < trials; i++ {
+		ok, err := s.MightContain(snap, fmt.Sprint("file_absent", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok {
+			falsePositives++
+		}
+	}
+	// 允许实际误判率达到配置的两倍
+	if rate := float64(falsePositives) / trials; rate > 2*fpRate {
+		t.Fatalf("false positive rate %.4f, configured %.2f", rate, fpRate)
+	}
+	if _, err := s.MightContain("dir_missing", "file_x"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+	// 快照没有被固定，GC同时删除其过滤器
+	if _, err := s.GC(); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := st.Has(bloomPrefix + snap); ok {
+		t.Fatal("Bloom filter survived GC of its snapshot")
+	}
+}
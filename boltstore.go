@@ -0,0 +1,110 @@
+package merkledag
+
+import bolt "go.etcd.io/bbolt"
+
+var boltBucket = []byte("blocks")
+
+// BoltStore 是基于BoltDB的KVStore，所有数据保存在同一个bucket中
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore 打开（不存在时创建）path处的BoltDB文件
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+func (b *BoltStore) Has(key string) (bool, error) {
+	var ok bool
+	err := b.db.View(func(tx *bolt.Tx) error {
+		ok = tx.Bucket(boltBucket).Get([]byte(key)) != nil
+		return nil
+	})
+	return ok, err
+}
+
+func (b *BoltStore) Put(key string, value []byte) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Put([]byte(key), value)
+	})
+}
+
+func (b *BoltStore) Get(key string) ([]byte, error) {
+	var value []byte
+	err := b.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(boltBucket).Get([]byte(key))
+		if v == nil {
+			return ErrNotFound
+		}
+		// v只在事务内有效，需要复制一份
+		value = append([]byte{}, v...)
+		return nil
+	})
+	return value, err
+}
+
+func (b *BoltStore) Delete(key string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Delete([]byte(key))
+	})
+}
+
+func (b *BoltStore) Keys() ([]string, error) {
+	var keys []string
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).ForEach(func(k, _ []byte) error {
+			keys = append(keys, string(k))
+			return nil
+		})
+	})
+	return keys, err
+}
+
+// Begin 返回一个在Commit时用一个事务写入所有数据的Batch
+func (b *BoltStore) Begin() Batch {
+	return &boltBatch{db: b.db}
+}
+
+// boltBatch 在内存中收集写入，Commit时一次写入BoltDB
+type boltBatch struct {
+	db     *bolt.DB
+	keys   []string
+	values [][]byte
+}
+
+func (b *boltBatch) Put(key string, value []byte) error {
+	b.keys = append(b.keys, key)
+	b.values = append(b.values, value)
+	return nil
+}
+
+func (b *boltBatch) Commit() error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucket)
+		for i, key := range b.keys {
+			if err := bucket.Put([]byte(key), b.values[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	b.keys, b.values = nil, nil
+	return err
+}
+
+// Close 关闭底层的BoltDB文件
+func (b *BoltStore) Close() error {
+	return b.db.Close()
+}
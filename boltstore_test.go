@@ -0,0 +1,38 @@
+package merkledag
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestBoltStoreDurability(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocks.db")
+	tree := NewDirBuilder().
+		AddFile("a", []byte("alpha")).
+		AddDir("sub", NewDirBuilder().AddFile("b", []byte("beta")).Build()).
+		Build()
+	store, err := NewBoltStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := NewDagService(store).Add(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := NewBoltStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	got, err := NewDagService(reopened).Get(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, diff := sameTree(tree, got); !ok {
+		t.Fatalf("tree differs after reopening: %s", diff)
+	}
+}
@@ -0,0 +1,53 @@
+package merkledag
+
+// NewFile 返回内容为data的File
+func NewFile(data []byte) File {
+	return &file{data: data}
+}
+
+// DirBuilder 用于构造传给Add的Dir
+type DirBuilder struct {
+	entries []entry
+	names   map[string]bool
+	err     error
+}
+
+// NewDirBuilder 返回一个空目录的DirBuilder
+func NewDirBuilder() *DirBuilder {
+	return &DirBuilder{names: make(map[string]bool)}
+}
+
+// AddFile 在目录中加入名为name、内容为data的文件
+func (b *DirBuilder) AddFile(name string, data []byte) *DirBuilder {
+	return b.add(name, NewFile(data))
+}
+
+// AddDir 在目录中加入名为name的子目录sub
+func (b *DirBuilder) AddDir(name string, sub Dir) *DirBuilder {
+	return b.add(name, sub)
+}
+
+// add 加入一个目录项。目录中已有同名的目录项时不加入，记录ErrDuplicateEntry，
+// Build返回的Dir在Add时返回这个错误
+func (b *DirBuilder) add(name string, node Node) *DirBuilder {
+	if b.names[name] {
+		if b.err == nil {
+			b.err = &ErrDuplicateEntry{Name: name}
+		}
+		return b
+	}
+	b.names[name] = true
+	b.entries = append(b.entries, entry{name: name, node: node})
+	return b
+}
+
+// Build 返回包含已加入的目录项的Dir，之后继续加入目录项不影响已返回的Dir。
+// 加入过重名的目录项时，Add这个Dir时返回ErrDuplicateEntry，ValidateNode返回ErrInvalidNode
+func (b *DirBuilder) Build() Dir {
+	return &dir{entries: append([]entry(nil), b.entries...), err: b.err}
+}
+
+// Err 返回加入目录项时发现的第一个错误，目前只有ErrDuplicateEntry
+func (b *DirBuilder) Err() error {
+	return b.err
+}
@@ -0,0 +1,89 @@
+package merkledag
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestBuilderTwoLevels(t *testing.T) {
+	s := NewDagService(NewMemStore())
+	tree := NewDirBuilder().
+		AddFile("a", []byte("a")).
+		AddDir("sub", NewDirBuilder().AddFile("x", []byte("xyz")).Build()).
+		Build()
+	root, err := s.Add(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := s.Resolve(root, "sub/x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := n.(File).Bytes(); string(got) != "xyz" {
+		t.Fatalf("sub/x = %q", got)
+	}
+	if tree.Size() != 4 {
+		t.Fatalf("size %d, want 4", tree.Size())
+	}
+}
+
+func TestBuilderDuplicateEntry(t *testing.T) {
+	b := NewDirBuilder().AddFile("a", []byte("1")).AddFile("a", []byte("2"))
+	var dup *ErrDuplicateEntry
+	if !errors.As(b.Err(), &dup) || dup.Name != "a" {
+		t.Fatalf("Err() = %v, want ErrDuplicateEntry for a", b.Err())
+	}
+	tree := b.Build()
+	for _, opts := range [][]Option{nil, {WithLastEntryWins(true)}} {
+		if _, err := NewDagService(NewMemStore(), opts...).Add(tree); !errors.As(err, &dup) {
+			t.Fatalf("Add: got %v, want ErrDuplicateEntry", err)
+		}
+	}
+	// 重复出现在子目录中时同样报告
+	nested := NewDirBuilder().AddDir("sub", b.Build()).Build()
+	if _, err := NewDagService(NewMemStore()).Add(nested); !errors.As(err, &dup) {
+		t.Fatalf("nested Add: got %v, want ErrDuplicateEntry", err)
+	}
+	var invalid *ErrInvalidNode
+	if err := ValidateNode(nested); !errors.As(err, &invalid) || invalid.Path != "/sub" {
+		t.Fatalf("ValidateNode: got %v", err)
+	}
+}
+
+// dirNames 遍历d的一个新迭代器，返回所有子节点的名字
+func dirNames(d Dir) []string {
+	var names []string
+	for it := d.It(); it.Next(); {
+		names = append(names, it.Name())
+	}
+	return names
+}
+
+func TestDirIteratesTwice(t *testing.T) {
+	built := NewDirBuilder().AddFile("a", []byte("1")).AddFile("b", []byte("2")).Build()
+	s := NewDagService(NewMemStore())
+	root, err := s.Add(built)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := s.Get(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []Dir{built, stored.(Dir)} {
+		// 遍历到末尾后再次调用It得到从头开始的新迭代器
+		first, second := dirNames(d), dirNames(d)
+		if strings.Join(first, " ") != "a b" || strings.Join(second, " ") != "a b" {
+			t.Fatalf("first %q, second %q", first, second)
+		}
+	}
+	// Add遍历过的Dir仍然可以再次Add和读取
+	again, err := s.Add(built)
+	if err != nil || again != root {
+		t.Fatalf("re-adding gives %s, %v; want %s", again, err, root)
+	}
+	if again, err := s.Add(stored); err != nil || again != root {
+		t.Fatalf("adding the stored dir gives %s, %v; want %s", again, err, root)
+	}
+}
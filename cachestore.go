@@ -0,0 +1,132 @@
+package merkledag
+
+import (
+	"container/list"
+	"sync"
+)
+
+// CacheOption 用于配置CachingStore
+type CacheOption func(*CachingStore)
+
+// WithCacheMetrics 指定命中缓存时调用的Metrics，只报告IncCacheHit，
+// 读写的计数由DagService报告
+func WithCacheMetrics(m Metrics) CacheOption {
+	return func(c *CachingStore) {
+		c.metrics = m
+	}
+}
+
+// CachingStore 在KVStore之上缓存最近读取的数据块，超过容量时淘汰最久未使用的。
+// 可以被多个goroutine同时使用
+type CachingStore struct {
+	store    KVStore
+	capacity int
+	metrics  Metrics
+
+	mu    sync.Mutex
+	lru   *list.List
+	items map[string]*list.Element
+}
+
+// cacheEntry 是LRU链表中的一项
+type cacheEntry struct {
+	key   string
+	value []byte
+}
+
+// NewCachingStore 创建一个最多缓存capacity个数据块的CachingStore
+func NewCachingStore(store KVStore, capacity int, opts ...CacheOption) *CachingStore {
+	c := &CachingStore{
+		store:    store,
+		capacity: capacity,
+		metrics:  NopMetrics{},
+		lru:      list.New(),
+		items:    make(map[string]*list.Element),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *CachingStore) Has(key string) (bool, error) {
+	c.mu.Lock()
+	_, ok := c.items[key]
+	c.mu.Unlock()
+	if ok {
+		return true, nil
+	}
+	return c.store.Has(key)
+}
+
+func (c *CachingStore) Put(key string, value []byte) error {
+	if err := c.store.Put(key, value); err != nil {
+		return err
+	}
+	c.add(key, value)
+	return nil
+}
+
+func (c *CachingStore) Get(key string) ([]byte, error) {
+	c.mu.Lock()
+	if elem, ok := c.items[key]; ok {
+		c.lru.MoveToFront(elem)
+		value := elem.Value.(*cacheEntry).value
+		c.mu.Unlock()
+		c.metrics.IncCacheHit()
+		return append([]byte(nil), value...), nil
+	}
+	c.mu.Unlock()
+
+	value, err := c.store.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	c.add(key, value)
+	return value, nil
+}
+
+func (c *CachingStore) Delete(key string) error {
+	c.mu.Lock()
+	if elem, ok := c.items[key]; ok {
+		c.lru.Remove(elem)
+		delete(c.items, key)
+	}
+	c.mu.Unlock()
+	return c.store.Delete(key)
+}
+
+// Close 关闭底层KVStore，底层KVStore没有实现io.Closer时直接返回nil
+func (c *CachingStore) Close() error {
+	return closeStores(c.store)
+}
+
+// Keys 列出底层KVStore中的所有键值，底层KVStore没有实现Enumerate时返回ErrNotEnumerable
+func (c *CachingStore) Keys() ([]string, error) {
+	lister, ok := c.store.(Enumerate)
+	if !ok {
+		return nil, ErrNotEnumerable
+	}
+	return lister.Keys()
+}
+
+// add 将value的副本加入缓存，并淘汰超出容量的数据块
+func (c *CachingStore) add(key string, value []byte) {
+	if c.capacity <= 0 {
+		return
+	}
+	value = append([]byte(nil), value...)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		elem.Value.(*cacheEntry).value = value
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.items[key] = c.lru.PushFront(&cacheEntry{key: key, value: value})
+	for c.lru.Len() > c.capacity {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).key)
+	}
+}
@@ -0,0 +1,91 @@
+package merkledag
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestCachingStoreSecondTraversal(t *testing.T) {
+	backing := newCountingStore()
+	root, err := NewDagService(backing).Add(walkTree())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewDagService(NewCachingStore(backing, 1000))
+	backing.reset()
+	if _, err := s.Get(root); err != nil {
+		t.Fatal(err)
+	}
+	first := backing.blockGets()
+	backing.reset()
+	n, err := s.Get(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := backing.blockGets(); got != 0 || first == 0 {
+		t.Fatalf("first traversal read %d blocks, second %d", first, got)
+	}
+	if ok, msg := sameTree(n, walkTree()); !ok {
+		t.Fatal(msg)
+	}
+}
+
+func TestCachingStoreEviction(t *testing.T) {
+	backing := newCountingStore()
+	c := NewCachingStore(backing, 2)
+	for i := 0; i < 3; i++ {
+		if err := c.Put(fmt.Sprint("k", i), []byte{byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	backing.reset()
+	for _, key := range []string{"k1", "k2", "k0"} {
+		if _, err := c.Get(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// k0最早写入，已被淘汰
+	if backing.gets["k0"] != 1 || backing.gets["k1"] != 0 || backing.gets["k2"] != 0 {
+		t.Fatalf("backing gets %v", backing.gets)
+	}
+	if err := c.Delete("k0"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get("k0"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v after Delete, want ErrNotFound", err)
+	}
+	// 修改Get返回的值不影响缓存
+	v, err := c.Get("k2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v[0] = 99
+	if v, _ := c.Get("k2"); v[0] != 2 {
+		t.Fatal("cached value was modified through a returned slice")
+	}
+}
+
+func TestCachingStoreConcurrent(t *testing.T) {
+	c := NewCachingStore(NewMemStore(), 16)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				key := fmt.Sprint("k", (g*7+i)%40)
+				if err := c.Put(key, []byte(key)); err != nil {
+					t.Error(err)
+					return
+				}
+				if v, err := c.Get(key); err != nil || string(v) != key {
+					t.Errorf("Get(%s) = %q, %v", key, v, err)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,108 @@
+package merkledag
+
+import (
+	"bytes"
+	"io"
+)
+
+// Cat 返回root下path对应文件的内容。分块文件的数据块在读取时才从KVStore中取出，
+// 不会一次性加载整个文件
+func (s *DagService) Cat(root string, path string) (io.ReadCloser, error) {
+	key, objType, err := s.resolveKey(root, path)
+	if err != nil {
+		return nil, err
+	}
+	return s.openFile(key, objType)
+}
+
+// GetFileBytes 返回键值为key的文件的全部内容，分块保存的文件按顺序拼接所有块。
+// key不是文件时返回ErrNotAFile
+func (s *DagService) GetFileBytes(key string) ([]byte, error) {
+	objType, err := rootType(key)
+	if err != nil {
+		return nil, err
+	}
+	r, err := s.openFile(key, objType)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// openFile 返回键值为key的文件的内容
+func (s *DagService) openFile(key string, objType string) (io.ReadCloser, error) {
+	if objType != BLOB && objType != LIST {
+		return nil, ErrNotAFile
+	}
+	data, err := s.getBlock(key)
+	if err != nil {
+		return nil, err
+	}
+	switch objType {
+	case BLOB:
+		return io.NopCloser(bytes.NewReader(data)), nil
+	default:
+		obj, err := s.decodeBlock(key, data)
+		if err != nil {
+			return nil, err
+		}
+		return &listReader{s: s, stack: []*listCursor{{obj: obj}}}, nil
+	}
+}
+
+// listCursor 记录在一个LIST中读取到的位置
+type listCursor struct {
+	obj  *Object
+	next int
+}
+
+// listReader 按顺序读取LIST链接的数据块，嵌套的LIST用栈保存。
+// fetch不为nil时用它代替getBlock读取数据块
+type listReader struct {
+	s     *DagService
+	stack []*listCursor
+	buf   []byte
+	fetch func(key string) ([]byte, error)
+}
+
+func (r *listReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if len(r.stack) == 0 {
+			return 0, io.EOF
+		}
+		top := r.stack[len(r.stack)-1]
+		if top.next >= len(top.obj.Links) {
+			r.stack = r.stack[:len(r.stack)-1]
+			continue
+		}
+		i := top.next
+		top.next++
+		fetch := r.fetch
+		if fetch == nil {
+			fetch = r.s.getBlock
+		}
+		data, err := fetch(string(top.obj.Links[i].Hash))
+		if err != nil {
+			return 0, err
+		}
+		if top.obj.linkType(i) == LIST {
+			obj, err := r.s.decodeBlock(string(top.obj.Links[i].Hash), data)
+			if err != nil {
+				return 0, err
+			}
+			r.stack = append(r.stack, &listCursor{obj: obj})
+			continue
+		}
+		r.buf = data
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func (r *listReader) Close() error {
+	r.stack = nil
+	r.buf = nil
+	return nil
+}
@@ -0,0 +1,84 @@
+package merkledag
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+func TestCatRandomData(t *testing.T) {
+	data := make([]byte, 5<<20+17)
+	rand.New(rand.NewSource(1)).Read(data)
+	st := newCountingStore()
+	s := NewDagService(st, WithChunkSize(64*K))
+	tree := NewDirBuilder().
+		AddFile("small.txt", []byte("small")).
+		AddDir("sub", NewDirBuilder().add("big", NewChunkedFile(bytes.NewReader(data), int64(len(data)))).Build()).
+		Build()
+	root, err := s.Add(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.reset()
+	r, err := s.Cat(root, "sub/big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 打开时只读取路径上的目录和LIST，数据块在读取时才取出
+	if st.blockGets() > 3 {
+		t.Fatalf("Cat read %d blocks before any Read", st.blockGets())
+	}
+	got, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("got %d bytes, %v", len(got), err)
+	}
+	if got := readPath(t, s, root, "small.txt"); string(got) != "small" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestCatNotAFile(t *testing.T) {
+	s := NewDagService(NewMemStore())
+	root, err := s.Add(NewDirBuilder().AddDir("sub", NewDirBuilder().Build()).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Cat(root, "sub"); !errors.Is(err, ErrNotAFile) {
+		t.Fatalf("got %v, want ErrNotAFile", err)
+	}
+	if _, err := s.Cat(root, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+}
+
+func TestGetFileBytes(t *testing.T) {
+	s := NewDagService(NewMemStore(), WithChunkSize(100))
+	chunked := bytes.Repeat([]byte("xyz"), 1000)
+	chunkedKey, err := s.Add(NewChunkedFile(bytes.NewReader(chunked), int64(len(chunked))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ, _ := rootType(chunkedKey); typ != LIST {
+		t.Fatalf("chunked file stored as %v", typ)
+	}
+	plainKey, err := s.Add(NewFile([]byte("plain")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.GetFileBytes(chunkedKey); err != nil || !bytes.Equal(got, chunked) {
+		t.Fatalf("chunked: got %d bytes, %v", len(got), err)
+	}
+	if got, err := s.GetFileBytes(plainKey); err != nil || string(got) != "plain" {
+		t.Fatalf("plain: got %q, %v", got, err)
+	}
+	dirKey, err := s.Add(NewDirBuilder().AddFile("a", []byte("a")).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetFileBytes(dirKey); !errors.Is(err, ErrNotAFile) {
+		t.Fatalf("directory: got %v, want ErrNotAFile", err)
+	}
+}
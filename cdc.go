@@ -0,0 +1,153 @@
+package merkledag
+
+import (
+	"bufio"
+	"io"
+	"math/bits"
+)
+
+// ChunkerStrategy 决定ChunkedFile的切分点。无论使用哪种切分方式，保存的格式都相同：
+// 一个按顺序链接所有块的LIST，读取时不需要知道切分方式
+type ChunkerStrategy interface {
+	// NewSplitter 返回从r中依次读取块的Splitter，每块不超过maxSize字节
+	NewSplitter(r io.Reader, maxSize int) Splitter
+}
+
+// Splitter 依次返回文件的块，读完时返回io.EOF
+type Splitter interface {
+	Next() ([]byte, error)
+}
+
+// FixedSizeChunker 每块maxSize字节，只有最后一块可能更小。是默认的切分方式
+type FixedSizeChunker struct{}
+
+func (FixedSizeChunker) NewSplitter(r io.Reader, maxSize int) Splitter {
+	return &fixedSplitter{r: r, size: maxSize}
+}
+
+type fixedSplitter struct {
+	r    io.Reader
+	size int
+}
+
+func (s *fixedSplitter) Next() ([]byte, error) {
+	chunk := make([]byte, s.size)
+	n, err := io.ReadFull(s.r, chunk)
+	if n > 0 {
+		return chunk[:n], nil
+	}
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return nil, err
+}
+
+// buzhashWindow 是滚动哈希的窗口大小
+const buzhashWindow = 32
+
+// buzhashTable 是buzhash中每个字节对应的随机数，由固定的种子生成，保证切分点在不同进程中相同
+var buzhashTable = func() (table [256]uint32) {
+	// splitmix64
+	x := uint64(0x6d65726b6c656461)
+	for i := range table {
+		x += 0x9e3779b97f4a7c15
+		z := x
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = uint32(z ^ (z >> 31))
+	}
+	return table
+}()
+
+// ContentDefinedChunker 用buzhash滚动哈希根据内容选择切分点：最近buzhashWindow个字节的哈希
+// 满足条件时切分，因此在文件中插入或删除数据只会改变附近的块，其余的块仍然可以去重。
+// 块的大小在MinSize和maxSize之间，平均约为AvgSize。字段为0时MinSize为maxSize/8，
+// AvgSize为maxSize/4。
+// Anchored为true时滚动哈希在块之间不重置，切分点只取决于其前面buzhashWindow个字节，不受MinSize限制，
+// 因此不同文件中相同的内容无论从哪个偏移开始，在第一个切分点之后都得到相同的块
+type ContentDefinedChunker struct {
+	MinSize  int
+	AvgSize  int
+	Anchored bool
+}
+
+func (c ContentDefinedChunker) NewSplitter(r io.Reader, maxSize int) Splitter {
+	minSize, avgSize := c.MinSize, c.AvgSize
+	if minSize <= 0 {
+		minSize = maxSize / 8
+	}
+	if avgSize <= 0 {
+		avgSize = maxSize / 4
+	}
+	// 哈希的低位全为0时切分，平均每2^bits个字节切分一次
+	mask := uint32(1)<<max(bits.Len(uint(avgSize))-1, 0) - 1
+	return &cdcSplitter{r: bufio.NewReader(r), min: max(minSize, 1), max: max(maxSize, 1), mask: mask, anchored: c.Anchored}
+}
+
+type cdcSplitter struct {
+	r    *bufio.Reader
+	min  int
+	max  int
+	mask uint32
+
+	// anchored为true时window保存最近的buzhashWindow个字节，hash为它们的滚动哈希，n为已读取的字节数
+	anchored bool
+	window   [buzhashWindow]byte
+	hash     uint32
+	n        int
+}
+
+// roll 将b加入跨越块的滚动哈希，返回新的哈希
+func (s *cdcSplitter) roll(b byte) uint32 {
+	i := s.n % buzhashWindow
+	s.hash = bits.RotateLeft32(s.hash, 1) ^ buzhashTable[b]
+	if s.n >= buzhashWindow {
+		s.hash ^= bits.RotateLeft32(buzhashTable[s.window[i]], buzhashWindow)
+	}
+	s.window[i] = b
+	s.n++
+	return s.hash
+}
+
+func (s *cdcSplitter) Next() ([]byte, error) {
+	var chunk []byte
+	var h uint32
+	for len(chunk) < s.max {
+		b, err := s.r.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		chunk = append(chunk, b)
+		if s.anchored {
+			if s.roll(b)&s.mask == 0 {
+				break
+			}
+			continue
+		}
+		h = bits.RotateLeft32(h, 1) ^ buzhashTable[b]
+		if len(chunk) > buzhashWindow {
+			// 移出窗口的字节已经被旋转了buzhashWindow次
+			h ^= bits.RotateLeft32(buzhashTable[chunk[len(chunk)-1-buzhashWindow]], buzhashWindow)
+		}
+		if len(chunk) >= s.min && h&s.mask == 0 {
+			break
+		}
+	}
+	if len(chunk) == 0 {
+		return nil, io.EOF
+	}
+	return chunk, nil
+}
+
+// splitter 返回ChunkedFile使用的切分方式，设置了WithChunkAnchoring时切分点锚定在内容上
+func (s *DagService) splitter() ChunkerStrategy {
+	if !s.chunkAnchoring {
+		return s.chunker
+	}
+	c, _ := s.chunker.(ContentDefinedChunker)
+	c.Anchored = true
+	return c
+}
@@ -0,0 +1,105 @@
+package merkledag
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+// sharedChunks 返回在data前插入一个字节后，新文件的数据块中与原文件相同的比例
+func sharedChunks(t *testing.T, data []byte, opts ...Option) float64 {
+	t.Helper()
+	s := NewDagService(NewMemStore(), append([]Option{WithChunkSize(16 * K)}, opts...)...)
+	edited := append([]byte{42}, data...)
+	var chunks [2][]Link
+	for i, content := range [][]byte{data, edited} {
+		key, err := s.Add(NewChunkedFile(bytes.NewReader(content), int64(len(content))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := s.GetFileBytes(key); err != nil || !bytes.Equal(got, content) {
+			t.Fatalf("read back %d bytes, %v", len(got), err)
+		}
+		obj, err := s.readObject(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunks[i] = obj.Links
+	}
+	before := make(map[string]bool, len(chunks[0]))
+	for _, link := range chunks[0] {
+		before[string(link.Hash)] = true
+	}
+	same := 0
+	for _, link := range chunks[1] {
+		if before[string(link.Hash)] {
+			same++
+		}
+	}
+	return float64(same) / float64(len(chunks[1]))
+}
+
+func TestContentDefinedChunking(t *testing.T) {
+	data := make([]byte, 1<<20)
+	rand.New(rand.NewSource(1)).Read(data)
+	if f := sharedChunks(t, data); f > 0.1 {
+		t.Errorf("fixed-size chunking kept %.2f of chunks, want nearly none", f)
+	}
+	if f := sharedChunks(t, data, WithChunker(ContentDefinedChunker{})); f < 0.9 {
+		t.Errorf("content-defined chunking kept %.2f of chunks, want most", f)
+	}
+}
+
+// sharedMiddle 返回两个文件中相同的数据块的总大小占共同的中间部分mid的比例
+func sharedMiddle(t *testing.T, a, b, mid []byte, opts ...Option) float64 {
+	t.Helper()
+	s := NewDagService(NewMemStore(), append([]Option{WithChunkSize(64 * K)}, opts...)...)
+	var chunks [2][]Link
+	for i, content := range [][]byte{a, b} {
+		key, err := s.Add(NewChunkedFile(bytes.NewReader(content), int64(len(content))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := s.GetFileBytes(key); err != nil || !bytes.Equal(got, content) {
+			t.Fatalf("read back %d bytes, %v", len(got), err)
+		}
+		obj, err := s.readObject(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunks[i] = obj.Links
+	}
+	inFirst := make(map[string]bool, len(chunks[0]))
+	for _, link := range chunks[0] {
+		inFirst[string(link.Hash)] = true
+	}
+	var shared int64
+	for _, link := range chunks[1] {
+		if inFirst[string(link.Hash)] {
+			shared += link.Size
+		}
+	}
+	return float64(shared) / float64(len(mid))
+}
+
+func TestChunkAnchoring(t *testing.T) {
+	rnd := rand.New(rand.NewSource(7))
+	random := func(n int) []byte {
+		b := make([]byte, n)
+		rnd.Read(b)
+		return b
+	}
+	// 两个文件共享1MiB的中间部分，但起始偏移不同
+	mid := random(1 << 20)
+	a := append(append(random(12345), mid...), random(5000)...)
+	b := append(append(random(777), mid...), random(9000)...)
+	if f := sharedMiddle(t, a, b, mid); f > 0.1 {
+		t.Errorf("fixed-size chunking shared %.2f of the middle", f)
+	}
+	if f := sharedMiddle(t, a, b, mid, WithChunkAnchoring(true)); f < 0.9 {
+		t.Errorf("anchored chunking shared %.2f of the middle", f)
+	}
+	if f := sharedMiddle(t, a, b, mid, WithChunker(ContentDefinedChunker{AvgSize: 8 * K}), WithChunkAnchoring(true)); f < 0.9 {
+		t.Errorf("anchored chunking with 8K chunks shared %.2f of the middle", f)
+	}
+}
@@ -0,0 +1,148 @@
+package merkledag
+
+import (
+	"encoding/hex"
+	"hash"
+	"io"
+)
+
+// ChunkedFile 是从io.Reader中读取内容的文件。Add时按DagService的块大小
+// 边读边切分，每块作为单独的数据块保存，不会把整个文件读入内存
+type ChunkedFile struct {
+	r    io.Reader
+	size int64
+	meta *Metadata
+}
+
+// NewChunkedFile 创建一个从r读取的ChunkedFile，size为文件的大小
+func NewChunkedFile(r io.Reader, size int64) *ChunkedFile {
+	return &ChunkedFile{r: r, size: size}
+}
+
+func (f *ChunkedFile) Size() int64 {
+	return f.size
+}
+
+func (f *ChunkedFile) Type() int {
+	return FILE
+}
+
+// SetMetadata 设置文件的元数据，元数据保存在LIST中
+func (f *ChunkedFile) SetMetadata(meta *Metadata) {
+	f.meta = meta
+}
+
+func (f *ChunkedFile) Metadata() *Metadata {
+	return f.meta
+}
+
+// blockChunkSize 返回切块时每块的大小，设置了最大块大小时不超过它
+func (s *DagService) blockChunkSize() int {
+	if s.maxBlockSize > 0 && s.maxBlockSize < s.chunkSize {
+		return s.maxBlockSize
+	}
+	return s.chunkSize
+}
+
+// putChunkedFile 将f切块保存，再保存链接所有块的LIST，返回LIST的键值和Merkle Root。
+// 空文件按NewFile([]byte{})保存
+func (s *adder) putChunkedFile(f *ChunkedFile, path string) (string, string, error) {
+	obj := &Object{Meta: f.meta}
+	var hashes []string
+	// content 在设置了WithContentNormalization时计算完整内容的哈希
+	var content hash.Hash
+	if s.contentNormalization {
+		content = s.hasher()
+	}
+	splitter := s.splitter().NewSplitter(f.r, s.blockChunkSize())
+	for {
+		if err := s.ctx.Err(); err != nil {
+			return "", "", err
+		}
+		chunk, err := splitter.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", "", err
+		}
+		key, hash, err := s.putNode(&file{data: chunk}, chunk, []string{s.hashBytes(chunk)})
+		if err != nil {
+			return "", "", err
+		}
+		if content != nil {
+			content.Write(chunk)
+		}
+		s.progress.add(path, int64(len(chunk)), 0)
+		obj.Links = append(obj.Links, Link{Hash: []byte(key), Size: int64(len(chunk))})
+		obj.Data = append(obj.Data, BLOB...)
+		hashes = append(hashes, hash)
+	}
+	if len(obj.Links) == 0 {
+		// 空文件的规范形式是内容为空的BLOB（带元数据时为链接它的LIST），与NewFile([]byte{})相同，
+		// 而不是没有链接的LIST
+		return s.putFile(&file{data: []byte{}, meta: f.meta}, path)
+	}
+	data, err := s.serializer.Marshal(obj)
+	if err != nil {
+		return "", "", err
+	}
+	s.progress.add(path, 0, 1)
+	var contentHash string
+	if content != nil {
+		contentHash = hex.EncodeToString(content.Sum(nil))
+	}
+	leaves, err := s.listLeaves(obj, data, hashes, contentHash)
+	if err != nil {
+		return "", "", err
+	}
+	return s.putEncoded(f, data, leaves)
+}
+
+// listLeaves 返回编码结果为data的LIST obj的叶子哈希，chunks为其链接的各块的Merkle Root。
+// 默认为chunks加上data的哈希；设置了WithContentNormalization时由完整内容的哈希content计算，
+// 没有元数据时只有content一个叶子，与整体保存的文件相同，有元数据时与putFileWithMetadata相同
+func (s *DagService) listLeaves(obj *Object, data []byte, chunks []string, content string) ([]string, error) {
+	if !s.contentNormalization {
+		return append(chunks, s.hashBytes(data)), nil
+	}
+	if obj.Meta == nil {
+		return []string{content}, nil
+	}
+	root, err := s.calculateMerkleRoot([]string{content})
+	if err != nil {
+		return nil, err
+	}
+	return []string{root, s.hashBytes(data)}, nil
+}
+
+// normalizedListRoot 返回设置了WithContentNormalization时编码结果为data的LIST obj的Merkle Root
+func (s *DagService) normalizedListRoot(obj *Object, data []byte, fetch func(key string) ([]byte, error)) (string, error) {
+	root, err := s.listContentRoot(obj, fetch)
+	if err != nil || obj.Meta == nil {
+		return root, err
+	}
+	return s.calculateMerkleRoot([]string{root, s.hashBytes(data)})
+}
+
+// listContentRoot 返回LIST obj的内容整体保存时的Merkle Root。
+// 只链接一个BLOB时即为该BLOB的Merkle Root，否则用fetch读取全部内容计算
+func (s *DagService) listContentRoot(obj *Object, fetch func(key string) ([]byte, error)) (string, error) {
+	if len(obj.Links) == 1 && obj.linkType(0) == BLOB {
+		return s.keyHash(string(obj.Links[0].Hash)), nil
+	}
+	content, err := s.listContentHash(obj, fetch)
+	if err != nil {
+		return "", err
+	}
+	return s.calculateMerkleRoot([]string{content})
+}
+
+// listContentHash 返回LIST obj链接的完整内容的哈希，fetch读取其中的数据块，嵌套的LIST同样展开
+func (s *DagService) listContentHash(obj *Object, fetch func(key string) ([]byte, error)) (string, error) {
+	h := s.hasher()
+	if _, err := io.Copy(h, &listReader{s: s, stack: []*listCursor{{obj: obj}}, fetch: fetch}); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
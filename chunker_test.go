@@ -0,0 +1,212 @@
+package merkledag
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// peakStore 只保存小的数据块，每次Put时记录GC后仍在使用的堆内存的峰值
+type peakStore struct {
+	mu   sync.Mutex
+	kv   map[string][]byte
+	peak uint64
+}
+
+func (p *peakStore) Has(key string) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, ok := p.kv[key]
+	return ok, nil
+}
+
+func (p *peakStore) Put(key string, value []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(value) < 1024 {
+		p.kv[key] = append([]byte(nil), value...)
+	} else {
+		p.kv[key] = nil
+	}
+	var m runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&m)
+	if m.HeapAlloc > p.peak {
+		p.peak = m.HeapAlloc
+	}
+	return nil
+}
+
+func (p *peakStore) Get(key string) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if v, ok := p.kv[key]; ok && v != nil {
+		return v, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (p *peakStore) Delete(key string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.kv, key)
+	return nil
+}
+
+func TestChunkedFileStreams(t *testing.T) {
+	const size = 50 << 20
+	st := &peakStore{kv: make(map[string][]byte)}
+	s := NewDagService(st)
+	f := NewChunkedFile(io.LimitReader(rand.New(rand.NewSource(1)), size), size)
+	var m runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&m)
+	if _, err := s.Add(f); err != nil {
+		t.Fatal(err)
+	}
+	// 除了正在处理的块，只有块的哈希列表等少量数据
+	if grown := int64(st.peak) - int64(m.HeapAlloc); grown > 4*BLOCK_SIZE {
+		t.Fatalf("heap grew by %d bytes while adding a %d byte file", grown, size)
+	}
+}
+
+func TestChunkedFileRoundTrip(t *testing.T) {
+	data := chunkedSource(10007)
+	s := NewDagService(NewMemStore(), WithChunkSize(1000))
+	root, err := s.Add(NewChunkedFile(bytes.NewReader(data), int64(len(data))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.GetFileBytes(root); err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("got %d bytes, %v", len(got), err)
+	}
+}
+
+func TestEmptyFileCanonical(t *testing.T) {
+	for _, opts := range [][]Option{
+		nil,
+		{WithChunking(true), WithMaxBlockSize(256)},
+		{WithChunker(ContentDefinedChunker{})},
+		{WithTypePrefix(true)},
+	} {
+		s := NewDagService(NewMemStore(), opts...)
+		plain, err := s.Add(NewFile([]byte{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunked, err := s.Add(NewChunkedFile(bytes.NewReader(nil), 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if plain != chunked {
+			t.Fatalf("plain %s, chunked %s", plain, chunked)
+		}
+		if typ, _ := rootType(chunked); typ != BLOB {
+			t.Fatalf("empty file stored as %s", typ)
+		}
+		r, err := s.Cat(chunked, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n, err := r.Read(make([]byte, 4)); n != 0 || err != io.EOF {
+			t.Fatalf("Read = %d, %v; want 0, EOF", n, err)
+		}
+		if data, err := s.GetFileBytes(chunked); err != nil || len(data) != 0 {
+			t.Fatalf("GetFileBytes: %d bytes, %v", len(data), err)
+		}
+		// 带元数据时两种方式同样一致
+		meta := &Metadata{Mode: 0600}
+		withMeta, err := s.Add(&file{data: []byte{}, meta: meta})
+		if err != nil {
+			t.Fatal(err)
+		}
+		cf := NewChunkedFile(bytes.NewReader(nil), 0)
+		cf.SetMetadata(meta)
+		if key, err := s.Add(cf); err != nil || key != withMeta {
+			t.Fatalf("chunked with metadata %s, %v; want %s", key, err, withMeta)
+		}
+	}
+}
+
+func TestContentNormalization(t *testing.T) {
+	data := make([]byte, 5000)
+	for i := range data {
+		data[i] = byte(i * 7 % 251)
+	}
+	chunkedFile := func() *ChunkedFile {
+		return NewChunkedFile(bytes.NewReader(data), int64(len(data)))
+	}
+	mem := NewMemStore()
+	s := NewDagService(mem, WithContentNormalization(true), WithChunkSize(512))
+	whole, err := s.Add(NewFile(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunked, err := s.Add(chunkedFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if objType, _ := rootType(chunked); objType != LIST {
+		t.Fatalf("chunked file stored as %s", chunked)
+	}
+	if s.keyHash(whole) != s.keyHash(chunked) {
+		t.Fatalf("whole %s and chunked %s have different content identities", whole, chunked)
+	}
+	if FileHash(NewFile(data)) != s.keyHash(chunked) {
+		t.Fatal("FileHash differs from the normalized chunked root")
+	}
+
+	// 切块方式不影响键值
+	cdc := NewDagService(mem, WithContentNormalization(true), WithChunkSize(512),
+		WithChunker(ContentDefinedChunker{MinSize: 64, AvgSize: 256}))
+	if key, err := cdc.Add(chunkedFile()); err != nil || key != chunked {
+		t.Fatalf("content-defined chunks gave %s, %v; want %s", key, err, chunked)
+	}
+	auto := NewDagService(NewMemStore(), WithContentNormalization(true), WithChunking(true), WithMaxBlockSize(1000))
+	if key, err := auto.Add(NewFile(data)); err != nil || key != chunked {
+		t.Fatalf("automatic chunking gave %s, %v; want %s", key, err, chunked)
+	}
+	// 默认不规范化
+	plain, err := NewDagService(NewMemStore(), WithChunkSize(512)).Add(chunkedFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain == chunked {
+		t.Fatal("chunked root normalized without the option")
+	}
+
+	root, err := s.Add(NewDirBuilder().
+		AddFile("whole", data).
+		add("chunked", chunkedFile()).
+		add("meta", &file{data: data[:10], meta: &Metadata{Mode: 0o600}}).
+		Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report, err := VerifyStore(s, []string{root}); err != nil || !report.OK() {
+		t.Fatalf("normalized tree does not verify: %v", err)
+	}
+	verified := NewDagService(mem, WithContentNormalization(true), WithVerifyOnGet(true))
+	if got, err := verified.GetFileBytes(chunked); err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("reading the chunked file back: %v", err)
+	}
+	// 损坏一个数据块后校验失败
+	obj, err := s.readObject(chunked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunk := string(obj.Links[3].Hash)
+	orig, err := mem.Get(chunk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mem.Put(chunk, append([]byte("x"), orig[1:]...)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verified.GetFileBytes(chunked); err == nil {
+		t.Fatal("corrupt chunk passed verification")
+	}
+}
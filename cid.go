@@ -0,0 +1,74 @@
+package merkledag
+
+import (
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+)
+
+// CID格式的键值中使用的编码。BLOB和TREE使用IPFS中对应的raw和dag-pb，
+// LIST和符号链接在IPFS中没有单独的编码，使用私有范围内的编码以便区分
+const (
+	cidVersion      = 1
+	codecRaw        = 0x55
+	codecDagPB      = 0x70
+	codecList       = 0x300000
+	codecLink       = 0x300001
+	codecShard      = 0x300002
+	codecSnapshot   = 0x300003
+	codecMerkle     = 0x300004
+	codecCommit     = 0x300005
+	multihashSHA256 = 0x12
+)
+
+// cidEncoding 是CIDv1默认使用的小写、无填充的base32，编码后的字符串以'b'开头
+var cidEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+var cidCodecs = map[string]uint64{
+	BLOB:     codecRaw,
+	TREE:     codecDagPB,
+	LIST:     codecList,
+	LINK:     codecLink,
+	SHARD:    codecShard,
+	SNAPSHOT: codecSnapshot,
+	MERKLE:   codecMerkle,
+	COMMIT:   codecCommit,
+}
+
+// cidKey 返回类型为objType、Merkle Root为merkleRoot的数据块的CIDv1格式的键值：
+// 版本、编码和multihash（算法码、摘要长度、摘要）依次以uvarint写入，再用base32编码
+func cidKey(objType string, merkleRoot string) string {
+	digest, _ := hex.DecodeString(merkleRoot)
+	buf := binary.AppendUvarint(nil, cidVersion)
+	buf = binary.AppendUvarint(buf, cidCodecs[objType])
+	buf = binary.AppendUvarint(buf, multihashSHA256)
+	buf = binary.AppendUvarint(buf, uint64(len(digest)))
+	buf = append(buf, digest...)
+	return "b" + cidEncoding.EncodeToString(buf)
+}
+
+// parseCID 解析CID格式的键值，返回其类型标记和摘要。key不是CID时ok为false
+func parseCID(key string) (objType string, digest []byte, ok bool) {
+	if !strings.HasPrefix(key, "b") || strings.Contains(key, "_") {
+		return "", nil, false
+	}
+	data, err := cidEncoding.DecodeString(key[1:])
+	if err != nil {
+		return "", nil, false
+	}
+	r := &blockReader{data: data}
+	version := r.uvarint()
+	codec := r.uvarint()
+	_ = r.uvarint() // multihash的算法码，摘要由DagService的hasher计算
+	digest = r.bytes()
+	if r.err != nil || len(r.data) != 0 || version != cidVersion {
+		return "", nil, false
+	}
+	for t, c := range cidCodecs {
+		if c == codec {
+			return t, digest, true
+		}
+	}
+	return "", nil, false
+}
@@ -0,0 +1,71 @@
+package merkledag
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+func TestCIDKeys(t *testing.T) {
+	tree := NewDirBuilder().
+		AddFile("a.txt", []byte("alpha")).
+		AddDir("sub", NewDirBuilder().AddFile("b.txt", []byte("beta")).Build()).
+		Build()
+	st := NewMemStore()
+	s := NewDagService(st, WithCIDKeys(true))
+	root, err := s.Add(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(root, "b") || strings.Contains(root, "_") {
+		t.Fatalf("root %s is not a base32 CID", root)
+	}
+	raw, err := cidEncoding.DecodeString(root[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 版本、编码、multihash的算法码和摘要长度依次以uvarint写入
+	var fields []uint64
+	for i := 0; i < 4; i++ {
+		v, n := binary.Uvarint(raw)
+		if n <= 0 {
+			t.Fatalf("bad uvarint in %x", raw)
+		}
+		fields = append(fields, v)
+		raw = raw[n:]
+	}
+	if fields[0] != cidVersion || fields[1] != codecDagPB || fields[2] != multihashSHA256 || fields[3] != 32 {
+		t.Fatalf("version, codec, hash code, length = %v", fields)
+	}
+	if len(raw) != 32 {
+		t.Fatalf("digest has %d bytes, want 32", len(raw))
+	}
+	// 文件的摘要与默认键值格式中的Merkle Root相同；目录的块中包含子节点的键值，摘要不同
+	fileKey, err := s.Add(NewFile([]byte("alpha")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := NewDagService(NewMemStore()).Add(NewFile([]byte("alpha")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.keyHash(fileKey) != strings.TrimPrefix(plain, "file_") {
+		t.Fatalf("digest %s, plain key %s", s.keyHash(fileKey), plain)
+	}
+	n, err := s.Get(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, msg := sameTree(n, tree); !ok {
+		t.Fatal(msg)
+	}
+	keys, err := st.Keys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range keys {
+		if key != headerKey && strings.Contains(key, "_") {
+			t.Fatalf("key %s is not a CID", key)
+		}
+	}
+}
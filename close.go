@@ -0,0 +1,41 @@
+package merkledag
+
+import (
+	"errors"
+	"io"
+)
+
+// Close 关闭DagService：KVStore实现了Flusher时先写出缓存的数据，实现了io.Closer时再关闭它。
+// 关闭后的读写都返回ErrClosed，再次调用Close同样返回ErrClosed
+func (s *DagService) Close() error {
+	if !s.closed.CompareAndSwap(false, true) {
+		return ErrClosed
+	}
+	var flushErr, closeErr error
+	if f, ok := s.store.(Flusher); ok {
+		flushErr = f.Flush()
+	}
+	if c, ok := s.store.(io.Closer); ok {
+		closeErr = c.Close()
+	}
+	return errors.Join(flushErr, closeErr)
+}
+
+// checkOpen 在DagService已经关闭时返回ErrClosed
+func (s *DagService) checkOpen() error {
+	if s.closed.Load() {
+		return ErrClosed
+	}
+	return nil
+}
+
+// closeStores 依次关闭stores中实现了io.Closer的KVStore，返回所有错误
+func closeStores(stores ...KVStore) error {
+	var errs []error
+	for _, store := range stores {
+		if c, ok := store.(io.Closer); ok {
+			errs = append(errs, c.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,79 @@
+package merkledag
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// slowStore 的每次写入都有延迟，使异步写出在Add返回时仍未完成
+type slowStore struct {
+	*MemStore
+}
+
+func (s slowStore) Put(key string, value []byte) error {
+	time.Sleep(2 * time.Millisecond)
+	return s.MemStore.Put(key, value)
+}
+
+// closingStore 记录Close被调用的次数
+type closingStore struct {
+	*MemStore
+	closed int
+}
+
+func (c *closingStore) Close() error {
+	c.closed++
+	return nil
+}
+
+func TestCloseFlushesAsyncWrites(t *testing.T) {
+	slow := slowStore{NewMemStore()}
+	fast := &closingStore{MemStore: NewMemStore()}
+	s := NewDagService(NewCachingStore(NewTieredStore(fast, slow, WithAsyncFlush()), 10))
+	b := NewDirBuilder()
+	for i := 0; i < 30; i++ {
+		b.AddFile(fmt.Sprint(i), []byte(fmt.Sprint("content ", i)))
+	}
+	root, err := s.Add(b.Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if fast.closed != 1 {
+		t.Fatalf("fast tier closed %d times, want 1", fast.closed)
+	}
+	if ok, err := NewDagService(slow).HasComplete(root); !ok || err != nil {
+		t.Fatalf("slow tier after Close: %v, %v", ok, err)
+	}
+	if _, err := s.Add(NewFile([]byte("x"))); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Add after Close: got %v, want ErrClosed", err)
+	}
+	if _, err := s.Get(root); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Get after Close: got %v, want ErrClosed", err)
+	}
+	if err := s.Close(); !errors.Is(err, ErrClosed) {
+		t.Fatalf("second Close: got %v, want ErrClosed", err)
+	}
+}
+
+func TestCopyTreeAfterClose(t *testing.T) {
+	src := NewDagService(NewMemStore())
+	root, err := src.Add(NewDirBuilder().AddFile("a", []byte("a")).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := NewDagService(NewMemStore())
+	if err := closed.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CopyTree(closed, src, root); !errors.Is(err, ErrClosed) {
+		t.Fatalf("CopyTree to a closed service: got %v, want ErrClosed", err)
+	}
+	if _, err := CopyTree(src, closed, root); !errors.Is(err, ErrClosed) {
+		t.Fatalf("CopyTree from a closed service: got %v, want ErrClosed", err)
+	}
+}
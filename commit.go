@@ -0,0 +1,149 @@
+package merkledag
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// CommitInfo 是一个提交的内容。提交记录一个目录树的根节点和上一个提交，
+// 依次链接起来构成同一份数据的历史
+type CommitInfo struct {
+	// Key 为提交的键值
+	Key string
+	// Tree 为提交时的目录树的根节点
+	Tree string
+	// Parent 为上一个提交的键值，第一个提交为空字符串
+	Parent  string
+	Message string
+	Created time.Time
+}
+
+// Commit 保存一个指向treeRoot、上一个提交为parent的提交，返回提交的键值。
+// 第一个提交的parent为空字符串。提交的Merkle Root由treeRoot、parent的Merkle Root和提交本身的哈希计算，
+// 因此键值确定了整条历史
+func (s *DagService) Commit(treeRoot string, parent string, msg string) (string, error) {
+	if _, err := rootType(treeRoot); err != nil {
+		return "", err
+	}
+	exists, err := s.store.Has(treeRoot)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", &ErrBlockNotFound{Key: treeRoot}
+	}
+	if parent != "" {
+		if _, err := s.ReadCommit(parent); err != nil {
+			return "", err
+		}
+	}
+	data := encodeCommit(&CommitInfo{Tree: treeRoot, Parent: parent, Message: msg, Created: time.Now()})
+	hashes := []string{s.keyHash(treeRoot)}
+	if parent != "" {
+		hashes = append(hashes, s.keyHash(parent))
+	}
+	key, _, err := s.run(context.Background(), false, func(a *adder) (string, error) {
+		merkleRoot, err := a.treeRoot(append(hashes, s.hashBytes(data)))
+		if err != nil {
+			return "", err
+		}
+		key := s.formatKey(COMMIT, merkleRoot)
+		return key, a.putBlock(key, data)
+	})
+	return key, err
+}
+
+// ReadCommit 读取键值为key的提交
+func (s *DagService) ReadCommit(key string) (*CommitInfo, error) {
+	objType, err := rootType(key)
+	if err != nil {
+		return nil, err
+	}
+	if objType != COMMIT {
+		return nil, fmt.Errorf("%s is not a commit: %w", key, ErrUnsupportedNodeType)
+	}
+	data, err := s.getBlock(key)
+	if err != nil {
+		return nil, err
+	}
+	c, err := decodeCommit(data)
+	if err != nil {
+		return nil, corruptBlock(key, err)
+	}
+	c.Key = key
+	return c, nil
+}
+
+// History 从commitRoot出发沿上一个提交向前读取，返回整条历史，最新的提交在前。
+// 更早的提交已经被Compact删除时，历史到最早保留的提交为止
+func (s *DagService) History(commitRoot string) ([]CommitInfo, error) {
+	var history []CommitInfo
+	for key := commitRoot; key != ""; {
+		c, err := s.ReadCommit(key)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, *c)
+		shallow, err := s.isShallow(key)
+		if err != nil {
+			return nil, err
+		}
+		if shallow {
+			break
+		}
+		key = c.Parent
+	}
+	return history, nil
+}
+
+// encodeCommit 将提交编码为数据块：目录树、上一个提交、说明和创建时间依次写入，
+// 字符串前都写入其长度
+func encodeCommit(c *CommitInfo) []byte {
+	buf := binary.AppendUvarint(nil, uint64(len(c.Tree)))
+	buf = append(buf, c.Tree...)
+	buf = binary.AppendUvarint(buf, uint64(len(c.Parent)))
+	buf = append(buf, c.Parent...)
+	buf = binary.AppendUvarint(buf, uint64(len(c.Message)))
+	buf = append(buf, c.Message...)
+	buf = binary.AppendVarint(buf, c.Created.UnixNano())
+	return buf
+}
+
+func decodeCommit(data []byte) (*CommitInfo, error) {
+	r := &blockReader{data: data}
+	tree := r.bytes()
+	parent := r.bytes()
+	msg := r.bytes()
+	created := r.varint()
+	if r.err != nil {
+		return nil, r.err
+	}
+	if len(r.data) != 0 {
+		return nil, errMalformedObject
+	}
+	return &CommitInfo{
+		Tree:    string(tree),
+		Parent:  string(parent),
+		Message: string(msg),
+		Created: time.Unix(0, created),
+	}, nil
+}
+
+// commitLinks 返回链接提交的目录树和上一个提交的Object
+func commitLinks(c *CommitInfo) (*Object, error) {
+	obj := &Object{}
+	for _, key := range []string{c.Tree, c.Parent} {
+		if key == "" {
+			continue
+		}
+		objType, err := rootType(key)
+		if err != nil {
+			return nil, err
+		}
+		obj.Links = append(obj.Links, Link{Hash: []byte(key)})
+		obj.Data = append(obj.Data, objType...)
+	}
+	return obj, nil
+}
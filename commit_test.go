@@ -0,0 +1,60 @@
+package merkledag
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestHistoryNewestFirst(t *testing.T) {
+	for _, s := range []*DagService{NewDagService(NewMemStore()), NewDagService(NewMemStore(), WithCIDKeys(true))} {
+		var parent string
+		var commits []string
+		for i := 0; i < 3; i++ {
+			tree, err := s.Add(NewDirBuilder().AddFile("v", []byte{byte(i)}).Build())
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, err := s.Commit(tree, parent, fmt.Sprint("commit ", i))
+			if err != nil {
+				t.Fatal(err)
+			}
+			commits = append(commits, c)
+			parent = c
+		}
+		history, err := s.History(parent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(history) != 3 {
+			t.Fatalf("got %d commits, want 3", len(history))
+		}
+		for i, c := range history {
+			want := len(commits) - 1 - i
+			if c.Key != commits[want] || c.Message != fmt.Sprint("commit ", want) {
+				t.Fatalf("history[%d] = %s %q, want %s", i, c.Key, c.Message, commits[want])
+			}
+		}
+		if history[2].Parent != "" {
+			t.Fatalf("first commit has parent %s", history[2].Parent)
+		}
+		// 提交和其中的树都是可达的
+		if ok, err := s.HasComplete(parent); !ok || err != nil {
+			t.Fatalf("HasComplete: %v, %v", ok, err)
+		}
+	}
+}
+
+func TestCommitMissingTree(t *testing.T) {
+	s := NewDagService(NewMemStore())
+	tree, err := s.Add(NewDirBuilder().AddFile("v", []byte("v")).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Commit("dir_00", "", "missing"); err == nil {
+		t.Fatal("commit of a missing tree accepted")
+	}
+	// parent必须是一个提交
+	if _, err := s.Commit(tree, tree, "bad parent"); err == nil {
+		t.Fatal("directory accepted as parent commit")
+	}
+}
@@ -0,0 +1,56 @@
+package merkledag
+
+import (
+	"errors"
+	"fmt"
+)
+
+// shallowPrefix 是截断标记的键值前缀。shallowPrefix+key存在时，提交key之前的提交已经被Compact删除，
+// 遍历和GC不再沿它的上一个提交向前。截断标记不是数据块，GC只在提交本身被删除时删除它
+const shallowPrefix = "shallow_"
+
+// isShallow 判断提交key之前的历史是否已经被截断
+func (s *DagService) isShallow(key string) (bool, error) {
+	if err := s.checkOpen(); err != nil {
+		return false, err
+	}
+	return s.store.Has(shallowPrefix + key)
+}
+
+// Compact 对service中的每条提交历史只保留最近的keepLast个提交，然后运行GC，返回删除的数据块数量。
+// 每条历史的最新提交被固定，更早的提交被取消固定，最早保留的提交之前的历史被截断，
+// 只被删除的提交引用的数据块随之删除，与保留的提交共享的数据块不受影响。KVStore需要实现Enumerate
+func Compact(service *DagService, keepLast int) (removed int, err error) {
+	if keepLast < 1 {
+		return 0, fmt.Errorf("compact: keepLast must be at least 1, got %d", keepLast)
+	}
+	roots, err := FindRoots(service)
+	if err != nil {
+		return 0, err
+	}
+	for _, root := range roots {
+		if objType, _ := rootType(root); objType != COMMIT {
+			continue
+		}
+		history, err := service.History(root)
+		if err != nil {
+			return 0, err
+		}
+		if err := service.Pin(root); err != nil {
+			return 0, err
+		}
+		if len(history) <= keepLast {
+			continue
+		}
+		// 先写入截断标记再取消固定，中途失败时GC不会删除保留的提交
+		if err := service.store.Put(shallowPrefix+history[keepLast-1].Key, nil); err != nil {
+			return 0, err
+		}
+		for _, c := range history[keepLast:] {
+			if err := service.Unpin(c.Key); err != nil && !errors.Is(err, ErrNotFound) {
+				return 0, err
+			}
+		}
+	}
+	return service.GC()
+}
@@ -0,0 +1,107 @@
+package merkledag
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestCompact(t *testing.T) {
+	mem := NewMemStore()
+	s := NewDagService(mem)
+	shared := bytes.Repeat([]byte("shared"), 100)
+	var commits, trees []string
+	parent := ""
+	for i := 0; i < 5; i++ {
+		tree, err := s.Add(NewDirBuilder().AddFile("shared", shared).AddFile("v", []byte(fmt.Sprint("version", i))).Build())
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := s.Commit(tree, parent, fmt.Sprint("c", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Pin(c); err != nil {
+			t.Fatal(err)
+		}
+		commits, trees, parent = append(commits, c), append(trees, tree), c
+	}
+	unrelated, err := s.Add(NewFile([]byte("pinned elsewhere")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Pin(unrelated); err != nil {
+		t.Fatal(err)
+	}
+
+	// 三个最早的提交独有的数据块被删除，与保留的两个提交共享的数据块保留
+	unique, kept := make(map[string]bool), make(map[string]bool)
+	for i, c := range commits {
+		reachable := make(map[string]bool)
+		if err := s.markReachable(trees[i], reachable); err != nil {
+			t.Fatal(err)
+		}
+		for key := range reachable {
+			if i < 3 {
+				unique[key] = true
+			} else {
+				kept[key] = true
+			}
+		}
+		if i < 3 {
+			unique[c] = true
+		}
+	}
+	for key := range kept {
+		delete(unique, key)
+	}
+	removed, err := Compact(s, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != len(unique) || removed != 3*3 {
+		t.Fatalf("removed %d blocks, want %d", removed, len(unique))
+	}
+	for key := range unique {
+		if ok, _ := mem.Has(key); ok {
+			t.Fatalf("block %s of an old commit kept", key)
+		}
+	}
+	for key := range kept {
+		if ok, _ := mem.Has(key); !ok {
+			t.Fatalf("block %s of a retained commit removed", key)
+		}
+	}
+	if ok, _ := mem.Has(unrelated); !ok {
+		t.Fatal("unrelated pinned root removed")
+	}
+	history, err := s.History(commits[4])
+	if err != nil || len(history) != 2 || history[1].Key != commits[3] {
+		t.Fatalf("history after Compact: %v, %v", history, err)
+	}
+	if report, err := VerifyStore(s, []string{commits[4]}); err != nil || !report.OK() {
+		t.Fatalf("retained history does not verify: %v", err)
+	}
+
+	// 再次压缩不删除任何数据块；继续提交后最早的保留提交被删除
+	if removed, err := Compact(s, 2); removed != 0 || err != nil {
+		t.Fatalf("second Compact removed %d, %v", removed, err)
+	}
+	tree, err := s.Add(NewDirBuilder().AddFile("shared", shared).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c5, err := s.Commit(tree, commits[4], "c5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed, err := Compact(s, 2); err != nil || removed != 3 {
+		t.Fatalf("Compact after a new commit removed %d, %v", removed, err)
+	}
+	if history, err := s.History(c5); err != nil || len(history) != 2 {
+		t.Fatalf("history after new commit: %v, %v", history, err)
+	}
+	if _, err := Compact(s, 0); err == nil {
+		t.Fatal("Compact accepted keepLast 0")
+	}
+}
@@ -0,0 +1,91 @@
+package merkledag
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// CompressOption 用于配置CompressingStore
+type CompressOption func(*CompressingStore)
+
+// WithCompressionLevel 指定gzip的压缩级别，默认为gzip.DefaultCompression
+func WithCompressionLevel(level int) CompressOption {
+	return func(c *CompressingStore) {
+		c.level = level
+	}
+}
+
+// CompressingStore 在写入底层KVStore前用gzip压缩数据块，读取时解压。
+// 键值仍由未压缩的内容决定，压缩只影响存储
+type CompressingStore struct {
+	store KVStore
+	level int
+}
+
+// NewCompressingStore 创建一个压缩写入store的CompressingStore。
+// 压缩级别不合法时返回错误
+func NewCompressingStore(store KVStore, opts ...CompressOption) (*CompressingStore, error) {
+	c := &CompressingStore{store: store, level: gzip.DefaultCompression}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if _, err := gzip.NewWriterLevel(io.Discard, c.level); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *CompressingStore) Has(key string) (bool, error) {
+	return c.store.Has(key)
+}
+
+func (c *CompressingStore) Put(key string, value []byte) error {
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, c.level)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(value); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.store.Put(key, buf.Bytes())
+}
+
+func (c *CompressingStore) Get(key string) ([]byte, error) {
+	data, err := c.store.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decompress %s: %w", key, err)
+	}
+	value, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("decompress %s: %w", key, err)
+	}
+	return value, nil
+}
+
+func (c *CompressingStore) Delete(key string) error {
+	return c.store.Delete(key)
+}
+
+// Close 关闭底层KVStore，底层KVStore没有实现io.Closer时直接返回nil
+func (c *CompressingStore) Close() error {
+	return closeStores(c.store)
+}
+
+// Keys 列出底层KVStore中的所有键值，底层KVStore没有实现Enumerate时返回ErrNotEnumerable
+func (c *CompressingStore) Keys() ([]string, error) {
+	lister, ok := c.store.(Enumerate)
+	if !ok {
+		return nil, ErrNotEnumerable
+	}
+	return lister.Keys()
+}
@@ -0,0 +1,47 @@
+package merkledag
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+)
+
+func TestCompressingStoreSmallerAndTransparent(t *testing.T) {
+	data := bytes.Repeat([]byte("compressible "), 10000)
+	plain := NewMemStore()
+	backing := NewMemStore()
+	c, err := NewCompressingStore(backing, WithCompressionLevel(gzip.BestCompression))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewDagService(c)
+	root, err := s.Add(NewDirBuilder().AddFile("text", data).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 压缩不影响键值
+	plainRoot, err := NewDagService(plain).Add(NewDirBuilder().AddFile("text", data).Build())
+	if err != nil || plainRoot != root {
+		t.Fatalf("compressed root %s, plain root %s, %v", root, plainRoot, err)
+	}
+	fileKey, err := NewDagService(NewMemStore()).Add(NewFile(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := backing.Get(fileKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) >= len(data)/10 {
+		t.Fatalf("stored %d bytes for %d bytes of content", len(stored), len(data))
+	}
+	if got := readPath(t, s, root, "text"); !bytes.Equal(got, data) {
+		t.Fatal("content differs after decompression")
+	}
+}
+
+func TestCompressingStoreBadLevel(t *testing.T) {
+	if _, err := NewCompressingStore(NewMemStore(), WithCompressionLevel(42)); err == nil {
+		t.Fatal("invalid level accepted")
+	}
+}
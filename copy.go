@@ -0,0 +1,152 @@
+package merkledag
+
+import (
+	"context"
+	"sync"
+)
+
+// copyFrame 是CopyTree中子节点尚未复制完的数据块
+type copyFrame struct {
+	key      string
+	data     []byte
+	children []blockRef
+	next     int
+	// fetched 为并发读取的一组子节点的结果，子节点入栈时使用
+	fetched map[string]copyFetch
+}
+
+// copyFetch 是CopyTree读取一个子节点的结果，exists为true时dst中已经有这个数据块
+type copyFetch struct {
+	data   []byte
+	exists bool
+}
+
+// CopyTree 将src中root可达的数据块复制到dst中，返回复制的数据块数量。
+// 子节点总是先于父节点写入，因此dst中已经存在的数据块视为其子树完整，整个子树都被跳过；
+// 被多处引用的子树只复制一次。同一数据块的子节点按src的WithTraversalConcurrency分组并发读取。
+// src或dst已经关闭时返回ErrClosed
+func CopyTree(dst, src *DagService, root string) (int, error) {
+	if err := src.checkOpen(); err != nil {
+		return 0, err
+	}
+	if err := dst.checkOpen(); err != nil {
+		return 0, err
+	}
+	objType, err := rootType(root)
+	if err != nil {
+		return 0, err
+	}
+	visited := make(map[string]bool)
+	copied := 0
+	// fetch 检查dst中是否已经有key，没有时从src读取
+	fetch := func(key string) (copyFetch, error) {
+		exists, err := dst.store.Has(key)
+		if err != nil || exists {
+			return copyFetch{exists: exists}, err
+		}
+		data, err := src.getBlock(key)
+		return copyFetch{data: data}, err
+	}
+	// push 根据ref的读取结果创建栈帧，dst中已经存在时返回nil
+	push := func(ref blockRef, f copyFetch) (*copyFrame, error) {
+		if f.exists {
+			return nil, nil
+		}
+		frame := &copyFrame{key: ref.key, data: f.data}
+		obj, err := src.blockLinks(ref, f.data)
+		if err != nil || obj == nil {
+			return frame, err
+		}
+		for i, link := range obj.Links {
+			frame.children = append(frame.children, blockRef{key: string(link.Hash), objType: obj.linkType(i)})
+		}
+		return frame, nil
+	}
+	// prefetch 从parent的第from个子节点开始，并发读取之后最多WithTraversalConcurrency个尚未访问的子节点，
+	// 每层最多保留这么多数据块
+	prefetch := func(parent *copyFrame, from int) error {
+		parent.fetched = make(map[string]copyFetch)
+		var keys []string
+		seen := make(map[string]bool)
+		for _, child := range parent.children[from:] {
+			if len(keys) >= max(src.traversalConcurrency, 1) {
+				break
+			}
+			if _, ok := inlineData(child.key); ok || visited[child.key] || seen[child.key] {
+				continue
+			}
+			seen[child.key] = true
+			keys = append(keys, child.key)
+		}
+		var mu sync.Mutex
+		return src.fetchEach(context.Background(), keys, func(key string) error {
+			f, err := fetch(key)
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			parent.fetched[key] = f
+			return nil
+		})
+	}
+	// load 标记parent的第index个子节点ref已访问，读取并入栈。内嵌文件随父目录一起复制
+	load := func(parent *copyFrame, index int, ref blockRef) (*copyFrame, error) {
+		if _, ok := inlineData(ref.key); ok {
+			visited[ref.key] = true
+			return nil, nil
+		}
+		var f copyFetch
+		if parent == nil {
+			var err error
+			if f, err = fetch(ref.key); err != nil {
+				return nil, err
+			}
+		} else {
+			var ok bool
+			if f, ok = parent.fetched[ref.key]; !ok {
+				// ref还没有被访问，总是在这一组中
+				if err := prefetch(parent, index); err != nil {
+					return nil, err
+				}
+				f = parent.fetched[ref.key]
+			}
+			delete(parent.fetched, ref.key)
+		}
+		visited[ref.key] = true
+		return push(ref, f)
+	}
+
+	frame, err := load(nil, 0, blockRef{key: root, objType: objType})
+	if err != nil || frame == nil {
+		return 0, err
+	}
+	stack := []*copyFrame{frame}
+	for len(stack) > 0 {
+		top := stack[len(stack)-1]
+		if top.next < len(top.children) {
+			child := top.children[top.next]
+			top.next++
+			if visited[child.key] {
+				continue
+			}
+			frame, err := load(top, top.next-1, child)
+			if err != nil {
+				return copied, err
+			}
+			if frame != nil {
+				stack = append(stack, frame)
+			}
+			continue
+		}
+		if err := dst.ensureHeader(nil); err != nil {
+			return copied, err
+		}
+		if err := dst.store.Put(top.key, dst.encodeBlock(top.key, top.data)); err != nil {
+			return copied, err
+		}
+		copied++
+		stack = stack[:len(stack)-1]
+	}
+	return copied, nil
+}
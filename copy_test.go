@@ -0,0 +1,40 @@
+package merkledag
+
+import "testing"
+
+func TestCopyTree(t *testing.T) {
+	src := NewDagService(NewMemStore())
+	shared := NewDirBuilder().AddFile("x", []byte("12")).AddFile("y", []byte("345")).Build()
+	tree := NewDirBuilder().AddDir("a", shared).AddDir("b", shared).AddFile("z", []byte("z")).Build()
+	root, err := src.Add(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := NewDagService(NewMemStore())
+	// 根目录、共享的子目录只复制一次、子目录中的两个文件和z
+	copied, err := CopyTree(dst, src, root)
+	if err != nil || copied != 5 {
+		t.Fatalf("copied %d, %v; want 5", copied, err)
+	}
+	n, err := dst.Get(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, msg := sameTree(n, tree); !ok {
+		t.Fatal(msg)
+	}
+	if copied, err := CopyTree(dst, src, root); copied != 0 || err != nil {
+		t.Fatalf("second copy transferred %d, %v", copied, err)
+	}
+	// 只复制新的根目录和新文件
+	next, err := src.Add(NewDirBuilder().AddDir("a", shared).AddFile("new", []byte("new")).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if copied, err := CopyTree(dst, src, next); copied != 2 || err != nil {
+		t.Fatalf("copied %d, %v; want 2", copied, err)
+	}
+	if ok, err := dst.HasComplete(next); !ok || err != nil {
+		t.Fatalf("HasComplete: %v, %v", ok, err)
+	}
+}
@@ -1,100 +1,1020 @@
 package merkledag
 
 import (
-	"hash"
-	"crypto/sha256"
+	"bytes"
+	"context"
+	"encoding/binary"
 	"encoding/hex"
 	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
 )
 
-// Add 将Node中的数据保存在KVStore中，并返回计算出的Merkle Root
+const (
+	K          = 1 << 10
+	BLOCK_SIZE = 256 * K
+
+	TREE = "tree"
+	BLOB = "blob"
+	LIST = "list"
+	LINK = "link"
+	// SHARD 是分片目录中指向子分片的链接的类型标记
+	SHARD = "hamt"
+	// SNAPSHOT 是快照清单的类型标记
+	SNAPSHOT = "snap"
+	// MERKLE 是保存的Merkle树内部节点的类型标记
+	MERKLE = "mrkl"
+	// COMMIT 是提交的类型标记
+	COMMIT = "cmmt"
+)
+
+type Link struct {
+	Name string
+	Hash []byte
+	Size int64
+}
+
+type Object struct {
+	Links []Link
+	Data  []byte
+	// Meta 为TREE或LIST所表示的目录或文件的元数据，可以为nil
+	Meta *Metadata
+}
+
+// linkType 返回第i个链接的类型标记
+func (obj *Object) linkType(i int) string {
+	return string(obj.Data[i*STEP : (i+1)*STEP])
+}
+
+// Add 将Node中的数据保存在KVStore中，并返回根节点的键值（类型前缀+Merkle Root）
 func Add(kvstore KVStore, node Node) (string, error) {
-	// 1. 将Node中的数据保存在KVStore中
-	data, err := serialize(node)
+	return NewDagService(kvstore).Add(node)
+}
+
+// Add 将Node中的数据保存在KVStore中，并返回根节点的键值（类型前缀+Merkle Root）
+func (s *DagService) Add(node Node) (string, error) {
+	return s.AddContext(context.Background(), node)
+}
+
+// AddContext 与Add相同，但在处理每个节点前检查ctx，ctx取消后立即返回ctx.Err()
+func (s *DagService) AddContext(ctx context.Context, node Node) (string, error) {
+	key, _, err := s.add(ctx, node, false)
+	return key, err
+}
+
+// AddWithKeys 与Add相同，同时返回本次调用中实际写入KVStore的数据块的键值，
+// 已经存在的数据块不包括在内。键值没有重复，顺序不确定
+func (s *DagService) AddWithKeys(node Node) (string, []string, error) {
+	return s.add(context.Background(), node, true)
+}
+
+// ComputeRoot 计算node保存后的键值，与Add返回的相同，但不向KVStore写入任何数据块。
+// 超过最大块大小时同样返回ErrBlockTooLarge，但不检查WithMaxTotalSize
+func (s *DagService) ComputeRoot(node Node) (string, error) {
+	key, _, err := s.run(context.Background(), false, func(a *adder) (string, error) {
+		a.dryRun = true
+		key, _, err := a.put(node)
+		return key, err
+	})
+	return key, err
+}
+
+// RootOf 计算node使用opts配置时的键值，与保存到任意KVStore中时Add返回的相同，但不需要KVStore。
+// 用于验证在别处计算出的Merkle Root
+func RootOf(node Node, opts ...Option) (string, error) {
+	return NewDagService(emptyStore{}, opts...).ComputeRoot(node)
+}
+
+// FileHash 返回单个文件f使用opts配置时的Merkle Root，即Add返回的键值中去掉类型前缀的部分，以十六进制表示。
+// 没有元数据且不超过WithMaxBlockSize的文件直接由内容的哈希计算，不经过Add；其他文件与RootOf相同。
+// Add会失败时返回空字符串
+func FileHash(f File, opts ...Option) string {
+	s := NewDagService(emptyStore{}, opts...)
+	if metadataOf(f) == nil {
+		if data := f.Bytes(); s.maxBlockSize <= 0 || len(data) <= s.maxBlockSize {
+			root, err := s.calculateMerkleRoot([]string{s.hashBytes(data)})
+			if err != nil {
+				return ""
+			}
+			return root
+		}
+	}
+	key, err := s.ComputeRoot(f)
 	if err != nil {
-		return "", err
+		return ""
 	}
+	return s.keyHash(key)
+}
 
-	key := generateKey(node)
-	err = kvstore.Put(key, data)
+// AddedSize 计算保存node需要写入的字节数，但不写入任何数据块。totalBytes为node的所有数据块的总字节数，
+// newBytes为其中KVStore中还没有的数据块的总字节数，相同的数据块只计算一次
+func (s *DagService) AddedSize(node Node) (newBytes int64, totalBytes int64, err error) {
+	var a *adder
+	_, _, err = s.run(context.Background(), false, func(run *adder) (string, error) {
+		a = run
+		a.dryRun = true
+		a.measure = true
+		key, _, err := a.put(node)
+		return key, err
+	})
 	if err != nil {
-		return "", err
+		return 0, 0, err
+	}
+	return a.added, a.total, nil
+}
+
+// add 保存node，record为true时记录新写入的数据块的键值
+func (s *DagService) add(ctx context.Context, node Node, record bool) (string, []string, error) {
+	if s.validate {
+		if err := ValidateNode(node); err != nil {
+			return "", nil, err
+		}
 	}
+	return s.run(ctx, record, func(a *adder) (string, error) {
+		key, _, err := a.put(node)
+		return key, err
+	})
+}
 
-	// 2. 计算Merkle Root
-	merkleRoot, err := calculateMerkleRoot(kvstore, node)
+// run 创建一次写入的adder并执行fn，fn成功后提交batch，返回fn得到的键值
+func (s *DagService) run(ctx context.Context, record bool, fn func(a *adder) (string, error)) (string, []string, error) {
+	if err := s.checkOpen(); err != nil {
+		return "", nil, err
+	}
+	a := &adder{DagService: s, ctx: ctx, seen: make(map[string]bool), record: record}
+	a.sha256 = s.hashAlgorithm() == "sha256"
+	if s.concurrency > 1 {
+		a.group, a.ctx = errgroup.WithContext(ctx)
+		a.group.SetLimit(s.concurrency)
+	}
+	if bs, ok := s.store.(BatchStore); ok {
+		a.batch = bs.Begin()
+	} else if s.wal {
+		// 日志的记录按序号保存，同一时间只能有一个事务
+		s.walMu.Lock()
+		defer s.walMu.Unlock()
+		a.batch = &walBatch{s: s}
+	}
+	if s.progressFn != nil {
+		a.progress = &progress{fn: s.progressFn}
+	}
+	key, err := fn(a)
+	if a.group != nil {
+		// 等待所有worker退出；worker出错时ctx被取消，put返回的只是ctx.Err()
+		if groupErr := a.group.Wait(); groupErr != nil {
+			err = groupErr
+		}
+	}
+	wal, _ := a.batch.(*walBatch)
 	if err != nil {
-		return "", err
+		// 出错时不提交，已经写入batch的数据块被丢弃
+		if wal != nil {
+			err = errors.Join(err, wal.discard())
+		}
+		return "", nil, err
 	}
+	if wal != nil {
+		wal.root = key
+	}
+	if a.batch != nil && !a.dryRun {
+		if err := a.batch.Commit(); err != nil {
+			return "", nil, err
+		}
+	}
+	a.progress.done()
+	return key, a.newKeys, nil
+}
 
-	return merkleRoot, nil
+// adder 保存一次Add调用中的状态
+type adder struct {
+	*DagService
+	ctx context.Context
+	// group 在设置了并发度时用于并发处理文件，为nil时在当前goroutine中处理
+	group *errgroup.Group
+
+	// progress 在设置了WithProgress时累计进度，为nil时不报告
+	progress *progress
+	// batch 在KVStore实现了BatchStore时收集本次调用写入的数据块，最后一次提交
+	batch Batch
+	// headerQueued 为true时store的头部已经写入batch
+	headerQueued bool
+	// onStored 不为nil时，目录中的每个文件写入后以该文件和其键值调用，可能被并发调用
+	onStored func(node Node, key string)
+
+	mu sync.Mutex
+	// seen 记录本次调用中已经写入的键值，相同的子树只写入一次
+	seen map[string]bool
+	// record 为true时newKeys记录实际写入的数据块的键值
+	record  bool
+	newKeys []string
+	// dryRun 为true时只计算键值，不写入数据块
+	dryRun bool
+	// measure 为true时，dryRun中累计total和added：所有数据块和KVStore中还没有的数据块的总字节数
+	measure bool
+	total   int64
+	added   int64
+	// written 为本次调用写入的新数据块的总字节数
+	written int64
+	// sha256 为true时哈希函数为SHA-256，可以直接使用NewFileFromReader计算出的哈希
+	sha256 bool
 }
 
-// serialize 将Node中的数据序列化为字节数组
-func serialize(node Node) ([]byte, error) {
-	switch n := node.(type) {
-	case File:
-		return n.Bytes(), nil
-	case Dir:
-		it := n.It()
-		var serializedData []byte
-		for it.Next() {
-			childNode := it.Node()
-			childData, err := serialize(childNode)
+// dirFrame 是put中尚未处理完子节点的目录。子节点的结果按其在目录中的位置保存，
+// 因此无论文件以什么顺序处理完，得到的Merkle Root都相同
+type dirFrame struct {
+	node     Dir
+	path     string
+	names    []string
+	children []Node
+	keys     []string
+	hashes   []string
+	errs     []error
+	// sizes 为各子节点的大小，子目录的大小在其写入后由其子节点的大小相加得到，
+	// 不调用Dir.Size()，否则每一层都要重新遍历整棵子树
+	sizes []int64
+	// size 为目录的大小，在putDir中计算
+	size int64
+	// next 是下一个需要检查的子节点的位置
+	next int
+	// cur 是正在处理的子目录的位置
+	cur int
+	wg  sync.WaitGroup
+}
+
+// newDirFrame 读取目录的全部子节点，并开始处理其中的文件。
+// 子节点按名字的字节序排序，Merkle Root只取决于目录的内容，与It()返回的顺序无关。
+// 有重名的子节点时返回ErrDuplicateEntry，设置了WithLastEntryWins时保留It()中最后一个；
+// 名字不能通过checkName时返回ErrInvalidNode，导出时不会写到目标目录之外
+func (s *adder) newDirFrame(dirNode Dir, path string) (*dirFrame, error) {
+	if d, ok := dirNode.(*dir); ok && d.err != nil {
+		return nil, d.err
+	}
+	f := &dirFrame{node: dirNode, path: path}
+	var entries []entry
+	it := dirNode.It()
+	for it.Next() {
+		name := s.normalizeName(it.Name())
+		if reason := checkName(name); reason != "" {
+			return nil, &ErrInvalidNode{Path: "/" + path, Reason: reason}
+		}
+		entries = append(entries, entry{name: name, node: it.Node()})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].name < entries[j].name
+	})
+	for _, e := range entries {
+		if n := len(f.names); n > 0 && f.names[n-1] == e.name {
+			if !s.lastEntryWins {
+				return nil, &ErrDuplicateEntry{Name: e.name}
+			}
+			f.children[n-1] = e.node
+			continue
+		}
+		f.names = append(f.names, e.name)
+		f.children = append(f.children, e.node)
+	}
+	f.keys = make([]string, len(f.children))
+	f.hashes = make([]string, len(f.children))
+	f.errs = make([]error, len(f.children))
+	f.sizes = make([]int64, len(f.children))
+	for i, child := range f.children {
+		if _, ok := child.(Dir); ok {
+			continue
+		}
+		s.spawn(&f.wg, func() error {
+			f.keys[i], f.hashes[i], f.errs[i] = s.putChild(child, s.childPath(path, f.names[i]))
+			if f.errs[i] != nil {
+				return f.errs[i]
+			}
+			f.sizes[i] = child.Size()
+			if s.onStored != nil {
+				s.onStored(child, f.keys[i])
+			}
+			return nil
+		})
+	}
+	return f, nil
+}
+
+// childPath 返回目录dir下名为name的子节点的路径。路径只用于报告进度，
+// 没有设置WithProgress时返回空字符串，避免很深的树中拼接路径的开销
+func (s *adder) childPath(dir string, name string) string {
+	if s.progress == nil {
+		return ""
+	}
+	return joinPath(dir, name)
+}
+
+// nextDir 返回下一个未处理的子目录，没有时返回false
+func (f *dirFrame) nextDir() (Dir, bool) {
+	for ; f.next < len(f.children); f.next++ {
+		if dirNode, ok := f.children[f.next].(Dir); ok {
+			f.cur = f.next
+			f.next++
+			return dirNode, true
+		}
+	}
+	return nil, false
+}
+
+// spawn 执行fn。设置了并发度时fn在worker中执行，wg在fn结束后减一
+func (s *adder) spawn(wg *sync.WaitGroup, fn func() error) {
+	if s.group == nil {
+		fn()
+		return
+	}
+	wg.Add(1)
+	s.group.Go(func() error {
+		defer wg.Done()
+		return fn()
+	})
+}
+
+// put 将Node及其子节点保存在KVStore中，返回Node的键值和Merkle Root。
+// File的叶子为其内容的哈希，Dir的叶子为每个子节点的Merkle Root及其序列化后的链接的哈希。
+// 目录的遍历使用显式的栈而不是递归，树的深度不受goroutine栈大小的限制
+func (s *adder) put(node Node) (string, string, error) {
+	dirNode, ok := node.(Dir)
+	if !ok {
+		return s.putFile(node, "")
+	}
+	if e, ok, err := s.memoized(dirNode); err != nil || ok {
+		return e.key, e.merkleRoot, err
+	}
+	// ancestors 记录栈中目录的标识，子目录是自己的祖先时说明存在环
+	ancestors := make(map[any]bool)
+	if id, ok := nodeID(dirNode); ok {
+		ancestors[id] = true
+	}
+	frame, err := s.newDirFrame(dirNode, "")
+	if err != nil {
+		return "", "", err
+	}
+	stack := []*dirFrame{frame}
+	for {
+		if err := s.ctx.Err(); err != nil {
+			return "", "", err
+		}
+		top := stack[len(stack)-1]
+		// 子目录入栈，等其子节点都处理完后再写入
+		if child, ok := top.nextDir(); ok {
+			e, ok, err := s.memoized(child)
 			if err != nil {
-				return nil, err
+				return "", "", err
+			}
+			if ok {
+				top.keys[top.cur] = e.key
+				top.hashes[top.cur] = e.merkleRoot
+				top.sizes[top.cur] = e.size
+				continue
+			}
+			if id, ok := nodeID(child); ok {
+				if ancestors[id] {
+					return "", "", fmt.Errorf("%s: %w", top.names[top.cur], ErrCycleDetected)
+				}
+				ancestors[id] = true
 			}
-			serializedData = append(serializedData, childData...)
+			frame, err := s.newDirFrame(child, s.childPath(top.path, top.names[top.cur]))
+			if err != nil {
+				return "", "", err
+			}
+			stack = append(stack, frame)
+			continue
+		}
+		stack = stack[:len(stack)-1]
+		if id, ok := nodeID(top.node); ok {
+			delete(ancestors, id)
+		}
+		key, merkleRoot, err := s.putDir(top)
+		if err != nil {
+			return "", "", err
 		}
-		return serializedData, nil
+		s.remember(top.node, memoEntry{key: key, merkleRoot: merkleRoot, size: top.size})
+		if len(stack) == 0 {
+			return key, merkleRoot, nil
+		}
+		parent := stack[len(stack)-1]
+		parent.keys[parent.cur] = key
+		parent.hashes[parent.cur] = merkleRoot
+		parent.sizes[parent.cur] = top.size
+	}
+}
+
+// nodeID 返回可以判断两个Node是否为同一个对象的标识。指针类型的Node使用其地址，
+// 其他可比较的类型使用其值，不可比较的类型无法判断，返回false
+func nodeID(node Node) (any, bool) {
+	v := reflect.ValueOf(node)
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		return [2]any{v.Type(), v.Pointer()}, true
+	}
+	if !v.Comparable() {
+		return nil, false
+	}
+	return node, true
+}
+
+// putFile 保存path处的File，返回其键值和Merkle Root
+func (s *adder) putFile(node Node, path string) (string, string, error) {
+	if err := s.ctx.Err(); err != nil {
+		return "", "", err
+	}
+	if codec, ok := codecForNode(node); ok {
+		return s.putCustom(node, codec, path)
+	}
+	switch n := node.(type) {
+	case *storedFile:
+		s.progress.add(path, n.size, 1)
+		return n.key, s.keyHash(n.key), nil
+	case *ChunkedFile:
+		return s.putChunkedFile(n, path)
+	case Symlink:
+		data := []byte(n.Target())
+		s.progress.add(path, int64(len(data)), 1)
+		return s.putNode(node, data, []string{s.hashBytes(data)})
+	case File:
+		data := n.Bytes()
+		s.progress.add(path, int64(len(data)), 1)
+		if s.chunking && s.maxBlockSize > 0 && len(data) > s.maxBlockSize {
+			f := NewChunkedFile(bytes.NewReader(data), int64(len(data)))
+			f.meta = metadataOf(node)
+			return s.putChunkedFile(f, path)
+		}
+		if meta := metadataOf(node); meta != nil {
+			return s.putFileWithMetadata(n, data, meta)
+		}
+		return s.putNode(node, data, []string{s.contentHash(node, data)})
 	default:
-		return nil, errors.New("unsupported node type")
+		return "", "", fmt.Errorf("add %T: %w", node, ErrUnsupportedNodeType)
+	}
+}
+
+// putFileWithMetadata 将内容作为BLOB保存，再保存链接它并带有元数据的LIST。
+// 内容相同而元数据不同的文件得到不同的键值，但共享同一个BLOB
+func (s *adder) putFileWithMetadata(node File, data []byte, meta *Metadata) (string, string, error) {
+	key, hash, err := s.putNode(&file{data: data}, data, []string{s.hashBytes(data)})
+	if err != nil {
+		return "", "", err
+	}
+	obj := &Object{
+		Links: []Link{{Hash: []byte(key), Size: int64(len(data))}},
+		Data:  []byte(BLOB),
+		Meta:  meta,
+	}
+	listData, err := s.serializer.Marshal(obj)
+	if err != nil {
+		return "", "", err
 	}
+	return s.putEncoded(node, listData, []string{hash, s.hashBytes(listData)})
 }
 
-// generateKey 根据Node生成唯一的存储键值
-func generateKey(node Node) string {
+// putDir 保存子节点都已处理完的目录，返回其键值和Merkle Root。
+// 空目录序列化为链接数量0，Merkle Root即为其序列化结果的哈希，因此空目录同样有确定的键值
+func (s *adder) putDir(f *dirFrame) (string, string, error) {
+	f.wg.Wait()
+	s.progress.add(f.path, 0, 1)
+	obj := &Object{Meta: metadataOf(f.node)}
+	for i := range f.children {
+		if f.errs[i] != nil {
+			return "", "", f.errs[i]
+		}
+		// 类型标记取自子节点的键值，自动切块的文件虽然是File，保存的却是LIST
+		childType, err := rootType(f.keys[i])
+		if err != nil {
+			return "", "", err
+		}
+		obj.Links = append(obj.Links, Link{
+			Name: f.names[i],
+			Hash: []byte(f.keys[i]),
+			Size: f.sizes[i],
+		})
+		obj.Data = append(obj.Data, childType...)
+		f.size += f.sizes[i]
+	}
+	return s.putDirObject(f.node, obj, f.hashes)
+}
+
+// putDirObject 保存链接按名字排序的目录块obj，hashes为各子节点的Merkle Root。
+// 目录项过多时先分片，返回目录的键值和Merkle Root
+func (s *adder) putDirObject(node Node, obj *Object, childHashes []string) (string, string, error) {
+	data, err := s.marshalDir(obj)
+	if err != nil {
+		return "", "", err
+	}
+	hashes := childHashes[:len(childHashes):len(childHashes)]
+	if s.needsShard(len(obj.Links), data) {
+		entries := make([]shardEntry, len(obj.Links))
+		for i, link := range obj.Links {
+			entries[i] = shardEntry{link: link, tag: obj.linkType(i), hash: hashes[i]}
+		}
+		meta := obj.Meta
+		if obj, hashes, err = s.putShards(entries, 0); err != nil {
+			return "", "", err
+		}
+		obj.Meta = meta
+		if data, err = s.serializer.Marshal(obj); err != nil {
+			return "", "", err
+		}
+	}
+	// 子节点链接（键值和类型标记）也作为一个叶子参与计算，
+	// 使子节点相同但结构不同的目录得到不同的键值
+	return s.putEncoded(node, data, append(hashes, s.hashBytes(data)))
+}
+
+// putNode 根据叶子哈希计算Merkle Root，并以此生成键值写入data
+func (s *adder) putNode(node Node, data []byte, hashes []string) (string, string, error) {
+	return s.writeNode(node, data, hashes, false)
+}
+
+// putEncoded 与putNode相同，但data是刚编码出的缓冲区，之后不会再使用，可以交给KVStore而不复制
+func (s *adder) putEncoded(node Node, data []byte, hashes []string) (string, string, error) {
+	return s.writeNode(node, data, hashes, true)
+}
+
+func (s *adder) writeNode(node Node, data []byte, hashes []string, owned bool) (string, string, error) {
+	merkleRoot, err := s.treeRoot(hashes)
+	if err != nil {
+		return "", "", err
+	}
+	key, err := s.generateKey(node, merkleRoot)
+	if err != nil {
+		return "", "", err
+	}
+	err = s.writeBlock(key, data, owned)
+	if err != nil {
+		return "", "", err
+	}
+	return key, merkleRoot, nil
+}
+
+// putBlock 写入数据块。键值由内容决定，已存在的数据块无需重复写入
+func (s *adder) putBlock(key string, data []byte) error {
+	return s.writeBlock(key, data, false)
+}
+
+// putEncodedBlock 写入刚编码出的数据块data，之后不会再使用data
+func (s *adder) putEncodedBlock(key string, data []byte) error {
+	return s.writeBlock(key, data, true)
+}
+
+// writeBlock 写入数据块，owned表示data归本次写入所有，KVStore实现了NoCopyPutter时直接交给它
+func (s *adder) writeBlock(key string, data []byte, owned bool) error {
+	s.mu.Lock()
+	seen := s.seen[key]
+	s.mu.Unlock()
+	if seen {
+		return nil
+	}
+	if s.maxBlockSize > 0 && len(data) > s.maxBlockSize {
+		return &ErrBlockTooLarge{Key: key, Size: len(data)}
+	}
+	if s.dryRun {
+		exists := false
+		if s.measure {
+			var err error
+			if exists, err = s.store.Has(key); err != nil {
+				return err
+			}
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		// 并发计算同一个数据块时只累计一次
+		if s.measure && !s.seen[key] {
+			s.total += int64(len(data))
+			if !exists {
+				s.added += int64(len(data))
+			}
+		}
+		s.seen[key] = true
+		return nil
+	}
+	exists, err := s.store.Has(key)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if s.maxTotalSize > 0 {
+			s.mu.Lock()
+			s.written += int64(len(data))
+			over := s.written > s.maxTotalSize
+			s.mu.Unlock()
+			if over {
+				return fmt.Errorf("%s: %w", key, ErrQuotaExceeded)
+			}
+		}
+		if s.batch != nil {
+			s.mu.Lock()
+			if !s.headerQueued {
+				err = s.ensureHeader(s.batch)
+				s.headerQueued = err == nil
+			}
+			if err == nil {
+				err = s.batch.Put(key, s.encodeBlock(key, data))
+			}
+			s.mu.Unlock()
+		} else if err = s.ensureHeader(nil); err == nil {
+			// 设置了WithTypePrefix时encodeBlock总是返回新的缓冲区
+			p, ok := s.store.(NoCopyPutter)
+			if ok && (owned || s.typePrefix) {
+				err = p.PutNoCopy(key, s.encodeBlock(key, data))
+			} else {
+				err = s.store.Put(key, s.encodeBlock(key, data))
+			}
+		}
+		if err != nil {
+			return err
+		}
+		s.metrics.IncPut()
+		s.metrics.AddBytes(int64(len(data)))
+		s.logger.Debugf("put %s (%d bytes)", key, len(data))
+	} else {
+		s.logger.Debugf("skip existing block %s", key)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// 并发写入同一个数据块时只记录一次
+	if !exists && s.record && !s.seen[key] {
+		s.newKeys = append(s.newKeys, key)
+	}
+	s.seen[key] = true
+	return nil
+}
+
+// objType 返回Node在Object.Data中记录的类型标记
+func objType(node Node) string {
+	if _, ok := node.(*ChunkedFile); ok {
+		return LIST
+	}
 	switch node.Type() {
-	case FILE:
-		fileNode := node.(File)
-		return "file_" + hex.EncodeToString(fileNode.Bytes())
 	case DIR:
-		dirNode := node.(Dir)
-		return "dir_" + hex.EncodeToString([]byte(dirNode.Size())) // 使用文件夹的大小作为键值
+		return TREE
+	case SYMLINK:
+		return LINK
+	}
+	if codec, ok := codecForNode(node); ok {
+		return codec.Tag()
+	}
+	// 带有元数据的文件保存为链接其内容的LIST
+	if metadataOf(node) != nil {
+		return LIST
+	}
+	return BLOB
+}
+
+// hashBytes 返回数据的哈希的十六进制表示
+func (s *DagService) hashBytes(data []byte) string {
+	h := s.hasher()
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// serialize 将Dir的Object序列化为字节数组。
+// 格式为：链接数量，然后每个链接依次为类型标记、名字、键值和大小，
+// 其中名字和键值前都写入其长度，保证不同的Object不会得到相同的字节。
+// 有元数据时最后再写入元数据，没有元数据的Object的格式不变
+func serialize(obj *Object) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := serializeTo(obj, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// serializeTo 按serialize的格式将Object直接写入w，不需要先在内存中拼出整个数据块
+func serializeTo(obj *Object, w io.Writer) error {
+	if len(obj.Data) != len(obj.Links)*STEP {
+		return errMalformedObject
+	}
+	bw := &blockWriter{w: w}
+	bw.uvarint(uint64(len(obj.Links)))
+	for i, link := range obj.Links {
+		bw.write(obj.Data[i*STEP : (i+1)*STEP])
+		bw.bytes([]byte(link.Name))
+		bw.bytes(link.Hash)
+		bw.varint(link.Size)
+	}
+	if obj.Meta != nil {
+		bw.write(appendMetadata(nil, obj.Meta))
+	}
+	return bw.err
+}
+
+// blockWriter 按serialize的格式依次写入字段，出错后的写入都被忽略
+type blockWriter struct {
+	w   io.Writer
+	buf [binary.MaxVarintLen64]byte
+	err error
+}
+
+func (w *blockWriter) write(p []byte) {
+	if w.err == nil {
+		_, w.err = w.w.Write(p)
+	}
+}
+
+func (w *blockWriter) uvarint(v uint64) {
+	w.write(w.buf[:binary.PutUvarint(w.buf[:], v)])
+}
+
+func (w *blockWriter) varint(v int64) {
+	w.write(w.buf[:binary.PutVarint(w.buf[:], v)])
+}
+
+func (w *blockWriter) bytes(p []byte) {
+	w.uvarint(uint64(len(p)))
+	w.write(p)
+}
+
+var errMalformedObject = errors.New("malformed object")
+
+// deserialize 将字节数组还原为Object
+func deserialize(data []byte) (*Object, error) {
+	r := &blockReader{data: data}
+	n := r.uvarint()
+	if r.err != nil {
+		return nil, r.err
+	}
+	// 每个链接至少占用一个字节，链接数量超过剩余的字节数时数据块不完整
+	if n > uint64(len(r.data)) {
+		return nil, errTruncated
+	}
+	obj := &Object{Links: make([]Link, 0, n)}
+	for i := uint64(0); i < n; i++ {
+		obj.Data = append(obj.Data, r.next(STEP)...)
+		name := r.bytes()
+		hash := r.bytes()
+		size := r.varint()
+		if r.err != nil {
+			return nil, r.err
+		}
+		obj.Links = append(obj.Links, Link{Name: string(name), Hash: hash, Size: size})
+	}
+	if len(r.data) != 0 {
+		obj.Meta = r.metadata()
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if len(r.data) != 0 {
+		return nil, errMalformedObject
+	}
+	return obj, nil
+}
+
+// blockReader 按serialize的格式依次读取字段，出错后的读取都返回零值
+type blockReader struct {
+	data []byte
+	err  error
+}
+
+func (r *blockReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 {
+		r.err = errMalformedObject
+		return nil
+	}
+	if n > len(r.data) {
+		r.err = errTruncated
+		return nil
+	}
+	b := r.data[:n:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *blockReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.err = varintErr(n)
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *blockReader) varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(r.data)
+	if n <= 0 {
+		r.err = varintErr(n)
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+// varintErr 返回binary.Uvarint或Varint的返回值n<=0对应的错误：n为0时数据在varint结束前就用完了，
+// 小于0时varint溢出
+func varintErr(n int) error {
+	if n == 0 {
+		return errTruncated
+	}
+	return errMalformedObject
+}
+
+func (r *blockReader) bytes() []byte {
+	n := r.uvarint()
+	if r.err == nil && n > uint64(len(r.data)) {
+		r.err = errTruncated
+		return nil
+	}
+	return r.next(int(n))
+}
+
+// generateKey 根据Node和其Merkle Root按DagService配置的格式生成唯一的存储键值。
+// 无法识别Node的类型时返回ErrUnsupportedNodeType，不同的未知类型不会共用同一个键值
+func (s *DagService) generateKey(node Node, merkleRoot string) (string, error) {
+	switch node.Type() {
+	case FILE, DIR, SYMLINK:
+		return s.formatKey(objType(node), merkleRoot), nil
+	}
+	if codec, ok := codecForNode(node); ok {
+		return s.formatKey(codec.Tag(), merkleRoot), nil
+	}
+	return "", fmt.Errorf("node type %d: %w", node.Type(), ErrUnsupportedNodeType)
+}
+
+// typedKey 返回类型前缀加Merkle Root形式的键值，自定义类型的前缀为其类型标记
+func typedKey(objType string, merkleRoot string) string {
+	if _, _, ok := codecForTag(objType); ok {
+		return objType + "_" + merkleRoot
+	}
+	switch objType {
+	case LIST:
+		return "list_" + merkleRoot
+	case TREE:
+		return "dir_" + merkleRoot
+	case LINK:
+		return "link_" + merkleRoot
+	case SHARD:
+		return "shard_" + merkleRoot
+	case SNAPSHOT:
+		return "snap_" + merkleRoot
+	case MERKLE:
+		return "merkle_" + merkleRoot
+	case COMMIT:
+		return "commit_" + merkleRoot
 	default:
-		return "unknown"
+		return "file_" + merkleRoot
+	}
+}
+
+// formatKey 按DagService配置的格式返回数据块的键值
+func (s *DagService) formatKey(objType string, merkleRoot string) string {
+	// 自定义类型没有对应的CID编码，总是使用类型前缀
+	if _, builtin := cidCodecs[objType]; s.cidKeys && builtin {
+		return cidKey(objType, merkleRoot)
+	}
+	if _, ok := s.keyEncoder.(HexEncoder); ok {
+		return typedKey(objType, merkleRoot)
+	}
+	digest, _ := hex.DecodeString(merkleRoot)
+	return typedKey(objType, s.keyEncoder.Encode(digest))
+}
+
+// keyHash 返回键值中的Merkle Root部分的十六进制表示，
+// 无法用DagService的KeyEncoder解码时原样返回。内嵌文件的Merkle Root由其内容计算
+func (s *DagService) keyHash(key string) string {
+	if data, ok := inlineData(key); ok {
+		root, _ := s.calculateMerkleRoot([]string{s.hashBytes(data)})
+		return root
+	}
+	if _, digest, ok := parseCID(key); ok {
+		return hex.EncodeToString(digest)
+	}
+	encoded := key[strings.IndexByte(key, '_')+1:]
+	if _, ok := s.keyEncoder.(HexEncoder); ok {
+		return encoded
+	}
+	digest, err := s.keyEncoder.Decode(encoded)
+	if err != nil {
+		return encoded
 	}
+	return hex.EncodeToString(digest)
 }
 
 // calculateMerkleRoot 计算Merkle Root
-func calculateMerkleRoot(hashes []string) (string, error) {
-    if len(hashes) == 0 {
-        return "", errors.New("no hashes provided")
-    }
-    if len(hashes) == 1 {
-        return hashes[0], nil
-    }
-
-    // 逐层计算Merkle Root
-    for len(hashes) > 1 {
-        // 如果哈希列表长度为奇数，则将最后一个哈希复制一份并添加到列表中
-        if len(hashes)%2 != 0 {
-            hashes = append(hashes, hashes[len(hashes)-1])
-        }
-
-        var newHashes []string
-        // 两两组合计算
-        for i := 0; i < len(hashes); i += 2 {
-            combinedHash := sha256.New()
-            combinedHash.Write([]byte(hashes[i] + hashes[i+1]))
-            newHash := hex.EncodeToString(combinedHash.Sum(nil))
-            newHashes = append(newHashes, newHash)
-        }
-
-        // 更新哈希列表
-        hashes = newHashes
-    }
-	
-    // 最终列表中的唯一元素即为Merkle Root
-    return hashes[0], nil
-}
\ No newline at end of file
+func (s *DagService) calculateMerkleRoot(hashes []string) (string, error) {
+	return s.merkleRoot(hashes, nil)
+}
+
+// merkleRoot 计算Merkle Root，store不为nil时对每个内部节点调用store
+func (s *DagService) merkleRoot(hashes []string, store func(hash string, data []byte) error) (string, error) {
+	if len(hashes) == 0 {
+		return "", fmt.Errorf("no hashes provided: %w", ErrEmptyInput)
+	}
+	hashes = s.leaves(hashes)
+	if len(hashes) == 1 {
+		return hashes[0], nil
+	}
+
+	// 逐层计算Merkle Root
+	for len(hashes) > 1 {
+		var err error
+		if hashes, err = s.nextLevel(hashes, store); err != nil {
+			return "", err
+		}
+	}
+
+	// 最终列表中的唯一元素即为Merkle Root
+	return hashes[0], nil
+}
+
+// branching 返回Merkle树的扇出
+func (s *DagService) branching() int {
+	if s.fanout < 2 {
+		return 2
+	}
+	return s.fanout
+}
+
+// nextLevel 将hashes按扇出分组，每组组合成上一层的一个哈希，store不为nil时对组合出的节点调用store。
+// 最后一组只有一个哈希时原样进入上一层。
+// 复制最后一个哈希会使[A,B,C]与[A,B,C,C]得到相同的Merkle Root
+func (s *DagService) nextLevel(hashes []string, store func(hash string, data []byte) error) ([]string, error) {
+	k := s.branching()
+	newHashes := make([]string, 0, (len(hashes)+k-1)/k)
+	for i := 0; i < len(hashes); i += k {
+		group := hashes[i:min(i+k, len(hashes))]
+		if len(group) == 1 {
+			newHashes = append(newHashes, group[0])
+			continue
+		}
+		hash := s.combine(group...)
+		if store != nil {
+			if err := store(hash, s.internalNode(group)); err != nil {
+				return nil, err
+			}
+		}
+		newHashes = append(newHashes, hash)
+	}
+	return newHashes, nil
+}
+
+// leaves 返回Merkle树的叶子层。设置了WithDomainSeparation时每个叶子为H(0x00||hash)，
+// 否则即为hashes本身
+func (s *DagService) leaves(hashes []string) []string {
+	if !s.domainSeparation {
+		return hashes
+	}
+	tagged := make([]string, len(hashes))
+	for i, hash := range hashes {
+		h := s.hasher()
+		h.Write([]byte{0x00})
+		h.Write(s.digestBytes(hash))
+		tagged[i] = hex.EncodeToString(h.Sum(nil))
+	}
+	return tagged
+}
+
+// combine 将同一组的哈希组合成上一层的哈希：默认为内部节点内容的哈希，
+// 设置了WithCombiner时为组合函数对各哈希的摘要的计算结果
+func (s *DagService) combine(hashes ...string) string {
+	if s.combiner == nil {
+		return s.hashBytes(s.internalNode(hashes))
+	}
+	digests := make([][]byte, len(hashes))
+	for i, hash := range hashes {
+		digests[i] = s.digestBytes(hash)
+	}
+	return hex.EncodeToString(s.combiner(digests))
+}
+
+// internalNode 返回由同一组的哈希组合成的内部节点的内容：按顺序拼接各哈希的摘要，
+// 设置了WithDomainSeparation时加上前缀0x01。内部节点的哈希即为其内容的哈希
+func (s *DagService) internalNode(hashes []string) []byte {
+	var data []byte
+	if s.domainSeparation {
+		data = append(data, 0x01)
+	}
+	for _, hash := range hashes {
+		data = append(data, s.digestBytes(hash)...)
+	}
+	return data
+}
+
+// digestBytes 返回Merkle树中拼接的十六进制哈希hash的字节：默认为解码后的原始摘要，
+// 设置了WithLegacyHexConcat时为十六进制字符串本身
+func (s *DagService) digestBytes(hash string) []byte {
+	if s.legacyHexConcat {
+		return []byte(hash)
+	}
+	digest, err := hex.DecodeString(hash)
+	if err != nil {
+		return []byte(hash)
+	}
+	return digest
+}
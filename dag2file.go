@@ -1,7 +1,10 @@
 package merkledag
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -11,9 +14,9 @@ const STEP = 4
 
 func Hash2File(store KVStore, hash []byte, path string, hp HashPool) []byte {	
     // 根据hash和path， 返回对应的文件, hash对应的类型是tree
-	flag, _ := store.Has(hash)
+	flag, _ := store.Has(string(hash))
 	if flag {
-		objBinary, _ := store.Get(hash)
+		objBinary, _ := store.Get(string(hash))
 		obj := binaryToObj(objBinary)
 		patharr := strings.Split(path, "\\")
 		cur := 1
@@ -36,17 +39,17 @@ func getFileByDir(obj *Object, patharr []string, cur int, store KVStore) []byte
 		}
 		switch objType {
 		case TREE:
-			objDirBinary, _ := store.Get(objInfo.Hash)
+			objDirBinary, _ := store.Get(string(objInfo.Hash))
 			objDir := binaryToObj(objDirBinary)
 			ans := getFileByDir(objDir, patharr, cur+1, store)
 			if ans != nil {
 				return ans
 			}
 		case BLOB:
-			ans, _ := store.Get(objInfo.Hash)
+			ans, _ := store.Get(string(objInfo.Hash))
 			return ans
 		case LIST:
-			objLinkBinary, _ := store.Get(objInfo.Hash)
+			objLinkBinary, _ := store.Get(string(objInfo.Hash))
 			objList := binaryToObj(objLinkBinary)
 			ans := getFileByList(objList, store)
 			return ans
@@ -62,7 +65,7 @@ func getFileByList(obj *Object, store KVStore) []byte {
 		curObjType := string(obj.Data[index : index+STEP])
 		index += STEP
 		curObjLink := obj.Links[i]
-		curObjBinary, _ := store.Get(curObjLink.Hash)
+		curObjBinary, _ := store.Get(string(curObjLink.Hash))
 		curObj := binaryToObj(curObjBinary)
 		if curObjType == BLOB {
 			ans = append(ans, curObjBinary...)
@@ -75,7 +78,409 @@ func getFileByList(obj *Object, store KVStore) []byte {
 }
 
 func binaryToObj(objBinary []byte) *Object {
-	var res Object
-	json.Unmarshal(objBinary, &res)
-	return &res
-}
\ No newline at end of file
+	res, err := deserialize(objBinary)
+	if err != nil {
+		return &Object{}
+	}
+	return res
+}
+
+// Get 从KVStore中读取merkleRoot对应的数据，重建出File或Dir
+func Get(store KVStore, merkleRoot string) (Node, error) {
+	return NewDagService(store).Get(merkleRoot)
+}
+
+// Get 从KVStore中读取merkleRoot对应的数据，重建出File或Dir
+func (s *DagService) Get(merkleRoot string) (Node, error) {
+	return s.GetContext(context.Background(), merkleRoot)
+}
+
+// GetContext 与Get相同，但在读取每个数据块前检查ctx，ctx取消后立即返回ctx.Err()
+func (s *DagService) GetContext(ctx context.Context, merkleRoot string) (Node, error) {
+	objType, err := rootType(merkleRoot)
+	if err != nil {
+		return nil, err
+	}
+	return s.newGetter(ctx).getNode(merkleRoot, objType)
+}
+
+// rootType 根据键值的前缀判断根节点的类型标记，key为空时返回ErrInvalidKey
+func rootType(key string) (string, error) {
+	if key == "" {
+		return "", fmt.Errorf("empty key: %w", ErrInvalidKey)
+	}
+	if objType, _, ok := parseCID(key); ok {
+		return objType, nil
+	}
+	switch {
+	case strings.HasPrefix(key, "file_"):
+		return BLOB, nil
+	case strings.HasPrefix(key, "dir_"):
+		return TREE, nil
+	case strings.HasPrefix(key, "list_"):
+		return LIST, nil
+	case strings.HasPrefix(key, "link_"):
+		return LINK, nil
+	case strings.HasPrefix(key, "shard_"):
+		return SHARD, nil
+	case strings.HasPrefix(key, "snap_"):
+		return SNAPSHOT, nil
+	case strings.HasPrefix(key, "merkle_"):
+		return MERKLE, nil
+	case strings.HasPrefix(key, "commit_"):
+		return COMMIT, nil
+	case strings.HasPrefix(key, inlinePrefix):
+		return BLOB, nil
+	}
+	if tag, _, ok := strings.Cut(key, "_"); ok {
+		if _, _, ok := codecForTag(tag); ok {
+			return tag, nil
+		}
+	}
+	return "", fmt.Errorf("unknown key type %q: %w", key, ErrUnsupportedNodeType)
+}
+
+// getter 保存一次Get调用中的状态
+type getter struct {
+	*DagService
+	ctx context.Context
+	// cache 保存本次调用中已经重建的节点，相同的子树只读取一次
+	cache map[string]Node
+	// names 为正在重建的节点在树中的路径，只在出错时拼接。
+	// trackPath为false时不知道起点的路径，错误中不填写路径
+	names     []string
+	trackPath bool
+	// maxDepth 不小于0时，比它更深的节点不读取，只返回Placeholder
+	maxDepth int
+	// prefetch 为true时并发读取每个目录的子节点的数据块，读取后放在blocks中直到被使用
+	prefetch bool
+	blocks   map[string][]byte
+}
+
+func (s *DagService) newGetter(ctx context.Context) *getter {
+	return &getter{DagService: s, ctx: ctx, cache: make(map[string]Node), trackPath: true, maxDepth: -1}
+}
+
+// getNode 重建key对应的节点。缺少数据块时在错误中记录出错的节点的路径
+func (g *getter) getNode(key string, objType string) (Node, error) {
+	cacheKey := key
+	if g.maxDepth >= 0 {
+		// 限制深度时同一个子树在不同深度重建出的节点不同
+		cacheKey = strconv.Itoa(len(g.names)) + "/" + key
+	}
+	if node, ok := g.cache[cacheKey]; ok {
+		return node, nil
+	}
+	node, err := g.loadNode(key, objType)
+	if err != nil {
+		var notFound *ErrBlockNotFound
+		if g.trackPath && errors.As(err, &notFound) && notFound.Path == "" {
+			notFound.Path = "/" + strings.Join(g.names, "/")
+		}
+		return nil, err
+	}
+	g.cache[cacheKey] = node
+	return node, nil
+}
+
+func (g *getter) loadNode(key string, objType string) (Node, error) {
+	s, ctx := g.DagService, g.ctx
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	data, ok := g.blocks[key]
+	if ok {
+		delete(g.blocks, key)
+	} else {
+		var err error
+		if data, err = s.getBlock(key); err != nil {
+			return nil, err
+		}
+	}
+	switch objType {
+	case BLOB:
+		return &file{data: data}, nil
+	case LINK:
+		return &symlink{target: string(data)}, nil
+	case SNAPSHOT:
+		m, err := s.manifestFrom(key, data)
+		if err != nil {
+			return nil, err
+		}
+		childType, err := rootType(m.Root)
+		if err != nil {
+			return nil, err
+		}
+		return g.getNode(m.Root, childType)
+	case COMMIT:
+		c, err := decodeCommit(data)
+		if err != nil {
+			return nil, corruptBlock(key, err)
+		}
+		childType, err := rootType(c.Tree)
+		if err != nil {
+			return nil, err
+		}
+		return g.getNode(c.Tree, childType)
+	case TREE:
+		obj, err := s.decodeBlock(key, data)
+		if err != nil {
+			return nil, err
+		}
+		if obj, err = s.expandShards(obj); err != nil {
+			return nil, err
+		}
+		if g.prefetch && g.maxDepth < 0 {
+			if err := g.prefetchChildren(obj); err != nil {
+				return nil, err
+			}
+		}
+		d := &dir{meta: obj.Meta}
+		for i, link := range obj.Links {
+			childType := obj.linkType(i)
+			if g.maxDepth >= 0 && len(g.names) >= g.maxDepth {
+				child := &Placeholder{Key: string(link.Hash), Kind: nodeKind(childType), size: link.Size}
+				d.entries = append(d.entries, entry{name: link.Name, node: child})
+				continue
+			}
+			g.names = append(g.names, link.Name)
+			child, err := g.getNode(string(link.Hash), childType)
+			g.names = g.names[:len(g.names)-1]
+			if err != nil {
+				return nil, err
+			}
+			d.entries = append(d.entries, entry{name: link.Name, node: child})
+		}
+		return d, nil
+	case LIST:
+		obj, err := s.decodeBlock(key, data)
+		if err != nil {
+			return nil, err
+		}
+		content, err := s.readList(ctx, obj)
+		if err != nil {
+			return nil, err
+		}
+		return &file{data: content, meta: obj.Meta}, nil
+	default:
+		if _, codec, ok := codecForTag(objType); ok {
+			node, err := codec.Decode(data)
+			if err != nil {
+				return nil, corruptBlock(key, err)
+			}
+			return node, nil
+		}
+		return nil, fmt.Errorf("unknown object type %q: %w", objType, ErrUnsupportedNodeType)
+	}
+}
+
+// readList 按顺序读取分块文件的所有数据块并拼接
+func (s *DagService) readList(ctx context.Context, obj *Object) ([]byte, error) {
+	var content []byte
+	for i, link := range obj.Links {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		data, err := s.getBlock(string(link.Hash))
+		if err != nil {
+			return nil, err
+		}
+		if obj.linkType(i) == LIST {
+			subObj, err := s.decodeBlock(string(link.Hash), data)
+			if err != nil {
+				return nil, err
+			}
+			if data, err = s.readList(ctx, subObj); err != nil {
+				return nil, err
+			}
+		}
+		content = append(content, data...)
+	}
+	return content, nil
+}
+
+// readObject 读取key对应的TREE或LIST数据块并还原为Object
+func (s *DagService) readObject(key string) (*Object, error) {
+	data, err := s.getBlock(key)
+	if err != nil {
+		return nil, err
+	}
+	return s.decodeBlock(key, data)
+}
+
+// decodeBlock 将键值为key的数据块data还原为Object，无法解析时返回ErrCorruptBlock
+func (s *DagService) decodeBlock(key string, data []byte) (*Object, error) {
+	obj, err := s.serializer.Unmarshal(data)
+	if err != nil {
+		return nil, corruptBlock(key, err)
+	}
+	return obj, nil
+}
+
+// blockLinks 返回ref的数据块data中指向子节点的链接。
+// 快照清单返回只链接其根节点的Object，提交返回链接其目录树和上一个提交的Object，
+// 上一个提交已经被Compact删除时只链接目录树。没有子节点的数据块返回nil
+func (s *DagService) blockLinks(ref blockRef, data []byte) (*Object, error) {
+	switch ref.objType {
+	case TREE, LIST, SHARD:
+		return s.serializer.Unmarshal(data)
+	case SNAPSHOT:
+		m, err := decodeManifest(data)
+		if err != nil {
+			return nil, err
+		}
+		childType, err := rootType(m.Root)
+		if err != nil {
+			return nil, err
+		}
+		return &Object{Links: []Link{{Hash: []byte(m.Root)}}, Data: []byte(childType)}, nil
+	case COMMIT:
+		c, err := decodeCommit(data)
+		if err != nil {
+			return nil, err
+		}
+		shallow, err := s.isShallow(ref.key)
+		if err != nil {
+			return nil, err
+		}
+		if shallow {
+			c.Parent = ""
+		}
+		return commitLinks(c)
+	default:
+		return nil, nil
+	}
+}
+
+// hasLinks 判断类型为objType的数据块是否可能有子节点
+func hasLinks(objType string) bool {
+	switch objType {
+	case TREE, LIST, SHARD, SNAPSHOT, COMMIT:
+		return true
+	}
+	return false
+}
+
+// readLinks 读取ref的数据块中指向子节点的链接，没有子节点的数据块不读取，返回nil
+func (s *DagService) readLinks(ref blockRef) (*Object, error) {
+	if !hasLinks(ref.objType) {
+		return nil, nil
+	}
+	data, err := s.getBlock(ref.key)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := s.blockLinks(ref, data)
+	if err != nil {
+		return nil, corruptBlock(ref.key, err)
+	}
+	return obj, nil
+}
+
+// readDir 读取key对应的目录块，分片的目录展开为直接链接所有目录项的Object
+func (s *DagService) readDir(key string) (*Object, error) {
+	obj, err := s.readObject(key)
+	if err != nil {
+		return nil, err
+	}
+	return s.expandShards(obj)
+}
+
+// getBlock 读取key对应的数据块，不存在时返回ErrBlockNotFound。内嵌文件不读取KVStore
+func (s *DagService) getBlock(key string) ([]byte, error) {
+	if data, ok := inlineData(key); ok {
+		return data, nil
+	}
+	if err := s.checkOpen(); err != nil {
+		return nil, err
+	}
+	if err := s.checkHeaderOnRead(); err != nil {
+		return nil, err
+	}
+	s.metrics.IncGet()
+	data, err := s.store.Get(key)
+	if errors.Is(err, ErrNotFound) {
+		return nil, &ErrBlockNotFound{Key: key}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if data, err = s.decodeStored(key, data); err != nil {
+		return nil, err
+	}
+	if s.verifyOnGet {
+		if err := s.verifyBlock(key, data); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// verifyBlock 重新计算数据块的键值，与读取时使用的key不一致时返回ErrHashMismatch
+func (s *DagService) verifyBlock(key string, data []byte) error {
+	return s.verifyBlockWith(key, data, s.getBlock)
+}
+
+// verifyBlockWith 与verifyBlock相同，设置了WithContentNormalization时用fetch读取LIST链接的块
+func (s *DagService) verifyBlockWith(key string, data []byte, fetch func(key string) ([]byte, error)) error {
+	objType, err := rootType(key)
+	if err != nil {
+		return err
+	}
+	got, err := s.blockHash(objType, data, fetch)
+	if err != nil {
+		s.logger.Warnf("verify %s: %v", key, err)
+		return corruptBlock(key, err)
+	}
+	if got != s.keyHash(key) {
+		s.logger.Warnf("verify %s: content hashes to %s", key, got)
+		return &ErrHashMismatch{Key: key, Got: s.formatKey(objType, got)}
+	}
+	return nil
+}
+
+// blockHash 根据数据块的类型和内容计算其Merkle Root，计算方式与Add相同。
+// 设置了WithContentNormalization时LIST的Merkle Root由其内容计算，用fetch读取它链接的块
+func (s *DagService) blockHash(objType string, data []byte, fetch func(key string) ([]byte, error)) (string, error) {
+	switch objType {
+	case MERKLE:
+		children, err := s.parseInternalNode(data)
+		if err != nil {
+			return "", err
+		}
+		return s.combine(children...), nil
+	case BLOB, LINK:
+		return s.calculateMerkleRoot([]string{s.hashBytes(data)})
+	case SNAPSHOT:
+		m, err := decodeManifest(data)
+		if err != nil {
+			return "", err
+		}
+		return s.calculateMerkleRoot([]string{s.keyHash(m.Root), s.hashBytes(data)})
+	case COMMIT:
+		c, err := decodeCommit(data)
+		if err != nil {
+			return "", err
+		}
+		hashes := []string{s.keyHash(c.Tree)}
+		if c.Parent != "" {
+			hashes = append(hashes, s.keyHash(c.Parent))
+		}
+		return s.calculateMerkleRoot(append(hashes, s.hashBytes(data)))
+	}
+	if _, codec, ok := codecForTag(objType); ok {
+		return s.calculateMerkleRoot(s.customLeaves(codec, data))
+	}
+	obj, err := s.serializer.Unmarshal(data)
+	if err != nil {
+		return "", err
+	}
+	if objType == LIST && s.contentNormalization {
+		return s.normalizedListRoot(obj, data, fetch)
+	}
+	hashes := make([]string, 0, len(obj.Links)+1)
+	for _, link := range obj.Links {
+		hashes = append(hashes, s.keyHash(string(link.Hash)))
+	}
+	return s.calculateMerkleRoot(append(hashes, s.hashBytes(data)))
+}
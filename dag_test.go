@@ -0,0 +1,816 @@
+package merkledag
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 固定的向量：改变序列化格式或Merkle树的计算方式都会改变它们
+func TestKnownVectors(t *testing.T) {
+	cases := []struct {
+		name string
+		node Node
+		want string
+	}{
+		// 只有一个叶子的文件的Merkle Root就是其内容的SHA-256
+		{"file", NewFile([]byte("hello")), "file_2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"},
+		{"dir", NewDirBuilder().
+			AddFile("a.txt", []byte("alpha")).
+			AddFile("b.txt", []byte("beta")).
+			AddDir("sub", NewDirBuilder().AddFile("c.txt", []byte("gamma")).Build()).
+			Build(), "dir_44ea87d03fab41d3279df86cc8e8f781d4432ca9cf3abad0f5dbb100c274f414"},
+		// 空目录序列化为一个0字节的链接数量
+		{"empty dir", NewDirBuilder().Build(), "dir_6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d"},
+	}
+	for _, c := range cases {
+		for i := 0; i < 2; i++ {
+			got, err := NewDagService(NewMemStore()).Add(c.node)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != c.want {
+				t.Errorf("%s: got %s, want %s", c.name, got, c.want)
+			}
+		}
+	}
+}
+
+// deepChain 返回depth层只有一个子目录的目录，最深处是一个文件
+func deepChain(depth int) Dir {
+	var node Dir = NewDirBuilder().AddFile("leaf", []byte("leaf")).Build()
+	for i := 0; i < depth; i++ {
+		node = NewDirBuilder().AddDir("d", node).Build()
+	}
+	return node
+}
+
+func TestAddDeepChain(t *testing.T) {
+	s := NewDagService(NewMemStore())
+	root, err := s.Add(deepChain(100000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Get(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Size() != 4 {
+		t.Fatalf("size %d, want 4", got.Size())
+	}
+	obj, err := s.readObject(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.Links[0].Size != 4 {
+		t.Fatalf("link size %d, want 4", obj.Links[0].Size)
+	}
+}
+
+// sameTree 比较两个Node的类型、大小、名字和内容是否相同，不同时返回第一个不同之处
+func sameTree(a, b Node) (bool, string) {
+	if a.Type() != b.Type() || a.Size() != b.Size() {
+		return false, fmt.Sprintf("type/size %d/%d vs %d/%d", a.Type(), a.Size(), b.Type(), b.Size())
+	}
+	switch a.Type() {
+	case FILE:
+		if !bytes.Equal(a.(File).Bytes(), b.(File).Bytes()) {
+			return false, "file content"
+		}
+	case SYMLINK:
+		if a.(Symlink).Target() != b.(Symlink).Target() {
+			return false, "symlink target"
+		}
+	case DIR:
+		ia, ib := a.(Dir).It(), b.(Dir).It()
+		for {
+			na, nb := ia.Next(), ib.Next()
+			if na != nb {
+				return false, "number of entries"
+			}
+			if !na {
+				break
+			}
+			if ia.Name() != ib.Name() {
+				return false, fmt.Sprintf("name %q vs %q", ia.Name(), ib.Name())
+			}
+			if ok, diff := sameTree(ia.Node(), ib.Node()); !ok {
+				return false, ia.Name() + ": " + diff
+			}
+		}
+	}
+	return true, ""
+}
+
+func TestAddGetRoundTrip(t *testing.T) {
+	tree := NewDirBuilder().
+		AddFile("a", []byte("alpha")).
+		AddFile("empty", []byte{}).
+		AddDir("sub", NewDirBuilder().
+			AddFile("b", []byte("beta")).
+			AddDir("deeper", NewDirBuilder().AddFile("c", []byte("gamma")).Build()).
+			Build()).
+		Build()
+	s := NewDagService(NewMemStore())
+	root, err := s.Add(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Get(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, diff := sameTree(tree, got); !ok {
+		t.Fatalf("Get differs from the added tree: %s", diff)
+	}
+	again, err := s.Add(got)
+	if err != nil || again != root {
+		t.Fatalf("re-adding the tree gave %s, %v, want %s", again, err, root)
+	}
+}
+
+func TestDirectoryKeys(t *testing.T) {
+	s := NewDagService(NewMemStore())
+	// 两个大小相同而内容不同的目录
+	a, err := s.Add(NewDirBuilder().AddFile("x", []byte("12")).AddFile("y", []byte("34")).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := s.Add(NewDirBuilder().AddFile("x", []byte("12")).AddFile("y", []byte("35")).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == b {
+		t.Fatalf("directories of equal size share the key %s", a)
+	}
+	// 内容相同的目录只保存一次
+	c, err := s.Add(NewDirBuilder().AddFile("y", []byte("34")).AddFile("x", []byte("12")).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != a {
+		t.Fatalf("identical directories got %s and %s", a, c)
+	}
+	file, err := s.Add(NewFile(bytes.Repeat([]byte("z"), 10000)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(file) != len("file_")+64 {
+		t.Fatalf("file key %q is not a SHA-256 digest", file)
+	}
+}
+
+func TestDirectorySize(t *testing.T) {
+	sub := NewDirBuilder().AddFile("b", []byte("1234")).AddFile("c", []byte("56789")).Build()
+	tree := NewDirBuilder().AddFile("a", []byte("12")).AddDir("sub", sub).Build()
+	s := NewDagService(NewMemStore())
+	root, err := s.Add(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Get(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var total int64
+	it := got.(Dir).It()
+	for it.Next() {
+		total += it.Node().Size()
+	}
+	if total != 11 || got.Size() != total {
+		t.Fatalf("children sum to %d, directory reports %d, want 11", total, got.Size())
+	}
+}
+
+func TestAddSkipsExistingBlocks(t *testing.T) {
+	st := newCountingStore()
+	s := NewDagService(st)
+	tree := NewDirBuilder().
+		AddFile("a", []byte("alpha")).
+		AddDir("sub", NewDirBuilder().AddFile("b", []byte("beta")).Build()).
+		Build()
+	root, err := s.Add(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.puts == 0 {
+		t.Fatal("first Add wrote nothing")
+	}
+	st.reset()
+	again, err := s.Add(tree)
+	if err != nil || again != root {
+		t.Fatalf("second Add: %s, %v", again, err)
+	}
+	if st.puts != 0 {
+		t.Fatalf("second Add issued %d Puts, want 0", st.puts)
+	}
+}
+
+// keysWithPrefix 返回m中以prefix开头的键值的数量
+func keysWithPrefix(t *testing.T, m *MemStore, prefix string) int {
+	t.Helper()
+	keys, err := m.Keys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for _, key := range keys {
+		if strings.HasPrefix(key, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestAddDeduplicatesIdenticalFiles(t *testing.T) {
+	st := newCountingStore()
+	s := NewDagService(st)
+	content := bytes.Repeat([]byte("same"), 100)
+	_, err := s.Add(NewDirBuilder().
+		AddFile("one", content).
+		AddFile("two", content).
+		AddDir("sub", NewDirBuilder().AddFile("three", content).Build()).
+		Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := keysWithPrefix(t, st.MemStore, "file_"); n != 1 {
+		t.Fatalf("%d file blocks, want 1", n)
+	}
+	// 文件块、两个目录块和存储头
+	if st.puts != 4 {
+		t.Fatalf("%d Puts, want 4", st.puts)
+	}
+}
+
+// cancelingFile 在内容被读取时取消ctx
+type cancelingFile struct {
+	data   []byte
+	cancel context.CancelFunc
+}
+
+func (f *cancelingFile) Size() int64 { return int64(len(f.data)) }
+func (f *cancelingFile) Type() int   { return FILE }
+func (f *cancelingFile) Bytes() []byte {
+	f.cancel()
+	return f.data
+}
+
+// cancelingStore 在第limit次Get时取消ctx
+type cancelingStore struct {
+	*MemStore
+	mu     sync.Mutex
+	gets   int
+	limit  int
+	cancel context.CancelFunc
+}
+
+func (c *cancelingStore) Get(key string) ([]byte, error) {
+	c.mu.Lock()
+	c.gets++
+	if c.gets == c.limit {
+		c.cancel()
+	}
+	c.mu.Unlock()
+	return c.MemStore.Get(key)
+}
+
+// wideTree 返回dirs个子目录、每个子目录files个文件的目录，middle不为nil时作为中间的一个文件
+func wideTree(dirs, files int, middle Node) Dir {
+	b := NewDirBuilder()
+	for i := 0; i < dirs; i++ {
+		sub := NewDirBuilder()
+		for j := 0; j < files; j++ {
+			if middle != nil && i == dirs/2 && j == 0 {
+				sub.add("middle", middle)
+				continue
+			}
+			sub.AddFile(fmt.Sprint("f", j), []byte(fmt.Sprint(i, "/", j)))
+		}
+		b.AddDir(fmt.Sprint("d", i), sub.Build())
+	}
+	return b.Build()
+}
+
+func TestAddContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	st := NewMemStore()
+	s := NewDagService(st, WithConcurrency(1))
+	_, err := s.AddContext(ctx, wideTree(100, 10, &cancelingFile{data: []byte("x"), cancel: cancel}))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+	if n := keysWithPrefix(t, st, "file_"); n >= 1000 {
+		t.Fatalf("all %d files were stored after cancellation", n)
+	}
+}
+
+func TestGetContextCanceled(t *testing.T) {
+	mem := NewMemStore()
+	root, err := NewDagService(mem).Add(wideTree(100, 10, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	st := &cancelingStore{MemStore: mem, limit: 50, cancel: cancel}
+	if _, err := NewDagService(st).GetContext(ctx, root); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+	if st.gets > 100 {
+		t.Fatalf("Get continued for %d reads after cancellation", st.gets-50)
+	}
+}
+
+// flatDir 返回包含n个文件的目录
+func flatDir(n int) Dir {
+	b := NewDirBuilder()
+	for i := 0; i < n; i++ {
+		b.AddFile(fmt.Sprintf("f%05d", i), []byte(fmt.Sprint("content ", i)))
+	}
+	return b.Build()
+}
+
+func TestConcurrentAddMatchesSerial(t *testing.T) {
+	tree := wideTree(20, 50, nil)
+	serial, err := NewDagService(NewMemStore()).Add(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int{2, 8, 64} {
+		for i := 0; i < 3; i++ {
+			got, err := NewDagService(NewMemStore(), WithConcurrency(n)).Add(tree)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != serial {
+				t.Fatalf("WithConcurrency(%d): got %s, want %s", n, got, serial)
+			}
+		}
+	}
+}
+
+func BenchmarkAddWideDir(b *testing.B) {
+	tree := flatDir(10000)
+	for _, n := range []int{1, 8} {
+		b.Run(fmt.Sprint("workers=", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := NewDagService(NewMemStore(), WithConcurrency(n)).Add(tree); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyOnGetDetectsCorruption(t *testing.T) {
+	st := NewMemStore()
+	root, err := NewDagService(st).Add(NewDirBuilder().AddFile("a.txt", []byte("hello")).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileKey, err := NewDagService(st).Add(NewFile([]byte("hello")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Put(fileKey, []byte("hellp")); err != nil {
+		t.Fatal(err)
+	}
+	// 默认不检查，读到的是损坏的内容
+	if got := readPath(t, NewDagService(st), root, "a.txt"); string(got) != "hellp" {
+		t.Fatalf("got %q", got)
+	}
+	s := NewDagService(st, WithVerifyOnGet(true))
+	var mismatch *ErrHashMismatch
+	if _, err := s.Get(root); !errors.As(err, &mismatch) {
+		t.Fatalf("Get: got %v, want ErrHashMismatch", err)
+	}
+	if mismatch.Key != fileKey || mismatch.Got == fileKey {
+		t.Fatalf("mismatch %+v", mismatch)
+	}
+	if _, err := s.Cat(root, "a.txt"); !errors.As(err, &mismatch) {
+		t.Fatalf("Cat: got %v, want ErrHashMismatch", err)
+	}
+}
+
+func TestRootIndependentOfInsertionOrder(t *testing.T) {
+	names := []string{"b", "a", "B", "a.txt", "a-b", "é", "z"}
+	build := func(order []int) Dir {
+		b := NewDirBuilder()
+		for _, i := range order {
+			b.AddFile(names[i], []byte(names[i]))
+		}
+		// 子目录中的目录项同样以不同的顺序加入
+		sub := NewDirBuilder().AddFile("y", []byte("y")).AddFile("x", []byte("x"))
+		if order[0] != 0 {
+			sub = NewDirBuilder().AddFile("x", []byte("x")).AddFile("y", []byte("y"))
+		}
+		return b.AddDir("sub", sub.Build()).Build()
+	}
+	s := NewDagService(NewMemStore())
+	want, err := s.Add(build([]int{0, 1, 2, 3, 4, 5, 6}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, order := range [][]int{{6, 5, 4, 3, 2, 1, 0}, {3, 0, 6, 1, 5, 2, 4}} {
+		got, err := s.Add(build(order))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("order %v: got %s, want %s", order, got, want)
+		}
+	}
+	n, err := s.Get(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 按字节序排列
+	var got []string
+	for it := n.(Dir).It(); it.Next(); {
+		got = append(got, it.Name())
+	}
+	if strings.Join(got, " ") != "B a a-b a.txt b sub z é" {
+		t.Fatalf("names %q", got)
+	}
+}
+
+func TestEmptyDirectory(t *testing.T) {
+	serializers := map[string]Serializer{"binary": BinarySerializer{}, "json": JSONSerializer{}}
+	for name, serializer := range serializers {
+		s := NewDagService(NewMemStore(), WithSerializer(serializer), WithVerifyOnGet(true))
+		root, err := s.Add(NewDirBuilder().Build())
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		n, err := s.Get(root)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		d, ok := n.(Dir)
+		if !ok || d.Size() != 0 || d.It().Next() {
+			t.Fatalf("%s: got %T with entries", name, n)
+		}
+		again, err := s.Add(n)
+		if err != nil || again != root {
+			t.Fatalf("%s: re-adding gives %s, %v; want %s", name, again, err, root)
+		}
+		// 空目录作为子目录同样可以保存和读取
+		parent, err := s.Add(NewDirBuilder().AddDir("empty", NewDirBuilder().Build()).Build())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n, err := s.Resolve(parent, "empty"); err != nil || n.Type() != DIR {
+			t.Fatalf("%s: got %v, %v", name, n, err)
+		}
+	}
+}
+
+func TestAddWithKeysReportsNewBlocks(t *testing.T) {
+	st := NewMemStore()
+	s := NewDagService(st, WithConcurrency(4))
+	shared := NewDirBuilder().AddFile("x", []byte("12")).AddFile("y", []byte("345")).Build()
+	root, keys, err := s.AddWithKeys(NewDirBuilder().AddDir("a", shared).AddDir("b", shared).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 根目录、共享的子目录和两个文件
+	if len(keys) != 4 {
+		t.Fatalf("first Add reported %v", keys)
+	}
+	before, err := st.Keys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, keys, err = s.AddWithKeys(NewDirBuilder().AddDir("a", shared).AddFile("new", []byte("new")).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := st.Keys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	existed := make(map[string]bool)
+	for _, key := range before {
+		existed[key] = true
+	}
+	var want []string
+	for _, key := range after {
+		if !existed[key] {
+			want = append(want, key)
+		}
+	}
+	sort.Strings(keys)
+	sort.Strings(want)
+	if strings.Join(keys, " ") != strings.Join(want, " ") || len(keys) != 2 {
+		t.Fatalf("second Add reported %v, want %v", keys, want)
+	}
+	if _, keys, err := s.AddWithKeys(NewDirBuilder().AddDir("a", shared).AddDir("b", shared).Build()); len(keys) != 0 || err != nil {
+		t.Fatalf("re-adding %s reported %v, %v", root, keys, err)
+	}
+}
+
+// loopDir 是子节点可以指向任意Dir的目录，用于构造环
+type loopDir struct {
+	children []Dir
+}
+
+func (d *loopDir) Size() int64 { return 0 }
+func (d *loopDir) Type() int   { return DIR }
+func (d *loopDir) It() DirIterator {
+	return &loopIterator{d: d, i: -1}
+}
+
+type loopIterator struct {
+	d *loopDir
+	i int
+}
+
+func (it *loopIterator) Next() bool   { it.i++; return it.i < len(it.d.children) }
+func (it *loopIterator) Name() string { return fmt.Sprint("d", it.i) }
+func (it *loopIterator) Node() Node   { return it.d.children[it.i] }
+
+func TestAddRejectsCycles(t *testing.T) {
+	self := &loopDir{}
+	self.children = []Dir{self}
+	a, b := &loopDir{}, &loopDir{}
+	a.children = []Dir{NewDirBuilder().AddFile("f", []byte("f")).Build(), b}
+	b.children = []Dir{a}
+	for name, tree := range map[string]Dir{"self": self, "indirect": a} {
+		done := make(chan error, 1)
+		go func() {
+			_, err := NewDagService(NewMemStore()).Add(tree)
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			if !errors.Is(err, ErrCycleDetected) {
+				t.Fatalf("%s: got %v, want ErrCycleDetected", name, err)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("%s: Add did not return", name)
+		}
+	}
+	// 同一个Dir在不同的分支中出现不是环
+	shared := &loopDir{}
+	if _, err := NewDagService(NewMemStore()).Add(&loopDir{children: []Dir{shared, shared}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestComputeRootMatchesAdd(t *testing.T) {
+	files := NewDirBuilder()
+	for i := 0; i < 10; i++ {
+		files.AddFile(fmt.Sprint(i), bytes.Repeat([]byte{byte(i)}, 1500))
+	}
+	tree := NewDirBuilder().AddDir("x", files.Build()).Build()
+	for _, opts := range [][]Option{
+		nil,
+		{WithPersistInternalNodes(true), WithShardThreshold(3), WithConcurrency(4)},
+		{WithCIDKeys(true), WithChunking(true), WithMaxBlockSize(1000), WithShardThreshold(2)},
+	} {
+		st := newCountingStore()
+		s := NewDagService(st, opts...)
+		st.reset()
+		root, err := s.ComputeRoot(tree)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st.puts != 0 {
+			t.Fatalf("ComputeRoot wrote %d blocks", st.puts)
+		}
+		keys, err := st.Keys()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range keys {
+			if key != headerKey {
+				t.Fatalf("ComputeRoot stored %s", key)
+			}
+		}
+		added, err := s.Add(tree)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if root != added {
+			t.Fatalf("ComputeRoot = %s, Add = %s", root, added)
+		}
+	}
+}
+
+// TestConcurrentAddsShareService 需要用-race运行才能发现DagService内部状态上的竞争
+func TestConcurrentAddsShareService(t *testing.T) {
+	// 第i棵树包含前i+1个文件，相邻的树共享大部分数据块
+	tree := func(n int) Dir {
+		b := NewDirBuilder()
+		for i := 0; i < n; i++ {
+			b.AddFile(fmt.Sprint(i), []byte(fmt.Sprint("content ", i)))
+		}
+		return NewDirBuilder().AddDir("d", b.Build()).Build()
+	}
+	want := make([]string, 20)
+	for i := range want {
+		root, err := NewDagService(NewMemStore(), WithShardThreshold(5)).Add(tree(i + 1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want[i] = root
+	}
+	s := NewDagService(NewCachingStore(NewMemStore(), 50),
+		WithConcurrency(4), WithShardThreshold(5), WithVerifyOnGet(true))
+	var wg sync.WaitGroup
+	for g := 0; g < 40; g++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			root, err := s.Add(tree(i + 1))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if root != want[i] {
+				t.Errorf("tree %d: got %s, want %s", i, root, want[i])
+				return
+			}
+			if _, err := s.Get(root); err != nil {
+				t.Error(err)
+			}
+			if _, err := s.Stat(root); err != nil {
+				t.Error(err)
+			}
+		}(g % len(want))
+	}
+	wg.Wait()
+}
+
+func TestAddDuplicateEntryNames(t *testing.T) {
+	// 不经过DirBuilder构造的目录，序列化时才能发现重名
+	d := &dir{entries: []entry{
+		{name: "a.txt", node: NewFile([]byte("1"))},
+		{name: "b", node: NewFile([]byte("b"))},
+		{name: "a.txt", node: NewFile([]byte("2"))},
+	}}
+	var dup *ErrDuplicateEntry
+	if _, err := NewDagService(NewMemStore()).Add(d); !errors.As(err, &dup) || dup.Name != "a.txt" {
+		t.Fatalf("got %v, want ErrDuplicateEntry for a.txt", err)
+	}
+	nested := &dir{entries: []entry{{name: "x", node: d}}}
+	if _, err := NewDagService(NewMemStore(), WithConcurrency(4)).Add(nested); !errors.As(err, &dup) {
+		t.Fatalf("nested: got %v, want ErrDuplicateEntry", err)
+	}
+	s := NewDagService(NewMemStore(), WithLastEntryWins(true))
+	root, err := s.Add(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readPath(t, s, root, "a.txt"); string(got) != "2" {
+		t.Fatalf("last entry wins: got %q, want \"2\"", got)
+	}
+}
+
+func TestAddedSizeCountsOnlyNewBlocks(t *testing.T) {
+	st := NewMemStore()
+	s := NewDagService(st, WithConcurrency(4))
+	old, next := NewDirBuilder(), NewDirBuilder()
+	for i := 0; i < 20; i++ {
+		data := bytes.Repeat([]byte{byte(i)}, 1000)
+		if i < 10 {
+			old.AddFile(fmt.Sprint(i), data)
+		}
+		next.AddFile(fmt.Sprint(i), data)
+	}
+	// 与5内容相同的文件只计算一次
+	next.AddFile("dup", bytes.Repeat([]byte{5}, 1000))
+	if _, err := s.Add(old.Build()); err != nil {
+		t.Fatal(err)
+	}
+	before, err := st.Keys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newBytes, totalBytes, err := s.AddedSize(next.Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 20个文件加上一个目录块，其中前10个文件已经存在
+	if totalBytes-newBytes != 10000 {
+		t.Fatalf("existing bytes %d, want 10000", totalBytes-newBytes)
+	}
+	if totalBytes < 20000 || totalBytes > 22000 || newBytes < 10000 || newBytes > 12000 {
+		t.Fatalf("newBytes %d, totalBytes %d", newBytes, totalBytes)
+	}
+	if after, _ := st.Keys(); len(after) != len(before) {
+		t.Fatalf("AddedSize wrote %d blocks", len(after)-len(before))
+	}
+}
+
+func TestKeyedHasherRejectsOtherKey(t *testing.T) {
+	tree := NewDirBuilder().AddFile("a", []byte("a")).Build()
+	st := NewMemStore()
+	s := NewDagService(st, WithKeyedHasher([]byte("k1")), WithVerifyOnGet(true))
+	root, err := s.Add(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(root); err != nil {
+		t.Fatal(err)
+	}
+	plain, err := NewDagService(NewMemStore()).Add(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain == root {
+		t.Fatal("keyed root equals the unkeyed root")
+	}
+	// 存储头记录了算法，其他密钥打开同一个KVStore时直接报告
+	other := NewDagService(st, WithKeyedHasher([]byte("k2")), WithVerifyOnGet(true))
+	if _, err := other.Get(root); !errors.Is(err, ErrAlgorithmMismatch) {
+		t.Fatalf("got %v, want ErrAlgorithmMismatch", err)
+	}
+	// 没有存储头时，数据块的内容与其他密钥计算的键值不符
+	other = NewDagService(copyStore(t, st), WithKeyedHasher([]byte("k2")), WithVerifyOnGet(true))
+	if _, err := other.Get(root); err == nil {
+		t.Fatal("block verified under a different key")
+	}
+	if report, _ := VerifyStore(other, []string{root}); report.OK() {
+		t.Fatal("VerifyStore accepted blocks under a different key")
+	}
+	proof, err := s.ProveInclusion(root, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.VerifyInclusion(proof.Leaf, proof, root) || other.VerifyInclusion(proof.Leaf, proof, root) {
+		t.Fatal("proof must verify only under the original key")
+	}
+}
+
+func TestRootOfMatchesAdd(t *testing.T) {
+	tree := func() Node {
+		return NewDirBuilder().
+			AddFile("a", bytes.Repeat([]byte("a"), 5000)).
+			AddDir("d", NewDirBuilder().AddFile("b", []byte("b")).Build()).
+			Build()
+	}
+	for _, opts := range [][]Option{
+		nil,
+		{WithHasher(sha512.New), WithDomainSeparation(true), WithFanout(3), WithChunkSize(1000), WithChunking(true)},
+		{WithCIDKeys(true), WithInlineThreshold(4), WithTypePrefix(true), WithNamespace("n"), WithWAL(true)},
+	} {
+		root, err := RootOf(tree(), opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		added, err := NewDagService(NewMemStore(), opts...).Add(tree())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if root != added {
+			t.Fatalf("RootOf = %s, Add = %s", root, added)
+		}
+	}
+}
+
+func TestFileHashMatchesAdd(t *testing.T) {
+	cases := [][]Option{
+		nil,
+		{WithHasher(sha512.New)},
+		{WithDomainSeparation(true)},
+		{WithKeyEncoding(Base32Encoder{})},
+		{WithMaxBlockSize(400), WithChunking(true), WithChunkSize(200)},
+		{WithMaxBlockSize(400)},
+	}
+	files := []File{
+		NewFile([]byte("hello")),
+		NewFile(bytes.Repeat([]byte("a"), 500)),
+		&file{data: []byte("m"), meta: &Metadata{Mode: 0o644}},
+		NewFile([]byte{}),
+	}
+	for _, opts := range cases {
+		for _, f := range files {
+			s := NewDagService(NewMemStore(), opts...)
+			key, err := s.Add(f)
+			hash := FileHash(f, opts...)
+			if err != nil {
+				// 超过WithMaxBlockSize又不切块时Add失败，FileHash返回空字符串
+				if hash != "" {
+					t.Fatalf("FileHash = %q for a file Add rejects", hash)
+				}
+				continue
+			}
+			if hash == "" || hash != s.keyHash(key) {
+				t.Fatalf("FileHash = %q, Add returned %s", hash, key)
+			}
+			if len(opts) == 0 && key[strings.IndexByte(key, '_')+1:] != hash {
+				t.Fatalf("FileHash = %q is not %s without its prefix", hash, key)
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package merkledag
+
+import "sort"
+
+// ChangeKind 是Change的类型
+type ChangeKind int
+
+const (
+	Added ChangeKind = iota
+	Removed
+	Modified
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Modified:
+		return "modified"
+	default:
+		return "unknown"
+	}
+}
+
+// Change 表示两个DAG之间一个路径上的变化
+type Change struct {
+	Path string
+	Kind ChangeKind
+}
+
+// diffPair 是Diff中需要比较的两个节点
+type diffPair struct {
+	path string
+	a, b blockRef
+}
+
+// Diff 比较rootA和rootB，返回从rootA到rootB的变化，按路径排序。
+// 键值相同的子树一定相同，不再读取；新增或删除的目录只报告目录本身，
+// 重命名报告为一次删除和一次新增，类型改变或内容不同的节点报告为Modified
+func Diff(service *DagService, rootA, rootB string) ([]Change, error) {
+	typeA, err := rootType(rootA)
+	if err != nil {
+		return nil, err
+	}
+	typeB, err := rootType(rootB)
+	if err != nil {
+		return nil, err
+	}
+	var changes []Change
+	stack := []diffPair{{
+		a: blockRef{key: rootA, objType: typeA},
+		b: blockRef{key: rootB, objType: typeB},
+	}}
+	for len(stack) > 0 {
+		p := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if p.a.key == p.b.key {
+			continue
+		}
+		if p.a.objType != TREE || p.b.objType != TREE {
+			changes = append(changes, Change{Path: p.path, Kind: Modified})
+			continue
+		}
+		objA, err := service.readDir(p.a.key)
+		if err != nil {
+			return nil, err
+		}
+		objB, err := service.readDir(p.b.key)
+		if err != nil {
+			return nil, err
+		}
+		inB := make(map[string]blockRef, len(objB.Links))
+		for i, link := range objB.Links {
+			inB[link.Name] = blockRef{key: string(link.Hash), objType: objB.linkType(i)}
+		}
+		inA := make(map[string]bool, len(objA.Links))
+		for i, link := range objA.Links {
+			inA[link.Name] = true
+			childPath := joinPath(p.path, link.Name)
+			refB, ok := inB[link.Name]
+			if !ok {
+				changes = append(changes, Change{Path: childPath, Kind: Removed})
+				continue
+			}
+			refA := blockRef{key: string(link.Hash), objType: objA.linkType(i)}
+			stack = append(stack, diffPair{path: childPath, a: refA, b: refB})
+		}
+		for _, link := range objB.Links {
+			if !inA[link.Name] {
+				changes = append(changes, Change{Path: joinPath(p.path, link.Name), Kind: Added})
+			}
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
+
+// joinPath 返回目录dir下名为name的子节点的路径
+func joinPath(dir string, name string) string {
+	if dir == "" {
+		return name
+	}
+	return dir + "/" + name
+}
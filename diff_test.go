@@ -0,0 +1,64 @@
+package merkledag
+
+import "testing"
+
+func TestDiffOneModifiedFile(t *testing.T) {
+	st := newCountingStore()
+	s := NewDagService(st)
+	rootA, err := s.Add(wideTree(10, 10, NewFile([]byte("before"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootB, err := s.Add(wideTree(10, 10, NewFile([]byte("after"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.reset()
+	changes, err := Diff(s, rootA, rootB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0] != (Change{Path: "d5/middle", Kind: Modified}) {
+		t.Fatalf("changes %v", changes)
+	}
+	// 只读取两个根目录和两个不同的子目录，相同的子树被跳过
+	if st.blockGets() != 4 {
+		t.Fatalf("read %d blocks, want 4", st.blockGets())
+	}
+}
+
+func TestDiffAddedRemoved(t *testing.T) {
+	s := NewDagService(NewMemStore())
+	rootA, err := s.Add(NewDirBuilder().
+		AddFile("kept", []byte("k")).
+		AddFile("old", []byte("o")).
+		AddDir("gone", NewDirBuilder().AddFile("x", []byte("x")).Build()).
+		Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootB, err := s.Add(NewDirBuilder().
+		AddFile("kept", []byte("k")).
+		AddFile("new", []byte("o")).
+		AddDir("gone", NewDirBuilder().Build()).
+		Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes, err := Diff(s, rootA, rootB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Change{{"gone/x", Removed}, {"new", Added}, {"old", Removed}}
+	if len(changes) != len(want) {
+		t.Fatalf("changes %v, want %v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Fatalf("changes %v, want %v", changes, want)
+		}
+	}
+	if changes, err := Diff(s, rootA, rootA); len(changes) != 0 || err != nil {
+		t.Fatalf("diff of a root with itself: %v, %v", changes, err)
+	}
+}
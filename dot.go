@@ -0,0 +1,55 @@
+package merkledag
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// dotKeyLen 是DOT中节点标签显示的Merkle Root的长度
+const dotKeyLen = 8
+
+// ToDOT 将root可达的DAG以Graphviz DOT格式写入w。每个数据块是一个节点，
+// 标签为其类型和Merkle Root的前几位；被多处引用的子树只出现一次，
+// 有多条指向它的边，可以直观地看到去重的效果
+func (s *DagService) ToDOT(root string, w io.Writer) error {
+	objType, err := rootType(root)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph dag {")
+	visited := make(map[string]bool)
+	stack := []blockRef{{key: root, objType: objType}}
+	for len(stack) > 0 {
+		ref := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if visited[ref.key] {
+			continue
+		}
+		visited[ref.key] = true
+		fmt.Fprintf(bw, "\t%q [label=%q];\n", ref.key, ref.objType+"\n"+s.shortHash(ref.key))
+		obj, err := s.readLinks(ref)
+		if err != nil {
+			return err
+		}
+		if obj == nil {
+			continue
+		}
+		for i, link := range obj.Links {
+			fmt.Fprintf(bw, "\t%q -> %q [label=%q];\n", ref.key, link.Hash, link.Name)
+			stack = append(stack, blockRef{key: string(link.Hash), objType: obj.linkType(i)})
+		}
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// shortHash 返回键值中Merkle Root的前dotKeyLen位
+func (s *DagService) shortHash(key string) string {
+	hash := s.keyHash(key)
+	if len(hash) > dotKeyLen {
+		return hash[:dotKeyLen]
+	}
+	return hash
+}
@@ -0,0 +1,46 @@
+package merkledag
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestToDOT(t *testing.T) {
+	s := NewDagService(NewMemStore())
+	shared := NewDirBuilder().AddFile("x", []byte("x")).Build()
+	root, err := s.Add(NewDirBuilder().
+		AddDir("a", shared).
+		AddDir("b", shared).
+		AddFile("f.txt", []byte("f")).
+		Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := s.ToDOT(root, &buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.HasPrefix(out, "digraph dag {\n") || !strings.HasSuffix(out, "}\n") {
+		t.Fatalf("not a digraph:\n%s", out)
+	}
+	// 根目录、共享的子目录和两个文件；共享的子目录有两条入边
+	nodes := strings.Count(out, "[label=\"") - strings.Count(out, "->")
+	if nodes != 4 {
+		t.Fatalf("%d nodes, want 4:\n%s", nodes, out)
+	}
+	if edges := strings.Count(out, "->"); edges != 4 {
+		t.Fatalf("%d edges, want 4:\n%s", edges, out)
+	}
+	sharedKey, err := s.Add(shared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if in := strings.Count(out, "-> \""+sharedKey+"\""); in != 2 {
+		t.Fatalf("shared dir has %d incoming edges, want 2", in)
+	}
+	if !strings.Contains(out, s.shortHash(root)) {
+		t.Fatal("root label missing")
+	}
+}
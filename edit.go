@@ -0,0 +1,109 @@
+package merkledag
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// AddEntry 在键值为dirRoot的目录中加入名为name的子节点child，已有同名的目录项时替换它，
+// 返回新目录的键值。只写入child和新的目录块，其余子节点的数据块不变并被新目录直接引用
+func (s *DagService) AddEntry(dirRoot string, name string, child Node) (string, error) {
+	name = s.normalizeName(name)
+	if reason := checkName(name); reason != "" {
+		return "", &ErrInvalidNode{Path: "/", Reason: reason}
+	}
+	entries, meta, err := s.dirEntries(dirRoot)
+	if err != nil {
+		return "", err
+	}
+	key, _, err := s.run(context.Background(), false, func(a *adder) (string, error) {
+		childKey, childHash, err := a.putChild(child, "")
+		if err != nil {
+			return "", err
+		}
+		childType, err := rootType(childKey)
+		if err != nil {
+			return "", err
+		}
+		e := shardEntry{
+			link: Link{Name: name, Hash: []byte(childKey), Size: child.Size()},
+			tag:  childType,
+			hash: childHash,
+		}
+		return a.putEntries(setEntry(entries, e), meta)
+	})
+	return key, err
+}
+
+// setEntry 用e替换entries中同名的目录项，没有时加在最后
+func setEntry(entries []shardEntry, e shardEntry) []shardEntry {
+	for i := range entries {
+		if entries[i].link.Name == e.link.Name {
+			entries[i] = e
+			return entries
+		}
+	}
+	return append(entries, e)
+}
+
+// dirEntries 读取键值为root的目录的全部目录项，分片的目录被展开
+func (s *DagService) dirEntries(root string) ([]shardEntry, *Metadata, error) {
+	objType, err := rootType(root)
+	if err != nil {
+		return nil, nil, err
+	}
+	if objType != TREE {
+		return nil, nil, &ErrNotADirectory{}
+	}
+	obj, err := s.readDir(root)
+	if err != nil {
+		return nil, nil, err
+	}
+	entries := make([]shardEntry, len(obj.Links))
+	for i, link := range obj.Links {
+		entries[i] = shardEntry{link: link, tag: obj.linkType(i), hash: s.keyHash(string(link.Hash))}
+	}
+	return entries, obj.Meta, nil
+}
+
+// putEntries 将目录项按名字排序后保存为带有元数据meta的目录，返回其键值。
+// 得到的键值与Add同样内容的Dir相同
+func (s *adder) putEntries(entries []shardEntry, meta *Metadata) (string, error) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].link.Name < entries[j].link.Name
+	})
+	obj, hashes := shardLeaf(entries)
+	obj.Meta = meta
+	key, _, err := s.putDirObject(&dir{meta: meta}, obj, hashes)
+	return key, err
+}
+
+// RemoveEntry 返回键值为dirRoot的目录去掉名为name的目录项后的新目录的键值，
+// 目录中没有该名字时返回ErrNotFound。原来的数据块不会被删除，不再需要时由GC回收。
+// 去掉最后一个目录项得到的是空目录
+func (s *DagService) RemoveEntry(dirRoot string, name string) (string, error) {
+	name = s.normalizeName(name)
+	if reason := checkName(name); reason != "" {
+		return "", &ErrInvalidNode{Path: "/", Reason: reason}
+	}
+	entries, meta, err := s.dirEntries(dirRoot)
+	if err != nil {
+		return "", err
+	}
+	index := -1
+	for i := range entries {
+		if entries[i].link.Name == name {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return "", fmt.Errorf("%s: %w", name, ErrNotFound)
+	}
+	entries = append(entries[:index], entries[index+1:]...)
+	key, _, err := s.run(context.Background(), false, func(a *adder) (string, error) {
+		return a.putEntries(entries, meta)
+	})
+	return key, err
+}
@@ -0,0 +1,112 @@
+package merkledag
+
+import (
+	"errors"
+	"testing"
+)
+
+// childKeys 返回目录dirRoot中每个名字对应的子节点的键值
+func childKeys(t *testing.T, s *DagService, dirRoot string) map[string]string {
+	t.Helper()
+	entries, err := s.ReadDir(dirRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := make(map[string]string, len(entries))
+	for _, e := range entries {
+		keys[e.Name] = e.Key
+	}
+	return keys
+}
+
+func TestAddEntry(t *testing.T) {
+	for _, threshold := range []int{0, 2} {
+		st := newCountingStore()
+		s := NewDagService(st, WithShardThreshold(threshold))
+		sub := NewDirBuilder().AddFile("x", []byte("x")).Build()
+		root, err := s.Add(NewDirBuilder().AddFile("b", []byte("b")).AddDir("d", sub).Build())
+		if err != nil {
+			t.Fatal(err)
+		}
+		before := childKeys(t, s, root)
+		st.reset()
+		added, err := s.AddEntry(root, "c", NewFile([]byte("c")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 只写入新文件和新的目录块，子目录d没有被读取或重写
+		if st.gets[before["d"]] != 0 {
+			t.Fatalf("threshold %d: unchanged subdirectory was read", threshold)
+		}
+		want, err := s.Add(NewDirBuilder().AddFile("b", []byte("b")).AddFile("c", []byte("c")).AddDir("d", sub).Build())
+		if err != nil || added != want {
+			t.Fatalf("threshold %d: got %s, want %s (%v)", threshold, added, want, err)
+		}
+		after := childKeys(t, s, added)
+		if after["b"] != before["b"] || after["d"] != before["d"] {
+			t.Fatalf("threshold %d: unchanged children changed keys", threshold)
+		}
+		// 同名的目录项被替换
+		replaced, err := s.AddEntry(added, "b", NewFile([]byte("B")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := readPath(t, s, replaced, "b"); string(got) != "B" {
+			t.Fatalf("threshold %d: got %q", threshold, got)
+		}
+	}
+}
+
+func TestAddEntryNotADirectory(t *testing.T) {
+	s := NewDagService(NewMemStore())
+	fileKey, err := s.Add(NewFile([]byte("f")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var notDir *ErrNotADirectory
+	if _, err := s.AddEntry(fileKey, "x", NewFile([]byte("x"))); !errors.As(err, &notDir) {
+		t.Fatalf("got %v, want ErrNotADirectory", err)
+	}
+}
+
+func TestRemoveEntry(t *testing.T) {
+	s := NewDagService(NewMemStore())
+	root, err := s.Add(NewDirBuilder().
+		AddFile("a", []byte("a")).
+		AddFile("b", []byte("b")).
+		AddFile("c", []byte("c")).
+		Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	removed, err := s.RemoveEntry(root, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "c"} {
+		if got := readPath(t, s, removed, name); string(got) != name {
+			t.Fatalf("%s: got %q", name, got)
+		}
+	}
+	if _, err := s.Resolve(removed, "b"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+	// 原来的目录保持不变
+	if got := readPath(t, s, root, "b"); string(got) != "b" {
+		t.Fatalf("old root lost b: %q", got)
+	}
+	if _, err := s.RemoveEntry(root, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+	// 删除最后一个目录项得到空目录
+	last, err := s.RemoveEntry(removed, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last, err = s.RemoveEntry(last, "c"); err != nil {
+		t.Fatal(err)
+	}
+	if last != emptyDirKey(t, s) {
+		t.Fatalf("got %s, want the empty directory", last)
+	}
+}
@@ -0,0 +1,80 @@
+package merkledag
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+)
+
+// EncryptingStore 在写入底层KVStore前用AES-GCM加密数据块，读取时解密。
+// 键值仍是明文内容的哈希，相同的数据依然可以去重
+type EncryptingStore struct {
+	store KVStore
+	aead  cipher.AEAD
+}
+
+// NewEncryptingStore 创建一个使用32字节密钥key（AES-256）加密写入store的EncryptingStore
+func NewEncryptingStore(store KVStore, key []byte) (*EncryptingStore, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &EncryptingStore{store: store, aead: aead}, nil
+}
+
+func (e *EncryptingStore) Has(key string) (bool, error) {
+	return e.store.Has(key)
+}
+
+// Put 加密value后写入，格式为随机nonce加密文。键值作为附加数据参与认证，
+// 数据块被移动到其他键值下时也无法通过解密
+func (e *EncryptingStore) Put(key string, value []byte) error {
+	nonce := make([]byte, e.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	return e.store.Put(key, e.aead.Seal(nonce, nonce, value, []byte(key)))
+}
+
+// Get 读取并解密key对应的数据块，密钥错误或数据被篡改时返回ErrDecryptFailed
+func (e *EncryptingStore) Get(key string) ([]byte, error) {
+	data, err := e.store.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	n := e.aead.NonceSize()
+	if len(data) < n {
+		return nil, fmt.Errorf("%s: %w", key, ErrDecryptFailed)
+	}
+	value, err := e.aead.Open(nil, data[:n], data[n:], []byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", key, ErrDecryptFailed)
+	}
+	return value, nil
+}
+
+func (e *EncryptingStore) Delete(key string) error {
+	return e.store.Delete(key)
+}
+
+// Close 关闭底层KVStore，底层KVStore没有实现io.Closer时直接返回nil
+func (e *EncryptingStore) Close() error {
+	return closeStores(e.store)
+}
+
+// Keys 列出底层KVStore中的所有键值，底层KVStore没有实现Enumerate时返回ErrNotEnumerable
+func (e *EncryptingStore) Keys() ([]string, error) {
+	lister, ok := e.store.(Enumerate)
+	if !ok {
+		return nil, ErrNotEnumerable
+	}
+	return lister.Keys()
+}
@@ -0,0 +1,69 @@
+package merkledag
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestEncryptingStoreReopen(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	backing := NewMemStore()
+	e, err := NewEncryptingStore(backing, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := []byte("top secret contents")
+	root, err := NewDagService(e).Add(NewDirBuilder().AddFile("secret.txt", secret).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileKey, err := NewDagService(NewMemStore()).Add(NewFile(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := backing.Get(fileKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(stored, secret) {
+		t.Fatal("plaintext stored")
+	}
+
+	reopened, err := NewEncryptingStore(backing, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readPath(t, NewDagService(reopened), root, "secret.txt"); !bytes.Equal(got, secret) {
+		t.Fatalf("got %q", got)
+	}
+
+	wrong, err := NewEncryptingStore(backing, bytes.Repeat([]byte{8}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wrong.Get(fileKey); !errors.Is(err, ErrDecryptFailed) {
+		t.Fatalf("wrong key: got %v, want ErrDecryptFailed", err)
+	}
+	tampered := append([]byte(nil), stored...)
+	tampered[len(tampered)-1] ^= 1
+	if err := backing.Put(fileKey, tampered); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reopened.Get(fileKey); !errors.Is(err, ErrDecryptFailed) {
+		t.Fatalf("tampered: got %v, want ErrDecryptFailed", err)
+	}
+	// 数据块被移动到其他键值下时认证失败
+	if err := backing.Put("file_moved", stored); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reopened.Get("file_moved"); !errors.Is(err, ErrDecryptFailed) {
+		t.Fatalf("moved: got %v, want ErrDecryptFailed", err)
+	}
+}
+
+func TestEncryptingStoreKeyLength(t *testing.T) {
+	if _, err := NewEncryptingStore(NewMemStore(), make([]byte, 16)); err == nil {
+		t.Fatal("16-byte key accepted")
+	}
+}
@@ -0,0 +1,178 @@
+package merkledag
+
+import (
+	"bytes"
+	"io"
+)
+
+// equalPair 是Equal中需要比较的两个节点
+type equalPair struct {
+	a, b blockRef
+}
+
+// Equal 判断service中的rootA和rootB是否表示相同的树，见EqualAcross
+func Equal(service *DagService, rootA, rootB string) (bool, error) {
+	return EqualAcross(service, rootA, service, rootB)
+}
+
+// EqualAcross 判断serviceA中的rootA和serviceB中的rootB是否表示相同的树：
+// 目录中的名字相同，对应的文件内容和符号链接的目标相同。元数据、切块方式和哈希函数不参与比较，
+// 因此两个DagService可以使用不同的哈希函数。键值相同的子树一定相同，不再读取
+func EqualAcross(serviceA *DagService, rootA string, serviceB *DagService, rootB string) (bool, error) {
+	typeA, err := rootType(rootA)
+	if err != nil {
+		return false, err
+	}
+	typeB, err := rootType(rootB)
+	if err != nil {
+		return false, err
+	}
+	stack := []equalPair{{
+		a: blockRef{key: rootA, objType: typeA},
+		b: blockRef{key: rootB, objType: typeB},
+	}}
+	for len(stack) > 0 {
+		p := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if p.a.key == p.b.key {
+			continue
+		}
+		if p.a, err = serviceA.snapshotRoot(p.a); err != nil {
+			return false, err
+		}
+		if p.b, err = serviceB.snapshotRoot(p.b); err != nil {
+			return false, err
+		}
+		kindA, kindB := nodeKind(p.a.objType), nodeKind(p.b.objType)
+		if kindA != kindB {
+			return false, nil
+		}
+		switch kindA {
+		case FILE:
+			same, err := sameFile(serviceA, p.a, serviceB, p.b)
+			if err != nil || !same {
+				return false, err
+			}
+		case SYMLINK:
+			targetA, err := serviceA.getBlock(p.a.key)
+			if err != nil {
+				return false, err
+			}
+			targetB, err := serviceB.getBlock(p.b.key)
+			if err != nil {
+				return false, err
+			}
+			if !bytes.Equal(targetA, targetB) {
+				return false, nil
+			}
+		case DIR:
+			objA, err := serviceA.readDir(p.a.key)
+			if err != nil {
+				return false, err
+			}
+			objB, err := serviceB.readDir(p.b.key)
+			if err != nil {
+				return false, err
+			}
+			if len(objA.Links) != len(objB.Links) {
+				return false, nil
+			}
+			inB := make(map[string]blockRef, len(objB.Links))
+			for i, link := range objB.Links {
+				inB[link.Name] = blockRef{key: string(link.Hash), objType: objB.linkType(i)}
+			}
+			for i, link := range objA.Links {
+				refB, ok := inB[link.Name]
+				if !ok {
+					return false, nil
+				}
+				stack = append(stack, equalPair{a: blockRef{key: string(link.Hash), objType: objA.linkType(i)}, b: refB})
+			}
+		default:
+			return false, ErrUnsupportedNodeType
+		}
+	}
+	return true, nil
+}
+
+// snapshotRoot 对快照返回其根节点，其他节点原样返回
+func (s *DagService) snapshotRoot(ref blockRef) (blockRef, error) {
+	if ref.objType != SNAPSHOT {
+		return ref, nil
+	}
+	m, err := s.ReadManifest(ref.key)
+	if err != nil {
+		return blockRef{}, err
+	}
+	objType, err := rootType(m.Root)
+	if err != nil {
+		return blockRef{}, err
+	}
+	return blockRef{key: m.Root, objType: objType}, nil
+}
+
+// treeRoot 对提交返回其指向的树，对快照返回其根节点，其他节点原样返回
+func (s *DagService) treeRoot(ref blockRef) (blockRef, error) {
+	if ref.objType == COMMIT {
+		c, err := s.ReadCommit(ref.key)
+		if err != nil {
+			return blockRef{}, err
+		}
+		objType, err := rootType(c.Tree)
+		if err != nil {
+			return blockRef{}, err
+		}
+		ref = blockRef{key: c.Tree, objType: objType}
+	}
+	return s.snapshotRoot(ref)
+}
+
+// nodeKind 返回类型标记对应的Node类型，无法对应时返回-1
+func nodeKind(objType string) int {
+	switch objType {
+	case BLOB, LIST:
+		return FILE
+	case TREE:
+		return DIR
+	case LINK:
+		return SYMLINK
+	}
+	if typ, _, ok := codecForTag(objType); ok {
+		return int(typ)
+	}
+	return -1
+}
+
+// sameFile 逐段读取并比较两个文件的内容
+func sameFile(serviceA *DagService, a blockRef, serviceB *DagService, b blockRef) (bool, error) {
+	ra, err := serviceA.openFile(a.key, a.objType)
+	if err != nil {
+		return false, err
+	}
+	defer ra.Close()
+	rb, err := serviceB.openFile(b.key, b.objType)
+	if err != nil {
+		return false, err
+	}
+	defer rb.Close()
+	bufA := make([]byte, 32*K)
+	bufB := make([]byte, 32*K)
+	for {
+		n, errA := io.ReadFull(ra, bufA)
+		m, errB := io.ReadFull(rb, bufB)
+		if !bytes.Equal(bufA[:n], bufB[:m]) {
+			return false, nil
+		}
+		endA := errA == io.EOF || errA == io.ErrUnexpectedEOF
+		endB := errB == io.EOF || errB == io.ErrUnexpectedEOF
+		if errA != nil && !endA {
+			return false, errA
+		}
+		if errB != nil && !endB {
+			return false, errB
+		}
+		if endA || endB {
+			return endA == endB, nil
+		}
+	}
+}
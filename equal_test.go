@@ -0,0 +1,76 @@
+package merkledag
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"testing"
+)
+
+func TestEqualSameKey(t *testing.T) {
+	st := newCountingStore()
+	s := NewDagService(st)
+	root, err := s.Add(walkTree())
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.reset()
+	if ok, err := Equal(s, root, root); !ok || err != nil {
+		t.Fatalf("got %v, %v", ok, err)
+	}
+	// 键值相同时不读取任何数据块
+	if st.blockGets() != 0 {
+		t.Fatalf("read %d blocks", st.blockGets())
+	}
+	other, err := s.Add(wideTree(3, 4, NewFile([]byte("changed"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := Equal(s, root, other); ok || err != nil {
+		t.Fatalf("different trees: %v, %v", ok, err)
+	}
+}
+
+func TestEqualAcrossHashers(t *testing.T) {
+	big := chunkedSource(3000)
+	tree := func() Dir {
+		return NewDirBuilder().
+			AddFile("a", []byte("alpha")).
+			add("big", NewChunkedFile(bytes.NewReader(big), int64(len(big)))).
+			AddDir("sub", walkTree()).
+			Build()
+	}
+	a := NewDagService(NewMemStore())
+	b := NewDagService(NewMemStore(), WithHasher(sha512.New), WithChunkSize(700))
+	rootA, err := a.Add(tree())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootB, err := b.Add(tree())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rootA == rootB {
+		t.Fatal("different hashers gave the same root")
+	}
+	if ok, err := EqualAcross(a, rootA, b, rootB); !ok || err != nil {
+		t.Fatalf("got %v, %v", ok, err)
+	}
+	changed, err := b.Add(NewDirBuilder().
+		AddFile("a", []byte("alphA")).
+		add("big", NewChunkedFile(bytes.NewReader(big), int64(len(big)))).
+		AddDir("sub", walkTree()).
+		Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := EqualAcross(a, rootA, b, changed); ok || err != nil {
+		t.Fatalf("changed file: %v, %v", ok, err)
+	}
+	renamed, err := b.Add(NewDirBuilder().AddFile("a", []byte("alpha")).AddDir("sub2", walkTree()).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := EqualAcross(a, rootA, b, renamed); ok || err != nil {
+		t.Fatalf("renamed dir: %v, %v", ok, err)
+	}
+}
@@ -0,0 +1,159 @@
+package merkledag
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrUnsupportedNodeType 表示节点或数据块的类型无法处理
+	ErrUnsupportedNodeType = errors.New("unsupported node type")
+	// ErrEmptyInput 表示计算所需的输入为空
+	ErrEmptyInput = errors.New("empty input")
+	// ErrNotFound 表示KVStore中的键值或目录中的路径不存在
+	ErrNotFound = errors.New("not found")
+	// ErrNotAFile 表示试图读取一个不是文件的节点的内容
+	ErrNotAFile = errors.New("not a file")
+	// ErrDecryptFailed 表示数据块无法解密，密钥错误或数据被篡改
+	ErrDecryptFailed = errors.New("decryption failed")
+	// ErrQuotaExceeded 表示一次写入的数据超过了WithMaxTotalSize指定的上限
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	// ErrCycleDetected 表示内存中的Dir直接或间接地包含了它自己
+	ErrCycleDetected = errors.New("cycle detected")
+	// ErrClosed 表示DagService已经被Close关闭
+	ErrClosed = errors.New("service closed")
+	// ErrInvalidKey 表示作为根节点或数据块传入的键值为空
+	ErrInvalidKey = errors.New("invalid key")
+)
+
+// ErrBlockNotFound 表示KVStore中缺少键值为Key的数据块。
+// Path为该数据块所属节点在树中的路径，无法确定时为空
+type ErrBlockNotFound struct {
+	Key  string
+	Path string
+}
+
+func (e *ErrBlockNotFound) Error() string {
+	if e.Path == "" {
+		return "block not found: " + e.Key
+	}
+	return "block not found: " + e.Key + " at " + e.Path
+}
+
+// Is 使errors.Is(err, ErrNotFound)对缺少的数据块同样成立
+func (e *ErrBlockNotFound) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// ErrCorruptBlock 表示键值为Key的数据块无法解析，Err为解析时的错误
+type ErrCorruptBlock struct {
+	Key string
+	Err error
+}
+
+func (e *ErrCorruptBlock) Error() string {
+	return "corrupt block " + e.Key + ": " + e.Err.Error()
+}
+
+func (e *ErrCorruptBlock) Unwrap() error {
+	return e.Err
+}
+
+// ErrTruncatedBlock 表示键值为Key的数据块在长度前缀或计数所需的字节之前就结束了，
+// 通常是崩溃前只写入了一部分。errors.As也可以把它当作ErrCorruptBlock
+type ErrTruncatedBlock struct {
+	Key string
+}
+
+func (e *ErrTruncatedBlock) Error() string {
+	return "truncated block: " + e.Key
+}
+
+func (e *ErrTruncatedBlock) Unwrap() error {
+	return &ErrCorruptBlock{Key: e.Key, Err: errTruncated}
+}
+
+// errTruncated 是解析数据块时数据不足的错误
+var errTruncated = errors.New("unexpected end of block")
+
+// ErrUnsupportedFormat 表示数据块由更新的、不兼容的格式版本Version写入，当前版本无法正确解析
+type ErrUnsupportedFormat struct {
+	Version FormatVersion
+}
+
+func (e *ErrUnsupportedFormat) Error() string {
+	return fmt.Sprintf("unsupported format version %s, newest supported is %s", e.Version, manifestFormat)
+}
+
+// corruptBlock 返回键值为key的数据块无法解析的错误，数据不足时为ErrTruncatedBlock，
+// 格式版本不受支持时原样返回ErrUnsupportedFormat
+func corruptBlock(key string, err error) error {
+	var unsupported *ErrUnsupportedFormat
+	if errors.As(err, &unsupported) {
+		return err
+	}
+	if errors.Is(err, errTruncated) {
+		return &ErrTruncatedBlock{Key: key}
+	}
+	return &ErrCorruptBlock{Key: key, Err: err}
+}
+
+// ErrNotADirectory 表示试图进入路径Path处一个不是目录的节点
+type ErrNotADirectory struct {
+	Path string
+}
+
+func (e *ErrNotADirectory) Error() string {
+	if e.Path == "" {
+		return "not a directory"
+	}
+	return "not a directory: " + e.Path
+}
+
+// ErrInvalidNode 表示在路径Path处发现了不合法的节点或目录项，Reason说明原因
+type ErrInvalidNode struct {
+	Path   string
+	Reason string
+}
+
+func (e *ErrInvalidNode) Error() string {
+	return "invalid node at " + e.Path + ": " + e.Reason
+}
+
+// ErrCorruptArchive 表示归档中键值为Key的数据块与其内容不符，或者根节点可达的数据块Key不在归档中
+type ErrCorruptArchive struct {
+	Key string
+}
+
+func (e *ErrCorruptArchive) Error() string {
+	return "corrupt archive: block " + e.Key
+}
+
+// ErrBlockTooLarge 表示键值为Key的数据块有Size字节，超过了最大块大小
+type ErrBlockTooLarge struct {
+	Key  string
+	Size int
+}
+
+func (e *ErrBlockTooLarge) Error() string {
+	return fmt.Sprintf("block too large: %s has %d bytes", e.Key, e.Size)
+}
+
+// ErrHashMismatch 表示键值为Key的数据块的内容重新计算出的键值为Got，数据块已损坏
+type ErrHashMismatch struct {
+	Key string
+	Got string
+}
+
+func (e *ErrHashMismatch) Error() string {
+	return "hash mismatch: block " + e.Key + " hashes to " + e.Got
+}
+
+// ErrDuplicateEntry 表示同一个目录中有多个名为Name的子节点
+type ErrDuplicateEntry struct {
+	Name string
+}
+
+func (e *ErrDuplicateEntry) Error() string {
+	return "duplicate entry: " + e.Name
+}
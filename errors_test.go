@@ -0,0 +1,187 @@
+package merkledag
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// unknownNode 是DagService无法识别的节点类型
+type unknownNode struct{}
+
+func (unknownNode) Size() int64 { return 0 }
+func (unknownNode) Type() int   { return 99 }
+
+func TestUnsupportedNodeType(t *testing.T) {
+	s := NewDagService(NewMemStore())
+	for _, node := range []Node{unknownNode{}, NewDirBuilder().add("x", unknownNode{}).Build()} {
+		_, err := s.Add(node)
+		if !errors.Is(err, ErrUnsupportedNodeType) {
+			t.Fatalf("got %v, want ErrUnsupportedNodeType", err)
+		}
+		if !strings.Contains(err.Error(), "unsupported node type") {
+			t.Fatalf("message %q", err)
+		}
+	}
+}
+
+func TestSentinelErrors(t *testing.T) {
+	s := NewDagService(NewMemStore())
+	if _, err := s.calculateMerkleRoot(nil); !errors.Is(err, ErrEmptyInput) {
+		t.Fatalf("got %v, want ErrEmptyInput", err)
+	}
+	root, err := s.Add(NewDirBuilder().AddFile("a.txt", []byte("x")).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Resolve(root, "b.txt"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+	var notDir *ErrNotADirectory
+	if _, err := s.Resolve(root, "a.txt/b"); !errors.As(err, &notDir) || notDir.Path != "/a.txt" {
+		t.Fatalf("got %v, want ErrNotADirectory at /a.txt", err)
+	}
+}
+
+func TestErrorsCarryPath(t *testing.T) {
+	st := NewMemStore()
+	s := NewDagService(st)
+	root, err := s.Add(NewDirBuilder().
+		AddDir("a", NewDirBuilder().AddFile("b", []byte("bb")).AddFile("c", []byte("c")).Build()).
+		Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	missing, err := s.Add(NewFile([]byte("bb")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Delete(missing); err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Get(root)
+	var notFound *ErrBlockNotFound
+	if !errors.As(err, &notFound) || notFound.Key != missing || notFound.Path != "/a/b" {
+		t.Fatalf("got %v, want a missing block at /a/b", err)
+	}
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("%v does not match ErrNotFound", err)
+	}
+	if !strings.Contains(err.Error(), missing) || !strings.Contains(err.Error(), "/a/b") {
+		t.Fatalf("message %q", err)
+	}
+	var notDir *ErrNotADirectory
+	if _, err := s.Resolve(root, "a/c/x"); !errors.As(err, &notDir) || notDir.Path != "/a/c" {
+		t.Fatalf("got %v, want ErrNotADirectory at /a/c", err)
+	}
+	if err := st.Put(root, []byte{0xff, 0xff}); err != nil {
+		t.Fatal(err)
+	}
+	var corrupt *ErrCorruptBlock
+	if _, err := s.Get(root); !errors.As(err, &corrupt) || corrupt.Key != root {
+		t.Fatalf("got %v, want ErrCorruptBlock for the root", err)
+	}
+}
+
+func TestUnknownNodeWritesNothing(t *testing.T) {
+	m := NewMemStore()
+	s := NewDagService(m)
+	if _, err := s.generateKey(unknownNode{}, leafHashes(1)[0]); !errors.Is(err, ErrUnsupportedNodeType) {
+		t.Fatalf("generateKey: got %v, want ErrUnsupportedNodeType", err)
+	}
+	// 两个无法识别的节点不会共享同一个键值而互相覆盖
+	tree := NewDirBuilder().AddFile("a", []byte("a")).add("x", unknownNode{}).add("y", unknownNode{}).Build()
+	if _, err := s.Add(tree); !errors.Is(err, ErrUnsupportedNodeType) {
+		t.Fatalf("Add: got %v, want ErrUnsupportedNodeType", err)
+	}
+	keys, err := m.Keys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range keys {
+		if _, err := rootType(key); err != nil && key != headerKey {
+			t.Fatalf("stored block with invalid key %q", key)
+		}
+	}
+}
+
+func TestEmptyKeyIsInvalid(t *testing.T) {
+	mem := NewMemStore()
+	s := NewDagService(mem)
+	calls := map[string]func() error{
+		"Get":          func() error { _, err := Get(mem, ""); return err },
+		"Resolve":      func() error { _, err := s.Resolve("", "a"); return err },
+		"ResolveEmpty": func() error { _, err := s.Resolve("", ""); return err },
+		"Stat":         func() error { _, err := s.Stat(""); return err },
+		"HasRoot":      func() error { _, err := s.HasRoot(""); return err },
+		"Pin":          func() error { return s.Pin("") },
+		"MightContain": func() error { _, err := s.MightContain("", "x"); return err },
+		"Walk":         func() error { return s.Walk("", func(string, Node) error { return nil }) },
+	}
+	for name, call := range calls {
+		if err := call(); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("%s: got %v, want ErrInvalidKey", name, err)
+		}
+	}
+}
+
+func TestResolveEmptyPathReturnsRoot(t *testing.T) {
+	s := NewDagService(NewMemStore())
+	root, err := s.Add(NewDirBuilder().AddDir("d", NewDirBuilder().AddFile("x", []byte("1")).Build()).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"", "/", ".", "./", "/./"} {
+		n, err := s.Resolve(root, path)
+		if err != nil || n.Type() != DIR {
+			t.Fatalf("Resolve(%q): %v", path, err)
+		}
+		if key, err := RootOf(n); err != nil || key != root {
+			t.Fatalf("Resolve(%q) returned %s, want the root %s", path, key, root)
+		}
+	}
+	if n, err := s.Resolve(root, "./d/./x"); err != nil || string(n.(File).Bytes()) != "1" {
+		t.Fatalf("Resolve with dot segments: %v", err)
+	}
+}
+
+func TestEmptyStore(t *testing.T) {
+	s := NewDagService(NewMemStore())
+	root, err := s.Add(NewDirBuilder().AddDir("d", NewDirBuilder().AddFile("x", []byte("1")).Build()).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob := s.formatKey(BLOB, s.hashBytes([]byte("z")))
+	empty := NewDagService(NewMemStore())
+	calls := map[string]func() error{
+		"GetBlob":      func() error { _, err := empty.Get(blob); return err },
+		"Get":          func() error { _, err := empty.Get(root); return err },
+		"Resolve":      func() error { _, err := empty.Resolve(root, "d"); return err },
+		"Stat":         func() error { _, err := empty.Stat(root); return err },
+		"ReadDir":      func() error { _, err := empty.ReadDir(root); return err },
+		"Walk":         func() error { return empty.Walk(root, func(string, Node) error { return nil }) },
+		"Cat":          func() error { _, err := empty.Cat(root, "d/x"); return err },
+		"Pin":          func() error { return empty.Pin(root) },
+		"MightContain": func() error { _, err := empty.MightContain(root, blob); return err },
+	}
+	for name, call := range calls {
+		if err := call(); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: got %v, want ErrNotFound", name, err)
+		}
+	}
+	if ok, err := empty.HasRoot(root); ok || err != nil {
+		t.Fatalf("HasRoot = %v, %v", ok, err)
+	}
+	if removed, err := empty.GC(); err != nil || removed != 0 {
+		t.Fatalf("GC removed %d, %v", removed, err)
+	}
+	if roots, err := FindRoots(empty); err != nil || len(roots) != 0 {
+		t.Fatalf("FindRoots = %v, %v", roots, err)
+	}
+	if recovered, err := empty.Recover(); recovered != "" || err != nil {
+		t.Fatalf("Recover = %q, %v", recovered, err)
+	}
+	if err := empty.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,55 @@
+package merkledag
+
+import "errors"
+
+// HasRoot 判断root的数据块是否已经保存在KVStore中，不检查其子节点
+func (s *DagService) HasRoot(root string) (bool, error) {
+	if _, err := rootType(root); err != nil {
+		return false, err
+	}
+	if err := s.checkOpen(); err != nil {
+		return false, err
+	}
+	return s.store.Has(root)
+}
+
+// HasComplete 判断root可达的所有数据块是否都保存在KVStore中，遇到第一个缺失的数据块时返回false。
+// 有子节点的数据块需要读取以找到子节点，其余数据块只检查是否存在
+func (s *DagService) HasComplete(root string) (bool, error) {
+	objType, err := rootType(root)
+	if err != nil {
+		return false, err
+	}
+	checked := make(map[string]bool)
+	stack := []blockRef{{key: root, objType: objType}}
+	for len(stack) > 0 {
+		ref := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if checked[ref.key] {
+			continue
+		}
+		checked[ref.key] = true
+		obj, err := s.readLinks(ref)
+		var notFound *ErrBlockNotFound
+		if errors.As(err, &notFound) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if _, ok := inlineData(ref.key); ok {
+			continue
+		}
+		if obj == nil {
+			exists, err := s.store.Has(ref.key)
+			if err != nil || !exists {
+				return false, err
+			}
+			continue
+		}
+		for i, link := range obj.Links {
+			stack = append(stack, blockRef{key: string(link.Hash), objType: obj.linkType(i)})
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,61 @@
+package merkledag
+
+import "testing"
+
+func TestHasComplete(t *testing.T) {
+	st := NewMemStore()
+	s := NewDagService(st)
+	root, err := s.Add(walkTree())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := s.HasRoot(root); !ok || err != nil {
+		t.Fatalf("HasRoot: %v, %v", ok, err)
+	}
+	if ok, err := s.HasComplete(root); !ok || err != nil {
+		t.Fatalf("HasComplete: %v, %v", ok, err)
+	}
+	// 删除一个子目录的数据块，根节点仍然存在
+	obj, err := s.readObject(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Delete(string(obj.Links[1].Hash)); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := s.HasRoot(root); !ok || err != nil {
+		t.Fatalf("HasRoot after delete: %v, %v", ok, err)
+	}
+	if ok, err := s.HasComplete(root); ok || err != nil {
+		t.Fatalf("HasComplete after delete: %v, %v", ok, err)
+	}
+}
+
+func TestHasCompleteMissingFile(t *testing.T) {
+	st := NewMemStore()
+	s := NewDagService(st)
+	root, err := s.Add(walkTree())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileKey, err := s.Add(NewFile([]byte("2/3")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Delete(fileKey); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := s.HasComplete(root); ok || err != nil {
+		t.Fatalf("got %v, %v", ok, err)
+	}
+	other, err := NewDagService(NewMemStore()).Add(NewFile([]byte("elsewhere")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := s.HasRoot(other); ok || err != nil {
+		t.Fatalf("HasRoot of a missing root: %v, %v", ok, err)
+	}
+	if ok, err := s.HasComplete(other); ok || err != nil {
+		t.Fatalf("HasComplete of a missing root: %v, %v", ok, err)
+	}
+}
@@ -0,0 +1,166 @@
+package merkledag
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ExportOption 用于配置ExportPath
+type ExportOption func(*exporter)
+
+// WithOverwrite 指定导出时是否覆盖已经存在的文件，默认不覆盖
+func WithOverwrite(overwrite bool) ExportOption {
+	return func(exp *exporter) {
+		exp.overwrite = overwrite
+	}
+}
+
+// WithFileName 指定root为文件时导出的文件名，默认使用root的键值
+func WithFileName(name string) ExportOption {
+	return func(exp *exporter) {
+		exp.fileName = name
+	}
+}
+
+// exporter 保存一次ExportPath调用中的配置
+type exporter struct {
+	service   *DagService
+	overwrite bool
+	fileName  string
+	// symlinks 记录本次导出创建的符号链接，之后不再写入这些路径
+	symlinks map[string]bool
+}
+
+// ExportPath 将root对应的DAG还原到文件系统的destDir中。root为目录时，
+// 其中的内容写入destDir；root为文件时，写入destDir下的一个文件；root为快照或提交时导出其指向的树。
+// 目录项的名字不能通过checkName，或者要写入本次导出创建的符号链接时返回ErrInvalidNode，不会写到destDir之外
+func ExportPath(service *DagService, root string, destDir string, opts ...ExportOption) error {
+	exp := &exporter{service: service, fileName: root, symlinks: make(map[string]bool)}
+	for _, opt := range opts {
+		opt(exp)
+	}
+	objType, err := rootType(root)
+	if err != nil {
+		return err
+	}
+	ref, err := service.treeRoot(blockRef{key: root, objType: objType})
+	if err != nil {
+		return err
+	}
+	if ref.objType == TREE {
+		return exp.writeDir(ref.key, destDir)
+	}
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return err
+	}
+	return exp.writeChild(ref.key, ref.objType, filepath.Join(destDir, exp.fileName))
+}
+
+// writeDir 创建目录path，并写入键值为key的目录中的所有子节点
+func (exp *exporter) writeDir(key string, path string) error {
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return err
+	}
+	obj, err := exp.service.readObject(key)
+	if err != nil {
+		return err
+	}
+	if obj, err = exp.service.expandShards(obj); err != nil {
+		return err
+	}
+	for i, link := range obj.Links {
+		// 存储中的目录可能来自ImportArchive等不经过Add的途径，导出前再检查一次名字
+		if reason := checkName(link.Name); reason != "" {
+			return &ErrInvalidNode{Path: path, Reason: reason}
+		}
+		err = exp.writeChild(string(link.Hash), obj.linkType(i), filepath.Join(path, link.Name))
+		if err != nil {
+			return err
+		}
+	}
+	// 子节点写完后再恢复元数据，否则写入会改变目录的修改时间
+	return restoreMetadata(path, obj.Meta)
+}
+
+// writeChild 根据类型标记将键值为key的节点写入path。名字中没有路径分隔符，
+// path的上级目录都由writeDir创建，只需检查path本身是不是本次导出创建的符号链接
+func (exp *exporter) writeChild(key string, objType string, path string) error {
+	if exp.symlinks[path] {
+		return &ErrInvalidNode{Path: path, Reason: "path is a symlink written by this export"}
+	}
+	switch objType {
+	case TREE:
+		return exp.writeDir(key, path)
+	case LINK:
+		return exp.writeSymlink(key, path)
+	default:
+		return exp.writeFile(key, objType, path)
+	}
+}
+
+// writeSymlink 在path处创建键值为key的符号链接
+func (exp *exporter) writeSymlink(key string, path string) error {
+	target, err := exp.service.getBlock(key)
+	if err != nil {
+		return err
+	}
+	if exp.overwrite {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Symlink(string(target), path); err != nil {
+		return err
+	}
+	exp.symlinks[path] = true
+	return nil
+}
+
+// writeFile 将键值为key的文件的内容写入path
+func (exp *exporter) writeFile(key string, objType string, path string) error {
+	r, err := exp.service.openFile(key, objType)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	flag := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if exp.overwrite {
+		flag = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	f, err := os.OpenFile(path, flag, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if objType != LIST {
+		return nil
+	}
+	obj, err := exp.service.readObject(key)
+	if err != nil {
+		return err
+	}
+	return restoreMetadata(path, obj.Meta)
+}
+
+// restoreMetadata 恢复path的扩展属性、权限和修改时间，meta为nil时不做任何修改。
+// 扩展属性在修改权限之前写入，只读的文件也能写入
+func restoreMetadata(path string, meta *Metadata) error {
+	if meta == nil {
+		return nil
+	}
+	if err := writeXattrs(path, meta.Xattrs); err != nil {
+		return err
+	}
+	if err := os.Chmod(path, meta.Mode); err != nil {
+		return err
+	}
+	return os.Chtimes(path, meta.ModTime, meta.ModTime)
+}
@@ -0,0 +1,249 @@
+package merkledag
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// putRawDir 不经过Add的检查，直接保存由names和children组成的目录，模拟从归档等途径得到的目录块
+func putRawDir(t *testing.T, s *DagService, names []string, children []Node) string {
+	t.Helper()
+	key, _, err := s.run(context.Background(), false, func(a *adder) (string, error) {
+		obj := &Object{}
+		var hashes []string
+		for i, name := range names {
+			key, hash, err := a.put(children[i])
+			if err != nil {
+				return "", err
+			}
+			childType, err := rootType(key)
+			if err != nil {
+				return "", err
+			}
+			obj.Links = append(obj.Links, Link{Name: name, Hash: []byte(key), Size: children[i].Size()})
+			obj.Data = append(obj.Data, childType...)
+			hashes = append(hashes, hash)
+		}
+		key, _, err := a.putDirObject(NewDirBuilder().Build(), obj, hashes)
+		return key, err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestAddRejectsUnsafeNames(t *testing.T) {
+	for _, name := range []string{"", ".", "..", "../escaped.txt", "a/b", `a\b`, "/etc/passwd"} {
+		s := NewDagService(NewMemStore())
+		_, err := s.Add(NewDirBuilder().AddFile(name, []byte("x")).Build())
+		var invalid *ErrInvalidNode
+		if !errors.As(err, &invalid) {
+			t.Errorf("Add(%q): got %v, want ErrInvalidNode", name, err)
+		}
+		if _, err := s.AddEntry(emptyDirKey(t, s), name, NewFile([]byte("x"))); !errors.As(err, &invalid) {
+			t.Errorf("AddEntry(%q): got %v, want ErrInvalidNode", name, err)
+		}
+	}
+}
+
+func emptyDirKey(t *testing.T, s *DagService) string {
+	t.Helper()
+	key, err := s.Add(NewDirBuilder().Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestExportRejectsTraversal(t *testing.T) {
+	s := NewDagService(NewMemStore())
+	root := putRawDir(t, s, []string{"../escaped.txt"}, []Node{NewFile([]byte("x"))})
+	base := t.TempDir()
+	dest := filepath.Join(base, "out")
+	err := ExportPath(s, root, dest)
+	var invalid *ErrInvalidNode
+	if !errors.As(err, &invalid) {
+		t.Fatalf("got %v, want ErrInvalidNode", err)
+	}
+	if _, err := os.Lstat(filepath.Join(base, "escaped.txt")); !os.IsNotExist(err) {
+		t.Fatalf("file written outside destDir: %v", err)
+	}
+}
+
+func TestExportDoesNotWriteThroughSymlink(t *testing.T) {
+	s := NewDagService(NewMemStore())
+	outside := t.TempDir()
+	sub := NewDirBuilder().AddFile("owned.txt", []byte("x")).Build()
+	// 符号链接与子目录同名，按顺序导出时子目录的内容会经符号链接写到outside中
+	root := putRawDir(t, s, []string{"a", "a"}, []Node{&symlink{target: outside}, sub})
+	for _, overwrite := range []bool{false, true} {
+		err := ExportPath(s, root, t.TempDir(), WithOverwrite(overwrite))
+		var invalid *ErrInvalidNode
+		if !errors.As(err, &invalid) {
+			t.Fatalf("overwrite=%v: got %v, want ErrInvalidNode", overwrite, err)
+		}
+		if _, err := os.Lstat(filepath.Join(outside, "owned.txt")); !os.IsNotExist(err) {
+			t.Fatalf("overwrite=%v: file written through symlink: %v", overwrite, err)
+		}
+	}
+}
+
+func TestExportRoundTrip(t *testing.T) {
+	s := NewDagService(NewMemStore())
+	tree := NewDirBuilder().
+		AddFile("a.txt", []byte("hello")).
+		AddDir("sub", NewDirBuilder().AddFile("b.txt", []byte("world")).Build()).
+		Build()
+	root, err := s.Add(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dest := t.TempDir()
+	if err := ExportPath(s, root, dest); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(filepath.Join(dest, "sub", "b.txt"))
+	if err != nil || string(got) != "world" {
+		t.Fatalf("got %q, %v", got, err)
+	}
+	got, err = os.ReadFile(filepath.Join(dest, "a.txt"))
+	if err != nil || string(got) != "hello" {
+		t.Fatalf("got %q, %v", got, err)
+	}
+}
+
+func TestExportSnapshotAndCommit(t *testing.T) {
+	s := NewDagService(NewMemStore())
+	tree := NewDirBuilder().AddFile("a.txt", []byte("hello")).Build()
+	snapshot, err := s.AddSnapshot(tree, "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, err := s.Commit(snapshot, "", "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 快照和提交都导出其指向的树
+	for _, root := range []string{snapshot, commit} {
+		dest := t.TempDir()
+		if err := ExportPath(s, root, dest); err != nil {
+			t.Fatalf("%s: %v", root, err)
+		}
+		got, err := os.ReadFile(filepath.Join(dest, "a.txt"))
+		if err != nil || string(got) != "hello" {
+			t.Fatalf("%s: got %q, %v", root, got, err)
+		}
+	}
+}
+
+// diffDirs 比较两个目录下的文件和目录是否完全相同，返回第一个不同之处
+func diffDirs(t *testing.T, a, b string) string {
+	t.Helper()
+	seen := make(map[string]bool)
+	err := filepath.WalkDir(a, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(a, path)
+		if err != nil {
+			return err
+		}
+		seen[rel] = true
+		other := filepath.Join(b, rel)
+		info, err := os.Stat(other)
+		if err != nil {
+			return fmt.Errorf("%s: %w", rel, err)
+		}
+		if info.IsDir() != d.IsDir() {
+			return fmt.Errorf("%s: directory in only one tree", rel)
+		}
+		if d.IsDir() {
+			return nil
+		}
+		want, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		got, err := os.ReadFile(other)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(got, want) {
+			return fmt.Errorf("%s: contents differ", rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return err.Error()
+	}
+	err = filepath.WalkDir(b, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(b, path)
+		if err != nil {
+			return err
+		}
+		if !seen[rel] {
+			return fmt.Errorf("%s: only in %s", rel, b)
+		}
+		return nil
+	})
+	if err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+func TestImportExportIdentical(t *testing.T) {
+	src := t.TempDir()
+	writeTestTree(t, src)
+	s := NewDagService(NewMemStore())
+	root, err := ImportPath(s, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dest := filepath.Join(t.TempDir(), "out")
+	if err := ExportPath(s, root, dest); err != nil {
+		t.Fatal(err)
+	}
+	if diff := diffDirs(t, src, dest); diff != "" {
+		t.Fatal(diff)
+	}
+	// 已经存在的文件默认不覆盖
+	if err := ExportPath(s, root, dest); err == nil {
+		t.Fatal("export overwrote existing files")
+	}
+	if err := os.WriteFile(filepath.Join(dest, "a", "x.txt"), []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ExportPath(s, root, dest, WithOverwrite(true)); err != nil {
+		t.Fatal(err)
+	}
+	if diff := diffDirs(t, src, dest); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestExportSingleFile(t *testing.T) {
+	s := NewDagService(NewMemStore())
+	root, err := s.Add(NewFile([]byte("single")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dest := t.TempDir()
+	if err := ExportPath(s, root, dest, WithFileName("named.txt")); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(filepath.Join(dest, "named.txt"))
+	if err != nil || string(got) != "single" {
+		t.Fatalf("got %q, %v", got, err)
+	}
+}
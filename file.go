@@ -3,10 +3,13 @@ package merkledag
 const (
 	FILE = iota
 	DIR
+	SYMLINK
+	// PLACEHOLDER 是GetShallow中没有读取的子树，见Placeholder
+	PLACEHOLDER
 )
 
 type Node interface {
-	Size() uint64
+	Size() int64
 	Type() int
 }
 
@@ -19,11 +22,21 @@ type File interface {
 type Dir interface {
 	Node
 
+	// It 每次调用都返回一个从第一个子节点开始的新迭代器，
+	// 因此同一个Dir可以被多次遍历
 	It() DirIterator
 }
 
+type Symlink interface {
+	Node
+
+	Target() string
+}
+
 type DirIterator interface {
 	Next() bool
 
+	// Name 返回当前子节点在目录中的名字，名字与子节点的键值一起序列化，因此也参与Merkle Root的计算
+	Name() string
 	Node() Node
 }
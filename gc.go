@@ -0,0 +1,153 @@
+package merkledag
+
+import (
+	"errors"
+	"strings"
+)
+
+// pinPrefix 是记录固定的根节点的键值前缀
+const pinPrefix = "pin_"
+
+// ErrNotEnumerable 表示KVStore没有实现Enumerate，无法列出所有键值
+var ErrNotEnumerable = errors.New("store cannot enumerate keys")
+
+// Pin 将root记录为固定的根节点，GC时root可达的数据块都会被保留
+func (s *DagService) Pin(root string) error {
+	if _, err := rootType(root); err != nil {
+		return err
+	}
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
+	exists, err := s.store.Has(root)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return &ErrBlockNotFound{Key: root}
+	}
+	return s.store.Put(pinPrefix+root, nil)
+}
+
+// Unpin 取消root的固定，root的数据块在下次GC时可能被删除
+func (s *DagService) Unpin(root string) error {
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
+	return s.store.Delete(pinPrefix + root)
+}
+
+// PinPath 固定root下path处的子树：GC时即使root没有被固定，子树的数据块也会被保留。
+// 固定记录的是子树的键值，内容相同的子树共享同一个固定记录。内嵌在目录中的文件没有自己的数据块，无需固定
+func (s *DagService) PinPath(root string, path string) error {
+	key, _, err := s.resolveKey(root, path)
+	if err != nil {
+		return err
+	}
+	if _, ok := inlineData(key); ok {
+		return nil
+	}
+	return s.Pin(key)
+}
+
+// UnpinPath 取消PinPath对root下path处的子树的固定。路径上的目录块需要仍然存在，
+// root已经被GC删除时，用Unpin取消子树的键值的固定
+func (s *DagService) UnpinPath(root string, path string) error {
+	key, _, err := s.resolveKey(root, path)
+	if err != nil {
+		return err
+	}
+	if _, ok := inlineData(key); ok {
+		return nil
+	}
+	return s.Unpin(key)
+}
+
+// GC 标记所有固定的根节点可达的数据块，然后删除其余的数据块，返回删除的数量。
+// KVStore需要实现Enumerate
+func (s *DagService) GC() (int, error) {
+	if err := s.checkOpen(); err != nil {
+		return 0, err
+	}
+	lister, ok := s.store.(Enumerate)
+	if !ok {
+		return 0, ErrNotEnumerable
+	}
+	keys, err := lister.Keys()
+	if err != nil {
+		return 0, err
+	}
+
+	marked := make(map[string]bool)
+	for _, key := range keys {
+		if root, ok := strings.CutPrefix(key, pinPrefix); ok {
+			if err := s.markReachable(root, marked); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	removed := 0
+	for _, key := range keys {
+		// 根节点被删除时一起删除它的Bloom过滤器，提交被删除时一起删除其截断标记
+		root, ok := strings.CutPrefix(key, bloomPrefix)
+		if !ok {
+			root, ok = strings.CutPrefix(key, shallowPrefix)
+		}
+		if ok && !marked[root] {
+			if err := s.store.Delete(key); err != nil {
+				return removed, err
+			}
+			continue
+		}
+		// 只删除数据块，固定记录等其他键值保持不变
+		if _, err := rootType(key); err != nil || marked[key] {
+			continue
+		}
+		if err := s.store.Delete(key); err != nil {
+			return removed, err
+		}
+		s.logger.Debugf("gc: delete %s", key)
+		removed++
+	}
+	s.logger.Infof("gc: kept %d blocks, removed %d", len(marked), removed)
+	return removed, nil
+}
+
+// markReachable 将root可达的所有数据块的键值加入marked，已标记的子树不再重复读取
+func (s *DagService) markReachable(root string, marked map[string]bool) error {
+	objType, err := rootType(root)
+	if err != nil {
+		return err
+	}
+	stack := []blockRef{{key: root, objType: objType}}
+	for len(stack) > 0 {
+		ref := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if marked[ref.key] {
+			continue
+		}
+		marked[ref.key] = true
+		// 内部节点不被任何数据块链接，从数据块的Merkle Root向下查找
+		if s.persistInternal && ref.objType != BLOB && ref.objType != LINK {
+			keys, err := s.internalNodeKeys(s.keyHash(ref.key))
+			if err != nil {
+				return err
+			}
+			for _, key := range keys {
+				marked[key] = true
+			}
+		}
+		obj, err := s.readLinks(ref)
+		if err != nil {
+			return err
+		}
+		if obj == nil {
+			continue
+		}
+		for i, link := range obj.Links {
+			stack = append(stack, blockRef{key: string(link.Hash), objType: obj.linkType(i)})
+		}
+	}
+	return nil
+}
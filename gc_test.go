@@ -0,0 +1,148 @@
+package merkledag
+
+import (
+	"errors"
+	"testing"
+)
+
+// overlappingTrees 保存两棵共享子目录shared的树，返回两个根节点的键值
+func overlappingTrees(t *testing.T, s *DagService) (string, string) {
+	t.Helper()
+	shared := NewDirBuilder().AddFile("x", []byte("x")).AddFile("y", []byte("y")).Build()
+	r1, err := s.Add(NewDirBuilder().AddDir("shared", shared).AddFile("only", []byte("only1")).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r2, err := s.Add(NewDirBuilder().AddDir("shared", shared).AddFile("only", []byte("only2")).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r1, r2
+}
+
+func TestGCKeepsPinnedTrees(t *testing.T) {
+	st := NewMemStore()
+	s := NewDagService(st)
+	r1, r2 := overlappingTrees(t, s)
+	only2, err := s.Add(NewFile([]byte("only2")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Pin(r1); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Pin(r2); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Unpin(r2); err != nil {
+		t.Fatal(err)
+	}
+	removed, err := s.GC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 只有r2和它独有的文件
+	if removed != 2 {
+		t.Fatalf("removed %d blocks, want 2", removed)
+	}
+	for _, key := range []string{r2, only2} {
+		if ok, _ := st.Has(key); ok {
+			t.Fatalf("%s survived GC", key)
+		}
+	}
+	n, err := s.Get(r1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, msg := sameTree(n, NewDirBuilder().
+		AddFile("only", []byte("only1")).
+		AddDir("shared", NewDirBuilder().AddFile("x", []byte("x")).AddFile("y", []byte("y")).Build()).
+		Build()); !ok {
+		t.Fatal(msg)
+	}
+	// 再次GC没有可删除的数据块
+	if removed, err := s.GC(); removed != 0 || err != nil {
+		t.Fatalf("second GC removed %d, %v", removed, err)
+	}
+}
+
+func TestPinMissingRoot(t *testing.T) {
+	s := NewDagService(NewMemStore())
+	root, err := NewDagService(NewMemStore()).Add(NewFile([]byte("elsewhere")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var missing *ErrBlockNotFound
+	if err := s.Pin(root); !errors.As(err, &missing) {
+		t.Fatalf("got %v, want ErrBlockNotFound", err)
+	}
+}
+
+func TestPinPathKeepsSubtree(t *testing.T) {
+	st := NewMemStore()
+	s := NewDagService(st)
+	keep := NewDirBuilder().
+		AddFile("k", []byte("kk")).
+		AddDir("in", NewDirBuilder().AddFile("z", []byte("z")).Build()).
+		Build()
+	root, err := s.Add(NewDirBuilder().
+		AddFile("top", []byte("t")).
+		AddDir("keep", keep).
+		AddDir("drop", NewDirBuilder().AddFile("d", []byte("dd")).Build()).
+		Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Pin(root); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PinPath(root, "keep"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Unpin(root); err != nil {
+		t.Fatal(err)
+	}
+	keepKey, _, err := s.resolveKey(root, "keep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 根目录、top、drop和drop/d被删除
+	removed, err := s.GC()
+	if err != nil || removed != 4 {
+		t.Fatalf("GC removed %d, %v; want 4", removed, err)
+	}
+	if ok, err := s.HasComplete(keepKey); !ok || err != nil {
+		t.Fatalf("pinned subtree incomplete: %v, %v", ok, err)
+	}
+	if ok, _ := st.Has(root); ok {
+		t.Fatal("unpinned root survived GC")
+	}
+	// 根节点已经被删除，不能再按路径取消固定
+	if err := s.UnpinPath(root, "keep"); err == nil {
+		t.Fatal("UnpinPath resolved through a collected root")
+	}
+	if err := s.Unpin(keepKey); err != nil {
+		t.Fatal(err)
+	}
+	// keep、k、in和in/z
+	if removed, err := s.GC(); err != nil || removed != 4 {
+		t.Fatalf("GC removed %d, %v; want 4", removed, err)
+	}
+}
+
+func TestUnpinPath(t *testing.T) {
+	s := NewDagService(NewMemStore())
+	root, err := s.Add(NewDirBuilder().AddDir("a", NewDirBuilder().AddFile("x", []byte("x")).Build()).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PinPath(root, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UnpinPath(root, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if removed, err := s.GC(); err != nil || removed != 3 {
+		t.Fatalf("GC removed %d, %v; want 3", removed, err)
+	}
+}
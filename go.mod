@@ -1,3 +1,11 @@
 module merkle-dag
 
 go 1.22.0
+
+require (
+	go.etcd.io/bbolt v1.3.11
+	golang.org/x/crypto v0.6.0
+	golang.org/x/sync v0.5.0
+	golang.org/x/sys v0.5.0
+	golang.org/x/text v0.14.0
+)
@@ -0,0 +1,130 @@
+package merkledag
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// headerKey 是记录store所用哈希函数和键值编码的头部的键值，它不是数据块，GC不会删除它
+const headerKey = "header_store"
+
+// ErrAlgorithmMismatch 表示store中的数据块使用的哈希函数或键值编码与DagService配置的不同
+var ErrAlgorithmMismatch = errors.New("store algorithm mismatch")
+
+// storeHeader 是store的头部，在第一次写入数据块时保存
+type storeHeader struct {
+	HashAlgorithm string
+	KeyEncoding   string
+}
+
+// keyEncoding 返回DagService的键值编码的名字。无法识别的KeyEncoder由对固定摘要的编码结果标识
+func (s *DagService) keyEncoding() string {
+	if s.cidKeys {
+		return "cid"
+	}
+	switch s.keyEncoder.(type) {
+	case HexEncoder:
+		return "hex"
+	case Base32Encoder:
+		return "base32"
+	case Base58Encoder:
+		return "base58"
+	}
+	return "unknown-" + s.keyEncoder.Encode([]byte("merkledag"))
+}
+
+func (s *DagService) header() storeHeader {
+	return storeHeader{HashAlgorithm: s.hashAlgorithm(), KeyEncoding: s.keyEncoding()}
+}
+
+// Validate 检查store的头部记录的哈希函数和键值编码与DagService的配置相同，不同时返回
+// ErrAlgorithmMismatch。store还没有头部时返回nil。第一次读取或写入数据块时也会进行同样的检查，
+// 打开一个已有的store后调用Validate可以尽早发现配置不同
+func (s *DagService) Validate() error {
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
+	data, err := s.store.Get(headerKey)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.checkHeader(data); err != nil {
+		return err
+	}
+	s.headerChecked.Store(true)
+	return nil
+}
+
+// checkHeaderOnRead 在第一次读取数据块前调用，用Validate检查store的头部，之后的读取直接返回同样的结果。
+// 已经检查或写入过头部时不再读取它
+func (s *DagService) checkHeaderOnRead() error {
+	if s.headerChecked.Load() {
+		return nil
+	}
+	s.readCheck.Do(func() {
+		s.readCheckErr = s.Validate()
+	})
+	return s.readCheckErr
+}
+
+// ensureHeader 在第一次写入数据块前调用：store已有头部时检查它，否则写入当前配置的头部。
+// batch不为nil时头部和数据块一起提交，提交之前不能确定头部已经写入，下次写入时会再检查。
+// 每个DagService只检查一次，并发的写入可能重复写入相同的头部
+func (s *DagService) ensureHeader(batch Batch) error {
+	if s.headerChecked.Load() {
+		return nil
+	}
+	data, err := s.store.Get(headerKey)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		if batch != nil {
+			return batch.Put(headerKey, encodeHeader(s.header()))
+		}
+		if err := s.store.Put(headerKey, encodeHeader(s.header())); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	default:
+		if err := s.checkHeader(data); err != nil {
+			return err
+		}
+	}
+	s.headerChecked.Store(true)
+	return nil
+}
+
+func (s *DagService) checkHeader(data []byte) error {
+	h, err := decodeHeader(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", headerKey, err)
+	}
+	if want := s.header(); h != want {
+		return fmt.Errorf("store uses %s/%s, service uses %s/%s: %w",
+			h.HashAlgorithm, h.KeyEncoding, want.HashAlgorithm, want.KeyEncoding, ErrAlgorithmMismatch)
+	}
+	return nil
+}
+
+// encodeHeader 依次写入哈希函数和键值编码的名字，字符串前都写入其长度
+func encodeHeader(h storeHeader) []byte {
+	buf := binary.AppendUvarint(nil, uint64(len(h.HashAlgorithm)))
+	buf = append(buf, h.HashAlgorithm...)
+	buf = binary.AppendUvarint(buf, uint64(len(h.KeyEncoding)))
+	buf = append(buf, h.KeyEncoding...)
+	return buf
+}
+
+func decodeHeader(data []byte) (storeHeader, error) {
+	r := &blockReader{data: data}
+	alg := r.bytes()
+	enc := r.bytes()
+	if r.err != nil || len(r.data) != 0 {
+		return storeHeader{}, errMalformedObject
+	}
+	return storeHeader{HashAlgorithm: string(alg), KeyEncoding: string(enc)}, nil
+}
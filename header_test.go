@@ -0,0 +1,95 @@
+package merkledag
+
+import (
+	"crypto/sha512"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// headerReadStore 记录头部被读取的次数
+type headerReadStore struct {
+	*MemStore
+	headerGets atomic.Int64
+}
+
+func (h *headerReadStore) Get(key string) ([]byte, error) {
+	if key == headerKey {
+		h.headerGets.Add(1)
+	}
+	return h.MemStore.Get(key)
+}
+
+func TestHeaderMismatch(t *testing.T) {
+	st := NewMemStore()
+	if _, err := NewDagService(st).Add(NewDirBuilder().AddFile("a", []byte("a")).Build()); err != nil {
+		t.Fatal(err)
+	}
+	other := NewDagService(st, WithHasher(sha512.New))
+	if err := other.Validate(); !errors.Is(err, ErrAlgorithmMismatch) {
+		t.Fatalf("Validate: got %v, want ErrAlgorithmMismatch", err)
+	}
+	if _, err := other.Add(NewFile([]byte("b"))); !errors.Is(err, ErrAlgorithmMismatch) {
+		t.Fatalf("Add: got %v, want ErrAlgorithmMismatch", err)
+	}
+	if err := NewDagService(st).Validate(); err != nil {
+		t.Fatalf("same configuration: %v", err)
+	}
+}
+
+func TestHeaderCheckedOnFirstGet(t *testing.T) {
+	st := NewMemStore()
+	root, err := NewDagService(st).Add(NewDirBuilder().AddFile("a", []byte("a")).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 没有调用Validate，第一次Get就发现配置不同，并发的Get得到同样的错误
+	other := NewDagService(st, WithHasher(sha512.New))
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = other.Get(root)
+		}()
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if !errors.Is(err, ErrAlgorithmMismatch) {
+			t.Fatalf("Get %d: got %v, want ErrAlgorithmMismatch", i, err)
+		}
+	}
+	st2 := &headerReadStore{MemStore: NewMemStore()}
+	s := NewDagService(st2)
+	root, err = s.Add(NewDirBuilder().AddFile("a", []byte("a")).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 新的DagService只在第一次读取时读取头部
+	st2.headerGets.Store(0)
+	fresh := NewDagService(st2)
+	for i := 0; i < 3; i++ {
+		if _, err := fresh.Get(root); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := st2.headerGets.Load(); n != 1 {
+		t.Fatalf("header read %d times, want 1", n)
+	}
+}
+
+func TestHeaderMissingAllowsReads(t *testing.T) {
+	st := NewMemStore()
+	root, err := NewDagService(st).Add(NewFile([]byte("content")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Delete(headerKey); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewDagService(st).Get(root); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,280 @@
+package merkledag
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ImportOption 用于配置ImportPath
+type ImportOption func(*importer)
+
+// WithFollowSymlinks 指定导入时是否跟随符号链接，不跟随时符号链接本身作为Symlink保存
+func WithFollowSymlinks(follow bool) ImportOption {
+	return func(imp *importer) {
+		imp.followSymlinks = follow
+	}
+}
+
+// importer 保存一次ImportPath调用中的状态
+type importer struct {
+	service        *DagService
+	followSymlinks bool
+	// prev 为ReImportPath中上次导入的文件的路径到其键值的映射
+	prev map[string]string
+	// resume 在设置了WithResumeState时记录导入的进度
+	resume *resumeState
+
+	mu  sync.Mutex
+	err error
+	// open 是打开后尚未读完的文件
+	open map[*os.File]bool
+}
+
+// ImportPath 将文件系统中fsPath处的文件或目录Add到service中，返回根节点的键值。
+// 大于块大小的文件作为ChunkedFile边读边保存，空目录也会得到一个合法的目录节点
+func ImportPath(service *DagService, fsPath string, opts ...ImportOption) (string, error) {
+	imp := &importer{service: service, open: make(map[*os.File]bool)}
+	for _, opt := range opts {
+		opt(imp)
+	}
+	defer imp.closeAll()
+
+	if imp.resume != nil {
+		if err := imp.resume.load(); err != nil {
+			return "", err
+		}
+		// 上次记录的文件与ReImportPath的文件一样按大小和元数据判断能否复用
+		prev := make(map[string]string, len(imp.prev)+len(imp.resume.done))
+		for path, key := range imp.prev {
+			prev[path] = key
+		}
+		for path, key := range imp.resume.done {
+			prev[path] = key
+		}
+		imp.prev = prev
+	}
+
+	info, err := os.Stat(fsPath)
+	if err != nil {
+		return "", err
+	}
+	node := imp.node(fsPath, info)
+	root, _, err := service.run(context.Background(), false, func(a *adder) (string, error) {
+		if imp.resume != nil {
+			a.onStored = imp.resume.stored
+		}
+		key, _, err := a.put(node)
+		return key, err
+	})
+	if imp.resume != nil {
+		// 失败时同样保存，下次导入从这里继续
+		if saveErr := imp.resume.save(); err == nil {
+			err = saveErr
+		}
+	}
+	if err := imp.firstErr(); err != nil {
+		return "", err
+	}
+	return root, err
+}
+
+// node 根据文件信息创建对应的Node，文件的权限、修改时间、属主和扩展属性作为元数据保存
+func (imp *importer) node(path string, info os.FileInfo) Node {
+	meta, err := imp.metadata(path, info)
+	if err != nil {
+		imp.fail(err)
+	}
+	if info.IsDir() {
+		return &fsDir{imp: imp, path: path, meta: meta}
+	}
+	var n Node
+	if reused, ok := imp.reuse(path, info); ok {
+		n = reused
+	} else if info.Size() > int64(imp.service.chunkSize) {
+		f := NewChunkedFile(&fsReader{imp: imp, path: path}, info.Size())
+		f.SetMetadata(meta)
+		n = f
+	} else {
+		n = &fsFile{imp: imp, path: path, size: info.Size(), meta: meta}
+	}
+	if imp.resume != nil {
+		imp.resume.track(path, n)
+	}
+	return n
+}
+
+// metadata 读取path处的文件的元数据，包括其扩展属性
+func (imp *importer) metadata(path string, info os.FileInfo) (*Metadata, error) {
+	meta := fileMetadata(info)
+	xattrs, err := readXattrs(path)
+	if err != nil {
+		return meta, err
+	}
+	meta.Xattrs = xattrs
+	return meta, nil
+}
+
+// fail 记录导入过程中遇到的第一个错误。Node的接口无法返回错误，
+// 因此读取文件系统的错误在Add结束后由ImportPath返回
+func (imp *importer) fail(err error) {
+	imp.mu.Lock()
+	defer imp.mu.Unlock()
+	if imp.err == nil {
+		imp.err = err
+	}
+}
+
+func (imp *importer) firstErr() error {
+	imp.mu.Lock()
+	defer imp.mu.Unlock()
+	return imp.err
+}
+
+func (imp *importer) closeAll() {
+	imp.mu.Lock()
+	defer imp.mu.Unlock()
+	for f := range imp.open {
+		f.Close()
+	}
+	imp.open = nil
+}
+
+// fsFile 是文件系统中的小文件，内容在Bytes时才读取
+type fsFile struct {
+	imp  *importer
+	path string
+	size int64
+	meta *Metadata
+}
+
+func (f *fsFile) Size() int64 {
+	return f.size
+}
+
+func (f *fsFile) Type() int {
+	return FILE
+}
+
+func (f *fsFile) Metadata() *Metadata {
+	return f.meta
+}
+
+func (f *fsFile) Bytes() []byte {
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		f.imp.fail(err)
+	}
+	return data
+}
+
+// fsReader 在第一次Read时打开文件，读完后关闭
+type fsReader struct {
+	imp  *importer
+	path string
+	f    *os.File
+	done bool
+}
+
+func (r *fsReader) Read(p []byte) (int, error) {
+	if r.done {
+		return 0, io.EOF
+	}
+	if r.f == nil {
+		f, err := os.Open(r.path)
+		if err != nil {
+			r.imp.fail(err)
+			return 0, err
+		}
+		r.imp.mu.Lock()
+		r.imp.open[f] = true
+		r.imp.mu.Unlock()
+		r.f = f
+	}
+	n, err := r.f.Read(p)
+	if err != nil {
+		r.done = true
+		r.imp.mu.Lock()
+		delete(r.imp.open, r.f)
+		r.imp.mu.Unlock()
+		r.f.Close()
+		if err != io.EOF {
+			r.imp.fail(err)
+		}
+	}
+	return n, err
+}
+
+// fsDir 是文件系统中的目录，子节点在第一次使用时才读取
+type fsDir struct {
+	imp     *importer
+	path    string
+	meta    *Metadata
+	entries []entry
+	listed  bool
+	size    int64
+	sized   bool
+}
+
+func (d *fsDir) list() []entry {
+	if d.listed {
+		return d.entries
+	}
+	d.listed = true
+	dirEntries, err := os.ReadDir(d.path)
+	if err != nil {
+		d.imp.fail(err)
+		return nil
+	}
+	for _, de := range dirEntries {
+		path := filepath.Join(d.path, de.Name())
+		var info os.FileInfo
+		if de.Type()&os.ModeSymlink != 0 {
+			if !d.imp.followSymlinks {
+				target, err := os.Readlink(path)
+				if err != nil {
+					d.imp.fail(err)
+					return nil
+				}
+				d.entries = append(d.entries, entry{name: de.Name(), node: &symlink{target: target}})
+				continue
+			}
+			info, err = os.Stat(path)
+		} else {
+			info, err = de.Info()
+		}
+		if err != nil {
+			d.imp.fail(err)
+			return nil
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			continue
+		}
+		d.entries = append(d.entries, entry{name: de.Name(), node: d.imp.node(path, info)})
+	}
+	return d.entries
+}
+
+func (d *fsDir) Size() int64 {
+	if !d.sized {
+		for _, e := range d.list() {
+			d.size += e.node.Size()
+		}
+		d.sized = true
+	}
+	return d.size
+}
+
+func (d *fsDir) Type() int {
+	return DIR
+}
+
+func (d *fsDir) Metadata() *Metadata {
+	return d.meta
+}
+
+func (d *fsDir) It() DirIterator {
+	return &dirIterator{entries: d.list(), cur: -1}
+}
@@ -0,0 +1,110 @@
+package merkledag
+
+import (
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestTree 在dir下创建一棵包含嵌套目录、空目录和跨多个块的大文件的树
+func writeTestTree(t *testing.T, dir string) {
+	t.Helper()
+	big := make([]byte, 700*K+3)
+	rand.New(rand.NewSource(1)).Read(big)
+	files := map[string][]byte{
+		"a/x.txt":   []byte("hello"),
+		"a/b/big":   big,
+		"a/b/empty": {},
+		"top.txt":   []byte("top level"),
+	}
+	for path, data := range files {
+		full := filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "empty"), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImportPathSizes(t *testing.T) {
+	dir := t.TempDir()
+	writeTestTree(t, dir)
+	s := NewDagService(NewMemStore())
+	root, err := ImportPath(s, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 每个目录的大小为其下所有文件大小之和
+	sizes := make(map[string]int64)
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			// 空目录也要检查，大小为0
+			sizes[path] += 0
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		for p := path; p != dir; {
+			p = filepath.Dir(p)
+			sizes[p] += info.Size()
+		}
+		sizes[path] = info.Size()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range sizes {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := s.Resolve(root, filepath.ToSlash(rel))
+		if err != nil {
+			t.Fatalf("%s: %v", rel, err)
+		}
+		if n.Size() != want {
+			t.Errorf("%s: size %d, want %d", rel, n.Size(), want)
+		}
+	}
+	n, err := s.Resolve(root, "empty")
+	if err != nil || n.Type() != DIR {
+		t.Fatalf("empty dir: %v, %v", n, err)
+	}
+}
+
+func TestImportPathSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "x.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("x.txt", filepath.Join(dir, "link")); err != nil {
+		t.Skip(err)
+	}
+	s := NewDagService(NewMemStore())
+	for _, c := range []struct {
+		follow bool
+		want   int
+	}{{false, SYMLINK}, {true, FILE}} {
+		root, err := ImportPath(s, dir, WithFollowSymlinks(c.follow))
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := s.Resolve(root, "link")
+		if err != nil || n.Type() != c.want {
+			t.Fatalf("follow=%v: got %v, %v", c.follow, n, err)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package merkledag
+
+import (
+	"encoding/base64"
+	"strings"
+)
+
+// inlinePrefix 是内嵌文件的键值前缀。内嵌文件没有自己的数据块，
+// 键值中直接保存其内容，随父目录的数据块一起序列化
+const inlinePrefix = "inline_"
+
+// inlineKey 返回内容为data的内嵌文件的键值
+func inlineKey(data []byte) string {
+	return inlinePrefix + base64.RawURLEncoding.EncodeToString(data)
+}
+
+// inlineData 返回内嵌文件的键值中保存的内容，key不是内嵌文件时ok为false
+func inlineData(key string) (data []byte, ok bool) {
+	encoded, ok := strings.CutPrefix(key, inlinePrefix)
+	if !ok {
+		return nil, false
+	}
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	return data, err == nil
+}
+
+// putChild 保存目录中path处的子节点。设置了WithInlineThreshold时，小于阈值且没有元数据的文件
+// 不写入KVStore，返回包含其内容的键值；Merkle Root与单独保存时相同
+func (s *adder) putChild(node Node, path string) (string, string, error) {
+	if _, ok := node.(Dir); ok {
+		return s.put(node)
+	}
+	f, ok := node.(File)
+	if _, chunked := node.(*ChunkedFile); chunked || !ok || node.Type() != FILE || s.inlineThreshold <= 0 || metadataOf(node) != nil {
+		return s.putFile(node, path)
+	}
+	data := f.Bytes()
+	if len(data) >= s.inlineThreshold {
+		return s.putFile(node, path)
+	}
+	if err := s.ctx.Err(); err != nil {
+		return "", "", err
+	}
+	s.progress.add(path, int64(len(data)), 1)
+	merkleRoot, err := s.treeRoot([]string{s.hashBytes(data)})
+	if err != nil {
+		return "", "", err
+	}
+	return inlineKey(data), merkleRoot, nil
+}
+
+// readBlock 直接从KVStore中读取key对应的数据块的内容，内嵌文件返回键值中的内容
+func (s *DagService) readBlock(key string) ([]byte, error) {
+	if data, ok := inlineData(key); ok {
+		return data, nil
+	}
+	if err := s.checkOpen(); err != nil {
+		return nil, err
+	}
+	data, err := s.store.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return s.decodeStored(key, data)
+}
@@ -0,0 +1,67 @@
+package merkledag
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func TestInlineSmallFiles(t *testing.T) {
+	s := NewDagService(NewMemStore(), WithInlineThreshold(64))
+	b := NewDirBuilder()
+	for i := 0; i < 50; i++ {
+		b.AddFile(fmt.Sprintf("f%02d", i), []byte(fmt.Sprint("tiny ", i)))
+	}
+	b.AddFile("big", bytes.Repeat([]byte("x"), 100))
+	b.AddDir("sub", NewDirBuilder().AddFile("a", []byte("aa")).Build())
+	tree := b.Build()
+	root, keys, err := s.AddWithKeys(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 只有超过阈值的big单独保存，其余只有两个目录块
+	files := 0
+	for _, key := range keys {
+		if typ, _ := rootType(key); typ == BLOB {
+			files++
+		}
+	}
+	if files != 1 || len(keys) != 3 {
+		t.Fatalf("wrote %v, want two directories and one file", keys)
+	}
+	node, err := s.Resolve(root, "f07")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(node.(File).Bytes()); got != "tiny 7" {
+		t.Fatalf("Resolve f07: got %q", got)
+	}
+	rc, err := s.Cat(root, "sub/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(rc)
+	if err != nil || string(got) != "aa" {
+		t.Fatalf("Cat sub/a: got %q, %v", got, err)
+	}
+	if ok, err := s.HasComplete(root); !ok || err != nil {
+		t.Fatalf("HasComplete: %v, %v", ok, err)
+	}
+	// 内嵌的文件仍有自己的哈希，与不内嵌时得到的树相等，并且可以证明包含关系
+	plain := NewDagService(NewMemStore())
+	plainRoot, err := plain.Add(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := EqualAcross(s, root, plain, plainRoot); !ok || err != nil {
+		t.Fatalf("EqualAcross: %v, %v", ok, err)
+	}
+	proof, err := s.ProveInclusion(root, "f03")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.VerifyInclusion(proof.Leaf, proof, root) {
+		t.Fatal("proof for inline file rejected")
+	}
+}
@@ -0,0 +1,83 @@
+package merkledag
+
+import (
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// KeyEncoder 将Merkle Root的摘要编码为键值中的字符串，Decode必须能还原Encode的结果
+type KeyEncoder interface {
+	Encode(digest []byte) string
+	Decode(s string) ([]byte, error)
+}
+
+// HexEncoder 是默认的KeyEncoder，使用小写十六进制
+type HexEncoder struct{}
+
+func (HexEncoder) Encode(digest []byte) string {
+	return hex.EncodeToString(digest)
+}
+
+func (HexEncoder) Decode(s string) ([]byte, error) {
+	return hex.DecodeString(s)
+}
+
+// Base32Encoder 使用小写、无填充的base32，适合不区分大小写的文件系统
+type Base32Encoder struct{}
+
+func (Base32Encoder) Encode(digest []byte) string {
+	return cidEncoding.EncodeToString(digest)
+}
+
+func (Base32Encoder) Decode(s string) ([]byte, error) {
+	return cidEncoding.DecodeString(s)
+}
+
+// Base58Encoder 使用Bitcoin和IPFS中的base58字母表
+type Base58Encoder struct{}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+var errInvalidBase58 = errors.New("invalid base58 string")
+
+func (Base58Encoder) Encode(digest []byte) string {
+	n := new(big.Int).SetBytes(digest)
+	radix := big.NewInt(58)
+	mod := new(big.Int)
+	var out []byte
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, mod)
+		out = append(out, base58Alphabet[mod.Int64()])
+	}
+	// 每个前导的0字节编码为一个'1'
+	for _, b := range digest {
+		if b != 0 {
+			break
+		}
+		out = append(out, base58Alphabet[0])
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
+
+func (Base58Encoder) Decode(s string) ([]byte, error) {
+	n := new(big.Int)
+	radix := big.NewInt(58)
+	zeros := 0
+	for zeros < len(s) && s[zeros] == base58Alphabet[0] {
+		zeros++
+	}
+	for i := 0; i < len(s); i++ {
+		digit := strings.IndexByte(base58Alphabet, s[i])
+		if digit < 0 {
+			return nil, errInvalidBase58
+		}
+		n.Mul(n, radix)
+		n.Add(n, big.NewInt(int64(digit)))
+	}
+	return append(make([]byte, zeros), n.Bytes()...), nil
+}
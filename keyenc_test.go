@@ -0,0 +1,59 @@
+package merkledag
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"strings"
+	"testing"
+)
+
+func TestKeyEncodings(t *testing.T) {
+	tree := NewDirBuilder().
+		AddFile("a.txt", []byte("alpha")).
+		AddDir("sub", NewDirBuilder().AddFile("b.txt", []byte("beta")).Build()).
+		Build()
+	want := sha256.Sum256([]byte("alpha"))
+	encoders := map[string]KeyEncoder{"hex": HexEncoder{}, "base32": Base32Encoder{}, "base58": Base58Encoder{}}
+	for name, enc := range encoders {
+		s := NewDagService(NewMemStore(), WithKeyEncoding(enc))
+		root, err := s.Add(tree)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := s.Get(root)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if ok, msg := sameTree(n, tree); !ok {
+			t.Fatalf("%s: %s", name, msg)
+		}
+		fileKey, err := s.Add(NewFile([]byte("alpha")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoded := strings.TrimPrefix(fileKey, "file_")
+		digest, err := enc.Decode(encoded)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !bytes.Equal(digest, want[:]) {
+			t.Fatalf("%s: digest %x, want %x", name, digest, want)
+		}
+		if enc.Encode(digest) != encoded {
+			t.Fatalf("%s: re-encoding gives %s, want %s", name, enc.Encode(digest), encoded)
+		}
+	}
+}
+
+func TestBase58LeadingZeros(t *testing.T) {
+	enc := Base58Encoder{}
+	for _, digest := range [][]byte{{}, {0}, {0, 0, 1}, {0, 255, 0}, bytes.Repeat([]byte{255}, 32)} {
+		got, err := enc.Decode(enc.Encode(digest))
+		if err != nil || !bytes.Equal(got, digest) {
+			t.Fatalf("%x: got %x, %v", digest, got, err)
+		}
+	}
+	if _, err := enc.Decode("0OIl"); err == nil {
+		t.Fatal("invalid base58 accepted")
+	}
+}
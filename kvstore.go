@@ -1,8 +1,46 @@
 package merkledag
 
+// KVStore 是保存数据块的存储器。
+// Get在key不存在时必须返回ErrNotFound（可以被包装），以便调用方区分缺失的数据块和IO错误。
+// Put返回后调用方可能修改value，需要在返回后继续持有value的实现必须复制它
 type KVStore interface {
-	Has(key []byte) (bool, error)
-	Put(key, value []byte) error
-	Get(key []byte) ([]byte, error)
-	Delete(key []byte) error
+	Has(key string) (bool, error)
+	Put(key string, value []byte) error
+	Get(key string) ([]byte, error)
+	Delete(key string) error
+}
+
+// NoCopyPutter 是可以接管value的KVStore。调用PutNoCopy之后调用方不再读写value，
+// 实现可以直接保存它而不复制。Add只对刚编码出的、不会再使用的缓冲区调用PutNoCopy
+type NoCopyPutter interface {
+	PutNoCopy(key string, value []byte) error
+}
+
+// BatchStore 是支持批量写入的KVStore。Add会把所有数据块写入同一个Batch，
+// 最后只提交一次
+type BatchStore interface {
+	Begin() Batch
+}
+
+// Batch 收集一组写入，Commit时一起写入KVStore，Commit之前的写入对Get不可见。
+// 调用方保证不会并发调用Put，且在Commit之前不修改value
+type Batch interface {
+	Put(key string, value []byte) error
+	Commit() error
+}
+
+// MultiGetter 是可以一次读取多个数据块的KVStore，适合每次请求开销较大的远程存储。
+// 不存在的键值不出现在返回的映射中，只有IO错误时返回error
+type MultiGetter interface {
+	GetMany(keys []string) (map[string][]byte, error)
+}
+
+// Flusher 是缓存了尚未写出的数据的KVStore，DagService.Close在关闭前调用Flush
+type Flusher interface {
+	Flush() error
+}
+
+// Enumerate 是可以列出所有键值的KVStore，GC等需要遍历整个存储的操作依赖它
+type Enumerate interface {
+	Keys() ([]string, error)
 }
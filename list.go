@@ -0,0 +1,92 @@
+package merkledag
+
+// FileEntry 是ListFiles返回的一个文件
+type FileEntry struct {
+	Path string
+	Size int64
+	Key  string
+}
+
+// listFrame 是ListFiles中正在列出的目录
+type listFrame struct {
+	path string
+	obj  *Object
+	next int
+}
+
+// ListFiles 返回root下所有文件和符号链接的路径、大小和键值，路径以"/"分隔。
+// 只读取目录块，大小取自目录中的链接，不读取文件的内容。root本身是文件时返回路径为空的一项
+func (s *DagService) ListFiles(root string) ([]FileEntry, error) {
+	key, objType, err := s.resolveKey(root, "")
+	if err != nil {
+		return nil, err
+	}
+	if objType != TREE {
+		st, err := s.Stat(key)
+		if err != nil {
+			return nil, err
+		}
+		return []FileEntry{{Size: st.Size, Key: key}}, nil
+	}
+	obj, err := s.readDir(key)
+	if err != nil {
+		return nil, err
+	}
+	var files []FileEntry
+	stack := []*listFrame{{obj: obj}}
+	for len(stack) > 0 {
+		top := stack[len(stack)-1]
+		if top.next >= len(top.obj.Links) {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		i := top.next
+		top.next++
+		link := top.obj.Links[i]
+		path := joinPath(top.path, link.Name)
+		if top.obj.linkType(i) != TREE {
+			files = append(files, FileEntry{Path: path, Size: link.Size, Key: string(link.Hash)})
+			continue
+		}
+		obj, err := s.readDir(string(link.Hash))
+		if err != nil {
+			return nil, err
+		}
+		stack = append(stack, &listFrame{path: path, obj: obj})
+	}
+	return files, nil
+}
+
+// Entry 是ReadDir返回的一个目录项，Type为FILE、DIR或SYMLINK，Size取自目录中的链接
+type Entry struct {
+	Name string
+	Key  string
+	Type int
+	Size int64
+}
+
+// ReadDir 返回目录dirRoot的直接子节点，按名字的字节序排列，与保存时的顺序相同。
+// 只读取目录块（分片的目录还需要读取其分片），不读取子节点。dirRoot不是目录时返回ErrNotADirectory
+func (s *DagService) ReadDir(dirRoot string) ([]Entry, error) {
+	key, objType, err := s.resolveKey(dirRoot, "")
+	if err != nil {
+		return nil, err
+	}
+	if objType != TREE {
+		return nil, &ErrNotADirectory{Path: "/"}
+	}
+	obj, err := s.readDir(key)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, 0, len(obj.Links))
+	for i, link := range obj.Links {
+		entries = append(entries, Entry{
+			Name: link.Name,
+			Key:  string(link.Hash),
+			Type: nodeKind(obj.linkType(i)),
+			Size: link.Size,
+		})
+	}
+	return entries, nil
+}
@@ -0,0 +1,89 @@
+package merkledag
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestListFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeTestTree(t, dir)
+	st := newCountingStore()
+	s := NewDagService(st)
+	root, err := ImportPath(s, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.reset()
+	files, err := s.ListFiles(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []FileEntry{
+		{Path: "a/b/big", Size: 700*K + 3},
+		{Path: "a/b/empty", Size: 0},
+		{Path: "a/x.txt", Size: 5},
+		{Path: "top.txt", Size: 9},
+	}
+	// 只读取了根目录和a、a/b、empty三个子目录
+	if st.blockGets() != 4 {
+		t.Fatalf("read %d blocks, want 4", st.blockGets())
+	}
+	if len(files) != len(want) {
+		t.Fatalf("got %+v", files)
+	}
+	for i, f := range files {
+		if f.Path != want[i].Path || f.Size != want[i].Size {
+			t.Errorf("file %d = %+v, want %+v", i, f, want[i])
+		}
+		key, _, err := s.resolveKey(root, f.Path)
+		if err != nil || key != f.Key {
+			t.Errorf("%s: key %s, resolved %s (%v)", f.Path, f.Key, key, err)
+		}
+	}
+}
+
+func TestReadDir(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithShardThreshold(3)}} {
+		st := newCountingStore()
+		s := NewDagService(st, opts...)
+		b := NewDirBuilder().
+			AddFile("b", []byte("bb")).
+			AddDir("a", NewDirBuilder().AddFile("x", []byte("xyz")).Build())
+		for i := 0; i < 6; i++ {
+			b.AddFile(fmt.Sprint("f", i), make([]byte, i))
+		}
+		root, err := s.Add(b.Build())
+		if err != nil {
+			t.Fatal(err)
+		}
+		st.reset()
+		entries, err := s.ReadDir(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []Entry{{Name: "a", Type: DIR, Size: 3}, {Name: "b", Type: FILE, Size: 2}}
+		for i := 0; i < 6; i++ {
+			want = append(want, Entry{Name: fmt.Sprint("f", i), Type: FILE, Size: int64(i)})
+		}
+		if len(entries) != len(want) {
+			t.Fatalf("got %d entries, want %d", len(entries), len(want))
+		}
+		for i, e := range entries {
+			if e.Name != want[i].Name || e.Type != want[i].Type || e.Size != want[i].Size {
+				t.Fatalf("entry %d = %+v, want %+v", i, e, want[i])
+			}
+		}
+		// 不读取子节点的数据块
+		for _, e := range entries {
+			if st.gets[e.Key] != 0 {
+				t.Fatalf("ReadDir read child %s", e.Name)
+			}
+		}
+		var notDir *ErrNotADirectory
+		if _, err := s.ReadDir(entries[1].Key); !errors.As(err, &notDir) {
+			t.Fatalf("got %v, want ErrNotADirectory", err)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package merkledag
+
+// Logger 接收DagService的调试日志，可以接入任意日志库。实现需要可以被多个goroutine同时调用
+type Logger interface {
+	Debugf(format string, args ...any)
+	Infof(format string, args ...any)
+	Warnf(format string, args ...any)
+}
+
+// NopLogger 忽略所有日志，是没有设置WithLogger时的默认值
+type NopLogger struct{}
+
+func (NopLogger) Debugf(string, ...any) {}
+func (NopLogger) Infof(string, ...any)  {}
+func (NopLogger) Warnf(string, ...any)  {}
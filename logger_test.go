@@ -0,0 +1,77 @@
+package merkledag
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// capturingLogger 记录所有日志，每行以级别开头
+type capturingLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *capturingLogger) add(level string, format string, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, level+" "+fmt.Sprintf(format, args...))
+}
+
+func (l *capturingLogger) Debugf(format string, args ...any) { l.add("DEBUG", format, args...) }
+func (l *capturingLogger) Infof(format string, args ...any)  { l.add("INFO", format, args...) }
+func (l *capturingLogger) Warnf(format string, args ...any)  { l.add("WARN", format, args...) }
+
+// count 返回以prefix开头的日志的行数
+func (l *capturingLogger) count(prefix string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := 0
+	for _, line := range l.lines {
+		if strings.HasPrefix(line, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestLoggerReportsDedupSkips(t *testing.T) {
+	log := &capturingLogger{}
+	st := NewMemStore()
+	s := NewDagService(st, WithLogger(log), WithVerifyOnGet(true), WithConcurrency(3))
+	tree := func() Node {
+		return NewDirBuilder().
+			AddFile("a", []byte("a")).
+			AddDir("d", NewDirBuilder().AddFile("b", []byte("b")).Build()).
+			Build()
+	}
+	root, err := s.Add(tree())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if puts, skips := log.count("DEBUG put "), log.count("DEBUG skip existing"); puts != 4 || skips != 0 {
+		t.Fatalf("first Add: %d puts, %d skips; want 4 and 0", puts, skips)
+	}
+	if _, err := s.Add(tree()); err != nil {
+		t.Fatal(err)
+	}
+	if skips := log.count("DEBUG skip existing"); skips != 4 {
+		t.Fatalf("second Add: %d skips, want 4", skips)
+	}
+	if err := st.Put(root, []byte("junk")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(root); err == nil {
+		t.Fatal("corrupt block read")
+	}
+	if n := log.count("WARN verify "); n != 1 {
+		t.Fatalf("%d verification warnings, want 1", n)
+	}
+	if _, err := s.GC(); err != nil {
+		t.Fatal(err)
+	}
+	if sweeps, deletes := log.count("INFO gc:"), log.count("DEBUG gc: delete"); sweeps != 1 || deletes != 4 {
+		t.Fatalf("GC: %d sweeps, %d deletes; want 1 and 4", sweeps, deletes)
+	}
+}
@@ -0,0 +1,197 @@
+package merkledag
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"time"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// ErrHashAlgorithmMismatch 表示快照使用的哈希函数与DagService配置的不同
+var ErrHashAlgorithmMismatch = errors.New("hash algorithm mismatch")
+
+// Manifest 是快照的清单，记录快照的名字、创建时间、使用的哈希函数和其包含的DAG的根节点。
+// Format为写入清单时的格式版本，AddSnapshot总是使用manifestFormat
+type Manifest struct {
+	Name          string
+	Created       time.Time
+	HashAlgorithm string
+	Root          string
+	Format        FormatVersion
+}
+
+// FormatVersion 是数据块的格式版本。主版本不同的格式互不兼容；
+// 次版本更新时只在末尾追加字段，旧的实现跳过不认识的部分仍然可以读取
+type FormatVersion struct {
+	Major uint64
+	Minor uint64
+}
+
+func (v FormatVersion) String() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// manifestFormat 是当前写入的清单的格式版本。没有记录版本的清单为1.0
+var manifestFormat = FormatVersion{Major: 1, Minor: 1}
+
+// knownHashes 是detectHashAlgorithm能够识别名字的哈希函数
+var knownHashes = []struct {
+	name string
+	new  func() hash.Hash
+}{
+	{"sha256", sha256.New},
+	{"sha224", sha256.New224},
+	{"sha512", sha512.New},
+	{"sha384", sha512.New384},
+	{"sha1", sha1.New},
+	{"md5", md5.New},
+	{"blake2b-256", newBlake2b256},
+	{"blake2b-512", newBlake2b512},
+}
+
+// newBlake2b256 和newBlake2b512 返回不带密钥的BLAKE2b，不带密钥时不会出错
+func newBlake2b256() hash.Hash {
+	h, _ := blake2b.New256(nil)
+	return h
+}
+
+func newBlake2b512() hash.Hash {
+	h, _ := blake2b.New512(nil)
+	return h
+}
+
+// hashAlgorithm 返回DagService的哈希函数的名字
+func (s *DagService) hashAlgorithm() string {
+	return s.hashName
+}
+
+// detectHashAlgorithm 识别DagService的哈希函数。哈希函数无法直接比较，
+// 因此比较对固定内容计算出的摘要；无法识别时返回由摘要得到的标识
+func (s *DagService) detectHashAlgorithm() string {
+	probe := []byte("merkledag")
+	got := s.hashBytes(probe)
+	for _, known := range knownHashes {
+		h := known.new()
+		h.Write(probe)
+		if hex.EncodeToString(h.Sum(nil)) == got {
+			return known.name
+		}
+	}
+	return "unknown-" + got[:16]
+}
+
+// AddSnapshot 保存node，再保存一个指向它的快照清单，返回清单的键值作为快照的标识。
+// 清单的Merkle Root由node的Merkle Root和清单本身的哈希计算
+func (s *DagService) AddSnapshot(node Node, name string) (string, error) {
+	root, err := s.Add(node)
+	if err != nil {
+		return "", err
+	}
+	m := &Manifest{
+		Name:          name,
+		Created:       time.Now(),
+		HashAlgorithm: s.hashAlgorithm(),
+		Root:          root,
+		Format:        manifestFormat,
+	}
+	data := encodeManifest(m)
+	merkleRoot, err := s.calculateMerkleRoot([]string{s.keyHash(root), s.hashBytes(data)})
+	if err != nil {
+		return "", err
+	}
+	a := &adder{DagService: s, ctx: context.Background(), seen: make(map[string]bool)}
+	key := s.formatKey(SNAPSHOT, merkleRoot)
+	if err := a.putBlock(key, data); err != nil {
+		return "", err
+	}
+	if s.bloomFPRate > 0 {
+		if err := s.storeBloomFilter(key); err != nil {
+			return "", err
+		}
+	}
+	return key, nil
+}
+
+// ReadManifest 读取快照的清单。清单记录的哈希函数与DagService的不同时返回ErrHashAlgorithmMismatch，
+// 由主版本更新的格式写入时返回ErrUnsupportedFormat
+func (s *DagService) ReadManifest(key string) (*Manifest, error) {
+	objType, err := rootType(key)
+	if err != nil {
+		return nil, err
+	}
+	if objType != SNAPSHOT {
+		return nil, fmt.Errorf("%s is not a snapshot: %w", key, ErrUnsupportedNodeType)
+	}
+	data, err := s.getBlock(key)
+	if err != nil {
+		return nil, err
+	}
+	return s.manifestFrom(key, data)
+}
+
+// manifestFrom 解码已经读取的快照key的清单data，并像ReadManifest一样检查其哈希函数
+func (s *DagService) manifestFrom(key string, data []byte) (*Manifest, error) {
+	m, err := decodeManifest(data)
+	if err != nil {
+		return nil, err
+	}
+	if alg := s.hashAlgorithm(); m.HashAlgorithm != alg {
+		return nil, fmt.Errorf("snapshot %s uses %s, service uses %s: %w", key, m.HashAlgorithm, alg, ErrHashAlgorithmMismatch)
+	}
+	return m, nil
+}
+
+// encodeManifest 将清单编码为数据块：名字、创建时间、哈希函数和根节点的键值依次写入，
+// 字符串前都写入其长度。1.1之后的格式最后写入主版本和次版本
+func encodeManifest(m *Manifest) []byte {
+	buf := binary.AppendUvarint(nil, uint64(len(m.Name)))
+	buf = append(buf, m.Name...)
+	buf = binary.AppendVarint(buf, m.Created.UnixNano())
+	buf = binary.AppendUvarint(buf, uint64(len(m.HashAlgorithm)))
+	buf = append(buf, m.HashAlgorithm...)
+	buf = binary.AppendUvarint(buf, uint64(len(m.Root)))
+	buf = append(buf, m.Root...)
+	if m.Format != (FormatVersion{Major: 1}) {
+		buf = binary.AppendUvarint(buf, m.Format.Major)
+		buf = binary.AppendUvarint(buf, m.Format.Minor)
+	}
+	return buf
+}
+
+func decodeManifest(data []byte) (*Manifest, error) {
+	r := &blockReader{data: data}
+	name := r.bytes()
+	created := r.varint()
+	alg := r.bytes()
+	root := r.bytes()
+	format := FormatVersion{Major: 1}
+	if r.err == nil && len(r.data) != 0 {
+		format = FormatVersion{Major: r.uvarint(), Minor: r.uvarint()}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if format.Major > manifestFormat.Major {
+		return nil, &ErrUnsupportedFormat{Version: format}
+	}
+	// 同一主版本中更新的次版本可能在末尾追加了字段，跳过它们
+	if format.Major == 0 || len(r.data) != 0 && format.Minor <= manifestFormat.Minor {
+		return nil, errMalformedObject
+	}
+	return &Manifest{
+		Name:          string(name),
+		Created:       time.Unix(0, created),
+		HashAlgorithm: string(alg),
+		Root:          string(root),
+		Format:        format,
+	}, nil
+}
@@ -0,0 +1,227 @@
+package merkledag
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"hash"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHashAlgorithmNames(t *testing.T) {
+	cases := []struct {
+		opts []Option
+		want string
+	}{
+		{nil, "sha256"},
+		{[]Option{WithHasher(sha512.New)}, "sha512"},
+		{[]Option{WithHasher(newBlake2b256)}, "blake2b-256"},
+		{[]Option{WithHasher(newBlake2b512)}, "blake2b-512"},
+	}
+	for _, c := range cases {
+		if got := NewDagService(NewMemStore(), c.opts...).hashAlgorithm(); got != c.want {
+			t.Errorf("got %q, want %q", got, c.want)
+		}
+	}
+	keyed := NewDagService(NewMemStore(), WithKeyedHasher([]byte("k"))).hashAlgorithm()
+	if !strings.HasPrefix(keyed, "unknown-") {
+		t.Errorf("keyed hasher named %q", keyed)
+	}
+}
+
+func TestAddDoesNotProbeHasher(t *testing.T) {
+	calls := 0
+	counting := func() hash.Hash {
+		calls++
+		return sha256.New()
+	}
+	s := NewDagService(NewMemStore(), WithHasher(counting))
+	// 保存只有一个叶子的文件只需要计算一次内容的哈希，不再为识别哈希函数而计算
+	for i := 0; i < 3; i++ {
+		calls = 0
+		if _, err := s.Add(NewFile([]byte{byte(i)})); err != nil {
+			t.Fatal(err)
+		}
+		if calls != 1 {
+			t.Fatalf("Add called the hasher %d times, want 1", calls)
+		}
+	}
+}
+
+func TestGetSnapshotReadsManifestOnce(t *testing.T) {
+	st := newCountingStore()
+	s := NewDagService(st)
+	snap, err := s.AddSnapshot(NewDirBuilder().AddFile("a", []byte("a")).Build(), "daily")
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.reset()
+	node, err := s.Get(snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.Size() != 1 {
+		t.Fatalf("size %d, want 1", node.Size())
+	}
+	if n := st.gets[snap]; n != 1 {
+		t.Fatalf("manifest read %d times, want 1", n)
+	}
+	// 没有存储头时由清单中记录的哈希函数发现配置不同
+	if err := st.Delete(headerKey); err != nil {
+		t.Fatal(err)
+	}
+	bad := NewDagService(st, WithHasher(sha512.New))
+	if _, err := bad.Get(snap); !errors.Is(err, ErrHashAlgorithmMismatch) {
+		t.Fatalf("got %v, want ErrHashAlgorithmMismatch", err)
+	}
+}
+
+func TestRootDependsOnHasher(t *testing.T) {
+	tree := NewDirBuilder().AddFile("a", []byte("alpha")).AddFile("b", []byte("beta")).Build()
+	roots := make(map[string]string)
+	for _, h := range []struct {
+		name string
+		opts []Option
+	}{
+		{"sha256", nil},
+		{"blake2b-256", []Option{WithHasher(newBlake2b256)}},
+	} {
+		first, err := NewDagService(NewMemStore(), h.opts...).Add(tree)
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, err := NewDagService(NewMemStore(), h.opts...).Add(tree)
+		if err != nil || second != first {
+			t.Fatalf("%s: root %s then %s (%v)", h.name, first, second, err)
+		}
+		roots[h.name] = first
+	}
+	if roots["sha256"] == roots["blake2b-256"] {
+		t.Fatal("SHA-256 and BLAKE2b gave the same root")
+	}
+}
+
+func TestSnapshotManifest(t *testing.T) {
+	st := NewMemStore()
+	s := NewDagService(st)
+	tree := NewDirBuilder().AddFile("a", []byte("alpha")).Build()
+	before := time.Now()
+	snap, err := s.AddSnapshot(tree, "nightly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := s.Add(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := s.ReadManifest(snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Name != "nightly" || m.HashAlgorithm != "sha256" || m.Root != root || m.Created.Before(before.Truncate(time.Second)) {
+		t.Fatalf("manifest %+v", m)
+	}
+	n, err := s.Get(snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, msg := sameTree(n, tree); !ok {
+		t.Fatal(msg)
+	}
+	// 同样的树在另一个名字下得到不同的快照
+	other, err := s.AddSnapshot(tree, "weekly")
+	if err != nil || other == snap {
+		t.Fatalf("snapshot %s, %v", other, err)
+	}
+
+	// 不带存储头复制到另一个store，只能由清单发现哈希函数不同
+	bare := NewMemStore()
+	keys, err := st.Keys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range keys {
+		if key == headerKey {
+			continue
+		}
+		data, err := st.Get(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := bare.Put(key, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bad := NewDagService(bare, WithHasher(sha512.New))
+	if _, err := bad.Get(snap); !errors.Is(err, ErrHashAlgorithmMismatch) {
+		t.Fatalf("Get: got %v, want ErrHashAlgorithmMismatch", err)
+	}
+	if _, err := bad.ReadManifest(snap); !errors.Is(err, ErrHashAlgorithmMismatch) {
+		t.Fatalf("ReadManifest: got %v, want ErrHashAlgorithmMismatch", err)
+	}
+}
+
+func TestManifestFormatVersion(t *testing.T) {
+	mem := NewMemStore()
+	s := NewDagService(mem)
+	snap, err := s.AddSnapshot(NewFile([]byte("x")), "n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := s.ReadManifest(snap)
+	if err != nil || m.Format != manifestFormat {
+		t.Fatalf("manifest format %v, %v; want %v", m, err, manifestFormat)
+	}
+	// put 保存格式版本为format、末尾追加extra的清单，返回其键值
+	put := func(format FormatVersion, extra []byte) string {
+		t.Helper()
+		manifest := *m
+		manifest.Format = format
+		data := append(encodeManifest(&manifest), extra...)
+		root, err := s.calculateMerkleRoot([]string{s.keyHash(manifest.Root), s.hashBytes(data)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		key := s.formatKey(SNAPSHOT, root)
+		if err := mem.Put(key, data); err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+
+	// 旧的1.0格式和向后兼容的次版本都能读取，次版本追加的字段被忽略
+	if got, err := s.ReadManifest(put(FormatVersion{Major: 1}, nil)); err != nil || got.Format.Minor != 0 {
+		t.Fatalf("1.0 manifest: %v, %v", got, err)
+	}
+	minor := put(FormatVersion{Major: 1, Minor: 7}, []byte("future"))
+	if _, err := s.Get(minor); err != nil {
+		t.Fatalf("newer minor version rejected: %v", err)
+	}
+	data, err := mem.Get(minor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.verifyBlock(minor, data); err != nil {
+		t.Fatalf("newer minor version fails verification: %v", err)
+	}
+
+	// 主版本更新后拒绝读取
+	major := put(FormatVersion{Major: 2}, []byte("future"))
+	var unsupported *ErrUnsupportedFormat
+	if _, err := s.Get(major); !errors.As(err, &unsupported) || unsupported.Version.Major != 2 {
+		t.Fatalf("Get: got %v, want ErrUnsupportedFormat for 2.0", err)
+	}
+	if _, err := s.ReadManifest(major); !errors.As(err, &unsupported) {
+		t.Fatalf("ReadManifest: got %v, want ErrUnsupportedFormat", err)
+	}
+	if _, err := s.Resolve(major, ""); !errors.As(err, &unsupported) {
+		t.Fatalf("Resolve: got %v, want ErrUnsupportedFormat", err)
+	}
+
+	// 当前版本的清单末尾有多余的数据时是损坏的数据块
+	if _, err := s.ReadManifest(put(manifestFormat, []byte("x"))); err == nil {
+		t.Fatal("trailing bytes accepted in a current-version manifest")
+	}
+}
@@ -0,0 +1,81 @@
+package merkledag
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// maxStoredBlock 返回m中最大的数据块的字节数
+func maxStoredBlock(t *testing.T, m *MemStore) int {
+	t.Helper()
+	keys, err := m.Keys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	largest := 0
+	for _, key := range keys {
+		data, err := m.Get(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		largest = max(largest, len(data))
+	}
+	return largest
+}
+
+func TestMaxBlockSizeFile(t *testing.T) {
+	const limit = 1000
+	fits := NewFile(chunkedSource(limit))
+	over := chunkedSource(limit + 1)
+	s := NewDagService(NewMemStore(), WithMaxBlockSize(limit))
+	if _, err := s.Add(fits); err != nil {
+		t.Fatalf("file of exactly the limit: %v", err)
+	}
+	_, err := s.Add(NewDirBuilder().AddFile("big", over).Build())
+	var tooLarge *ErrBlockTooLarge
+	if !errors.As(err, &tooLarge) || tooLarge.Size != limit+1 {
+		t.Fatalf("got %v, want ErrBlockTooLarge of %d bytes", err, limit+1)
+	}
+
+	st := NewMemStore()
+	s = NewDagService(st, WithMaxBlockSize(limit), WithChunking(true))
+	root, err := s.Add(NewDirBuilder().AddFile("big", over).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readPath(t, s, root, "big"); !bytes.Equal(got, over) {
+		t.Fatal("chunked content differs")
+	}
+	if largest := maxStoredBlock(t, st); largest > limit {
+		t.Fatalf("stored a %d byte block", largest)
+	}
+}
+
+func TestMaxBlockSizeDirectory(t *testing.T) {
+	// 分片的索引块最多链接256个子分片，限制需要能容纳它
+	const limit = 32 * K
+	b := NewDirBuilder()
+	for i := 0; i < 1000; i++ {
+		b.AddFile(fmt.Sprintf("file-with-a-long-name-%03d", i), []byte(fmt.Sprint(i)))
+	}
+	tree := b.Build()
+	var tooLarge *ErrBlockTooLarge
+	if _, err := NewDagService(NewMemStore(), WithMaxBlockSize(limit)).Add(tree); !errors.As(err, &tooLarge) {
+		t.Fatalf("got %v, want ErrBlockTooLarge", err)
+	}
+	// 设置了分片时超过最大块大小的目录被分片
+	st := NewMemStore()
+	s := NewDagService(st, WithMaxBlockSize(limit), WithShardThreshold(1000))
+	root, err := s.Add(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if largest := maxStoredBlock(t, st); largest > limit {
+		t.Fatalf("stored a %d byte block", largest)
+	}
+	if got := readPath(t, s, root, "file-with-a-long-name-042"); string(got) != "42" {
+		t.Fatalf("got %q", got)
+	}
+}
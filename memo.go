@@ -0,0 +1,62 @@
+package merkledag
+
+import "sync"
+
+// memoEntry 是保存过的目录的键值、Merkle Root和大小
+type memoEntry struct {
+	key        string
+	merkleRoot string
+	size       int64
+}
+
+// dirMemo 保存设置了WithDirMemo时已经保存过的目录，以nodeID为键
+type dirMemo struct {
+	mu    sync.Mutex
+	roots map[any]memoEntry
+}
+
+func (m *dirMemo) get(id any) (memoEntry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.roots[id]
+	return e, ok
+}
+
+func (m *dirMemo) put(id any, e memoEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.roots == nil {
+		m.roots = make(map[any]memoEntry)
+	}
+	m.roots[id] = e
+}
+
+// memoized 返回之前保存过的目录node的键值、Merkle Root和保存时记录的大小。
+// 目录块已经不在KVStore中时（例如被GC删除，或之前的Add没有提交）需要重新保存，ok为false
+func (s *adder) memoized(node Dir) (e memoEntry, ok bool, err error) {
+	if !s.memoDirs {
+		return memoEntry{}, false, nil
+	}
+	id, ok := nodeID(node)
+	if !ok {
+		return memoEntry{}, false, nil
+	}
+	if e, ok = s.memo.get(id); !ok || s.dryRun {
+		return e, ok, nil
+	}
+	exists, err := s.store.Has(e.key)
+	if err != nil || !exists {
+		return memoEntry{}, false, err
+	}
+	return e, true, nil
+}
+
+// remember 记录目录node保存后的键值、Merkle Root和大小
+func (s *adder) remember(node Dir, e memoEntry) {
+	if !s.memoDirs {
+		return
+	}
+	if id, ok := nodeID(node); ok {
+		s.memo.put(id, e)
+	}
+}
@@ -0,0 +1,98 @@
+package merkledag
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"sync/atomic"
+	"testing"
+)
+
+// writeCountingHash 记录所有实例的Write调用次数
+type writeCountingHash struct {
+	hash.Hash
+	writes *atomic.Int64
+}
+
+func (h writeCountingHash) Write(p []byte) (int, error) {
+	h.writes.Add(1)
+	return h.Hash.Write(p)
+}
+
+func TestDirMemoHashesSharedSubtreeOnce(t *testing.T) {
+	var writes atomic.Int64
+	st := NewMemStore()
+	s := NewDagService(st, WithDirMemo(true), WithHasher(func() hash.Hash {
+		return writeCountingHash{sha256.New(), &writes}
+	}))
+	b := NewDirBuilder()
+	for i := 0; i < 100; i++ {
+		b.AddFile(fmt.Sprint("f", i), []byte(fmt.Sprint(i)))
+	}
+	shared := b.Build()
+	first := NewDirBuilder().AddDir("s", shared).AddFile("a", []byte("a")).Build()
+	second := NewDirBuilder().AddDir("s", shared).AddFile("b", []byte("b")).Build()
+	if _, err := s.Add(first); err != nil {
+		t.Fatal(err)
+	}
+	full := writes.Swap(0)
+	root, err := s.Add(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 第二次只计算b和新的根目录，共享的子目录直接使用记住的键值
+	if again := writes.Swap(0); again*10 > full {
+		t.Fatalf("second Add hashed %d times, first %d", again, full)
+	}
+	plain, err := NewDagService(NewMemStore()).Add(second)
+	if err != nil || plain != root {
+		t.Fatalf("memoized root %s, plain %s, %v", root, plain, err)
+	}
+	// 目录块已经不在KVStore中时重新计算并保存
+	sub, _, err := s.resolveKey(root, "s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Delete(sub); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Add(NewDirBuilder().AddDir("s", shared).Build()); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := s.HasComplete(root); !ok || err != nil {
+		t.Fatalf("HasComplete after re-add: %v, %v", ok, err)
+	}
+}
+
+// sizeCountingDir 记录Size被调用的次数
+type sizeCountingDir struct {
+	Dir
+	sizes int
+}
+
+func (d *sizeCountingDir) Size() int64 {
+	d.sizes++
+	return d.Dir.Size()
+}
+
+func TestDirMemoUsesRecordedSize(t *testing.T) {
+	s := NewDagService(NewMemStore(), WithDirMemo(true))
+	shared := &sizeCountingDir{Dir: NewDirBuilder().AddFile("x", []byte("xyz")).Build()}
+	if _, err := s.Add(NewDirBuilder().AddDir("s", shared).Build()); err != nil {
+		t.Fatal(err)
+	}
+	shared.sizes = 0
+	tree := NewDirBuilder().AddDir("s", shared).AddFile("a", []byte("a")).Build()
+	root, err := s.Add(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 记住的子目录使用保存时记录的大小，不再调用Size
+	if shared.sizes != 0 {
+		t.Fatalf("Size called %d times on a memoized directory", shared.sizes)
+	}
+	plain, err := NewDagService(NewMemStore()).Add(tree)
+	if err != nil || plain != root {
+		t.Fatalf("memoized root %s, plain %s, %v", root, plain, err)
+	}
+}
@@ -0,0 +1,82 @@
+package merkledag
+
+import "sync"
+
+// MemStore 是基于map的内存KVStore，可以被多个goroutine同时使用
+type MemStore struct {
+	mu   sync.RWMutex
+	data map[string][]byte
+}
+
+// NewMemStore 创建一个空的MemStore
+func NewMemStore() *MemStore {
+	return &MemStore{data: make(map[string][]byte)}
+}
+
+func (m *MemStore) Has(key string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.data[key]
+	return ok, nil
+}
+
+func (m *MemStore) Put(key string, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data[key] = append([]byte(nil), value...)
+	return nil
+}
+
+// PutNoCopy 直接保存value而不复制，调用方之后不能再修改value
+func (m *MemStore) PutNoCopy(key string, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data[key] = value
+	return nil
+}
+
+func (m *MemStore) Get(key string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	value, ok := m.data[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]byte(nil), value...), nil
+}
+
+func (m *MemStore) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.data, key)
+	return nil
+}
+
+func (m *MemStore) Keys() ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keys := make([]string, 0, len(m.data))
+	for key := range m.data {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// emptyStore 是始终为空的KVStore，写入被丢弃，供不需要存储的计算使用
+type emptyStore struct{}
+
+func (emptyStore) Has(key string) (bool, error) {
+	return false, nil
+}
+
+func (emptyStore) Put(key string, value []byte) error {
+	return nil
+}
+
+func (emptyStore) Get(key string) ([]byte, error) {
+	return nil, ErrNotFound
+}
+
+func (emptyStore) Delete(key string) error {
+	return nil
+}
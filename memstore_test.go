@@ -0,0 +1,140 @@
+package merkledag
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestMemStoreConcurrentAccess(t *testing.T) {
+	m := NewMemStore()
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				key := fmt.Sprint("k", w, "-", i)
+				if err := m.Put(key, []byte(key)); err != nil {
+					t.Error(err)
+					return
+				}
+				// 同时读取其他goroutine写入的键值，读到的内容必须完整
+				other := fmt.Sprint("k", (w+1)%8, "-", i)
+				if v, err := m.Get(other); err == nil && string(v) != other {
+					t.Errorf("%s = %q", other, v)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	keys, err := m.Keys()
+	if err != nil || len(keys) != 8*200 {
+		t.Fatalf("%d keys, %v", len(keys), err)
+	}
+}
+
+func TestMemStoreCopiesValues(t *testing.T) {
+	m := NewMemStore()
+	value := []byte("value")
+	if err := m.Put("k", value); err != nil {
+		t.Fatal(err)
+	}
+	value[0] = 'X'
+	got, err := m.Get("k")
+	if err != nil || string(got) != "value" {
+		t.Fatalf("got %q, %v", got, err)
+	}
+	got[0] = 'Y'
+	if again, _ := m.Get("k"); string(again) != "value" {
+		t.Fatalf("modifying a returned value changed the store: %q", again)
+	}
+	if err := m.Delete("k"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Get("k"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v after Delete, want ErrNotFound", err)
+	}
+	if ok, _ := m.Has("k"); ok {
+		t.Fatal("Has after Delete")
+	}
+}
+
+// ownershipStore 记录通过PutNoCopy交给存储的缓冲区
+type ownershipStore struct {
+	*MemStore
+	owned map[string][]byte
+}
+
+func (o *ownershipStore) PutNoCopy(key string, value []byte) error {
+	o.owned[key] = value
+	return o.MemStore.PutNoCopy(key, value)
+}
+
+func TestPutNoCopyNoAliasing(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithTypePrefix(true)}, {WithPersistInternalNodes(true), WithShardThreshold(3)}} {
+		st := &ownershipStore{MemStore: NewMemStore(), owned: make(map[string][]byte)}
+		s := NewDagService(st, opts...)
+		content := []byte("user-owned content")
+		b := NewDirBuilder()
+		for i := 0; i < 5; i++ {
+			b.AddFile(fmt.Sprint("f", i), content)
+		}
+		root, err := s.Add(b.Build())
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 调用方在Add之后修改自己的数据，不影响已保存的数据块
+		for i := range content {
+			content[i] = 'X'
+		}
+		n, err := s.Resolve(root, "f3")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(n.(File).Bytes()); got != "user-owned content" {
+			t.Fatalf("stored content changed to %q", got)
+		}
+		if len(st.owned) == 0 {
+			t.Fatal("Add never used PutNoCopy")
+		}
+		for key := range st.owned {
+			if objType, _ := rootType(key); objType == BLOB && !s.typePrefix {
+				t.Fatalf("caller's file data %s handed to PutNoCopy", key)
+			}
+		}
+		// 修改Get返回的副本也不影响存储
+		if raw, err := st.Get(root); err == nil && len(raw) > 0 {
+			raw[0] ^= 0xff
+		}
+		if report, err := VerifyStore(s, []string{root}); err != nil || !report.OK() {
+			t.Fatalf("store corrupted: %v, %+v", err, report)
+		}
+	}
+}
+
+func BenchmarkAddMemStore(b *testing.B) {
+	builder := NewDirBuilder()
+	for i := 0; i < 200; i++ {
+		builder.AddFile(fmt.Sprint("file-", i), []byte(fmt.Sprint(i)))
+	}
+	dir := builder.Build()
+	for _, c := range []struct {
+		name  string
+		store func() KVStore
+	}{
+		// 包装后的MemStore只有Put，每个数据块都会被复制
+		{"Put", func() KVStore { return struct{ KVStore }{NewMemStore()} }},
+		{"PutNoCopy", func() KVStore { return NewMemStore() }},
+	} {
+		b.Run(c.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := NewDagService(c.store(), WithShardThreshold(0)).Add(dir); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
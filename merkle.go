@@ -0,0 +1,136 @@
+package merkledag
+
+import (
+	"encoding/hex"
+	"errors"
+)
+
+// treeRoot 计算Merkle Root，设置了WithPersistInternalNodes时同时保存每个内部节点
+func (s *adder) treeRoot(hashes []string) (string, error) {
+	if !s.persistInternal {
+		return s.calculateMerkleRoot(hashes)
+	}
+	return s.merkleRoot(hashes, func(hash string, data []byte) error {
+		return s.putEncodedBlock(s.formatKey(MERKLE, hash), data)
+	})
+}
+
+// readInternalNode 读取哈希为hash的内部节点，返回组合成它的子哈希。
+// 没有保存该节点时ok为false
+func (s *DagService) readInternalNode(hash string) (children []string, ok bool, err error) {
+	key := s.formatKey(MERKLE, hash)
+	data, err := s.store.Get(key)
+	if errors.Is(err, ErrNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if data, err = s.decodeStored(key, data); err != nil {
+		return nil, false, err
+	}
+	if children, err = s.parseInternalNode(data); err != nil {
+		return nil, false, corruptBlock(key, err)
+	}
+	if got := s.combine(children...); got != hash {
+		return nil, false, &ErrHashMismatch{Key: key, Got: s.formatKey(MERKLE, got)}
+	}
+	return children, true, nil
+}
+
+// parseInternalNode 返回内部节点的内容data中按顺序拼接的子哈希
+func (s *DagService) parseInternalNode(data []byte) ([]string, error) {
+	if s.domainSeparation {
+		if len(data) == 0 || data[0] != 0x01 {
+			return nil, errMalformedObject
+		}
+		data = data[1:]
+	}
+	width := s.hasher().Size()
+	if s.legacyHexConcat {
+		width *= 2
+	}
+	if len(data) == 0 || len(data)%width != 0 {
+		return nil, errMalformedObject
+	}
+	var children []string
+	for i := 0; i < len(data); i += width {
+		if s.legacyHexConcat {
+			children = append(children, string(data[i:i+width]))
+		} else {
+			children = append(children, hex.EncodeToString(data[i:i+width]))
+		}
+	}
+	return children, nil
+}
+
+// storedProof 从保存的内部节点生成n个叶子中第index个叶子到Merkle Root root的证明，
+// 结果与merkleProof相同。树的形状只取决于n和扇出，从根向下每层只读取一个内部节点。
+// 缺少内部节点时ok为false，调用方需要重新计算
+func (s *DagService) storedProof(root string, n int, index int) (steps []ProofStep, ok bool, err error) {
+	k := s.branching()
+	// sizes[l]为第l层的哈希数量，positions[l]为叶子在第l层的祖先的位置
+	sizes := []int{n}
+	positions := []int{index}
+	for sizes[len(sizes)-1] > 1 {
+		last := len(sizes) - 1
+		sizes = append(sizes, (sizes[last]+k-1)/k)
+		positions = append(positions, positions[last]/k)
+	}
+	hash := root
+	steps = make([]ProofStep, 0, len(sizes))
+	for l := len(sizes) - 2; l >= 0; l-- {
+		start := positions[l] / k * k
+		size := min(k, sizes[l]-start)
+		// 只有一个哈希的组原样进入上一层，第l层的哈希与上一层相同
+		if size == 1 {
+			continue
+		}
+		group, ok, err := s.readInternalNode(hash)
+		if err != nil || !ok {
+			return nil, false, err
+		}
+		if len(group) != size {
+			return nil, false, &ErrCorruptBlock{Key: s.formatKey(MERKLE, hash), Err: errMalformedObject}
+		}
+		pos := positions[l] - start
+		if size == 2 {
+			sibling := 1 - pos
+			steps = append(steps, ProofStep{Hash: group[sibling], Left: sibling < pos})
+		} else {
+			steps = append(steps, ProofStep{
+				Before: append([]string(nil), group[:pos]...),
+				After:  append([]string(nil), group[pos+1:]...),
+			})
+		}
+		hash = group[pos]
+	}
+	// 证明从叶子向根排列
+	for i, j := 0, len(steps)-1; i < j; i, j = i+1, j-1 {
+		steps[i], steps[j] = steps[j], steps[i]
+	}
+	if len(steps) > 0 {
+		steps[0].First = true
+	}
+	return steps, true, nil
+}
+
+// internalNodeKeys 返回Merkle Root为root的树中已经保存的所有内部节点的键值
+func (s *DagService) internalNodeKeys(root string) ([]string, error) {
+	var keys []string
+	stack := []string{root}
+	for len(stack) > 0 {
+		hash := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		children, ok, err := s.readInternalNode(hash)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		keys = append(keys, s.formatKey(MERKLE, hash))
+		stack = append(stack, children...)
+	}
+	return keys, nil
+}
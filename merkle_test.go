@@ -0,0 +1,247 @@
+package merkledag
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+// leafHashes 返回n个不同的叶子哈希
+func leafHashes(n int) []string {
+	hashes := make([]string, n)
+	for i := range hashes {
+		sum := sha256.Sum256([]byte(fmt.Sprint("leaf ", i)))
+		hashes[i] = hex.EncodeToString(sum[:])
+	}
+	return hashes
+}
+
+func mustRoot(t *testing.T, s *DagService, hashes []string) string {
+	t.Helper()
+	root, err := s.calculateMerkleRoot(hashes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestOddLeafNotDuplicated(t *testing.T) {
+	s := NewDagService(nil)
+	h := leafHashes(3)
+	abc := mustRoot(t, s, h)
+	abcc := mustRoot(t, s, append(h, h[2]))
+	if abc == abcc {
+		t.Fatal("[A,B,C] and [A,B,C,C] have the same root")
+	}
+	// 奇数个叶子时最后一个直接进入上一层，等价于先组合前两个
+	want := mustRoot(t, s, []string{s.combine(h[0], h[1]), h[2]})
+	if abc != want {
+		t.Fatalf("root %s, want %s", abc, want)
+	}
+}
+
+// 固定的向量，叶子为leafHashes。不区分时内部节点为H(左||右)，
+// 区分时叶子为H(0x00||叶子)，内部节点为H(0x01||左||右)
+func TestDomainSeparationVectors(t *testing.T) {
+	cases := []struct {
+		tagged bool
+		leaves int
+		want   string
+	}{
+		{false, 1, leafHashes(1)[0]},
+		{false, 2, "06f4672c8871ec3b0085b38a1682a938005d5fa05ef1366bf23b5f9eb46ff543"},
+		{false, 3, "3ee41213e35a6d399dd8365d2bb2340693bebea8916a1340e4c0f1edd25ed4c7"},
+		{true, 1, "31d82f8c2c1211c119bc7352c5688f177e9b25b5162483c5b571fa3eece8b3a3"},
+		{true, 2, "34959128c8eb35cc38a329af9116b54ef78c6b044d0687478eab65e4b70078bb"},
+		{true, 3, "06e2e1946f3b546e28fec6b7d571e36753bbe5350e820315f0cb6945ec3ead03"},
+	}
+	for _, c := range cases {
+		s := NewDagService(nil, WithDomainSeparation(c.tagged))
+		if got := mustRoot(t, s, leafHashes(c.leaves)); got != c.want {
+			t.Errorf("tagged=%v, %d leaves: got %s, want %s", c.tagged, c.leaves, got, c.want)
+		}
+	}
+}
+
+func TestDomainSeparationChangesKeys(t *testing.T) {
+	tree := NewDirBuilder().AddFile("a", []byte("alpha")).AddFile("b", []byte("beta")).Build()
+	plain, err := NewDagService(NewMemStore()).Add(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewDagService(NewMemStore(), WithDomainSeparation(true))
+	tagged, err := s.Add(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain == tagged {
+		t.Fatal("domain separation did not change the root")
+	}
+	proof, err := s.ProveInclusion(tagged, "b")
+	if err != nil || !s.VerifyInclusion(proof.Leaf, proof, tagged) {
+		t.Fatalf("tagged proof rejected: %v", err)
+	}
+}
+
+func TestFanoutDepth(t *testing.T) {
+	leaves := leafHashes(256)
+	binary := NewDagService(nil)
+	wide := NewDagService(nil, WithFanout(16))
+	// 从叶子到根的每一层证明中都有一步
+	if depth := len(binary.merkleProof(leaves, 0)); depth != 8 {
+		t.Fatalf("fanout 2 depth %d, want 8", depth)
+	}
+	if depth := len(wide.merkleProof(leaves, 0)); depth != 2 {
+		t.Fatalf("fanout 16 depth %d, want 2", depth)
+	}
+	if mustRoot(t, NewDagService(nil, WithFanout(2)), leaves) != mustRoot(t, binary, leaves) {
+		t.Fatal("WithFanout(2) changed the root")
+	}
+	root := mustRoot(t, wide, leaves)
+	if root == mustRoot(t, binary, leaves) {
+		t.Fatal("fanout 16 and 2 share a root")
+	}
+	if again := mustRoot(t, NewDagService(nil, WithFanout(16)), leaves); again != root {
+		t.Fatalf("fanout 16 root %s then %s", root, again)
+	}
+}
+
+func TestFanoutProof(t *testing.T) {
+	b := NewDirBuilder()
+	for i := 0; i < 40; i++ {
+		b.AddFile(fmt.Sprint("f", i), []byte(fmt.Sprint(i)))
+	}
+	tree := b.Build()
+	for _, k := range []int{3, 16} {
+		s := NewDagService(NewMemStore(), WithFanout(k))
+		root, err := s.Add(tree)
+		if err != nil {
+			t.Fatal(err)
+		}
+		proof, err := s.ProveInclusion(root, "f17")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if proof.Fanout != k || !s.VerifyInclusion(proof.Leaf, proof, root) {
+			t.Fatalf("fanout %d: proof %+v rejected", k, proof)
+		}
+		// 每一步中保存了同一组的所有兄弟哈希
+		if len(proof.Steps[0].Before)+len(proof.Steps[0].After) != k-1 {
+			t.Fatalf("fanout %d: first step %+v", k, proof.Steps[0])
+		}
+	}
+}
+
+// 固定的向量，叶子为leafHashes。内部节点默认为H(左||右)，拼接原始摘要；
+// WithLegacyHexConcat时拼接十六进制字符串，与旧版本保存的数据相同
+func TestLegacyHexConcatVectors(t *testing.T) {
+	cases := []struct {
+		legacy bool
+		leaves int
+		want   string
+	}{
+		{false, 2, "06f4672c8871ec3b0085b38a1682a938005d5fa05ef1366bf23b5f9eb46ff543"},
+		{false, 3, "3ee41213e35a6d399dd8365d2bb2340693bebea8916a1340e4c0f1edd25ed4c7"},
+		{false, 4, "e912c730a1e4726d70d2b02628e68440b78373a09fbfeffe43263500f3300a3c"},
+		{true, 2, "ccbb48159730bd4be158de07e74e3a5aa8ac043aedb8ab0be1cad4338febb3a6"},
+		{true, 3, "53cd0310e66ea1cc485a9e5aa5c512aefeaa5779c80493cc06b98bbb39a860f5"},
+		{true, 4, "679216e779e8bf38faa9770bfa5232914f9774ab6aaefe0e5c58127b1b9e960b"},
+	}
+	for _, c := range cases {
+		s := NewDagService(nil, WithLegacyHexConcat(c.legacy))
+		if got := mustRoot(t, s, leafHashes(c.leaves)); got != c.want {
+			t.Errorf("legacy=%v, %d leaves: got %s, want %s", c.legacy, c.leaves, got, c.want)
+		}
+	}
+}
+
+func TestLegacyHexConcatReadsBack(t *testing.T) {
+	s := NewDagService(NewMemStore(), WithLegacyHexConcat(true), WithPersistInternalNodes(true))
+	root, err := s.Add(flatDir(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := VerifyStore(s, []string{root})
+	if err != nil || !report.OK() {
+		t.Fatalf("VerifyStore: %+v, %v", report, err)
+	}
+	proof, err := s.ProveInclusion(root, "f00003")
+	if err != nil || !s.VerifyInclusion(proof.Leaf, proof, root) {
+		t.Fatalf("legacy proof rejected: %v", err)
+	}
+	modern, err := NewDagService(NewMemStore()).Add(flatDir(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if modern == root {
+		t.Fatal("legacy and corrected roots are equal")
+	}
+}
+
+// xorCombiner 把一组摘要按位异或后计算SHA-256
+func xorCombiner(hashes [][]byte) []byte {
+	acc := make([]byte, len(hashes[0]))
+	for _, h := range hashes {
+		for i := range acc {
+			acc[i] ^= h[i]
+		}
+	}
+	sum := sha256.Sum256(acc)
+	return sum[:]
+}
+
+func TestCombiner(t *testing.T) {
+	s := NewDagService(nil, WithCombiner(xorCombiner))
+	a, b := sha256.Sum256([]byte("a")), sha256.Sum256([]byte("b"))
+	want := hex.EncodeToString(xorCombiner([][]byte{a[:], b[:]}))
+	if got := mustRoot(t, s, []string{hex.EncodeToString(a[:]), hex.EncodeToString(b[:])}); got != want {
+		t.Fatalf("root %s, want %s", got, want)
+	}
+
+	tree := func() Node {
+		b := NewDirBuilder()
+		for i := 0; i < 7; i++ {
+			b.AddFile(fmt.Sprint("f", i), []byte(fmt.Sprint(i)))
+		}
+		sub := NewDirBuilder().AddFile("x", []byte("x")).AddFile("y", []byte("y")).Build()
+		return b.AddDir("d", sub).Build()
+	}
+	plain, err := NewDagService(NewMemStore()).Add(tree())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, opts := range [][]Option{
+		{WithCombiner(xorCombiner)},
+		{WithCombiner(xorCombiner), WithFanout(3), WithDomainSeparation(true)},
+		{WithCombiner(xorCombiner), WithPersistInternalNodes(true)},
+	} {
+		s := NewDagService(NewMemStore(), append(opts, WithVerifyOnGet(true))...)
+		root, err := s.Add(tree())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if root == plain {
+			t.Fatal("combiner did not change the root")
+		}
+		if _, err := s.Get(root); err != nil {
+			t.Fatal(err)
+		}
+		for _, path := range []string{"f3", "d/y"} {
+			proof, err := s.ProveInclusion(root, path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !s.VerifyInclusion(proof.Leaf, proof, root) {
+				t.Fatalf("%s: proof rejected", path)
+			}
+			other := NewDagService(nil, WithFanout(proof.Fanout), WithDomainSeparation(proof.DomainSeparation))
+			if other.VerifyInclusion(proof.Leaf, proof, root) {
+				t.Fatalf("%s: proof accepted with the default combiner", path)
+			}
+		}
+		if report, err := VerifyStore(s, []string{root}); err != nil || !report.OK() {
+			t.Fatalf("VerifyStore: %+v, %v", report, err)
+		}
+	}
+}
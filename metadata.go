@@ -0,0 +1,101 @@
+package merkledag
+
+import (
+	"encoding/binary"
+	"os"
+	"sort"
+	"time"
+)
+
+// Metadata 是文件或目录在文件系统中的属性
+type Metadata struct {
+	// Mode 为权限位，以及setuid、setgid和sticky位
+	Mode    os.FileMode
+	ModTime time.Time
+	Uid     int
+	Gid     int
+	// Xattrs 为扩展属性，保存时按名字排序
+	Xattrs []Xattr
+}
+
+// Xattr 是一个扩展属性
+type Xattr struct {
+	Name  string
+	Value []byte
+}
+
+// sortedXattrs 返回按名字排序的xattrs的副本，原来的切片不会被修改
+func sortedXattrs(xattrs []Xattr) []Xattr {
+	sorted := append([]Xattr(nil), xattrs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
+
+// MetadataNode 是可以携带Metadata的Node。Metadata返回nil表示没有元数据，
+// 否则元数据会和节点一起序列化，参与Merkle Root的计算。
+// 文件的元数据保存在链接其内容的小数据块中，内容仍按其哈希单独保存，
+// 内容相同而元数据不同的文件共享内容的数据块
+type MetadataNode interface {
+	Node
+
+	Metadata() *Metadata
+}
+
+// metadataOf 返回node的元数据，没有时返回nil
+func metadataOf(node Node) *Metadata {
+	if m, ok := node.(MetadataNode); ok {
+		return m.Metadata()
+	}
+	return nil
+}
+
+// appendMetadata 将meta按serialize的格式追加到buf之后
+func appendMetadata(buf []byte, meta *Metadata) []byte {
+	buf = binary.AppendUvarint(buf, uint64(meta.Mode))
+	buf = binary.AppendVarint(buf, meta.ModTime.UnixNano())
+	buf = binary.AppendVarint(buf, int64(meta.Uid))
+	buf = binary.AppendVarint(buf, int64(meta.Gid))
+	// 没有扩展属性时不写入数量，之前保存的元数据的格式不变
+	if len(meta.Xattrs) == 0 {
+		return buf
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(meta.Xattrs)))
+	for _, x := range sortedXattrs(meta.Xattrs) {
+		buf = binary.AppendUvarint(buf, uint64(len(x.Name)))
+		buf = append(buf, x.Name...)
+		buf = binary.AppendUvarint(buf, uint64(len(x.Value)))
+		buf = append(buf, x.Value...)
+	}
+	return buf
+}
+
+func (r *blockReader) metadata() *Metadata {
+	mode := r.uvarint()
+	mtime := r.varint()
+	uid := r.varint()
+	gid := r.varint()
+	var xattrs []Xattr
+	if r.err == nil && len(r.data) != 0 {
+		n := r.uvarint()
+		if r.err == nil && n > uint64(len(r.data)) {
+			r.err = errTruncated
+		}
+		for i := uint64(0); i < n && r.err == nil; i++ {
+			name := r.bytes()
+			value := r.bytes()
+			xattrs = append(xattrs, Xattr{Name: string(name), Value: value})
+		}
+	}
+	if r.err != nil {
+		return nil
+	}
+	return &Metadata{
+		Mode:    os.FileMode(mode),
+		ModTime: time.Unix(0, mtime),
+		Uid:     int(uid),
+		Gid:     int(gid),
+		Xattrs:  xattrs,
+	}
+}
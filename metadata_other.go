@@ -0,0 +1,13 @@
+//go:build !unix
+
+package merkledag
+
+import "os"
+
+// fileMetadata 从文件信息中读取元数据，uid和gid在此平台上为0
+func fileMetadata(info os.FileInfo) *Metadata {
+	return &Metadata{
+		Mode:    info.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky),
+		ModTime: info.ModTime(),
+	}
+}
@@ -0,0 +1,117 @@
+package merkledag
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestModeChangesRoot(t *testing.T) {
+	s := NewDagService(NewMemStore())
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	var roots []string
+	for _, mode := range []os.FileMode{0644, 0600, 0755} {
+		meta := &Metadata{Mode: mode, ModTime: mtime}
+		root, err := s.Add(NewDirBuilder().add("a.txt", &file{data: []byte("same"), meta: meta}).Build())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range roots {
+			if r == root {
+				t.Fatalf("mode %v shares a root with another mode", mode)
+			}
+		}
+		roots = append(roots, root)
+		n, err := s.Resolve(root, "a.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := metadataOf(n)
+		if got == nil || got.Mode != mode || !got.ModTime.Equal(mtime) {
+			t.Fatalf("metadata %+v", got)
+		}
+	}
+	plain, err := s.Add(NewDirBuilder().AddFile("a.txt", []byte("same")).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range roots {
+		if r == plain {
+			t.Fatal("metadata did not change the root")
+		}
+	}
+}
+
+func TestExportRestoresMetadata(t *testing.T) {
+	src := t.TempDir()
+	path := filepath.Join(src, "a.txt")
+	if err := os.WriteFile(path, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2021, 6, 7, 8, 9, 10, 0, time.UTC)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	s := NewDagService(NewMemStore())
+	before, err := ImportPath(s, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		t.Fatal(err)
+	}
+	root, err := ImportPath(s, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root == before {
+		t.Fatal("changing the mode did not change the root")
+	}
+	dest := t.TempDir()
+	if err := ExportPath(s, root, dest); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(filepath.Join(dest, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("mode %v, want 0600", info.Mode().Perm())
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Fatalf("mtime %v, want %v", info.ModTime(), mtime)
+	}
+}
+
+func TestMetadataSharesContentBlock(t *testing.T) {
+	s := NewDagService(NewMemStore())
+	data := bytes.Repeat([]byte("q"), 100*K)
+	var roots [2]string
+	var written [2][]string
+	for i := range roots {
+		meta := &Metadata{Mode: 0644, ModTime: time.Unix(int64(i+1), 0)}
+		root, keys, err := s.AddWithKeys(&file{data: data, meta: meta})
+		if err != nil {
+			t.Fatal(err)
+		}
+		roots[i], written[i] = root, keys
+	}
+	if roots[0] == roots[1] {
+		t.Fatal("different mtimes share a root")
+	}
+	// 第一次写入内容和元数据两个数据块，第二次只写入新的元数据块
+	if len(written[0]) != 2 || len(written[1]) != 1 || written[1][0] != roots[1] {
+		t.Fatalf("wrote %v then %v", written[0], written[1])
+	}
+	for _, key := range written[0] {
+		if key == roots[0] {
+			continue
+		}
+		stored, err := s.getBlock(key)
+		if err != nil || !bytes.Equal(stored, data) {
+			t.Fatalf("content block %s: %d bytes, %v", key, len(stored), err)
+		}
+	}
+}
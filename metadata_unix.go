@@ -0,0 +1,21 @@
+//go:build unix
+
+package merkledag
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileMetadata 从文件信息中读取元数据
+func fileMetadata(info os.FileInfo) *Metadata {
+	meta := &Metadata{
+		Mode:    info.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky),
+		ModTime: info.ModTime(),
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		meta.Uid = int(st.Uid)
+		meta.Gid = int(st.Gid)
+	}
+	return meta
+}
@@ -0,0 +1,56 @@
+package merkledag
+
+import "sync/atomic"
+
+// Metrics 接收DagService和CachingStore的计数，可以用来导出到监控系统。
+// 实现需要可以被多个goroutine同时调用
+type Metrics interface {
+	// IncPut 在一个新的数据块写入KVStore时调用
+	IncPut()
+	// IncGet 在从KVStore读取一个数据块时调用
+	IncGet()
+	// IncCacheHit 在CachingStore命中缓存时调用
+	IncCacheHit()
+	// IncRepair 在TieredStore用慢速层的数据块修复快速层中损坏的数据块时调用
+	IncRepair()
+	// AddBytes 在写入数据块后以其字节数调用
+	AddBytes(n int64)
+}
+
+// NopMetrics 忽略所有计数，是没有设置WithMetrics时的默认值
+type NopMetrics struct{}
+
+func (NopMetrics) IncPut()        {}
+func (NopMetrics) IncGet()        {}
+func (NopMetrics) IncCacheHit()   {}
+func (NopMetrics) IncRepair()     {}
+func (NopMetrics) AddBytes(int64) {}
+
+// CounterMetrics 用原子计数器累计所有计数，零值即可使用
+type CounterMetrics struct {
+	Puts      atomic.Int64
+	Gets      atomic.Int64
+	CacheHits atomic.Int64
+	Repairs   atomic.Int64
+	Bytes     atomic.Int64
+}
+
+func (m *CounterMetrics) IncPut() {
+	m.Puts.Add(1)
+}
+
+func (m *CounterMetrics) IncGet() {
+	m.Gets.Add(1)
+}
+
+func (m *CounterMetrics) IncCacheHit() {
+	m.CacheHits.Add(1)
+}
+
+func (m *CounterMetrics) IncRepair() {
+	m.Repairs.Add(1)
+}
+
+func (m *CounterMetrics) AddBytes(n int64) {
+	m.Bytes.Add(n)
+}
@@ -0,0 +1,54 @@
+package merkledag
+
+import "testing"
+
+func TestCounterMetrics(t *testing.T) {
+	var m CounterMetrics
+	mem := NewMemStore()
+	s := NewDagService(NewCachingStore(mem, 100, WithCacheMetrics(&m)), WithMetrics(&m))
+	// "a"和"c"内容相同，只写入一次
+	tree := NewDirBuilder().
+		AddFile("a", []byte("a")).
+		AddFile("b", []byte("bb")).
+		AddFile("c", []byte("a")).
+		Build()
+	root, err := s.Add(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Puts.Load(); got != 3 {
+		t.Fatalf("Puts = %d, want 3", got)
+	}
+	keys, err := mem.Keys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var written int64
+	for _, key := range keys {
+		if key == headerKey {
+			continue
+		}
+		data, err := mem.Get(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		written += int64(len(data))
+	}
+	if got := m.Bytes.Load(); got != written {
+		t.Fatalf("Bytes = %d, want %d", got, written)
+	}
+	// 已经存在的数据块不再计数
+	if _, err := s.Add(tree); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Puts.Load(); got != 3 {
+		t.Fatalf("Puts after re-add = %d, want 3", got)
+	}
+	gets, hits := m.Gets.Load(), m.CacheHits.Load()
+	if _, err := s.Get(root); err != nil {
+		t.Fatal(err)
+	}
+	if m.Gets.Load() <= gets || m.CacheHits.Load() <= hits {
+		t.Fatalf("Get not counted: gets %d->%d, hits %d->%d", gets, m.Gets.Load(), hits, m.CacheHits.Load())
+	}
+}
@@ -0,0 +1,94 @@
+package merkledag
+
+import "strings"
+
+// namespaceSep 分隔命名空间和键值，键值中不会出现它
+const namespaceSep = "/"
+
+// namespaceStore 在每个键值前加上命名空间，Keys只列出并去掉本命名空间的键值。
+// 命名空间之外的键值对DagService不可见，Get、Walk、GC和FindRoots都只作用于本命名空间
+type namespaceStore struct {
+	store  KVStore
+	prefix string
+}
+
+// namespaceBatchStore 是底层KVStore支持BatchStore时使用的namespaceStore
+type namespaceBatchStore struct {
+	*namespaceStore
+}
+
+// newNamespaceStore 返回在store中使用命名空间ns的KVStore，底层支持BatchStore时结果同样支持
+func newNamespaceStore(store KVStore, ns string) KVStore {
+	n := &namespaceStore{store: store, prefix: ns + namespaceSep}
+	if _, ok := store.(BatchStore); ok {
+		return namespaceBatchStore{n}
+	}
+	return n
+}
+
+func (n *namespaceStore) Has(key string) (bool, error) {
+	return n.store.Has(n.prefix + key)
+}
+
+func (n *namespaceStore) Put(key string, value []byte) error {
+	return n.store.Put(n.prefix+key, value)
+}
+
+func (n *namespaceStore) Get(key string) ([]byte, error) {
+	return n.store.Get(n.prefix + key)
+}
+
+func (n *namespaceStore) Delete(key string) error {
+	return n.store.Delete(n.prefix + key)
+}
+
+// Flush 写出底层KVStore缓存的数据，底层KVStore没有实现Flusher时直接返回nil
+func (n *namespaceStore) Flush() error {
+	if f, ok := n.store.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Close 关闭底层KVStore，底层KVStore没有实现io.Closer时直接返回nil。
+// 底层KVStore被多个命名空间共享时，只应关闭其中一个DagService
+func (n *namespaceStore) Close() error {
+	return closeStores(n.store)
+}
+
+// Keys 列出本命名空间的键值，底层KVStore没有实现Enumerate时返回ErrNotEnumerable
+func (n *namespaceStore) Keys() ([]string, error) {
+	lister, ok := n.store.(Enumerate)
+	if !ok {
+		return nil, ErrNotEnumerable
+	}
+	keys, err := lister.Keys()
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, key := range keys {
+		if rest, ok := strings.CutPrefix(key, n.prefix); ok {
+			out = append(out, rest)
+		}
+	}
+	return out, nil
+}
+
+func (n namespaceBatchStore) Begin() Batch {
+	return &namespaceBatch{batch: n.store.(BatchStore).Begin(), prefix: n.prefix}
+}
+
+// namespaceBatch 在写入的键值前加上命名空间
+type namespaceBatch struct {
+	batch  Batch
+	prefix string
+}
+
+func (b *namespaceBatch) Put(key string, value []byte) error {
+	return b.batch.Put(b.prefix+key, value)
+}
+
+func (b *namespaceBatch) Commit() error {
+	return b.batch.Commit()
+}
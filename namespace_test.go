@@ -0,0 +1,45 @@
+package merkledag
+
+import "testing"
+
+func TestNamespacesIsolateTrees(t *testing.T) {
+	st := NewMemStore()
+	a := NewDagService(st, WithNamespace("a"))
+	b := NewDagService(st, WithNamespace("b"))
+	rootA, err := a.Add(NewDirBuilder().AddFile("x", []byte("x")).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootB, err := b.Add(NewDirBuilder().AddFile("y", []byte("y")).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := st.Has("a/" + rootA); !ok {
+		t.Fatal("block not stored under its namespace")
+	}
+	for _, c := range []struct {
+		s    *DagService
+		want string
+	}{{a, rootA}, {b, rootB}} {
+		roots, err := FindRoots(c.s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(roots) != 1 || roots[0] != c.want {
+			t.Fatalf("got %v, want [%s]", roots, c.want)
+		}
+	}
+	if _, err := a.Get(rootB); err == nil {
+		t.Fatal("namespace a read a block of namespace b")
+	}
+	if roots, err := FindRoots(NewDagService(st)); err != nil || len(roots) != 0 {
+		t.Fatalf("unnamespaced service found %v, %v", roots, err)
+	}
+	// 没有固定任何根节点，GC只删除a中的两个数据块
+	if removed, err := a.GC(); err != nil || removed != 2 {
+		t.Fatalf("GC removed %d, %v; want 2", removed, err)
+	}
+	if ok, err := b.HasComplete(rootB); !ok || err != nil {
+		t.Fatalf("GC in a touched b: %v, %v", ok, err)
+	}
+}
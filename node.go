@@ -0,0 +1,96 @@
+package merkledag
+
+// file 是从KVStore中重建出的File
+type file struct {
+	data []byte
+	meta *Metadata
+}
+
+func (f *file) Size() int64 {
+	return int64(len(f.data))
+}
+
+func (f *file) Type() int {
+	return FILE
+}
+
+func (f *file) Bytes() []byte {
+	return f.data
+}
+
+func (f *file) Metadata() *Metadata {
+	return f.meta
+}
+
+// symlink 是从KVStore中重建出的Symlink
+type symlink struct {
+	target string
+}
+
+func (l *symlink) Size() int64 {
+	return int64(len(l.target))
+}
+
+func (l *symlink) Type() int {
+	return SYMLINK
+}
+
+func (l *symlink) Target() string {
+	return l.target
+}
+
+// entry 是目录中的一个带名字的子节点
+type entry struct {
+	name string
+	node Node
+}
+
+// dir 是从KVStore中重建出的Dir，或者DirBuilder构造的Dir。
+// err为DirBuilder加入目录项时发现的错误，Add时返回
+type dir struct {
+	entries []entry
+	meta    *Metadata
+	err     error
+}
+
+func (d *dir) Size() int64 {
+	var size int64
+	for _, e := range d.entries {
+		size += e.node.Size()
+	}
+	return size
+}
+
+func (d *dir) Type() int {
+	return DIR
+}
+
+func (d *dir) Metadata() *Metadata {
+	return d.meta
+}
+
+func (d *dir) It() DirIterator {
+	return &dirIterator{entries: d.entries, cur: -1}
+}
+
+// dirIterator 按顺序遍历dir的子节点
+type dirIterator struct {
+	entries []entry
+	cur     int
+}
+
+func (it *dirIterator) Next() bool {
+	if it.cur+1 >= len(it.entries) {
+		return false
+	}
+	it.cur++
+	return true
+}
+
+func (it *dirIterator) Name() string {
+	return it.entries[it.cur].name
+}
+
+func (it *dirIterator) Node() Node {
+	return it.entries[it.cur].node
+}
@@ -0,0 +1,16 @@
+package merkledag
+
+import (
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// normalizeName 在设置了WithNameNormalization时将名字或路径中的"\"替换为"/"，并转换为Unicode NFC形式，
+// 否则原样返回
+func (s *DagService) normalizeName(name string) string {
+	if !s.normalizeNames {
+		return name
+	}
+	return norm.NFC.String(strings.ReplaceAll(name, `\`, "/"))
+}
@@ -0,0 +1,75 @@
+package merkledag
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const (
+	nfcName = "caf\u00e9"
+	nfdName = "cafe\u0301"
+)
+
+func TestNameNormalizationStableRoots(t *testing.T) {
+	tree := func(name string) Dir {
+		sub := NewDirBuilder().AddFile(name, []byte("1")).Build()
+		return NewDirBuilder().AddDir("sub", sub).Build()
+	}
+	s := NewDagService(NewMemStore(), WithNameNormalization(true))
+	composed, err := s.Add(tree(nfcName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decomposed, err := s.Add(tree(nfdName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if composed != decomposed {
+		t.Fatal("NFC and NFD names give different roots")
+	}
+	// 已经是NFC的名字不受影响；不规范化时两种形式得到不同的根节点
+	plain := NewDagService(NewMemStore())
+	if root, err := plain.Add(tree(nfcName)); err != nil || root != composed {
+		t.Fatalf("NFC root changed by normalization: %s, %v", root, err)
+	}
+	if root, err := plain.Add(tree(nfdName)); err != nil || root == composed {
+		t.Fatalf("unnormalized NFD root %s, %v", root, err)
+	}
+	// 路径中的"\"和分解形式在Resolve时同样被规范化
+	if n, err := s.Resolve(composed, `sub\`+nfdName); err != nil || n.Size() != 1 {
+		t.Fatalf("Resolve with mixed separators: %v", err)
+	}
+	var invalid *ErrInvalidNode
+	if _, err := s.Add(NewDirBuilder().AddFile(`a\b`, []byte("x")).Build()); !errors.As(err, &invalid) {
+		t.Fatalf("got %v, want ErrInvalidNode", err)
+	}
+}
+
+func TestNameNormalizationImport(t *testing.T) {
+	// 导入时记录修改时间，两次导入使用相同的时间
+	mtime := time.Unix(1600000000, 0)
+	var roots []string
+	for _, name := range []string{nfcName, nfdName} {
+		dir := t.TempDir()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("same"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range []string{path, dir} {
+			if err := os.Chtimes(p, mtime, mtime); err != nil {
+				t.Fatal(err)
+			}
+		}
+		root, err := ImportPath(NewDagService(NewMemStore(), WithNameNormalization(true)), dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, root)
+	}
+	if roots[0] != roots[1] {
+		t.Fatal("imports of NFC and NFD file names differ")
+	}
+}
@@ -0,0 +1,91 @@
+package merkledag
+
+// Prefetch 读取root可达的所有数据块，返回键值到内容的映射。
+// KVStore实现了MultiGetter时按层读取，每层只调用一次GetMany，否则依次调用Get。
+// 缺少数据块时返回ErrBlockNotFound
+func (s *DagService) Prefetch(root string) (map[string][]byte, error) {
+	objType, err := rootType(root)
+	if err != nil {
+		return nil, err
+	}
+	blocks := make(map[string][]byte)
+	level := []blockRef{{key: root, objType: objType}}
+	for len(level) > 0 {
+		fetched, err := s.getBlocks(level)
+		if err != nil {
+			return nil, err
+		}
+		var next []blockRef
+		for _, ref := range level {
+			data := fetched[ref.key]
+			blocks[ref.key] = data
+			obj, err := s.blockLinks(ref, data)
+			if err != nil {
+				return nil, corruptBlock(ref.key, err)
+			}
+			if obj == nil {
+				continue
+			}
+			for i, link := range obj.Links {
+				key := string(link.Hash)
+				if _, ok := blocks[key]; ok {
+					continue
+				}
+				// 先占位，同一层中被多次引用的数据块只读取一次
+				blocks[key] = nil
+				next = append(next, blockRef{key: key, objType: obj.linkType(i)})
+			}
+		}
+		level = next
+	}
+	return blocks, nil
+}
+
+// getBlocks 读取refs中的所有数据块，KVStore实现了MultiGetter时一次读取。
+// 内嵌文件不读取KVStore
+func (s *DagService) getBlocks(refs []blockRef) (map[string][]byte, error) {
+	blocks := make(map[string][]byte, len(refs))
+	var keys []string
+	for _, ref := range refs {
+		if data, ok := inlineData(ref.key); ok {
+			blocks[ref.key] = data
+		} else {
+			keys = append(keys, ref.key)
+		}
+	}
+	mg, ok := s.store.(MultiGetter)
+	if !ok {
+		for _, key := range keys {
+			data, err := s.getBlock(key)
+			if err != nil {
+				return nil, err
+			}
+			blocks[key] = data
+		}
+		return blocks, nil
+	}
+	if len(keys) == 0 {
+		return blocks, nil
+	}
+	fetched, err := mg.GetMany(keys)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		data, ok := fetched[key]
+		if !ok {
+			return nil, &ErrBlockNotFound{Key: key}
+		}
+		s.metrics.IncGet()
+		if data, err = s.decodeStored(key, data); err != nil {
+			return nil, err
+		}
+		if s.verifyOnGet {
+			if err := s.verifyBlock(key, data); err != nil {
+				return nil, err
+			}
+		}
+		blocks[key] = data
+	}
+	return blocks, nil
+}
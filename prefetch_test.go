@@ -0,0 +1,73 @@
+package merkledag
+
+import (
+	"errors"
+	"testing"
+)
+
+// multiGetStore 在countingStore的基础上实现MultiGetter，记录GetMany的调用次数
+type multiGetStore struct {
+	*countingStore
+	many int
+}
+
+func (m *multiGetStore) GetMany(keys []string) (map[string][]byte, error) {
+	m.many++
+	blocks := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		if data, err := m.MemStore.Get(key); err == nil {
+			blocks[key] = data
+		}
+	}
+	return blocks, nil
+}
+
+func TestPrefetchUsesGetMany(t *testing.T) {
+	st := &multiGetStore{countingStore: newCountingStore()}
+	s := NewDagService(st)
+	root, err := s.Add(flatDir(20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.reset()
+	blocks, err := s.Prefetch(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blocks) != 21 {
+		t.Fatalf("got %d blocks, want 21", len(blocks))
+	}
+	// 每层调用一次GetMany：根目录一次，20个文件一次
+	if st.many != 2 || st.blockGets() != 0 {
+		t.Fatalf("%d GetMany and %d Get calls", st.many, st.blockGets())
+	}
+	// 不支持GetMany时依次读取每个数据块
+	plain := newCountingStore()
+	plain.MemStore = st.MemStore
+	blocks, err = NewDagService(plain).Prefetch(root)
+	if err != nil || len(blocks) != 21 {
+		t.Fatalf("got %d blocks, %v", len(blocks), err)
+	}
+	if plain.blockGets() != 21 {
+		t.Fatalf("%d Get calls, want 21", plain.blockGets())
+	}
+}
+
+func TestPrefetchMissingBlock(t *testing.T) {
+	st := NewMemStore()
+	s := NewDagService(st)
+	root, err := s.Add(flatDir(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := s.ListFiles(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Delete(keys[1].Key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Prefetch(root); !errors.As(err, new(*ErrBlockNotFound)) {
+		t.Fatalf("got %v, want ErrBlockNotFound", err)
+	}
+}
@@ -0,0 +1,53 @@
+package merkledag
+
+import (
+	"sync"
+	"time"
+)
+
+// progressInterval 是两次进度回调之间的最短间隔
+const progressInterval = 100 * time.Millisecond
+
+// ProgressEvent 是Add过程中的进度
+type ProgressEvent struct {
+	// Bytes 为已经处理的文件内容的字节数
+	Bytes int64
+	// Nodes 为已经保存的文件和目录的数量
+	Nodes int
+	// Path 为最近处理的节点相对于根节点的路径
+	Path string
+}
+
+// progress 累计一次Add调用的进度，并按progressInterval节流地调用回调
+type progress struct {
+	fn   func(ProgressEvent)
+	mu   sync.Mutex
+	ev   ProgressEvent
+	last time.Time
+}
+
+// add 记录path处理了bytes字节，完成了nodes个节点。回调在持有锁时调用，事件按顺序到达
+func (p *progress) add(path string, bytes int64, nodes int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ev.Bytes += bytes
+	p.ev.Nodes += nodes
+	p.ev.Path = path
+	if now := time.Now(); now.Sub(p.last) >= progressInterval {
+		p.last = now
+		p.fn(p.ev)
+	}
+}
+
+// done 在Add成功结束时调用，总是报告最终的进度
+func (p *progress) done() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fn(p.ev)
+}
@@ -0,0 +1,77 @@
+package merkledag
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// progressRecorder 记录收到的所有进度事件
+type progressRecorder struct {
+	mu     sync.Mutex
+	events []ProgressEvent
+}
+
+func (r *progressRecorder) record(ev ProgressEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, ev)
+}
+
+func TestProgressImport(t *testing.T) {
+	dir := t.TempDir()
+	writeTestTree(t, dir)
+	rec := &progressRecorder{}
+	s := NewDagService(NewMemStore(), WithProgress(rec.record), WithConcurrency(3))
+	root, err := ImportPath(s, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := s.Get(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.events) == 0 {
+		t.Fatal("no progress events")
+	}
+	// 4个文件和4个目录
+	last := rec.events[len(rec.events)-1]
+	if last.Bytes != n.Size() || last.Nodes != 8 {
+		t.Fatalf("final event %+v, want %d bytes and 8 nodes", last, n.Size())
+	}
+	for i := 1; i < len(rec.events); i++ {
+		if rec.events[i].Bytes < rec.events[i-1].Bytes || rec.events[i].Nodes < rec.events[i-1].Nodes {
+			t.Fatalf("progress went backwards: %+v then %+v", rec.events[i-1], rec.events[i])
+		}
+	}
+}
+
+func TestProgressThrottled(t *testing.T) {
+	rec := &progressRecorder{}
+	s := NewDagService(NewMemStore(), WithProgress(rec.record))
+	if _, err := s.Add(flatDir(10000)); err != nil {
+		t.Fatal(err)
+	}
+	// 一个很快完成的Add只有第一次和最终的事件
+	if len(rec.events) > 10 {
+		t.Fatalf("%d events for one Add", len(rec.events))
+	}
+	if last := rec.events[len(rec.events)-1]; last.Nodes != 10001 {
+		t.Fatalf("final event %+v", last)
+	}
+}
+
+func TestProgressPath(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "only.txt"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rec := &progressRecorder{}
+	if _, err := ImportPath(NewDagService(NewMemStore(), WithProgress(rec.record)), dir); err != nil {
+		t.Fatal(err)
+	}
+	if rec.events[0].Path != "only.txt" {
+		t.Fatalf("first event %+v", rec.events[0])
+	}
+}
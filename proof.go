@@ -0,0 +1,149 @@
+package merkledag
+
+import (
+	"strings"
+)
+
+// ProofStep 是包含证明中的一步：与兄弟哈希Hash组合得到上一层的哈希，
+// Left表示兄弟哈希在左边。扇出大于2时同一组有多个兄弟哈希，
+// 按顺序分别保存在Before和After中，此时Hash为空。
+// First表示这一步属于路径上新的一层目录，设置了WithDomainSeparation时当前哈希先作为叶子计算
+type ProofStep struct {
+	Hash   string
+	Left   bool
+	Before []string
+	After  []string
+	First  bool
+}
+
+// Proof 是叶子哈希包含在某个Merkle Root中的证明，Steps从叶子向根排列。
+// ProveInclusion同时记录叶子哈希Leaf、根节点的键值Root，以及验证时需要的
+// 哈希函数的名字和Merkle树的配置，接收方可以用VerifyProof独立验证
+type Proof struct {
+	Steps            []ProofStep
+	Leaf             string
+	Root             string
+	HashAlgorithm    string
+	Fanout           int
+	DomainSeparation bool
+}
+
+// ProveInclusion 生成root下path对应节点的包含证明，叶子哈希为该节点的Merkle Root。
+// 路径上每一层目录的证明依次拼接，因此证明可以从叶子一直计算到root
+func (s *DagService) ProveInclusion(root, path string) (Proof, error) {
+	var levels [][]ProofStep
+	key := root
+	objType, err := rootType(root)
+	if err != nil {
+		return Proof{}, err
+	}
+	// walked 为已经经过的路径，用于报告不是目录的节点
+	walked := ""
+	for _, name := range strings.Split(path, "/") {
+		if name == "" {
+			continue
+		}
+		if objType != TREE {
+			return Proof{}, &ErrNotADirectory{Path: "/" + walked}
+		}
+		walked = joinPath(walked, name)
+		steps, err := s.lookup(key, name)
+		if err != nil {
+			return Proof{}, err
+		}
+		// 分片目录中经过的每个分片块都是一层
+		blockKey := key
+		for _, step := range steps {
+			if s.persistInternal {
+				stored, ok, err := s.storedProof(s.keyHash(blockKey), len(step.obj.Links)+1, step.index)
+				if err != nil {
+					return Proof{}, err
+				}
+				if ok {
+					levels = append(levels, stored)
+					blockKey = string(step.obj.Links[step.index].Hash)
+					continue
+				}
+			}
+			// 目录的叶子与putDir中的相同：每个子节点的Merkle Root，加上链接的哈希
+			hashes := make([]string, 0, len(step.obj.Links)+1)
+			for _, link := range step.obj.Links {
+				hashes = append(hashes, s.keyHash(string(link.Hash)))
+			}
+			hashes = append(hashes, s.hashBytes(step.data))
+			levels = append(levels, s.merkleProof(hashes, step.index))
+			blockKey = string(step.obj.Links[step.index].Hash)
+		}
+		last := steps[len(steps)-1]
+		key = string(last.obj.Links[last.index].Hash)
+		objType = last.obj.linkType(last.index)
+	}
+
+	proof := Proof{
+		Leaf:             s.keyHash(key),
+		Root:             root,
+		HashAlgorithm:    s.hashAlgorithm(),
+		Fanout:           s.branching(),
+		DomainSeparation: s.domainSeparation,
+	}
+	for i := len(levels) - 1; i >= 0; i-- {
+		proof.Steps = append(proof.Steps, levels[i]...)
+	}
+	return proof, nil
+}
+
+// merkleProof 按calculateMerkleRoot的组合方式，返回hashes[index]到Merkle Root的证明
+func (s *DagService) merkleProof(hashes []string, index int) []ProofStep {
+	var steps []ProofStep
+	hashes = s.leaves(hashes)
+	k := s.branching()
+	for len(hashes) > 1 {
+		start := index / k * k
+		group := hashes[start:min(start+k, len(hashes))]
+		pos := index - start
+		// 只有一个哈希的组原样进入上一层，没有兄弟哈希
+		switch {
+		case len(group) == 2:
+			sibling := 1 - pos
+			steps = append(steps, ProofStep{Hash: group[sibling], Left: sibling < pos})
+		case len(group) > 2:
+			steps = append(steps, ProofStep{
+				Before: append([]string(nil), group[:pos]...),
+				After:  append([]string(nil), group[pos+1:]...),
+			})
+		}
+		index /= k
+		hashes, _ = s.nextLevel(hashes, nil)
+	}
+	if len(steps) > 0 {
+		steps[0].First = true
+	}
+	return steps
+}
+
+// VerifyInclusion 使用默认的哈希函数验证proof能否将leafHash计算到root
+func VerifyInclusion(leafHash string, proof Proof, root string) bool {
+	return NewDagService(nil).VerifyInclusion(leafHash, proof, root)
+}
+
+// VerifyInclusion 用与calculateMerkleRoot相同的组合方式，将leafHash依次与proof中的
+// 兄弟哈希组合，检查结果是否等于root的Merkle Root
+func (s *DagService) VerifyInclusion(leafHash string, proof Proof, root string) bool {
+	// 没有任何步骤时叶子即为Merkle Root，同样需要先作为叶子计算
+	hash := s.leaves([]string{leafHash})[0]
+	for i, step := range proof.Steps {
+		if i > 0 && step.First {
+			hash = s.leaves([]string{hash})[0]
+		}
+		switch {
+		case step.Hash == "":
+			group := append(append(append([]string(nil), step.Before...), hash), step.After...)
+			hash = s.combine(group...)
+		case step.Left:
+			hash = s.combine(step.Hash, hash)
+		default:
+			hash = s.combine(hash, step.Hash)
+		}
+	}
+	return hash == s.keyHash(root)
+}
@@ -0,0 +1,239 @@
+package merkledag
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"hash"
+	"testing"
+)
+
+// eightFiles 返回包含8个文件的目录
+func eightFiles() Dir {
+	b := NewDirBuilder()
+	for i := 0; i < 8; i++ {
+		b.AddFile(fmt.Sprint("f", i), []byte(fmt.Sprint("file ", i)))
+	}
+	return b.Build()
+}
+
+func TestProveInclusion(t *testing.T) {
+	s := NewDagService(NewMemStore())
+	root, err := s.Add(eightFiles())
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err := s.ProveInclusion(root, "f5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := s.Add(NewFile([]byte("file 5")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proof.Leaf != s.keyHash(leaf) || proof.Root != root {
+		t.Fatalf("leaf %s root %s", proof.Leaf, proof.Root)
+	}
+	// 8个子节点加上链接的哈希共9个叶子，需要4层
+	if len(proof.Steps) != 4 {
+		t.Fatalf("got %d steps, want 4", len(proof.Steps))
+	}
+	// 按步骤依次组合，与calculateMerkleRoot的结果相同
+	hash := proof.Leaf
+	for _, step := range proof.Steps {
+		if step.Left {
+			hash = s.combine(step.Hash, hash)
+		} else {
+			hash = s.combine(hash, step.Hash)
+		}
+	}
+	if hash != s.keyHash(root) {
+		t.Fatalf("proof folds to %s, want %s", hash, s.keyHash(root))
+	}
+}
+
+func TestProveInclusionNotFound(t *testing.T) {
+	s := NewDagService(NewMemStore())
+	root, err := s.Add(eightFiles())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ProveInclusion(root, "f9"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+}
+
+// cloneProof 返回p的副本，修改副本的步骤不影响p
+func cloneProof(p Proof) Proof {
+	p.Steps = append([]ProofStep(nil), p.Steps...)
+	return p
+}
+
+func TestVerifyInclusion(t *testing.T) {
+	s := NewDagService(NewMemStore())
+	root, err := s.Add(eightFiles())
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err := s.ProveInclusion(root, "f2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.VerifyInclusion(proof.Leaf, proof, root) {
+		t.Fatal("valid proof rejected")
+	}
+	if !VerifyInclusion(proof.Leaf, proof, root) {
+		t.Fatal("valid proof rejected by the default hasher")
+	}
+	other, err := s.Add(NewDirBuilder().AddFile("f2", []byte("file 2")).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	flipped := cloneProof(proof)
+	flipped.Steps[1].Left = !flipped.Steps[1].Left
+	tampered := cloneProof(proof)
+	tampered.Steps[0].Hash = s.hashBytes([]byte("tampered"))
+	dropped := cloneProof(proof)
+	dropped.Steps = dropped.Steps[:len(dropped.Steps)-1]
+	swapped := cloneProof(proof)
+	swapped.Steps[0], swapped.Steps[1] = swapped.Steps[1], swapped.Steps[0]
+	cases := []struct {
+		name  string
+		leaf  string
+		proof Proof
+		root  string
+	}{
+		{"flipped order", proof.Leaf, flipped, root},
+		{"tampered sibling", proof.Leaf, tampered, root},
+		{"missing step", proof.Leaf, dropped, root},
+		{"swapped steps", proof.Leaf, swapped, root},
+		{"wrong leaf", s.hashBytes([]byte("file 3")), proof, root},
+		{"wrong root", proof.Leaf, proof, other},
+	}
+	for _, c := range cases {
+		if s.VerifyInclusion(c.leaf, c.proof, c.root) {
+			t.Errorf("%s: corrupted proof accepted", c.name)
+		}
+	}
+}
+
+func TestVerifyInclusionUsesHasher(t *testing.T) {
+	s := NewDagService(NewMemStore(), WithHasher(sha512.New))
+	root, err := s.Add(eightFiles())
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err := s.ProveInclusion(root, "f6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.VerifyInclusion(proof.Leaf, proof, root) {
+		t.Fatal("valid sha512 proof rejected")
+	}
+	if VerifyInclusion(proof.Leaf, proof, root) {
+		t.Fatal("sha512 proof accepted with sha256")
+	}
+}
+
+func TestProofFromPersistedInternalNodes(t *testing.T) {
+	calls := 0
+	counting := func() hash.Hash {
+		calls++
+		return sha256.New()
+	}
+	tree := flatDir(1024)
+	st := newCountingStore()
+	stored := NewDagService(st, WithHasher(counting), WithPersistInternalNodes(true))
+	root, err := stored.Add(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	computed := NewDagService(NewMemStore(), WithHasher(counting))
+	if plain, err := computed.Add(tree); err != nil || plain != root {
+		t.Fatalf("persisting internal nodes changed the root: %s, %v", plain, err)
+	}
+	calls = 0
+	want, err := computed.ProveInclusion(root, "f00500")
+	if err != nil {
+		t.Fatal(err)
+	}
+	recomputed := calls
+	calls = 0
+	st.reset()
+	proof, err := stored.ProveInclusion(root, "f00500")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 从根向下每层读取一个内部节点，不重新计算1025个叶子组成的树
+	if calls > recomputed/10 {
+		t.Fatalf("proof computed %d hashes, recomputing the tree takes %d", calls, recomputed)
+	}
+	if gets := len(st.gets); gets > 15 {
+		t.Fatalf("proof read %d blocks", gets)
+	}
+	if len(proof.Steps) != len(want.Steps) {
+		t.Fatalf("%d steps, want %d", len(proof.Steps), len(want.Steps))
+	}
+	for i := range want.Steps {
+		if proof.Steps[i].Hash != want.Steps[i].Hash || proof.Steps[i].Left != want.Steps[i].Left {
+			t.Fatalf("step %d = %+v, want %+v", i, proof.Steps[i], want.Steps[i])
+		}
+	}
+	if !stored.VerifyInclusion(proof.Leaf, proof, root) {
+		t.Fatal("proof from stored nodes rejected")
+	}
+}
+
+// mutateStep 返回替换了第i步中一个兄弟哈希的证明
+func mutateStep(s *DagService, p Proof, i int) Proof {
+	q := cloneProof(p)
+	step := q.Steps[i]
+	other := s.hashBytes([]byte("mutated"))
+	switch {
+	case step.Hash != "":
+		step.Hash = other
+	case len(step.Before) > 0:
+		step.Before = append([]string{other}, step.Before[1:]...)
+	default:
+		step.After = append([]string{other}, step.After[1:]...)
+	}
+	q.Steps[i] = step
+	return q
+}
+
+func FuzzVerifyProof(f *testing.F) {
+	f.Add([]byte("seed"), uint8(1), uint8(0), uint8(2), false)
+	f.Add([]byte("seed"), uint8(2), uint8(1), uint8(2), true)
+	f.Add([]byte{}, uint8(5), uint8(4), uint8(2), false)
+	f.Add([]byte{0xff}, uint8(7), uint8(3), uint8(3), true)
+	f.Add([]byte("wide"), uint8(200), uint8(117), uint8(16), false)
+	f.Fuzz(func(t *testing.T, data []byte, n, idx, fanout uint8, tagged bool) {
+		if n == 0 {
+			return
+		}
+		s := NewDagService(nil, WithFanout(int(fanout%17)), WithDomainSeparation(tagged))
+		hashes := make([]string, n)
+		for i := range hashes {
+			hashes[i] = s.hashBytes(append([]byte{byte(i)}, data...))
+		}
+		index := int(idx) % len(hashes)
+		merkleRoot, err := s.calculateMerkleRoot(hashes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		root := s.formatKey(TREE, merkleRoot)
+		proof := Proof{Steps: s.merkleProof(hashes, index)}
+		if !s.VerifyInclusion(hashes[index], proof, root) {
+			t.Fatalf("proof for leaf %d of %d rejected", index, n)
+		}
+		for i := range proof.Steps {
+			if s.VerifyInclusion(hashes[index], mutateStep(s, proof, i), root) {
+				t.Fatalf("proof with mutated step %d accepted", i)
+			}
+		}
+		if s.VerifyInclusion(s.hashBytes([]byte("other leaf")), proof, root) {
+			t.Fatal("proof accepted for another leaf")
+		}
+	})
+}
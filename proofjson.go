@@ -0,0 +1,83 @@
+package merkledag
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrUnknownHashAlgorithm 表示证明中的哈希函数无法识别
+var ErrUnknownHashAlgorithm = errors.New("unknown hash algorithm")
+
+// proofJSON 是Proof的JSON格式
+type proofJSON struct {
+	Steps            []proofStepJSON `json:"steps"`
+	Leaf             string          `json:"leaf"`
+	Root             string          `json:"root"`
+	Algorithm        string          `json:"algorithm"`
+	Fanout           int             `json:"fanout,omitempty"`
+	DomainSeparation bool            `json:"domainSeparation,omitempty"`
+}
+
+// proofStepJSON 是ProofStep的JSON格式，只有一个兄弟哈希时使用hash和left
+type proofStepJSON struct {
+	Hash   string   `json:"hash,omitempty"`
+	Left   bool     `json:"left"`
+	Before []string `json:"before,omitempty"`
+	After  []string `json:"after,omitempty"`
+	First  bool     `json:"first,omitempty"`
+}
+
+// MarshalJSON 将证明编码为{"steps":[{"hash":"...","left":true}],"leaf":"...","root":"...","algorithm":"..."}，
+// 扇出不为2或区分叶子和内部节点时另外记录fanout和domainSeparation
+func (p Proof) MarshalJSON() ([]byte, error) {
+	out := proofJSON{
+		Steps:            make([]proofStepJSON, len(p.Steps)),
+		Leaf:             p.Leaf,
+		Root:             p.Root,
+		Algorithm:        p.HashAlgorithm,
+		DomainSeparation: p.DomainSeparation,
+	}
+	if p.Fanout > 2 {
+		out.Fanout = p.Fanout
+	}
+	for i, step := range p.Steps {
+		out.Steps[i] = proofStepJSON{Hash: step.Hash, Left: step.Left, Before: step.Before, After: step.After, First: step.First}
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON 解析MarshalJSON的结果，每一步必须有兄弟哈希
+func (p *Proof) UnmarshalJSON(data []byte) error {
+	var in proofJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	proof := Proof{
+		Steps:            make([]ProofStep, len(in.Steps)),
+		Leaf:             in.Leaf,
+		Root:             in.Root,
+		HashAlgorithm:    in.Algorithm,
+		Fanout:           in.Fanout,
+		DomainSeparation: in.DomainSeparation,
+	}
+	for i, step := range in.Steps {
+		if step.Hash == "" && len(step.Before)+len(step.After) == 0 {
+			return fmt.Errorf("proof step %d has no sibling hash: %w", i, errMalformedObject)
+		}
+		proof.Steps[i] = ProofStep{Hash: step.Hash, Left: step.Left, Before: step.Before, After: step.After, First: step.First}
+	}
+	*p = proof
+	return nil
+}
+
+// VerifyProof 使用证明中记录的哈希函数和Merkle树的配置，验证p.Leaf是否包含在p.Root中
+func VerifyProof(p Proof) (bool, error) {
+	for _, known := range knownHashes {
+		if known.name == p.HashAlgorithm {
+			s := NewDagService(nil, WithHasher(known.new), WithFanout(p.Fanout), WithDomainSeparation(p.DomainSeparation))
+			return s.VerifyInclusion(p.Leaf, p, p.Root), nil
+		}
+	}
+	return false, fmt.Errorf("%q: %w", p.HashAlgorithm, ErrUnknownHashAlgorithm)
+}
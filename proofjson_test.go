@@ -0,0 +1,66 @@
+package merkledag
+
+import (
+	"crypto/sha512"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"testing"
+)
+
+func proofTree() Dir {
+	b := NewDirBuilder()
+	for i := 0; i < 20; i++ {
+		b.AddFile(fmt.Sprint("f", i), []byte(fmt.Sprint("content ", i)))
+	}
+	return b.Build()
+}
+
+func TestProofJSONRoundTrip(t *testing.T) {
+	hashers := []struct {
+		name string
+		new  func() hash.Hash
+	}{
+		{"sha256", nil},
+		{"sha512", sha512.New},
+		{"blake2b-256", newBlake2b256},
+		{"blake2b-512", newBlake2b512},
+	}
+	for _, h := range hashers {
+		t.Run(h.name, func(t *testing.T) {
+			var opts []Option
+			if h.new != nil {
+				opts = append(opts, WithHasher(h.new))
+			}
+			s := NewDagService(NewMemStore(), opts...)
+			root, err := s.Add(proofTree())
+			if err != nil {
+				t.Fatal(err)
+			}
+			proof, err := s.ProveInclusion(root, "f7")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if proof.HashAlgorithm != h.name {
+				t.Fatalf("algorithm %q, want %q", proof.HashAlgorithm, h.name)
+			}
+			data, err := json.Marshal(proof)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// 反序列化后只依靠JSON中的内容验证，不使用原来的DagService
+			var decoded Proof
+			if err := json.Unmarshal(data, &decoded); err != nil {
+				t.Fatal(err)
+			}
+			ok, err := VerifyProof(decoded)
+			if err != nil || !ok {
+				t.Fatalf("VerifyProof = %v, %v", ok, err)
+			}
+			decoded.Leaf = proof.Root
+			if ok, _ := VerifyProof(decoded); ok {
+				t.Fatal("proof verified for the wrong leaf")
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package merkledag
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Prune 返回root去掉path处的子树后的新root，以及原来root可达、新root不再可达的数据块的键值freedKeys，
+// 按字典序排列。只重新保存路径上的目录，其余子树被新root直接引用；被去掉的子树中仍被树的其他部分共享的数据块
+// 不在freedKeys中。Prune不删除任何数据块，freedKeys中的数据块没有被其他root引用时可以直接删除。
+// path为空（或只有"/"和"."）时返回ErrEmptyInput，路径上的节点不存在时返回ErrNotFound
+func (s *DagService) Prune(root string, path string) (string, []string, error) {
+	// dirs[i]为names[i]所在的目录
+	var dirs, names []string
+	key := root
+	objType, err := rootType(root)
+	if err != nil {
+		return "", nil, err
+	}
+	walked := ""
+	for _, name := range strings.Split(s.normalizeName(path), "/") {
+		if name == "" || name == "." {
+			continue
+		}
+		if objType != TREE {
+			return "", nil, &ErrNotADirectory{Path: "/" + walked}
+		}
+		walked = joinPath(walked, name)
+		steps, err := s.lookup(key, name)
+		if err != nil {
+			return "", nil, err
+		}
+		dirs, names = append(dirs, key), append(names, name)
+		last := steps[len(steps)-1]
+		key = string(last.obj.Links[last.index].Hash)
+		objType = last.obj.linkType(last.index)
+	}
+	if len(dirs) == 0 {
+		return "", nil, fmt.Errorf("prune %q: %w", path, ErrEmptyInput)
+	}
+	newRoot, _, err := s.run(context.Background(), false, func(a *adder) (string, error) {
+		var key string
+		var size int64
+		// 从最深的目录开始：先去掉目录项，再依次替换上层目录中的目录项
+		for i := len(dirs) - 1; i >= 0; i-- {
+			entries, meta, err := s.dirEntries(dirs[i])
+			if err != nil {
+				return "", err
+			}
+			if i == len(dirs)-1 {
+				entries = removeEntry(entries, names[i])
+			} else {
+				childType, err := rootType(key)
+				if err != nil {
+					return "", err
+				}
+				entries = setEntry(entries, shardEntry{
+					link: Link{Name: names[i], Hash: []byte(key), Size: size},
+					tag:  childType,
+					hash: s.keyHash(key),
+				})
+			}
+			if key, err = a.putEntries(entries, meta); err != nil {
+				return "", err
+			}
+			size = 0
+			for _, e := range entries {
+				size += e.link.Size
+			}
+		}
+		return key, nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	freed, err := s.unreachableFrom(root, newRoot)
+	if err != nil {
+		return "", nil, err
+	}
+	return newRoot, freed, nil
+}
+
+// removeEntry 返回去掉entries中名为name的目录项后的目录项
+func removeEntry(entries []shardEntry, name string) []shardEntry {
+	for i := range entries {
+		if entries[i].link.Name == name {
+			return append(entries[:i], entries[i+1:]...)
+		}
+	}
+	return entries
+}
+
+// unreachableFrom 返回oldRoot可达而newRoot不可达的数据块的键值，按字典序排列。内嵌文件没有数据块，不包含在内
+func (s *DagService) unreachableFrom(oldRoot string, newRoot string) ([]string, error) {
+	kept := make(map[string]bool)
+	if err := s.markReachable(newRoot, kept); err != nil {
+		return nil, err
+	}
+	// 新root可达的数据块都已标记，遍历旧root时不再进入共享的子树
+	old := make(map[string]bool, len(kept))
+	for key := range kept {
+		old[key] = true
+	}
+	if err := s.markReachable(oldRoot, old); err != nil {
+		return nil, err
+	}
+	var freed []string
+	for key := range old {
+		if _, ok := inlineData(key); ok || kept[key] {
+			continue
+		}
+		freed = append(freed, key)
+	}
+	sort.Strings(freed)
+	return freed, nil
+}
@@ -0,0 +1,106 @@
+package merkledag
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestPrune(t *testing.T) {
+	mem := NewMemStore()
+	s := NewDagService(mem)
+	shared := bytes.Repeat([]byte("shared"), 50)
+	sub := NewDirBuilder().
+		AddFile("shared", shared).
+		AddFile("only", bytes.Repeat([]byte("only"), 50)).
+		AddDir("deep", NewDirBuilder().AddFile("x", []byte("deep x")).Build()).
+		Build()
+	// sib中的copy与sub中的shared内容相同，共享一个数据块
+	sib := NewDirBuilder().AddFile("copy", shared).AddFile("y", []byte("y")).Build()
+	root, err := s.Add(NewDirBuilder().
+		AddDir("a", NewDirBuilder().AddDir("sub", sub).AddFile("keep", []byte("k")).Build()).
+		AddDir("sib", sib).
+		Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	newRoot, freed, err := s.Prune(root, "/a/sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := s.Add(NewDirBuilder().
+		AddDir("a", NewDirBuilder().AddFile("keep", []byte("k")).Build()).
+		AddDir("sib", sib).
+		Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newRoot != want {
+		t.Fatalf("pruned root %s, want %s", newRoot, want)
+	}
+
+	// freed恰好是旧root可达而新root不可达的数据块：sub、only、deep、x和旧的a与root
+	before, after := make(map[string]bool), make(map[string]bool)
+	if err := s.markReachable(root, before); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.markReachable(newRoot, after); err != nil {
+		t.Fatal(err)
+	}
+	var unreachable []string
+	for key := range before {
+		if !after[key] {
+			unreachable = append(unreachable, key)
+		}
+	}
+	sort.Strings(unreachable)
+	if len(freed) != 6 || strings.Join(freed, ",") != strings.Join(unreachable, ",") {
+		t.Fatalf("freed %v, want %v", freed, unreachable)
+	}
+	sharedKey, err := RootOf(NewFile(shared))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range freed {
+		if key == sharedKey {
+			t.Fatal("block shared with the sibling freed")
+		}
+		if err := mem.Delete(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if report, err := VerifyStore(s, []string{newRoot}); err != nil || !report.OK() {
+		t.Fatalf("pruned tree incomplete after deleting freed blocks: %v", err)
+	}
+
+	// 去掉最后一个引用后共享的数据块也被释放
+	_, freed, err = s.Prune(newRoot, "sib/copy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i := sort.SearchStrings(freed, sharedKey); len(freed) != 3 || i == len(freed) || freed[i] != sharedKey {
+		t.Fatalf("freed %v, want the old root, sib and %s", freed, sharedKey)
+	}
+}
+
+func TestPruneErrors(t *testing.T) {
+	s := NewDagService(NewMemStore())
+	root, err := s.Add(NewDirBuilder().AddDir("a", NewDirBuilder().AddFile("keep", []byte("k")).Build()).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"", "/", "."} {
+		if _, _, err := s.Prune(root, path); !errors.Is(err, ErrEmptyInput) {
+			t.Errorf("Prune(%q): got %v, want ErrEmptyInput", path, err)
+		}
+	}
+	if _, _, err := s.Prune(root, "a/nope"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing entry: got %v, want ErrNotFound", err)
+	}
+	var notDir *ErrNotADirectory
+	if _, _, err := s.Prune(root, "a/keep/x"); !errors.As(err, &notDir) {
+		t.Errorf("path through a file: got %v, want ErrNotADirectory", err)
+	}
+}
@@ -0,0 +1,47 @@
+package merkledag
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMaxTotalSizeRollsBack(t *testing.T) {
+	dir := t.TempDir()
+	writeTestTree(t, dir)
+	st := &batchingStore{countingStore: newCountingStore()}
+	s := NewDagService(st, WithMaxTotalSize(100*K))
+	if _, err := ImportPath(s, dir); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("got %v, want ErrQuotaExceeded", err)
+	}
+	// 批量写入没有提交，除了存储头没有任何数据块
+	keys, err := st.Keys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range keys {
+		if key != headerKey {
+			t.Fatalf("block %s leaked from the aborted import", key)
+		}
+	}
+	// 上限足够时导入成功
+	s = NewDagService(st, WithMaxTotalSize(10*BLOCK_SIZE))
+	if _, err := ImportPath(s, dir); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMaxTotalSizeCountsNewBlocksOnly(t *testing.T) {
+	st := NewMemStore()
+	tree := NewDirBuilder().AddFile("a", chunkedSource(1000)).Build()
+	if _, err := NewDagService(st).Add(tree); err != nil {
+		t.Fatal(err)
+	}
+	// 已经存在的数据块不计入上限
+	if _, err := NewDagService(st, WithMaxTotalSize(10)).Add(tree); err != nil {
+		t.Fatal(err)
+	}
+	_, err := NewDagService(st, WithMaxTotalSize(10)).Add(NewFile(chunkedSource(11)))
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("got %v, want ErrQuotaExceeded", err)
+	}
+}
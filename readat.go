@@ -0,0 +1,87 @@
+package merkledag
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// rangeRef 是ReadAt中待读取的一个链接，start为其内容在文件中的起始位置，list为记录这个链接的LIST的键值
+type rangeRef struct {
+	blockRef
+	start int64
+	size  int64
+	list  string
+}
+
+// ReadAt 从root下path处的文件的off位置开始读取len(p)字节到p中，语义与io.ReaderAt相同：
+// 读到的字节数少于len(p)时返回io.EOF。根据LIST中每个链接记录的大小跳过不需要的数据块，
+// 只读取覆盖[off, off+len(p))的数据块。读到的数据块的长度或子LIST的总大小与链接记录的大小不符时，
+// 返回记录该链接的LIST的ErrCorruptBlock
+func (s *DagService) ReadAt(root string, path string, p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	key, objType, err := s.resolveKey(root, path)
+	if err != nil {
+		return 0, err
+	}
+	if objType != BLOB && objType != LIST {
+		return 0, ErrNotAFile
+	}
+	n := 0
+	stack := []rangeRef{{blockRef: blockRef{key: key, objType: objType}, size: -1}}
+	for len(stack) > 0 && n < len(p) {
+		ref := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		want := off + int64(n)
+		// 根节点的大小未知，总是需要读取
+		if ref.size >= 0 && ref.start+ref.size <= want {
+			continue
+		}
+		if ref.objType == LIST {
+			obj, err := s.readObject(ref.key)
+			if err != nil {
+				return n, err
+			}
+			// 逆序入栈，使链接按文件中的顺序被处理
+			end := ref.start
+			for _, link := range obj.Links {
+				end += link.Size
+			}
+			if ref.size >= 0 && end-ref.start != ref.size {
+				return n, sizeMismatch(ref, end-ref.start)
+			}
+			for i := len(obj.Links) - 1; i >= 0; i-- {
+				link := obj.Links[i]
+				end -= link.Size
+				stack = append(stack, rangeRef{
+					blockRef: blockRef{key: string(link.Hash), objType: obj.linkType(i)},
+					start:    end,
+					size:     link.Size,
+					list:     ref.key,
+				})
+			}
+			continue
+		}
+		data, err := s.getBlock(ref.key)
+		if err != nil {
+			return n, err
+		}
+		if ref.size >= 0 && int64(len(data)) != ref.size {
+			return n, sizeMismatch(ref, int64(len(data)))
+		}
+		if skip := want - ref.start; skip < int64(len(data)) {
+			n += copy(p[n:], data[skip:])
+		}
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// sizeMismatch 返回链接ref记录的大小与实际大小size不符时的错误
+func sizeMismatch(ref rangeRef, size int64) error {
+	return &ErrCorruptBlock{Key: ref.list, Err: fmt.Errorf("link %s has size %d, content has %d bytes", ref.key, ref.size, size)}
+}
@@ -0,0 +1,114 @@
+package merkledag
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+)
+
+// readCountingStore 记录数据块被Get的次数
+type readCountingStore struct {
+	*MemStore
+	mu   sync.Mutex
+	gets int
+}
+
+func (r *readCountingStore) Get(key string) ([]byte, error) {
+	if _, err := rootType(key); err == nil {
+		r.mu.Lock()
+		r.gets++
+		r.mu.Unlock()
+	}
+	return r.MemStore.Get(key)
+}
+
+func (r *readCountingStore) blockGets() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.gets
+}
+
+func (r *readCountingStore) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.gets = 0
+}
+
+// chunkedSource 返回n字节不重复的内容，按1000字节切块时每块都不同
+func chunkedSource(n int) []byte {
+	src := make([]byte, n)
+	for i := range src {
+		src[i] = byte(i * 7)
+	}
+	return src
+}
+
+func TestReadAtSkipsChunks(t *testing.T) {
+	src := chunkedSource(10000)
+	st := &readCountingStore{MemStore: NewMemStore()}
+	s := NewDagService(st, WithChunkSize(1000))
+	dir, err := s.Add(NewDirBuilder().Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := s.AddEntry(dir, "big", NewChunkedFile(bytes.NewReader(src), int64(len(src))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := make([]byte, 1024)
+	st.reset()
+	n, err := s.ReadAt(root, "big", p, 4500)
+	if n != len(p) || err != nil || !bytes.Equal(p, src[4500:5524]) {
+		t.Fatalf("got %d, %v", n, err)
+	}
+	// 目录、LIST和覆盖该范围的两个数据块
+	if st.blockGets() != 4 {
+		t.Fatalf("read %d blocks, want 4", st.blockGets())
+	}
+	n, err = s.ReadAt(root, "big", p, 9500)
+	if n != 500 || err != io.EOF || !bytes.Equal(p[:n], src[9500:]) {
+		t.Fatalf("got %d, %v", n, err)
+	}
+	if n, err = s.ReadAt(root, "big", p, 20000); n != 0 || err != io.EOF {
+		t.Fatalf("got %d, %v past the end", n, err)
+	}
+}
+
+func TestReadAtCorruptList(t *testing.T) {
+	src := chunkedSource(5000)
+	for _, delta := range []int64{-300, 300, 5000} {
+		st := NewMemStore()
+		s := NewDagService(st, WithChunkSize(1000))
+		key, err := s.Add(NewChunkedFile(bytes.NewReader(src), int64(len(src))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 改写LIST中第一个链接记录的大小，数据块本身不变
+		obj, err := s.readObject(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		obj.Links[0].Size += delta
+		data, err := s.serializer.Marshal(obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := st.Put(key, data); err != nil {
+			t.Fatal(err)
+		}
+		// 读到受影响的数据块时报告错误，其他位置也不能panic
+		for _, off := range []int64{0, 500, 1500, 4999} {
+			p := make([]byte, 2000)
+			_, err := s.ReadAt(key, "", p, off)
+			if off >= 1000 {
+				continue
+			}
+			var corrupt *ErrCorruptBlock
+			if !errors.As(err, &corrupt) || corrupt.Key != key {
+				t.Fatalf("delta %d off %d: got %v, want ErrCorruptBlock for %s", delta, off, err, key)
+			}
+		}
+	}
+}
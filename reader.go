@@ -0,0 +1,34 @@
+package merkledag
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+)
+
+// readerFile 是从io.Reader读取的File，digest为读取时计算出的内容的SHA-256
+type readerFile struct {
+	file
+	digest string
+}
+
+// NewFileFromReader 读取r的全部内容作为File，读取的同时计算内容的SHA-256。
+// 使用默认哈希函数的DagService保存该File时直接使用这个哈希，不需要再读一遍内容。
+// 内容全部保存在内存中，很大的数据应当使用NewChunkedFile
+func NewFileFromReader(r io.Reader) (File, error) {
+	var buf bytes.Buffer
+	h := sha256.New()
+	if _, err := io.Copy(&buf, io.TeeReader(r, h)); err != nil {
+		return nil, err
+	}
+	return &readerFile{file: file{data: buf.Bytes()}, digest: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// contentHash 返回File的内容data的哈希，读取时已经计算过且哈希函数相同时直接使用
+func (s *adder) contentHash(node Node, data []byte) string {
+	if f, ok := node.(*readerFile); ok && s.sha256 {
+		return f.digest
+	}
+	return s.hashBytes(data)
+}
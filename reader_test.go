@@ -0,0 +1,47 @@
+package merkledag
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"testing/iotest"
+)
+
+func TestNewFileFromReader(t *testing.T) {
+	data := chunkedSource(5000)
+	f, err := NewFileFromReader(iotest.OneByteReader(bytes.NewReader(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(f.Bytes(), data) || f.Size() != int64(len(data)) {
+		t.Fatal("file content differs from the consumed bytes")
+	}
+	sum := sha256.Sum256(data)
+	key, err := NewDagService(NewMemStore()).Add(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "file_" + hex.EncodeToString(sum[:]); key != want {
+		t.Fatalf("key %s, want %s", key, want)
+	}
+	// 其他哈希函数重新计算内容的哈希
+	s := NewDagService(NewMemStore(), WithHasher(sha512.New))
+	got, err := s.Add(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := s.Add(NewFile(data))
+	if err != nil || got != want {
+		t.Fatalf("sha512 key %s, want %s (%v)", got, want, err)
+	}
+}
+
+func TestNewFileFromReaderError(t *testing.T) {
+	boom := errors.New("boom")
+	if _, err := NewFileFromReader(iotest.ErrReader(boom)); !errors.Is(err, boom) {
+		t.Fatalf("got %v, want the reader's error", err)
+	}
+}
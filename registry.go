@@ -0,0 +1,100 @@
+package merkledag
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// NodeCodec 负责一种自定义节点的编码。自定义节点没有子节点，整个节点保存在一个数据块中，
+// 不能实现Dir。键值为Tag()加"_"再加Merkle Root，设置了WithCIDKeys时也是如此
+type NodeCodec interface {
+	// Tag 返回数据块的类型标记，必须是STEP个字节，且不与内置类型和其他已注册的类型重复
+	Tag() string
+	// Encode 将节点编码为数据块的内容
+	Encode(node Node) ([]byte, error)
+	// Decode 由数据块的内容还原节点，还原出的节点的Type()应为注册时的类型
+	Decode(data []byte) (Node, error)
+	// Leaves 返回数据块data参与Merkle Root计算的叶子，每个叶子的哈希作为Merkle树的一个叶子。
+	// 同样的data必须返回同样的叶子，通常返回[][]byte{data}
+	Leaves(data []byte) [][]byte
+}
+
+// nodeRegistry 保存所有注册的自定义节点类型，可以被多个goroutine同时使用
+var nodeRegistry = struct {
+	sync.RWMutex
+	byType map[NodeType]NodeCodec
+	byTag  map[string]NodeType
+}{byType: make(map[NodeType]NodeCodec), byTag: make(map[string]NodeType)}
+
+// builtinTags 是内置的类型标记，自定义类型不能使用
+var builtinTags = []string{TREE, BLOB, LIST, LINK, SHARD, SNAPSHOT, MERKLE, COMMIT}
+
+// RegisterNodeType 注册类型为typ的自定义节点的编码，之后Add、Get、Resolve、Walk和GC等
+// 都能处理这种节点。typ不能是内置类型，typ或codec.Tag()已被注册时返回错误。通常在init中调用
+func RegisterNodeType(typ NodeType, codec NodeCodec) error {
+	if typ >= FILE && typ <= PLACEHOLDER {
+		return fmt.Errorf("register node type %d: built-in type", typ)
+	}
+	tag := codec.Tag()
+	if len(tag) != STEP || strings.Contains(tag, "_") {
+		return fmt.Errorf("register node type %d: tag %q must be %d bytes without '_'", typ, tag, STEP)
+	}
+	for _, builtin := range builtinTags {
+		if tag == builtin {
+			return fmt.Errorf("register node type %d: tag %q is built-in", typ, tag)
+		}
+	}
+	nodeRegistry.Lock()
+	defer nodeRegistry.Unlock()
+	if _, ok := nodeRegistry.byType[typ]; ok {
+		return fmt.Errorf("register node type %d: already registered", typ)
+	}
+	if _, ok := nodeRegistry.byTag[tag]; ok {
+		return fmt.Errorf("register node type %d: tag %q already registered", typ, tag)
+	}
+	nodeRegistry.byType[typ] = codec
+	nodeRegistry.byTag[tag] = typ
+	return nil
+}
+
+// codecForNode 返回node的类型注册的编码，内置类型和没有注册的类型ok为false
+func codecForNode(node Node) (NodeCodec, bool) {
+	if node == nil {
+		return nil, false
+	}
+	nodeRegistry.RLock()
+	defer nodeRegistry.RUnlock()
+	codec, ok := nodeRegistry.byType[NodeType(node.Type())]
+	return codec, ok
+}
+
+// codecForTag 返回类型标记为tag的自定义类型及其编码
+func codecForTag(tag string) (NodeType, NodeCodec, bool) {
+	nodeRegistry.RLock()
+	defer nodeRegistry.RUnlock()
+	typ, ok := nodeRegistry.byTag[tag]
+	if !ok {
+		return 0, nil, false
+	}
+	return typ, nodeRegistry.byType[typ], true
+}
+
+// customLeaves 返回自定义节点的数据块data的各个叶子的哈希
+func (s *DagService) customLeaves(codec NodeCodec, data []byte) []string {
+	var hashes []string
+	for _, leaf := range codec.Leaves(data) {
+		hashes = append(hashes, s.hashBytes(leaf))
+	}
+	return hashes
+}
+
+// putCustom 编码并保存自定义节点
+func (s *adder) putCustom(node Node, codec NodeCodec, path string) (string, string, error) {
+	data, err := codec.Encode(node)
+	if err != nil {
+		return "", "", fmt.Errorf("encode %T: %w", node, err)
+	}
+	s.progress.add(path, int64(len(data)), 1)
+	return s.putEncoded(node, data, s.customLeaves(codec, data))
+}
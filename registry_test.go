@@ -0,0 +1,126 @@
+package merkledag
+
+import (
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TOMBSTONE 是测试用的自定义节点类型，表示被删除的文件
+const TOMBSTONE NodeType = 100
+
+type tombstone struct {
+	reason  string
+	deleted int64
+}
+
+func (t *tombstone) Size() int64 { return 0 }
+func (t *tombstone) Type() int   { return int(TOMBSTONE) }
+
+type tombstoneCodec struct{}
+
+func (tombstoneCodec) Tag() string { return "tomb" }
+
+func (tombstoneCodec) Encode(node Node) ([]byte, error) {
+	t := node.(*tombstone)
+	return append(binary.AppendVarint(nil, t.deleted), t.reason...), nil
+}
+
+func (tombstoneCodec) Decode(data []byte) (Node, error) {
+	deleted, n := binary.Varint(data)
+	if n <= 0 {
+		return nil, errors.New("bad tombstone")
+	}
+	return &tombstone{reason: string(data[n:]), deleted: deleted}, nil
+}
+
+// Leaves 只返回删除的原因，删除时间不参与键值
+func (tombstoneCodec) Leaves(data []byte) [][]byte {
+	_, n := binary.Varint(data)
+	return [][]byte{data[n:]}
+}
+
+// registerTombstone 注册只能进行一次，重复运行测试时跳过
+var registerTombstone sync.Once
+
+func tombstoneTree(deleted int64) Node {
+	return NewDirBuilder().
+		AddFile("a", []byte("alive")).
+		add("gone", &tombstone{reason: "removed by user", deleted: deleted}).
+		AddDir("sub", NewDirBuilder().add("t2", &tombstone{reason: "x", deleted: 1}).Build()).
+		Build()
+}
+
+func TestCustomNodeType(t *testing.T) {
+	registerTombstone.Do(func() {
+		if err := RegisterNodeType(TOMBSTONE, tombstoneCodec{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if RegisterNodeType(TOMBSTONE, tombstoneCodec{}) == nil {
+		t.Fatal("registered the same type twice")
+	}
+	if RegisterNodeType(FILE, tombstoneCodec{}) == nil {
+		t.Fatal("registered a built-in type")
+	}
+	for _, opts := range [][]Option{
+		nil,
+		{WithCIDKeys(true)},
+		{WithVerifyOnGet(true), WithTypePrefix(true), WithShardThreshold(2), WithInlineThreshold(10)},
+		{WithValidate(true), WithKeyEncoding(Base58Encoder{})},
+	} {
+		s := NewDagService(NewMemStore(), opts...)
+		root, err := s.Add(tombstoneTree(1234))
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := s.Resolve(root, "gone")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tomb, ok := n.(*tombstone); !ok || tomb.reason != "removed by user" || tomb.deleted != 1234 {
+			t.Fatalf("got %#v back", n)
+		}
+		got, err := s.Get(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again, err := s.Add(got); err != nil || again != root {
+			t.Fatalf("re-adding the retrieved tree gave %s, %v; want %s", again, err, root)
+		}
+		if report, err := VerifyStore(s, []string{root}); err != nil || !report.OK() {
+			t.Fatalf("tree does not verify: %v", err)
+		}
+		entries, err := s.ReadDir(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entries[1].Name != "gone" || entries[1].Type != int(TOMBSTONE) || !strings.HasPrefix(entries[1].Key, "tomb_") {
+			t.Fatalf("entries = %+v", entries)
+		}
+		if st, err := s.Stat(entries[1].Key); err != nil || st.Type != TOMBSTONE || st.Type.String() != "tomb" {
+			t.Fatalf("Stat = %+v, %v", st, err)
+		}
+		// Leaves不包含删除时间，删除时间不同的树键值相同
+		if other, err := s.Add(tombstoneTree(99)); err != nil || other != root {
+			t.Fatalf("deletion time changed the root: %s, %v", other, err)
+		}
+		if err := s.Pin(root); err != nil {
+			t.Fatal(err)
+		}
+		if removed, err := s.GC(); err != nil || removed != 0 {
+			t.Fatalf("GC removed %d blocks of a pinned tree, %v", removed, err)
+		}
+		dst := NewDagService(NewMemStore(), WithHasher(sha512.New))
+		newRoot, err := Rehash(s, dst, root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n, err := dst.Resolve(newRoot, "sub/t2"); err != nil || n.(*tombstone).reason != "x" {
+			t.Fatalf("rehashed tombstone: %v", err)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package merkledag
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Rehash 将src中root对应的DAG按dst的配置（哈希函数、键值编码、切块等）重建到dst中，返回新的根节点的键值。
+// 文件内容、目录结构、符号链接和元数据保持不变，只有键值和Merkle Root改变，可以用EqualAcross验证。
+// 目录在处理到时才从src读取，分块文件边读边写入，不会把整棵树读入内存。
+// root为快照或提交时重建的是其指向的树，返回的是新的树的根节点
+func Rehash(src *DagService, dst *DagService, root string) (string, error) {
+	objType, err := rootType(root)
+	if err != nil {
+		return "", err
+	}
+	ref := blockRef{key: root, objType: objType}
+	if objType == COMMIT {
+		c, err := src.ReadCommit(root)
+		if err != nil {
+			return "", err
+		}
+		if ref.objType, err = rootType(c.Tree); err != nil {
+			return "", err
+		}
+		ref.key = c.Tree
+	}
+	if ref, err = src.snapshotRoot(ref); err != nil {
+		return "", err
+	}
+	r := &rehasher{src: src}
+	node, err := r.node(ref, 0)
+	if err != nil {
+		return "", err
+	}
+	newRoot, err := dst.Add(node)
+	if err := r.firstErr(); err != nil {
+		return "", err
+	}
+	return newRoot, err
+}
+
+// rehasher 保存一次Rehash调用中的状态
+type rehasher struct {
+	src *DagService
+
+	mu  sync.Mutex
+	err error
+}
+
+// fail 记录读取src时遇到的第一个错误。Node的接口无法返回错误，因此错误在Add结束后由Rehash返回
+func (r *rehasher) fail(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+func (r *rehasher) firstErr() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// node 返回src中ref对应的节点，size为父目录中记录的大小。目录和分块文件的内容在使用时才读取
+func (r *rehasher) node(ref blockRef, size int64) (Node, error) {
+	switch ref.objType {
+	case BLOB:
+		return &srcFile{r: r, key: ref.key, size: size}, nil
+	case LIST:
+		// 读取LIST以得到元数据，内容在Add读取时才逐块取出
+		obj, err := r.src.readObject(ref.key)
+		if err != nil {
+			return nil, err
+		}
+		var total int64
+		for _, link := range obj.Links {
+			total += link.Size
+		}
+		f := NewChunkedFile(&listReader{s: r.src, stack: []*listCursor{{obj: obj}}}, total)
+		f.SetMetadata(obj.Meta)
+		return f, nil
+	case LINK:
+		data, err := r.src.getBlock(ref.key)
+		if err != nil {
+			return nil, err
+		}
+		return &symlink{target: string(data)}, nil
+	case TREE:
+		return &srcDir{r: r, key: ref.key, size: size}, nil
+	default:
+		if _, codec, ok := codecForTag(ref.objType); ok {
+			data, err := r.src.getBlock(ref.key)
+			if err != nil {
+				return nil, err
+			}
+			node, err := codec.Decode(data)
+			if err != nil {
+				return nil, corruptBlock(ref.key, err)
+			}
+			return node, nil
+		}
+		return nil, fmt.Errorf("rehash %s: %w", ref.key, ErrUnsupportedNodeType)
+	}
+}
+
+// srcFile 是src中保存为单个BLOB的文件，内容在Bytes时才读取
+type srcFile struct {
+	r    *rehasher
+	key  string
+	size int64
+}
+
+func (f *srcFile) Size() int64 {
+	return f.size
+}
+
+func (f *srcFile) Type() int {
+	return FILE
+}
+
+func (f *srcFile) Bytes() []byte {
+	data, err := f.r.src.getBlock(f.key)
+	if err != nil {
+		f.r.fail(err)
+	}
+	return data
+}
+
+// srcDir 是src中的目录，目录块在第一次使用时才读取
+type srcDir struct {
+	r       *rehasher
+	key     string
+	size    int64
+	meta    *Metadata
+	entries []entry
+	listed  bool
+}
+
+func (d *srcDir) list() []entry {
+	if d.listed {
+		return d.entries
+	}
+	d.listed = true
+	obj, err := d.r.src.readDir(d.key)
+	if err != nil {
+		d.r.fail(err)
+		return nil
+	}
+	d.meta = obj.Meta
+	for i, link := range obj.Links {
+		child, err := d.r.node(blockRef{key: string(link.Hash), objType: obj.linkType(i)}, link.Size)
+		if err != nil {
+			d.r.fail(err)
+			return nil
+		}
+		d.entries = append(d.entries, entry{name: link.Name, node: child})
+	}
+	return d.entries
+}
+
+func (d *srcDir) Size() int64 {
+	return d.size
+}
+
+func (d *srcDir) Type() int {
+	return DIR
+}
+
+func (d *srcDir) Metadata() *Metadata {
+	d.list()
+	return d.meta
+}
+
+func (d *srcDir) It() DirIterator {
+	return &dirIterator{entries: d.list(), cur: -1}
+}
@@ -0,0 +1,76 @@
+package merkledag
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRehash(t *testing.T) {
+	opts := []Option{WithChunkSize(1000), WithShardThreshold(4), WithInlineThreshold(3)}
+	src := NewDagService(NewMemStore(), opts...)
+	dst := NewDagService(NewMemStore(), WithHasher(newBlake2b256), WithChunkSize(700))
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "a", "b"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a", "big"), bytes.Repeat([]byte("xyz"), 2000), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a", "b", "s"), []byte("small"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("a/big", filepath.Join(dir, "ln")); err != nil {
+		t.Fatal(err)
+	}
+	imported, err := ImportPath(src, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := NewDirBuilder()
+	for i := 0; i < 10; i++ {
+		b.AddFile(fmt.Sprint("f", i), []byte(fmt.Sprint(i*i)))
+	}
+	sharded, err := src.Add(b.AddFile("big", bytes.Repeat([]byte("q"), 5000)).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, root := range []string{imported, sharded} {
+		newRoot, err := Rehash(src, dst, root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if newRoot == root {
+			t.Fatalf("root %s unchanged", root)
+		}
+		eq, err := EqualAcross(src, root, dst, newRoot)
+		if err != nil || !eq {
+			t.Fatalf("rehashed tree differs: %v, %v", eq, err)
+		}
+		if report, err := VerifyStore(dst, []string{newRoot}); err != nil || !report.OK() {
+			t.Fatalf("rehashed tree does not verify: %v", err)
+		}
+	}
+
+	// 元数据随树迁移，迁回原来的配置得到原来的root
+	newRoot, err := Rehash(src, dst, imported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := dst.Resolve(newRoot, "a/big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta := metadataOf(n); meta == nil || meta.Mode.Perm() != 0o600 {
+		t.Fatalf("metadata not preserved: %+v", meta)
+	}
+	back, err := Rehash(dst, NewDagService(NewMemStore(), opts...), newRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if back != imported {
+		t.Fatalf("round trip root %s, want %s", back, imported)
+	}
+}
@@ -0,0 +1,115 @@
+package merkledag
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+)
+
+// ReImportPath 与ImportPath相同，但对于prevRoot中已经存在的文件，
+// 如果文件系统中的大小和元数据（权限、修改时间、属主和扩展属性）与prevRoot中记录的相同，
+// 直接引用原来的数据块而不重新读取和计算哈希。prevRoot必须是之前导入fsPath得到的、
+// 保存在service中的根节点
+func ReImportPath(service *DagService, fsPath string, prevRoot string, opts ...ImportOption) (string, error) {
+	prev, err := service.importedFiles(fsPath, prevRoot)
+	if err != nil {
+		return "", err
+	}
+	return ImportPath(service, fsPath, append(opts, func(imp *importer) {
+		imp.prev = prev
+	})...)
+}
+
+// importedFiles 遍历prevRoot的目录块，返回每个文件在fsPath下的路径到其键值的映射，不读取文件的数据块
+func (s *DagService) importedFiles(fsPath string, prevRoot string) (map[string]string, error) {
+	objType, err := rootType(prevRoot)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]string)
+	if objType != TREE {
+		files[filepath.Clean(fsPath)] = prevRoot
+		return files, nil
+	}
+	type frame struct {
+		key  string
+		path string
+	}
+	stack := []frame{{key: prevRoot, path: filepath.Clean(fsPath)}}
+	for len(stack) > 0 {
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		obj, err := s.readDir(top.key)
+		if err != nil {
+			return nil, err
+		}
+		for i, link := range obj.Links {
+			path := filepath.Join(top.path, link.Name)
+			if obj.linkType(i) == TREE {
+				stack = append(stack, frame{key: string(link.Hash), path: path})
+			} else {
+				files[path] = string(link.Hash)
+			}
+		}
+	}
+	return files, nil
+}
+
+// reuse 在path处的文件与上次导入时相同时，返回引用原来的数据块的Node
+func (imp *importer) reuse(path string, info os.FileInfo) (Node, bool) {
+	key, ok := imp.prev[filepath.Clean(path)]
+	if !ok {
+		return nil, false
+	}
+	// 导入的文件都带有元数据，保存为链接其内容的LIST
+	if objType, err := rootType(key); err != nil || objType != LIST {
+		return nil, false
+	}
+	obj, err := imp.service.readObject(key)
+	if err != nil || obj.Meta == nil {
+		return nil, false
+	}
+	var size int64
+	for _, link := range obj.Links {
+		size += link.Size
+	}
+	meta, err := imp.metadata(path, info)
+	if err != nil {
+		return nil, false
+	}
+	if size != info.Size() || !sameMetadata(obj.Meta, meta) {
+		return nil, false
+	}
+	return &storedFile{key: key, size: size}, true
+}
+
+// sameMetadata 判断两份元数据保存后是否相同，修改时间按保存的纳秒精度比较，扩展属性按名字排序后比较
+func sameMetadata(a, b *Metadata) bool {
+	if a.Mode != b.Mode || a.ModTime.UnixNano() != b.ModTime.UnixNano() || a.Uid != b.Uid || a.Gid != b.Gid {
+		return false
+	}
+	if len(a.Xattrs) != len(b.Xattrs) {
+		return false
+	}
+	ax, bx := sortedXattrs(a.Xattrs), sortedXattrs(b.Xattrs)
+	for i := range ax {
+		if ax[i].Name != bx[i].Name || !bytes.Equal(ax[i].Value, bx[i].Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// storedFile 是已经保存在KVStore中的文件，Add时直接使用其键值
+type storedFile struct {
+	key  string
+	size int64
+}
+
+func (f *storedFile) Size() int64 {
+	return f.size
+}
+
+func (f *storedFile) Type() int {
+	return FILE
+}
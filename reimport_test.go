@@ -0,0 +1,69 @@
+package merkledag
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReImportRereadsOnlyChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{
+		"a":     []byte("aaaa"),
+		"sub/b": []byte("bbbb"),
+		"big":   bytes.Repeat([]byte("z"), 300*K),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := NewDagService(NewMemStore())
+	prev, err := ImportPath(s, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 改写a的内容但保留大小和修改时间：重新读取时才会发现变化
+	path := filepath.Join(dir, "a")
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("XXXX"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	// sub/b的大小和修改时间都变了
+	path = filepath.Join(dir, "sub", "b")
+	if err := os.WriteFile(path, []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := info.ModTime().Add(time.Hour)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	root, err := ReImportPath(s, dir, prev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{"a": "aaaa", "sub/b": "changed"} {
+		if got := readPath(t, s, root, name); string(got) != want {
+			t.Fatalf("%s: got %q, want %q", name, got, want)
+		}
+	}
+	if got := readPath(t, s, root, "big"); !bytes.Equal(got, files["big"]) {
+		t.Fatalf("big: got %d bytes", len(got))
+	}
+	// 没有变化时得到相同的root
+	again, err := ReImportPath(s, dir, root)
+	if err != nil || again != root {
+		t.Fatalf("unchanged re-import: got %s, %v; want %s", again, err, root)
+	}
+}
@@ -0,0 +1,64 @@
+package merkledag
+
+import (
+	"context"
+	"strings"
+)
+
+// Resolve 从root出发，按"/"分隔的path逐级查找目录项，返回路径末端的File或Dir。
+// path为空（或只有"/"和"."）时返回root本身。查找过程只读取路径上的目录块，不会重建整棵树
+func (s *DagService) Resolve(root string, path string) (Node, error) {
+	path = s.normalizeName(path)
+	key, objType, err := s.resolveKey(root, path)
+	if err != nil {
+		return nil, err
+	}
+	g := s.newGetter(context.Background())
+	for _, name := range strings.Split(path, "/") {
+		if name != "" && name != "." {
+			g.names = append(g.names, name)
+		}
+	}
+	return g.getNode(key, objType)
+}
+
+// resolveKey 返回path对应节点的键值和类型标记
+func (s *DagService) resolveKey(root string, path string) (string, string, error) {
+	path = s.normalizeName(path)
+	key := root
+	objType, err := rootType(root)
+	if err != nil {
+		return "", "", err
+	}
+	// 从快照出发时从快照的根节点开始查找
+	if objType == SNAPSHOT {
+		m, err := s.ReadManifest(root)
+		if err != nil {
+			return "", "", err
+		}
+		key = m.Root
+		if objType, err = rootType(key); err != nil {
+			return "", "", err
+		}
+	}
+	// walked 为已经经过的路径，用于报告不是目录的节点
+	walked := ""
+	for _, name := range strings.Split(path, "/") {
+		// 与filepath相同，空的和"."的路径段表示当前目录
+		if name == "" || name == "." {
+			continue
+		}
+		if objType != TREE {
+			return "", "", &ErrNotADirectory{Path: "/" + walked}
+		}
+		walked = joinPath(walked, name)
+		steps, err := s.lookup(key, name)
+		if err != nil {
+			return "", "", err
+		}
+		last := steps[len(steps)-1]
+		key = string(last.obj.Links[last.index].Hash)
+		objType = last.obj.linkType(last.index)
+	}
+	return key, objType, nil
+}
@@ -0,0 +1,34 @@
+package merkledag
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	s := NewDagService(NewMemStore())
+	root, err := s.Add(NewDirBuilder().
+		AddFile("a.txt", []byte("A")).
+		AddDir("b", NewDirBuilder().AddFile("c.txt", []byte("C")).Build()).
+		Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, err := s.Resolve(root, "b/c.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f, ok := node.(File); !ok || string(f.Bytes()) != "C" {
+		t.Fatalf("b/c.txt resolved to %#v", node)
+	}
+	if node, err := s.Resolve(root, "/b/"); err != nil || node.Type() != DIR {
+		t.Fatalf("b: %v, %v", node, err)
+	}
+	if _, err := s.Resolve(root, "b/missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("missing entry: got %v, want ErrNotFound", err)
+	}
+	var notDir *ErrNotADirectory
+	if _, err := s.Resolve(root, "a.txt/c.txt"); !errors.As(err, &notDir) {
+		t.Fatalf("descending into a file: got %v, want ErrNotADirectory", err)
+	}
+}
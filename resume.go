@@ -0,0 +1,98 @@
+package merkledag
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// resumeSaveEvery 是两次保存导入进度之间新完成的文件数
+const resumeSaveEvery = 100
+
+// WithResumeState 指定在path处的文件中记录导入的进度：每完成一批文件就保存已经写入的文件的路径和键值。
+// 导入中断后使用同一个path再次导入时，记录中大小和元数据都没有变化、数据块仍在KVStore中的文件
+// 直接引用原来的数据块，不再读取。目录总是重新列出，路径按传给ImportPath的fsPath记录
+func WithResumeState(path string) ImportOption {
+	return func(imp *importer) {
+		imp.resume = &resumeState{path: path, nodes: make(map[any]string), done: make(map[string]string)}
+	}
+}
+
+// resumeState 是一次导入中记录的进度
+type resumeState struct {
+	path string
+
+	mu sync.Mutex
+	// nodes 为导入的文件节点到其路径的映射
+	nodes map[any]string
+	// done 为已经写入的文件的路径到其键值的映射，包括上次导入记录的
+	done    map[string]string
+	pending int
+}
+
+// load 读取上次导入记录的进度，文件不存在时没有记录
+func (r *resumeState) load() error {
+	data, err := os.ReadFile(r.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &r.done)
+}
+
+// track 记录文件节点node对应的路径，node被写入后由stored记录进度
+func (r *resumeState) track(path string, node Node) {
+	id, ok := nodeID(node)
+	if !ok {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nodes[id] = filepath.Clean(path)
+}
+
+// stored 在node被写入后调用，每完成resumeSaveEvery个文件保存一次进度
+func (r *resumeState) stored(node Node, key string) {
+	id, ok := nodeID(node)
+	if !ok {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	path, ok := r.nodes[id]
+	if !ok {
+		return
+	}
+	r.done[path] = key
+	if r.pending++; r.pending >= resumeSaveEvery {
+		// 保存失败时下一批再试，最后由ImportPath报告
+		if r.saveLocked() == nil {
+			r.pending = 0
+		}
+	}
+}
+
+// save 保存当前的进度
+func (r *resumeState) save() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.saveLocked()
+}
+
+// saveLocked 先写入临时文件再重命名，保存到一半中断时原来的记录仍然完整
+func (r *resumeState) saveLocked() error {
+	data, err := json.Marshal(r.done)
+	if err != nil {
+		return err
+	}
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.path)
+}
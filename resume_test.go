@@ -0,0 +1,72 @@
+package merkledag
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResumeImportSkipsStoredFiles(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	if err := os.MkdirAll(filepath.Join(src, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 30; i++ {
+		path := filepath.Join(src, fmt.Sprint("f", i))
+		if i%3 == 0 {
+			path = filepath.Join(src, "sub", fmt.Sprint("g", i))
+		}
+		if err := os.WriteFile(path, []byte(fmt.Sprint("content-", i)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(src, "big"), bytes.Repeat([]byte("b"), 3*BLOCK_SIZE), 0644); err != nil {
+		t.Fatal(err)
+	}
+	want, err := ImportPath(NewDagService(NewMemStore()), src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 第26次写入时失败
+	st := &crashingStore{MemStore: NewMemStore(), failAt: 26}
+	state := filepath.Join(dir, "state.json")
+	if _, err := ImportPath(NewDagService(st), src, WithResumeState(state)); err == nil {
+		t.Fatal("import did not fail")
+	}
+	data, err := os.ReadFile(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var done map[string]string
+	if err := json.Unmarshal(data, &done); err != nil {
+		t.Fatal(err)
+	}
+	if len(done) == 0 {
+		t.Fatal("no progress recorded")
+	}
+	// 改写已记录的文件的内容但保持大小和修改时间，重新读取它们就会得到不同的根节点
+	for path := range done {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, bytes.Repeat([]byte("X"), int(info.Size())), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	st.failAt = 0
+	got, err := ImportPath(NewDagService(st), src, WithResumeState(state))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Fatalf("resumed root %s, want %s: recorded files were re-read", got, want)
+	}
+}
@@ -0,0 +1,47 @@
+package merkledag
+
+import "sort"
+
+// FindRoots 列出KVStore中保存的所有数据块，返回没有被其他数据块作为子节点引用的键值，
+// 即可能的根节点，按字典序排列。被多个数据块共享的子树只要被引用过就不是根节点。
+// 保存的Merkle树内部节点不属于任何DAG，不会被返回。KVStore需要实现Enumerate
+func FindRoots(service *DagService) ([]string, error) {
+	if err := service.checkOpen(); err != nil {
+		return nil, err
+	}
+	lister, ok := service.store.(Enumerate)
+	if !ok {
+		return nil, ErrNotEnumerable
+	}
+	keys, err := lister.Keys()
+	if err != nil {
+		return nil, err
+	}
+	var blocks []string
+	referenced := make(map[string]bool)
+	for _, key := range keys {
+		objType, err := rootType(key)
+		if err != nil || objType == MERKLE {
+			continue
+		}
+		blocks = append(blocks, key)
+		obj, err := service.readLinks(blockRef{key: key, objType: objType})
+		if err != nil {
+			return nil, err
+		}
+		if obj == nil {
+			continue
+		}
+		for _, link := range obj.Links {
+			referenced[string(link.Hash)] = true
+		}
+	}
+	var roots []string
+	for _, key := range blocks {
+		if !referenced[key] {
+			roots = append(roots, key)
+		}
+	}
+	sort.Strings(roots)
+	return roots, nil
+}
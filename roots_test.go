@@ -0,0 +1,42 @@
+package merkledag
+
+import (
+	"errors"
+	"sort"
+	"testing"
+)
+
+// nonEnumerableStore 隐藏了MemStore的Keys方法
+type nonEnumerableStore struct {
+	KVStore
+}
+
+func TestFindRoots(t *testing.T) {
+	s := NewDagService(NewMemStore(), WithPersistInternalNodes(true))
+	// 两棵树共享同一个子目录，共享的子目录不是根
+	shared := NewDirBuilder().AddFile("s", []byte("shared")).Build()
+	a, err := s.Add(NewDirBuilder().AddDir("x", shared).AddFile("a", []byte("a")).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := s.Add(NewDirBuilder().AddDir("y", shared).AddFile("b", []byte("bbb")).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots, err := FindRoots(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{a, b}
+	sort.Strings(want)
+	if len(roots) != 2 || roots[0] != want[0] || roots[1] != want[1] {
+		t.Fatalf("got %v, want %v", roots, want)
+	}
+}
+
+func TestFindRootsNotEnumerable(t *testing.T) {
+	s := NewDagService(nonEnumerableStore{NewMemStore()})
+	if _, err := FindRoots(s); !errors.Is(err, ErrNotEnumerable) {
+		t.Fatalf("got %v, want ErrNotEnumerable", err)
+	}
+}
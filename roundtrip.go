@@ -0,0 +1,53 @@
+package merkledag
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// assertRoundTrip 检查序列化和重建互为逆操作：将node保存到一个新的MemStore中，用Get重建，
+// 再把重建出的节点保存到另一个MemStore中，两次得到的根节点和每个数据块都必须相同。
+// 不一致时返回说明哪个数据块不同的错误。ChunkedFile的内容只能读取一次，node中不能包含它
+func assertRoundTrip(node Node) error {
+	first, second := NewMemStore(), NewMemStore()
+	root, err := Add(first, node)
+	if err != nil {
+		return fmt.Errorf("add: %w", err)
+	}
+	rebuilt, err := Get(first, root)
+	if err != nil {
+		return fmt.Errorf("get %s: %w", root, err)
+	}
+	again, err := Add(second, rebuilt)
+	if err != nil {
+		return fmt.Errorf("add rebuilt node: %w", err)
+	}
+	if again != root {
+		return fmt.Errorf("round trip changed root %s to %s", root, again)
+	}
+	keys, err := first.Keys()
+	if err != nil {
+		return err
+	}
+	secondKeys, err := second.Keys()
+	if err != nil {
+		return err
+	}
+	if len(keys) != len(secondKeys) {
+		return fmt.Errorf("round trip wrote %d blocks, originally %d", len(secondKeys), len(keys))
+	}
+	for _, key := range keys {
+		want, err := first.Get(key)
+		if err != nil {
+			return err
+		}
+		got, err := second.Get(key)
+		if err != nil {
+			return fmt.Errorf("block %s missing after round trip: %w", key, err)
+		}
+		if !bytes.Equal(got, want) {
+			return fmt.Errorf("block %s differs after round trip: %x, originally %x", key, got, want)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package merkledag
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+// fuzzTree 按seed中的字节依次决定目录的形状和内容，seed用完后剩余的选择都为0
+func fuzzTree(seed []byte) Dir {
+	pos := 0
+	next := func() byte {
+		if pos >= len(seed) {
+			return 0
+		}
+		pos++
+		return seed[pos-1]
+	}
+	type frame struct {
+		d     *dir
+		depth int
+	}
+	root := &dir{}
+	stack := []frame{{root, 0}}
+	for len(stack) > 0 {
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		n := int(next() % 5)
+		for i := 0; i < n; i++ {
+			name := fmt.Sprint("e", i)
+			var child Node
+			switch k := next() % 4; {
+			case k == 0 && top.depth < 4:
+				sub := &dir{}
+				stack = append(stack, frame{sub, top.depth + 1})
+				child = sub
+			case k == 1:
+				child = &symlink{target: fmt.Sprint("target", next())}
+			case k == 2:
+				meta := &Metadata{Mode: os.FileMode(next()) & os.ModePerm, ModTime: time.Unix(int64(next()), 0)}
+				child = &file{data: bytes.Repeat([]byte{next()}, int(next())), meta: meta}
+			default:
+				child = NewFile(bytes.Repeat([]byte{next()}, int(next())))
+			}
+			top.d.entries = append(top.d.entries, entry{name: name, node: child})
+		}
+	}
+	return root
+}
+
+func FuzzRoundTrip(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte{1, 2, 3, 4, 5, 6})
+	f.Add([]byte{4, 0, 3, 0, 2, 1, 7, 200, 3, 9, 1, 2, 2, 0, 4, 0, 1, 3, 1})
+	f.Fuzz(func(t *testing.T, seed []byte) {
+		if err := assertRoundTrip(fuzzTree(seed)); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,110 @@
+package merkledag
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// Serializer 将TREE和LIST的Object编码为数据块。数据块的哈希参与键值的计算，
+// 因此同一个Object每次编码必须得到相同的字节，且一个DAG只能使用同一种Serializer。
+// Marshal每次必须返回新分配的缓冲区，Add可能把它直接交给KVStore保存
+type Serializer interface {
+	Marshal(obj *Object) ([]byte, error)
+	Unmarshal(data []byte) (*Object, error)
+}
+
+// BinarySerializer 是默认的Serializer，使用紧凑的带长度前缀的二进制格式
+type BinarySerializer struct{}
+
+func (BinarySerializer) Marshal(obj *Object) ([]byte, error) {
+	return serialize(obj)
+}
+
+func (BinarySerializer) Unmarshal(data []byte) (*Object, error) {
+	return deserialize(data)
+}
+
+// JSONSerializer 将Object编码为便于阅读的JSON
+type JSONSerializer struct{}
+
+type jsonObject struct {
+	Links []jsonLink    `json:"links"`
+	Meta  *jsonMetadata `json:"meta,omitempty"`
+}
+
+type jsonLink struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+	Hash string `json:"hash"`
+	Size int64  `json:"size"`
+}
+
+// jsonMetadata 中的时间保存为纳秒数，编码结果不受时区影响
+type jsonMetadata struct {
+	Mode    uint32 `json:"mode"`
+	ModTime int64  `json:"mtime"`
+	Uid     int    `json:"uid"`
+	Gid     int    `json:"gid"`
+	// Xattrs 按名字排序，值编码为base64
+	Xattrs []jsonXattr `json:"xattrs,omitempty"`
+}
+
+type jsonXattr struct {
+	Name  string `json:"name"`
+	Value []byte `json:"value"`
+}
+
+func (JSONSerializer) Marshal(obj *Object) ([]byte, error) {
+	if len(obj.Data) != len(obj.Links)*STEP {
+		return nil, errMalformedObject
+	}
+	jobj := jsonObject{Links: make([]jsonLink, 0, len(obj.Links))}
+	for i, link := range obj.Links {
+		jobj.Links = append(jobj.Links, jsonLink{
+			Type: obj.linkType(i),
+			Name: link.Name,
+			Hash: string(link.Hash),
+			Size: link.Size,
+		})
+	}
+	if obj.Meta != nil {
+		jobj.Meta = &jsonMetadata{
+			Mode:    uint32(obj.Meta.Mode),
+			ModTime: obj.Meta.ModTime.UnixNano(),
+			Uid:     obj.Meta.Uid,
+			Gid:     obj.Meta.Gid,
+		}
+		for _, x := range sortedXattrs(obj.Meta.Xattrs) {
+			jobj.Meta.Xattrs = append(jobj.Meta.Xattrs, jsonXattr{Name: x.Name, Value: x.Value})
+		}
+	}
+	return json.Marshal(jobj)
+}
+
+func (JSONSerializer) Unmarshal(data []byte) (*Object, error) {
+	var jobj jsonObject
+	if err := json.Unmarshal(data, &jobj); err != nil {
+		return nil, errMalformedObject
+	}
+	obj := &Object{Links: make([]Link, 0, len(jobj.Links))}
+	for _, link := range jobj.Links {
+		if len(link.Type) != STEP {
+			return nil, errMalformedObject
+		}
+		obj.Data = append(obj.Data, link.Type...)
+		obj.Links = append(obj.Links, Link{Name: link.Name, Hash: []byte(link.Hash), Size: link.Size})
+	}
+	if m := jobj.Meta; m != nil {
+		obj.Meta = &Metadata{
+			Mode:    os.FileMode(m.Mode),
+			ModTime: time.Unix(0, m.ModTime),
+			Uid:     m.Uid,
+			Gid:     m.Gid,
+		}
+		for _, x := range m.Xattrs {
+			obj.Meta.Xattrs = append(obj.Meta.Xattrs, Xattr{Name: x.Name, Value: x.Value})
+		}
+	}
+	return obj, nil
+}
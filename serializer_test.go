@@ -0,0 +1,197 @@
+package merkledag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func TestSerializeKeepsNames(t *testing.T) {
+	obj := &Object{
+		Links: []Link{
+			{Name: "a.txt", Hash: []byte("file_01"), Size: 3},
+			{Name: "sub", Hash: []byte("dir_02"), Size: 10},
+		},
+		Data: []byte(BLOB + TREE),
+	}
+	data, err := serialize(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := deserialize(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Links) != len(obj.Links) {
+		t.Fatalf("%d links, want %d", len(got.Links), len(obj.Links))
+	}
+	for i, link := range obj.Links {
+		g := got.Links[i]
+		if g.Name != link.Name || string(g.Hash) != string(link.Hash) || g.Size != link.Size {
+			t.Errorf("link %d = %+v, want %+v", i, g, link)
+		}
+	}
+	if string(got.Data) != string(obj.Data) {
+		t.Errorf("types %q, want %q", got.Data, obj.Data)
+	}
+}
+
+func TestSerializeFraming(t *testing.T) {
+	// 不写入长度时两组链接拼接后得到相同的字节
+	a := &Object{
+		Links: []Link{{Name: "ab", Hash: []byte("c"), Size: 1}, {Name: "d", Hash: []byte("e"), Size: 1}},
+		Data:  []byte(BLOB + BLOB),
+	}
+	b := &Object{
+		Links: []Link{{Name: "a", Hash: []byte("bc"), Size: 1}, {Name: "d", Hash: []byte("e"), Size: 1}},
+		Data:  []byte(BLOB + BLOB),
+	}
+	da, err := serialize(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := serialize(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(da, db) {
+		t.Fatal("framed serializations collide")
+	}
+	s := NewDagService(NewMemStore())
+	ra, err := s.Add(NewDirBuilder().AddFile("ab", []byte("c")).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rb, err := s.Add(NewDirBuilder().AddFile("a", []byte("bc")).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ra == rb {
+		t.Fatal("directories with shifted name/content boundaries share a root")
+	}
+}
+
+func TestSerializersRoundTrip(t *testing.T) {
+	tree := NewDirBuilder().
+		AddFile("a.txt", []byte("alpha")).
+		add("meta.txt", &file{data: []byte("with meta"), meta: &Metadata{Mode: 0600, Uid: 1, Gid: 2}}).
+		AddDir("sub", NewDirBuilder().AddFile("b.txt", []byte("beta")).Build()).
+		Build()
+	roots := make(map[string]string)
+	for name, serializer := range map[string]Serializer{"binary": BinarySerializer{}, "json": JSONSerializer{}} {
+		s := NewDagService(NewMemStore(), WithSerializer(serializer))
+		root, err := s.Add(tree)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := s.Get(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok, msg := sameTree(n, tree); !ok {
+			t.Fatalf("%s: %s", name, msg)
+		}
+		m, err := s.Resolve(root, "meta.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if meta := metadataOf(m); meta == nil || meta.Mode != 0600 || meta.Uid != 1 || meta.Gid != 2 {
+			t.Fatalf("%s: metadata %+v", name, meta)
+		}
+		// 再次Add读回的树得到相同的根节点
+		again, err := s.Add(n)
+		if err != nil || again != root {
+			t.Fatalf("%s: re-adding gives %s, %v; want %s", name, again, err, root)
+		}
+		obj, err := s.readObject(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := serializer.Marshal(obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := serializer.Unmarshal(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		redone, err := serializer.Marshal(decoded)
+		if err != nil || !bytes.Equal(redone, data) {
+			t.Fatalf("%s: encoding is not stable", name)
+		}
+		roots[name] = root
+	}
+	if roots["binary"] == roots["json"] {
+		t.Fatal("binary and JSON encodings share a root")
+	}
+}
+
+// appendSerialize 用逐个append拼接的方式按serialize的格式编码obj，作为比较的基准
+func appendSerialize(obj *Object) []byte {
+	var buf []byte
+	buf = binary.AppendUvarint(buf, uint64(len(obj.Links)))
+	for i, link := range obj.Links {
+		buf = append(buf, obj.Data[i*STEP:(i+1)*STEP]...)
+		buf = binary.AppendUvarint(buf, uint64(len(link.Name)))
+		buf = append(buf, link.Name...)
+		buf = binary.AppendUvarint(buf, uint64(len(link.Hash)))
+		buf = append(buf, link.Hash...)
+		buf = binary.AppendVarint(buf, link.Size)
+	}
+	return buf
+}
+
+// wideObject 返回有n个链接的目录对象
+func wideObject(n int) *Object {
+	obj := &Object{}
+	for i := 0; i < n; i++ {
+		obj.Links = append(obj.Links, Link{
+			Name: fmt.Sprintf("file-%06d", i),
+			Hash: []byte(fmt.Sprintf("file_%064x", i)),
+			Size: int64(i),
+		})
+		obj.Data = append(obj.Data, BLOB...)
+	}
+	return obj
+}
+
+func TestSerializeToMatchesSerialize(t *testing.T) {
+	obj := wideObject(1000)
+	want := appendSerialize(obj)
+	got, err := serialize(obj)
+	if err != nil || !bytes.Equal(got, want) {
+		t.Fatalf("serialize differs: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := serializeTo(obj, &buf); err != nil || !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("serializeTo differs: %v", err)
+	}
+}
+
+func BenchmarkSerialize(b *testing.B) {
+	obj := wideObject(10000)
+	b.Run("append", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			appendSerialize(obj)
+		}
+	})
+	b.Run("buffer", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := serialize(obj); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("stream", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := serializeTo(obj, io.Discard); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
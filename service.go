@@ -0,0 +1,383 @@
+package merkledag
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"hash"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// DagService 将KVStore与相关配置组合在一起，提供DAG的读写操作。
+// DagService可以被多个goroutine同时使用：配置在NewDagService之后不再修改，
+// 每次调用的状态（已写入的键值、batch、进度等）只属于这次调用，
+// 调用之间共享的缓存由互斥锁保护。KVStore和Metrics需要同样可以被同时使用
+type DagService struct {
+	store          KVStore
+	hasher         func() hash.Hash
+	concurrency    int
+	chunkSize      int
+	verifyOnGet    bool
+	serializer     Serializer
+	cidKeys        bool
+	keyEncoder     KeyEncoder
+	maxBlockSize   int
+	chunking       bool
+	shardThreshold int
+	progressFn     func(ProgressEvent)
+	maxTotalSize   int64
+	// domainSeparation 为true时计算Merkle Root时区分叶子和内部节点
+	domainSeparation bool
+	fanout           int
+	persistInternal  bool
+	metrics          Metrics
+	logger           Logger
+	chunker          ChunkerStrategy
+	inlineThreshold  int
+	legacyHexConcat  bool
+	lastEntryWins    bool
+	memoDirs         bool
+	chunkAnchoring   bool
+	typePrefix       bool
+	smallDirEntries  int
+	wal              bool
+	namespace        string
+	normalizeNames   bool
+	combiner         func(hashes [][]byte) []byte
+	validate         bool
+	bloomFPRate      float64
+	// traversalConcurrency 为Walk和CopyTree同时读取的数据块的最大数量
+	traversalConcurrency int
+	// contentNormalization 为true时分块文件的Merkle Root由完整内容的哈希计算
+	contentNormalization bool
+	// hashName 为哈希函数的名字，在NewDagService中识别一次
+	hashName string
+
+	// stats 是所有调用共享的缓存，自带互斥锁
+	stats statCache
+	// closed 在Close之后为true
+	closed atomic.Bool
+	// headerChecked 在检查或写入store的头部之后为true
+	headerChecked atomic.Bool
+	// readCheck 保证第一次读取数据块前只检查一次头部，readCheckErr为检查的结果
+	readCheck    sync.Once
+	readCheckErr error
+	// walMu 保证同一时间只有一个使用预写日志的事务
+	walMu sync.Mutex
+	// memo 是设置了WithDirMemo时所有调用共享的目录缓存，自带互斥锁
+	memo dirMemo
+}
+
+// Option 用于配置DagService
+type Option func(*DagService)
+
+// NewDagService 使用store和若干Option创建DagService
+func NewDagService(store KVStore, opts ...Option) *DagService {
+	s := &DagService{
+		store:           store,
+		hasher:          sha256.New,
+		chunkSize:       BLOCK_SIZE,
+		serializer:      BinarySerializer{},
+		keyEncoder:      HexEncoder{},
+		metrics:         NopMetrics{},
+		logger:          NopLogger{},
+		chunker:         FixedSizeChunker{},
+		smallDirEntries: defaultSmallDirEntries,
+
+		traversalConcurrency: runtime.GOMAXPROCS(0),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.namespace != "" {
+		s.store = newNamespaceStore(s.store, s.namespace)
+	}
+	s.hashName = s.detectHashAlgorithm()
+	return s
+}
+
+// WithHasher 指定计算键值和Merkle Root时使用的哈希函数，默认为SHA-256
+func WithHasher(hasher func() hash.Hash) Option {
+	return func(s *DagService) {
+		s.hasher = hasher
+	}
+}
+
+// WithKeyedHasher 指定使用以key为密钥的HMAC-SHA256计算所有的哈希。只有持有密钥的一方才能算出
+// 与预期的键值相符的数据块，Merkle Root同时验证了DAG的结构和来源。读取和验证时需要使用相同的密钥
+func WithKeyedHasher(key []byte) Option {
+	key = append([]byte(nil), key...)
+	return WithHasher(func() hash.Hash {
+		return hmac.New(sha256.New, key)
+	})
+}
+
+// WithConcurrency 指定Add时同时处理文件的worker数量，n<=1时不使用并发
+func WithConcurrency(n int) Option {
+	return func(s *DagService) {
+		s.concurrency = n
+	}
+}
+
+// WithChunkSize 指定ChunkedFile切分时每个数据块的大小，默认为BLOCK_SIZE
+func WithChunkSize(n int) Option {
+	return func(s *DagService) {
+		s.chunkSize = n
+	}
+}
+
+// WithVerifyOnGet 指定读取数据块时是否重新计算键值以检查数据是否损坏，默认不检查
+func WithVerifyOnGet(verify bool) Option {
+	return func(s *DagService) {
+		s.verifyOnGet = verify
+	}
+}
+
+// WithSerializer 指定TREE和LIST数据块的编码格式，默认为BinarySerializer。
+// 编码格式决定键值，读取时必须使用与Add时相同的Serializer
+func WithSerializer(serializer Serializer) Option {
+	return func(s *DagService) {
+		s.serializer = serializer
+	}
+}
+
+// WithCIDKeys 指定是否使用CIDv1格式的键值，便于IPFS的工具识别，默认使用类型前缀加Merkle Root。
+// multihash的算法码固定为sha2-256，因此只应与默认的哈希函数一起使用。
+// 目录中保存的是子节点的键值，因此两种格式下同一个目录的Merkle Root不同。
+// 读取时两种格式的键值都可以识别
+func WithCIDKeys(enabled bool) Option {
+	return func(s *DagService) {
+		s.cidKeys = enabled
+	}
+}
+
+// WithKeyEncoding 指定键值中Merkle Root的编码方式，默认为HexEncoder。
+// 与WithCIDKeys同时使用时CID的编码优先。读取时必须使用与Add时相同的编码
+func WithKeyEncoding(enc KeyEncoder) Option {
+	return func(s *DagService) {
+		s.keyEncoder = enc
+	}
+}
+
+// WithMaxBlockSize 指定写入的数据块的最大字节数，超过时Add返回ErrBlockTooLarge，
+// n<=0时不限制。切块时每块的大小也不会超过n
+func WithMaxBlockSize(n int) Option {
+	return func(s *DagService) {
+		s.maxBlockSize = n
+	}
+}
+
+// WithChunking 指定内容超过最大块大小的File是否自动按ChunkedFile切块保存，默认不切块
+func WithChunking(enabled bool) Option {
+	return func(s *DagService) {
+		s.chunking = enabled
+	}
+}
+
+// WithShardThreshold 指定目录项多于n个的目录按名字的哈希分片保存，每个分片是一个单独的数据块，
+// 设置了最大块大小时超过它的目录也会分片。n<=0时不分片
+func WithShardThreshold(n int) Option {
+	return func(s *DagService) {
+		s.shardThreshold = n
+	}
+}
+
+// WithSmallDirThreshold 指定目录项不多于n个的目录使用预先分配缓冲区的编码方式，默认为16。
+// 编码结果与一般的方式完全相同，只减少内存分配。n<0时总是使用一般的方式
+func WithSmallDirThreshold(n int) Option {
+	return func(s *DagService) {
+		s.smallDirEntries = n
+	}
+}
+
+// WithMaxTotalSize 指定一次Add最多写入n字节的新数据块，超过时停止并返回ErrQuotaExceeded。
+// KVStore实现了BatchStore时已经写入的数据块不会被提交，否则留在KVStore中，由GC回收。
+// n<=0时不限制
+func WithMaxTotalSize(n int64) Option {
+	return func(s *DagService) {
+		s.maxTotalSize = n
+	}
+}
+
+// WithDomainSeparation 指定计算Merkle Root时是否区分叶子和内部节点：
+// 叶子哈希为H(0x00||叶子)，内部节点为H(0x01||左||右)，叶子不会被当作内部节点。
+// 打开后所有键值都会改变，默认关闭，以便与已有的数据和不区分的实现兼容
+func WithDomainSeparation(on bool) Option {
+	return func(s *DagService) {
+		s.domainSeparation = on
+	}
+}
+
+// WithLegacyHexConcat 指定Merkle树的内部节点是否拼接子哈希的十六进制字符串而不是原始摘要。
+// 旧版本拼接十六进制字符串，与其他Merkle树的实现都不兼容；旧版本保存的数据需要打开此选项，
+// 才能计算出与原来相同的键值。默认关闭
+func WithLegacyHexConcat(on bool) Option {
+	return func(s *DagService) {
+		s.legacyHexConcat = on
+	}
+}
+
+// WithLastEntryWins 指定目录中有重名的子节点时保留It()返回的最后一个，而不是返回ErrDuplicateEntry。
+// 默认关闭
+func WithLastEntryWins(on bool) Option {
+	return func(s *DagService) {
+		s.lastEntryWins = on
+	}
+}
+
+// WithDirMemo 指定是否记住保存过的Dir对象（指针类型按地址，其他类型按值）的键值。
+// 之后的Add再遇到同一个Dir，且其目录块仍在KVStore中时，直接使用记住的结果，不再遍历和计算其子树，
+// 多棵树共享的子目录只需计算一次。打开后Dir在保存后不能再被修改。默认关闭
+func WithDirMemo(on bool) Option {
+	return func(s *DagService) {
+		s.memoDirs = on
+	}
+}
+
+// WithTypePrefix 指定是否在写入KVStore的每个数据块前加上一个类型字节，不依赖键值也能判断数据块的类型：
+// 0x01为文件内容，0x02为目录，0x03为分块或带有元数据的文件，0x04为符号链接，0x05为目录分片，
+// 0x06为快照清单，0x07为Merkle树内部节点，0x08为提交。键值和Merkle Root只由内容计算，不受影响；
+// 读取时检查类型字节与键值相符。打开后无法读取没有类型字节的数据块，默认关闭
+func WithTypePrefix(on bool) Option {
+	return func(s *DagService) {
+		s.typePrefix = on
+	}
+}
+
+// WithFanout 指定Merkle树每个内部节点最多组合k个子哈希，默认为2。
+// 扇出越大树越浅，证明中的层数越少，但每层需要的兄弟哈希越多。k<2时使用2
+func WithFanout(k int) Option {
+	return func(s *DagService) {
+		s.fanout = k
+	}
+}
+
+// WithPersistInternalNodes 指定Add时是否把每个数据块的Merkle树的内部节点也作为MERKLE数据块保存，
+// 内容为组合成该节点的子哈希。生成包含证明时从根向下读取这些节点，不需要重新计算整棵树。
+// 默认不保存
+func WithPersistInternalNodes(on bool) Option {
+	return func(s *DagService) {
+		s.persistInternal = on
+	}
+}
+
+// WithInlineThreshold 指定小于n字节且没有元数据的文件直接内嵌在父目录的数据块中，不单独写入KVStore。
+// 内嵌文件的Merkle Root与单独保存时相同，读取时透明地返回其内容。n<=0时不内嵌，为默认值
+func WithInlineThreshold(n int) Option {
+	return func(s *DagService) {
+		s.inlineThreshold = n
+	}
+}
+
+// WithMetrics 指定接收读写计数的Metrics，默认为NopMetrics
+func WithMetrics(m Metrics) Option {
+	return func(s *DagService) {
+		s.metrics = m
+	}
+}
+
+// WithLogger 指定接收调试日志的Logger：写入和跳过已有的数据块、GC的清理以及验证失败时都会记录，
+// 默认为NopLogger
+func WithLogger(l Logger) Option {
+	return func(s *DagService) {
+		s.logger = l
+	}
+}
+
+// WithChunker 指定ChunkedFile的切分方式，默认为FixedSizeChunker。
+// 切分方式只影响块的边界，块的大小仍然不超过WithChunkSize和最大块大小
+func WithChunker(c ChunkerStrategy) Option {
+	return func(s *DagService) {
+		s.chunker = c
+	}
+}
+
+// WithChunkAnchoring 指定是否将ChunkedFile的切分点锚定在内容上：使用Anchored的ContentDefinedChunker，
+// 已经通过WithChunker指定了ContentDefinedChunker时保留其大小设置。
+// 不同文件中相同的一段内容无论位于什么偏移，都会切分出相同的块。默认关闭
+func WithChunkAnchoring(on bool) Option {
+	return func(s *DagService) {
+		s.chunkAnchoring = on
+	}
+}
+
+// WithContentNormalization 指定分块保存的文件的Merkle Root是否与切块方式无关，由完整内容的哈希计算：
+// 没有元数据的文件与整体保存的同样内容的文件得到相同的Merkle Root，只是键值的类型前缀为list_，
+// LIST作为按块读取内容的索引保存在这个Merkle Root下；带有元数据的文件的叶子为内容的Merkle Root和LIST的哈希，
+// 与整体保存的带有元数据的文件的计算方式相同。这样的LIST无法只由其数据块验证，验证时需要读取它链接的所有块。
+// 打开后分块文件的键值都会改变，读取和验证时需要使用相同的配置。默认关闭
+func WithContentNormalization(on bool) Option {
+	return func(s *DagService) {
+		s.contentNormalization = on
+	}
+}
+
+// WithProgress 指定Add过程中报告进度的回调。回调最多每100毫秒调用一次，
+// Add成功结束时总会以最终的进度调用一次
+func WithProgress(fn func(ProgressEvent)) Option {
+	return func(s *DagService) {
+		s.progressFn = fn
+	}
+}
+
+// WithWAL 指定在KVStore不支持BatchStore时让Add等写入操作通过预写日志完成：数据块先追加到日志中，
+// 写入提交记录后才写入store。中途崩溃时store中只会留下日志，由Recover重放或丢弃。默认关闭
+func WithWAL(on bool) Option {
+	return func(s *DagService) {
+		s.wal = on
+	}
+}
+
+// WithNamespace 指定在每个键值前加上命名空间ns，使多组互不相关的DAG可以共用一个KVStore。
+// DagService只能看到本命名空间的数据块，GC和FindRoots等也只作用于本命名空间，返回的键值不包含命名空间。
+// ns中不能包含"/"
+func WithNamespace(ns string) Option {
+	return func(s *DagService) {
+		s.namespace = ns
+	}
+}
+
+// WithNameNormalization 指定是否规范化目录项的名字：把"\"替换为"/"，并转换为Unicode NFC形式，
+// 使在不同操作系统上导入的同一棵树得到相同的根节点。Resolve、AddEntry和RemoveEntry中的名字同样被规范化。
+// 名字被改变的树得到的键值与不规范化时不同；目录项的名字中的"\"替换后仍是路径分隔符，Add时同样返回ErrInvalidNode。默认关闭
+func WithNameNormalization(on bool) Option {
+	return func(s *DagService) {
+		s.normalizeNames = on
+	}
+}
+
+// WithCombiner 指定Merkle树中把同一组的哈希组合成上一层的哈希的函数，hashes为按顺序排列的各哈希的摘要，
+// 返回的摘要长度必须与哈希函数的相同。默认为拼接后计算哈希，设置了WithDomainSeparation时拼接前加上0x01；
+// 设置了combine之后只对叶子加标记，内部节点完全由combine决定。VerifyInclusion使用同一个函数，
+// VerifyProof不知道组合函数，需要用同样配置的DagService的VerifyInclusion验证
+func WithCombiner(combine func(hashes [][]byte) []byte) Option {
+	return func(s *DagService) {
+		s.combiner = combine
+	}
+}
+
+// WithValidate 指定Add、AddContext和AddWithKeys是否先用ValidateNode检查node，不合法时不写入任何数据块。默认关闭
+func WithValidate(on bool) Option {
+	return func(s *DagService) {
+		s.validate = on
+	}
+}
+
+// WithBloomFilter 指定AddSnapshot同时为快照可达的所有数据块的键值建立误判率为fpRate的Bloom过滤器，
+// 与清单一起保存，供MightContain查询。fpRate需要在0和1之间，默认不建立
+func WithBloomFilter(fpRate float64) Option {
+	return func(s *DagService) {
+		if fpRate > 0 && fpRate < 1 {
+			s.bloomFPRate = fpRate
+		}
+	}
+}
+
+// WithTraversalConcurrency 指定Walk、WalkChan和CopyTree同时读取的数据块的最大数量，
+// 同一目录中接下来的最多n个子节点并发读取。n<=1时每个数据块在访问到时才读取，默认为GOMAXPROCS
+func WithTraversalConcurrency(n int) Option {
+	return func(s *DagService) {
+		s.traversalConcurrency = n
+	}
+}
@@ -0,0 +1,32 @@
+package merkledag
+
+import "context"
+
+// Placeholder 是GetShallow中没有读取的子树，只记录其键值和类型。
+// Type总是返回PLACEHOLDER，Kind为子树实际的类型（FILE、DIR或SYMLINK），Size取自父目录中的链接。
+// 需要展开时用Get读取Key即可
+type Placeholder struct {
+	Key  string
+	Kind int
+	size int64
+}
+
+func (p *Placeholder) Size() int64 {
+	return p.size
+}
+
+func (p *Placeholder) Type() int {
+	return PLACEHOLDER
+}
+
+// GetShallow 与Get相同，但只重建root以下maxDepth层的节点，更深的子树用Placeholder表示，
+// 不读取它们的数据块。root本身在第0层，maxDepth为0时只有root的子节点列表；maxDepth小于0时与Get相同
+func (s *DagService) GetShallow(root string, maxDepth int) (Node, error) {
+	objType, err := rootType(root)
+	if err != nil {
+		return nil, err
+	}
+	g := s.newGetter(context.Background())
+	g.maxDepth = maxDepth
+	return g.getNode(root, objType)
+}
@@ -0,0 +1,70 @@
+package merkledag
+
+import "testing"
+
+// childNode 返回目录d中名为name的子节点
+func childNode(t *testing.T, d Node, name string) Node {
+	t.Helper()
+	dir, ok := d.(Dir)
+	if !ok {
+		t.Fatalf("%T is not a directory", d)
+	}
+	it := dir.It()
+	for it.Next() {
+		if it.Name() == name {
+			return it.Node()
+		}
+	}
+	t.Fatalf("no entry %q", name)
+	return nil
+}
+
+func TestGetShallow(t *testing.T) {
+	st := newCountingStore()
+	s := NewDagService(st)
+	deep := NewDirBuilder().AddFile("z", []byte("zz")).Build()
+	mid := NewDirBuilder().AddFile("y", []byte("y")).AddDir("deep", deep).Build()
+	root, err := s.Add(NewDirBuilder().AddFile("x", []byte("x")).AddDir("mid", mid).AddDir("mid2", mid).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.reset()
+	n, err := s.GetShallow(root, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 根目录、x和mid，mid2与mid是同一个数据块，只读取一次
+	if got := st.blockGets(); got != 3 {
+		t.Fatalf("read %d blocks, want 3", got)
+	}
+	for _, name := range []string{"mid", "mid2"} {
+		p, ok := childNode(t, childNode(t, n, name), "deep").(*Placeholder)
+		if !ok || p.Type() != PLACEHOLDER || p.Kind != DIR || p.Size() != 2 {
+			t.Fatalf("%s/deep is not a directory placeholder", name)
+		}
+		full, err := s.Get(p.Key)
+		if err != nil || full.Size() != 2 {
+			t.Fatalf("expanding %s/deep: %v", name, err)
+		}
+		if p, ok := childNode(t, childNode(t, n, name), "y").(*Placeholder); !ok || p.Kind != FILE {
+			t.Fatalf("%s/y is not a file placeholder", name)
+		}
+	}
+	if _, ok := childNode(t, n, "x").(File); !ok {
+		t.Fatal("x at depth 1 is not reconstructed")
+	}
+	top, err := s.GetShallow(root, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := childNode(t, top, "mid").(*Placeholder); !ok {
+		t.Fatal("maxDepth 0 reconstructed mid")
+	}
+	if _, err := s.Add(top); err == nil {
+		t.Fatal("tree with placeholders added")
+	}
+	full, err := s.GetShallow(root, -1)
+	if err != nil || full.Size() != 7 {
+		t.Fatalf("maxDepth -1: size %d, %v", full.Size(), err)
+	}
+}
@@ -0,0 +1,189 @@
+package merkledag
+
+import (
+	"fmt"
+	"sort"
+)
+
+// shardEntry 是分片前目录中的一个目录项
+type shardEntry struct {
+	link Link
+	tag  string
+	// hash 为子节点的Merkle Root
+	hash string
+}
+
+// nameHash 返回目录项名字的哈希，分片时第level层按其第level个字节选择子分片
+func (s *DagService) nameHash(name string) []byte {
+	h := s.hasher()
+	h.Write([]byte(name))
+	return h.Sum(nil)
+}
+
+// needsShard 判断保存为data的目录块是否需要分片：目录项多于分片阈值，
+// 或者设置了分片且数据块超过最大块大小
+func (s *DagService) needsShard(entries int, data []byte) bool {
+	if s.shardThreshold <= 0 {
+		return false
+	}
+	return entries > s.shardThreshold || (s.maxBlockSize > 0 && len(data) > s.maxBlockSize)
+}
+
+// putShards 将目录项按名字的哈希分到最多256个子分片中，每个子分片作为SHARD数据块保存，
+// 目录项仍然过多的子分片继续按下一个字节分片。返回的Object链接所有子分片，
+// 其叶子哈希为各子分片的Merkle Root
+func (s *adder) putShards(entries []shardEntry, level int) (*Object, []string, error) {
+	buckets := make([][]shardEntry, 256)
+	for _, e := range entries {
+		b := s.nameHash(e.link.Name)[level]
+		buckets[b] = append(buckets[b], e)
+	}
+	obj := &Object{}
+	var hashes []string
+	for b, bucket := range buckets {
+		if len(bucket) == 0 {
+			continue
+		}
+		key, hash, err := s.putShard(bucket, level+1)
+		if err != nil {
+			return nil, nil, err
+		}
+		var size int64
+		for _, e := range bucket {
+			size += e.link.Size
+		}
+		obj.Links = append(obj.Links, Link{Name: fmt.Sprintf("%02x", b), Hash: []byte(key), Size: size})
+		obj.Data = append(obj.Data, SHARD...)
+		hashes = append(hashes, hash)
+	}
+	return obj, hashes, nil
+}
+
+// putShard 保存第level层的一个子分片，返回其键值和Merkle Root
+func (s *adder) putShard(entries []shardEntry, level int) (string, string, error) {
+	obj, hashes := shardLeaf(entries)
+	data, err := s.serializer.Marshal(obj)
+	if err != nil {
+		return "", "", err
+	}
+	if s.needsShard(len(entries), data) && level < len(s.nameHash("")) {
+		if obj, hashes, err = s.putShards(entries, level); err != nil {
+			return "", "", err
+		}
+		if data, err = s.serializer.Marshal(obj); err != nil {
+			return "", "", err
+		}
+	}
+	merkleRoot, err := s.treeRoot(append(hashes, s.hashBytes(data)))
+	if err != nil {
+		return "", "", err
+	}
+	key := s.formatKey(SHARD, merkleRoot)
+	if err := s.putEncodedBlock(key, data); err != nil {
+		return "", "", err
+	}
+	return key, merkleRoot, nil
+}
+
+// shardLeaf 返回直接链接所有目录项的Object及其叶子哈希
+func shardLeaf(entries []shardEntry) (*Object, []string) {
+	obj := &Object{}
+	hashes := make([]string, 0, len(entries))
+	for _, e := range entries {
+		obj.Links = append(obj.Links, e.link)
+		obj.Data = append(obj.Data, e.tag...)
+		hashes = append(hashes, e.hash)
+	}
+	return obj, hashes
+}
+
+// isSharded 判断目录块或分片块的链接是否指向子分片
+func isSharded(obj *Object) bool {
+	return len(obj.Links) > 0 && obj.linkType(0) == SHARD
+}
+
+// expandShards 读取obj的所有子分片，返回直接链接所有目录项的Object。
+// obj没有分片时原样返回。目录项总是按名字排序，与没有分片的目录块相同
+func (s *DagService) expandShards(obj *Object) (*Object, error) {
+	if !isSharded(obj) {
+		return obj, nil
+	}
+	flat := &Object{Meta: obj.Meta}
+	stack := []*Object{obj}
+	for len(stack) > 0 {
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !isSharded(top) {
+			flat.Links = append(flat.Links, top.Links...)
+			flat.Data = append(flat.Data, top.Data...)
+			continue
+		}
+		// 逆序入栈，使目录项按分片的顺序排列
+		for i := len(top.Links) - 1; i >= 0; i-- {
+			shard, err := s.readObject(string(top.Links[i].Hash))
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, shard)
+		}
+	}
+	// 分片中的目录项按名字的哈希排列，重新按名字排序，类型标记随链接一起移动
+	order := make([]int, len(flat.Links))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return flat.Links[order[i]].Name < flat.Links[order[j]].Name
+	})
+	sorted := &Object{Meta: flat.Meta, Links: make([]Link, len(order)), Data: make([]byte, 0, len(flat.Data))}
+	for i, j := range order {
+		sorted.Links[i] = flat.Links[j]
+		sorted.Data = append(sorted.Data, flat.linkType(j)...)
+	}
+	return sorted, nil
+}
+
+// dirStep 是在目录中查找一个名字时读取的一个数据块，obj.Links[index]为选中的链接
+type dirStep struct {
+	data  []byte
+	obj   *Object
+	index int
+}
+
+// lookup 在键值为key的目录中查找名为name的目录项，返回依次经过的目录块和分片块。
+// 分片目录中只读取name所在的分片
+func (s *DagService) lookup(key string, name string) ([]dirStep, error) {
+	var steps []dirStep
+	for level := 0; ; level++ {
+		data, err := s.getBlock(key)
+		if err != nil {
+			return nil, err
+		}
+		obj, err := s.decodeBlock(key, data)
+		if err != nil {
+			return nil, err
+		}
+		want := name
+		if isSharded(obj) {
+			if level >= len(s.nameHash("")) {
+				return nil, errMalformedObject
+			}
+			want = fmt.Sprintf("%02x", s.nameHash(name)[level])
+		}
+		index := -1
+		for i, link := range obj.Links {
+			if link.Name == want {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return nil, fmt.Errorf("%s: %w", name, ErrNotFound)
+		}
+		steps = append(steps, dirStep{data: data, obj: obj, index: index})
+		if !isSharded(obj) {
+			return steps, nil
+		}
+		key = string(obj.Links[index].Hash)
+	}
+}
@@ -0,0 +1,59 @@
+package merkledag
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestShardedDirectoryResolve(t *testing.T) {
+	const entries = 100000
+	big := NewDirBuilder()
+	for i := 0; i < entries; i++ {
+		big.AddFile(fmt.Sprint("f", i), []byte(fmt.Sprint(i)))
+	}
+	st := newCountingStore()
+	s := NewDagService(st, WithShardThreshold(256))
+	root, err := s.Add(NewDirBuilder().AddDir("big", big.Build()).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := st.Keys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	shards := 0
+	for _, key := range keys {
+		if objType, err := rootType(key); err == nil && objType == SHARD {
+			shards++
+		}
+	}
+	st.reset()
+	n, err := s.Resolve(root, "big/f77777")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f, ok := n.(File); !ok || string(f.Bytes()) != "77777" {
+		t.Fatalf("got %v", n)
+	}
+	// 根目录、分片目录的索引块、经过的一两层分片和文件本身
+	if got := st.blockGets(); got > 6 || got >= shards {
+		t.Fatalf("resolve read %d blocks of %d shards", got, shards)
+	}
+	if _, err := s.Resolve(root, "big/missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+
+	count := 0
+	err = s.Walk(root, func(key string, node Node) error {
+		count++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 分片对Walk不可见：两个目录和所有文件
+	if count != entries+2 {
+		t.Fatalf("walked %d nodes, want %d", count, entries+2)
+	}
+}
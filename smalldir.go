@@ -0,0 +1,61 @@
+package merkledag
+
+import "encoding/binary"
+
+// defaultSmallDirEntries 是默认使用serializeSmall的目录的最大目录项数量
+const defaultSmallDirEntries = 16
+
+// marshalDir 编码目录块。使用BinarySerializer且目录项不多于WithSmallDirThreshold时，
+// 预先计算编码后的长度，一次分配缓冲区，结果与serialize相同
+func (s *DagService) marshalDir(obj *Object) ([]byte, error) {
+	if _, ok := s.serializer.(BinarySerializer); ok && len(obj.Links) <= s.smallDirEntries {
+		return serializeSmall(obj)
+	}
+	return s.serializer.Marshal(obj)
+}
+
+// serializeSmall 按serialize的格式编码obj，所需的缓冲区只分配一次
+func serializeSmall(obj *Object) ([]byte, error) {
+	if len(obj.Data) != len(obj.Links)*STEP {
+		return nil, errMalformedObject
+	}
+	var metaBuf [4 * binary.MaxVarintLen64]byte
+	var meta []byte
+	if obj.Meta != nil {
+		meta = appendMetadata(metaBuf[:0], obj.Meta)
+	}
+	size := uvarintLen(uint64(len(obj.Links))) + len(meta)
+	for _, link := range obj.Links {
+		size += STEP + uvarintLen(uint64(len(link.Name))) + len(link.Name) +
+			uvarintLen(uint64(len(link.Hash))) + len(link.Hash) + varintLen(link.Size)
+	}
+	buf := make([]byte, 0, size)
+	buf = binary.AppendUvarint(buf, uint64(len(obj.Links)))
+	for i, link := range obj.Links {
+		buf = append(buf, obj.Data[i*STEP:(i+1)*STEP]...)
+		buf = binary.AppendUvarint(buf, uint64(len(link.Name)))
+		buf = append(buf, link.Name...)
+		buf = binary.AppendUvarint(buf, uint64(len(link.Hash)))
+		buf = append(buf, link.Hash...)
+		buf = binary.AppendVarint(buf, link.Size)
+	}
+	return append(buf, meta...), nil
+}
+
+// uvarintLen 返回v按uvarint编码后的字节数
+func uvarintLen(v uint64) int {
+	n := 1
+	for ; v >= 0x80; v >>= 7 {
+		n++
+	}
+	return n
+}
+
+// varintLen 返回v按varint编码后的字节数
+func varintLen(v int64) int {
+	ux := uint64(v) << 1
+	if v < 0 {
+		ux = ^ux
+	}
+	return uvarintLen(ux)
+}
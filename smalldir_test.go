@@ -0,0 +1,110 @@
+package merkledag
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSerializeSmallMatchesSerialize(t *testing.T) {
+	for i := 0; i < 2000; i++ {
+		r := rand.New(rand.NewSource(int64(i)))
+		obj := &Object{}
+		for j := r.Intn(20); j > 0; j-- {
+			name := make([]byte, r.Intn(300))
+			r.Read(name)
+			obj.Links = append(obj.Links, Link{
+				Name: string(name),
+				Hash: []byte(fmt.Sprint("file_", r.Int63())),
+				Size: r.Int63n(1<<40) - 1<<39,
+			})
+			obj.Data = append(obj.Data, BLOB...)
+		}
+		if r.Intn(2) == 0 {
+			obj.Meta = &Metadata{
+				Mode:    os.FileMode(r.Uint32()),
+				ModTime: time.Unix(0, r.Int63()-1<<62),
+				Uid:     r.Int() - 1<<62,
+				Gid:     -5,
+			}
+			if r.Intn(2) == 0 {
+				obj.Meta.Xattrs = []Xattr{{Name: "user.k", Value: bytes.Repeat([]byte{1}, r.Intn(100))}}
+			}
+		}
+		want, err := serialize(obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := serializeSmall(obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("case %d: serializeSmall differs from serialize", i)
+		}
+		if cap(got) != len(got) {
+			t.Fatalf("case %d: buffer of %d bytes has capacity %d", i, len(got), cap(got))
+		}
+	}
+	tree := NewDirBuilder().AddFile("a", []byte("a")).Build()
+	fast, err := NewDagService(NewMemStore()).Add(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	general, err := NewDagService(NewMemStore(), WithSmallDirThreshold(-1)).Add(tree)
+	if err != nil || general != fast {
+		t.Fatalf("general path root %s, %v; want %s", general, err, fast)
+	}
+}
+
+func BenchmarkSerializeSmallDir(b *testing.B) {
+	hash := []byte("file_0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef")
+	obj := &Object{
+		Links: []Link{{Name: "readme.txt", Hash: hash, Size: 120}, {Name: "main.go", Hash: hash, Size: 4000}},
+		Data:  []byte(BLOB + BLOB),
+	}
+	b.Run("general", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := serialize(obj); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("small", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := serializeSmall(obj); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkAddSmallDirs(b *testing.B) {
+	// 1000个各有两个小文件的子目录
+	root := NewDirBuilder()
+	for i := 0; i < 1000; i++ {
+		sub := NewDirBuilder().
+			AddFile("readme.txt", []byte(fmt.Sprint("readme ", i))).
+			AddFile("main.go", []byte(fmt.Sprint("package p", i)))
+		root.AddDir(fmt.Sprint("d", i), sub.Build())
+	}
+	tree := root.Build()
+	for _, c := range []struct {
+		name      string
+		threshold int
+	}{{"general", -1}, {"small", defaultSmallDirEntries}} {
+		b.Run(c.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := NewDagService(NewMemStore(), WithSmallDirThreshold(c.threshold)).Add(tree); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package merkledag
+
+import "sync"
+
+// NodeType 是Node.Type()返回的节点类型
+type NodeType int
+
+func (t NodeType) String() string {
+	switch t {
+	case FILE:
+		return "file"
+	case DIR:
+		return "dir"
+	case SYMLINK:
+		return "symlink"
+	}
+	nodeRegistry.RLock()
+	defer nodeRegistry.RUnlock()
+	if codec, ok := nodeRegistry.byType[t]; ok {
+		return codec.Tag()
+	}
+	return "unknown"
+}
+
+// Stat 是一个节点的概要信息
+type Stat struct {
+	Type NodeType
+	// Size 与Node.Size()相同，目录为其中所有文件的大小之和
+	Size int64
+	// NumChildren 为目录中的目录项数量，或分块文件的数据块数量
+	NumChildren int
+	// CumulativeSize 为该节点及其所有子节点的数据块的总字节数，
+	// 被多处引用的子树按引用的次数计算
+	CumulativeSize int64
+}
+
+// statCache 保存已经计算过的CumulativeSize。数据块的内容由键值决定，缓存不会失效
+type statCache struct {
+	mu    sync.Mutex
+	sizes map[string]int64
+}
+
+func (c *statCache) get(key string) (int64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	size, ok := c.sizes[key]
+	return size, ok
+}
+
+func (c *statCache) put(key string, size int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.sizes == nil {
+		c.sizes = make(map[string]int64)
+	}
+	c.sizes[key] = size
+}
+
+// Stat 返回key对应节点的概要信息，只读取该节点的数据块，
+// CumulativeSize第一次计算时需要读取整个子树
+func (s *DagService) Stat(key string) (Stat, error) {
+	objType, err := rootType(key)
+	if err != nil {
+		return Stat{}, err
+	}
+	data, err := s.getBlock(key)
+	if err != nil {
+		return Stat{}, err
+	}
+	var st Stat
+	switch objType {
+	case BLOB:
+		st = Stat{Type: FILE, Size: int64(len(data))}
+	case LINK:
+		st = Stat{Type: SYMLINK, Size: int64(len(data))}
+	case TREE, LIST:
+		obj, err := s.decodeBlock(key, data)
+		if err != nil {
+			return Stat{}, err
+		}
+		if obj, err = s.expandShards(obj); err != nil {
+			return Stat{}, err
+		}
+		st = Stat{Type: FILE, NumChildren: len(obj.Links)}
+		if objType == TREE {
+			st.Type = DIR
+		}
+		for _, link := range obj.Links {
+			st.Size += link.Size
+		}
+	default:
+		typ, codec, ok := codecForTag(objType)
+		if !ok {
+			return Stat{}, ErrUnsupportedNodeType
+		}
+		node, err := codec.Decode(data)
+		if err != nil {
+			return Stat{}, corruptBlock(key, err)
+		}
+		st = Stat{Type: typ, Size: node.Size()}
+	}
+	if st.CumulativeSize, err = s.cumulativeSize(key, objType); err != nil {
+		return Stat{}, err
+	}
+	return st, nil
+}
+
+// sizeFrame 是cumulativeSize中尚未计算完子节点的数据块
+type sizeFrame struct {
+	key      string
+	total    int64
+	children []blockRef
+	next     int
+}
+
+// cumulativeSize 计算key可达的所有数据块的总字节数，使用显式的栈后序遍历，结果保存在statCache中
+func (s *DagService) cumulativeSize(key string, objType string) (int64, error) {
+	if size, ok := s.stats.get(key); ok {
+		return size, nil
+	}
+	frame, err := s.newSizeFrame(blockRef{key: key, objType: objType})
+	if err != nil {
+		return 0, err
+	}
+	stack := []*sizeFrame{frame}
+	for {
+		top := stack[len(stack)-1]
+		if top.next < len(top.children) {
+			child := top.children[top.next]
+			if size, ok := s.stats.get(child.key); ok {
+				top.total += size
+				top.next++
+				continue
+			}
+			frame, err := s.newSizeFrame(child)
+			if err != nil {
+				return 0, err
+			}
+			stack = append(stack, frame)
+			continue
+		}
+		s.stats.put(top.key, top.total)
+		stack = stack[:len(stack)-1]
+		if len(stack) == 0 {
+			return top.total, nil
+		}
+		parent := stack[len(stack)-1]
+		parent.total += top.total
+		parent.next++
+	}
+}
+
+// newSizeFrame 读取ref的数据块，记录其大小和子节点
+func (s *DagService) newSizeFrame(ref blockRef) (*sizeFrame, error) {
+	data, err := s.getBlock(ref.key)
+	if err != nil {
+		return nil, err
+	}
+	frame := &sizeFrame{key: ref.key, total: int64(len(data))}
+	obj, err := s.blockLinks(ref, data)
+	if err != nil || obj == nil {
+		return frame, err
+	}
+	for i, link := range obj.Links {
+		frame.children = append(frame.children, blockRef{key: string(link.Hash), objType: obj.linkType(i)})
+	}
+	return frame, nil
+}
@@ -0,0 +1,71 @@
+package merkledag
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestStatDirectory(t *testing.T) {
+	st := newCountingStore()
+	s := NewDagService(st)
+	root, err := s.Add(NewDirBuilder().
+		AddFile("a.txt", []byte("alpha")).
+		AddFile("b.txt", []byte("beta")).
+		AddDir("sub", NewDirBuilder().AddFile("c.txt", []byte("gamma")).Build()).
+		Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 存储中只有这棵树的数据块和头部
+	keys, err := st.Keys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var total int64
+	for _, key := range keys {
+		if key == headerKey {
+			continue
+		}
+		data, err := st.MemStore.Get(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		total += int64(len(data))
+	}
+	st.reset()
+	got, err := s.Stat(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Stat{Type: DIR, Size: 14, NumChildren: 3, CumulativeSize: total}
+	if got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	// CumulativeSize已被缓存，再次Stat只读取目录本身
+	st.reset()
+	if again, err := s.Stat(root); err != nil || again != want {
+		t.Fatalf("got %+v, %v", again, err)
+	}
+	if st.blockGets() != 1 {
+		t.Fatalf("second Stat read %d blocks, want 1", st.blockGets())
+	}
+}
+
+func TestStatChunkedFile(t *testing.T) {
+	data := chunkedSource(2500)
+	s := NewDagService(NewMemStore(), WithChunkSize(1000))
+	key, err := s.Add(NewChunkedFile(bytes.NewReader(data), int64(len(data))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Stat(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Type != FILE || got.Size != 2500 || got.NumChildren != 3 || got.CumulativeSize <= 2500 {
+		t.Fatalf("got %+v", got)
+	}
+	if got.Type.String() != "file" {
+		t.Fatalf("type %q", got.Type)
+	}
+}
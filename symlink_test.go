@@ -0,0 +1,75 @@
+package merkledag
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// linkTarget 是目标为其自身的Symlink
+type linkTarget string
+
+func (l linkTarget) Size() int64    { return int64(len(l)) }
+func (l linkTarget) Type() int      { return SYMLINK }
+func (l linkTarget) Target() string { return string(l) }
+
+func TestSymlinkRoundTrip(t *testing.T) {
+	s := NewDagService(NewMemStore())
+	root, err := s.Add(NewDirBuilder().add("link", linkTarget("../some/target")).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := s.Resolve(root, "link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	link, ok := n.(Symlink)
+	if !ok || link.Type() != SYMLINK {
+		t.Fatalf("got %T", n)
+	}
+	if link.Target() != "../some/target" {
+		t.Fatalf("target %q", link.Target())
+	}
+	other, err := s.Add(NewDirBuilder().add("link", linkTarget("../other")).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == root {
+		t.Fatal("different targets share a root")
+	}
+	// 目标与文件内容相同时键值也不同
+	l, err := s.Add(linkTarget("x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := s.Add(NewFile([]byte("x")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l == f {
+		t.Fatal("symlink and file share a key")
+	}
+}
+
+func TestSymlinkImportExport(t *testing.T) {
+	src := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "x.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("x.txt", filepath.Join(src, "link")); err != nil {
+		t.Skip(err)
+	}
+	s := NewDagService(NewMemStore())
+	root, err := ImportPath(s, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dest := t.TempDir()
+	if err := ExportPath(s, root, dest); err != nil {
+		t.Fatal(err)
+	}
+	target, err := os.Readlink(filepath.Join(dest, "link"))
+	if err != nil || target != "x.txt" {
+		t.Fatalf("got %q, %v", target, err)
+	}
+}
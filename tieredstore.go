@@ -0,0 +1,191 @@
+package merkledag
+
+import (
+	"errors"
+	"sync"
+)
+
+// TieredOption 用于配置TieredStore
+type TieredOption func(*TieredStore)
+
+// WithAsyncFlush 指定Put只同步写入快速层，慢速层在后台写入。
+// 调用Flush等待后台写入完成并得到其中的错误
+func WithAsyncFlush() TieredOption {
+	return func(t *TieredStore) {
+		t.async = true
+	}
+}
+
+// WithSelfHeal 指定Get是否验证从快速层读到的数据块：内容与键值不符时（例如本地磁盘的位翻转），
+// 改为从慢速层读取正确的数据块，修复快速层后返回，并调用Metrics的IncRepair。
+// 验证使用WithHealService指定的DagService的配置
+func WithSelfHeal(on bool) TieredOption {
+	return func(t *TieredStore) {
+		t.selfHeal = on
+	}
+}
+
+// WithHealService 指定自愈时按service的配置（哈希函数、键值编码等）验证数据块，
+// 默认为NewDagService的默认配置
+func WithHealService(service *DagService) TieredOption {
+	return func(t *TieredStore) {
+		t.verifier = service
+	}
+}
+
+// WithTierMetrics 指定修复数据块时调用的Metrics，只报告IncRepair
+func WithTierMetrics(m Metrics) TieredOption {
+	return func(t *TieredStore) {
+		t.metrics = m
+	}
+}
+
+// TieredStore 由快速和慢速两层KVStore组成：Get先读快速层，没有时读慢速层并把数据块提升到快速层；
+// Put写入两层；Delete从两层删除。可以被多个goroutine同时使用
+type TieredStore struct {
+	fast     KVStore
+	slow     KVStore
+	async    bool
+	selfHeal bool
+	verifier *DagService
+	metrics  Metrics
+
+	// wg 等待后台写入慢速层的goroutine，err为其中的第一个错误
+	wg  sync.WaitGroup
+	mu  sync.Mutex
+	err error
+}
+
+// NewTieredStore 创建快速层为fast、慢速层为slow的TieredStore
+func NewTieredStore(fast, slow KVStore, opts ...TieredOption) *TieredStore {
+	t := &TieredStore{fast: fast, slow: slow, metrics: NopMetrics{}}
+	for _, opt := range opts {
+		opt(t)
+	}
+	if t.selfHeal && t.verifier == nil {
+		t.verifier = NewDagService(emptyStore{})
+	}
+	return t
+}
+
+func (t *TieredStore) Has(key string) (bool, error) {
+	ok, err := t.fast.Has(key)
+	if err != nil || ok {
+		return ok, err
+	}
+	return t.slow.Has(key)
+}
+
+func (t *TieredStore) Put(key string, value []byte) error {
+	if err := t.fast.Put(key, value); err != nil {
+		return err
+	}
+	if !t.async {
+		return t.slow.Put(key, value)
+	}
+	value = append([]byte(nil), value...)
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		if err := t.slow.Put(key, value); err != nil {
+			t.mu.Lock()
+			if t.err == nil {
+				t.err = err
+			}
+			t.mu.Unlock()
+		}
+	}()
+	return nil
+}
+
+func (t *TieredStore) Get(key string) ([]byte, error) {
+	value, err := t.fast.Get(key)
+	if err == nil && t.selfHeal && t.verify(key, value) != nil {
+		return t.heal(key)
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return value, err
+	}
+	if value, err = t.slow.Get(key); err != nil {
+		return nil, err
+	}
+	if err := t.fast.Put(key, value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// heal 从慢速层读取key对应的数据块，验证后写回快速层。慢速层的数据块同样损坏时返回验证的错误
+func (t *TieredStore) heal(key string) ([]byte, error) {
+	value, err := t.slow.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.verify(key, value); err != nil {
+		return nil, err
+	}
+	if err := t.fast.Put(key, value); err != nil {
+		return nil, err
+	}
+	t.metrics.IncRepair()
+	return value, nil
+}
+
+// verify 检查保存的数据块value与key是否相符。pin_等不是数据块的键值不验证
+func (t *TieredStore) verify(key string, value []byte) error {
+	if _, err := rootType(key); err != nil {
+		return nil
+	}
+	data, err := t.verifier.decodeStored(key, value)
+	if err != nil {
+		return err
+	}
+	return t.verifier.verifyBlock(key, data)
+}
+
+// Delete 从两层中删除key。先等待后台写入完成，避免数据块在删除后又被写入慢速层
+func (t *TieredStore) Delete(key string) error {
+	t.wg.Wait()
+	if err := t.fast.Delete(key); err != nil {
+		return err
+	}
+	return t.slow.Delete(key)
+}
+
+// Flush 等待所有后台写入慢速层完成，返回其中的第一个错误。没有设置WithAsyncFlush时直接返回nil
+func (t *TieredStore) Flush() error {
+	t.wg.Wait()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	err := t.err
+	t.err = nil
+	return err
+}
+
+// Close 等待后台写入完成，再关闭两层中实现了io.Closer的KVStore
+func (t *TieredStore) Close() error {
+	return errors.Join(t.Flush(), closeStores(t.fast, t.slow))
+}
+
+// Keys 列出两层中的所有键值，任意一层没有实现Enumerate时返回ErrNotEnumerable
+func (t *TieredStore) Keys() ([]string, error) {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, store := range []KVStore{t.fast, t.slow} {
+		lister, ok := store.(Enumerate)
+		if !ok {
+			return nil, ErrNotEnumerable
+		}
+		tierKeys, err := lister.Keys()
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range tierKeys {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,122 @@
+package merkledag
+
+import (
+	"crypto/sha512"
+	"errors"
+	"testing"
+)
+
+func TestTieredStorePromotes(t *testing.T) {
+	fast, slow := newCountingStore(), newCountingStore()
+	if err := slow.Put("file_x", []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	ts := NewTieredStore(fast, slow)
+	if v, err := ts.Get("file_x"); err != nil || string(v) != "x" {
+		t.Fatalf("got %q, %v", v, err)
+	}
+	if slow.gets["file_x"] != 1 {
+		t.Fatalf("slow tier read %d times, want 1", slow.gets["file_x"])
+	}
+	if ok, _ := fast.Has("file_x"); !ok {
+		t.Fatal("block not promoted to the fast tier")
+	}
+	// 第二次从快速层读取
+	if v, err := ts.Get("file_x"); err != nil || string(v) != "x" {
+		t.Fatalf("got %q, %v", v, err)
+	}
+	if slow.gets["file_x"] != 1 || fast.gets["file_x"] != 2 {
+		t.Fatalf("fast %d, slow %d reads; want 2 and 1", fast.gets["file_x"], slow.gets["file_x"])
+	}
+	if _, err := ts.Get("file_missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+}
+
+func TestTieredStoreWrites(t *testing.T) {
+	fast, slow := NewMemStore(), NewMemStore()
+	s := NewDagService(NewTieredStore(fast, slow))
+	root, err := s.Add(NewDirBuilder().AddFile("a", []byte("a")).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tier := range []*MemStore{fast, slow} {
+		if ok, _ := tier.Has(root); !ok {
+			t.Fatal("Put did not write to both tiers")
+		}
+	}
+	async := NewTieredStore(NewMemStore(), slow, WithAsyncFlush())
+	if err := async.Put("file_y", []byte("y")); err != nil {
+		t.Fatal(err)
+	}
+	if err := async.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := slow.Has("file_y"); !ok {
+		t.Fatal("Flush did not write to the slow tier")
+	}
+	if err := async.Delete("file_y"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := slow.Has("file_y"); ok {
+		t.Fatal("Delete left the block in the slow tier")
+	}
+}
+
+func TestTieredStoreSelfHeal(t *testing.T) {
+	fast, slow := NewMemStore(), NewMemStore()
+	var m CounterMetrics
+	ts := NewTieredStore(fast, slow, WithSelfHeal(true), WithTierMetrics(&m))
+	s := NewDagService(ts)
+	fileKey, err := s.Add(NewFile([]byte("hello")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := s.Add(NewDirBuilder().AddFile("a", []byte("hello")).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 快速层中的数据块发生位翻转
+	if err := fast.Put(fileKey, []byte("hellp")); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := ts.Get(fileKey); err != nil || string(v) != "hello" {
+		t.Fatalf("got %q, %v", v, err)
+	}
+	if got := m.Repairs.Load(); got != 1 {
+		t.Fatalf("Repairs = %d, want 1", got)
+	}
+	if v, _ := fast.Get(fileKey); string(v) != "hello" {
+		t.Fatalf("fast tier not repaired: %q", v)
+	}
+	if got := readPath(t, s, root, "a"); string(got) != "hello" || m.Repairs.Load() != 1 {
+		t.Fatalf("read %q after repair, %d repairs", got, m.Repairs.Load())
+	}
+	// 两层都损坏时报告错误
+	if err := fast.Put(fileKey, []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	if err := slow.Put(fileKey, []byte("y")); err != nil {
+		t.Fatal(err)
+	}
+	var mismatch *ErrHashMismatch
+	if _, err := ts.Get(fileKey); !errors.As(err, &mismatch) {
+		t.Fatalf("got %v, want ErrHashMismatch", err)
+	}
+}
+
+func TestTieredStoreSelfHealUsesServiceConfig(t *testing.T) {
+	opts := []Option{WithTypePrefix(true), WithHasher(sha512.New)}
+	fast, slow := NewMemStore(), NewMemStore()
+	ts := NewTieredStore(fast, slow, WithSelfHeal(true), WithHealService(NewDagService(NewMemStore(), opts...)))
+	key, err := NewDagService(ts, opts...).Add(NewFile([]byte("q")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 按WithHealService的配置验证，正确的数据块和存储头都不被当作损坏
+	for _, k := range []string{key, headerKey} {
+		if _, err := ts.Get(k); err != nil {
+			t.Fatalf("Get(%s): %v", k, err)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package merkledag
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// fetchEach 对keys中的每个键值调用fetch，同时进行的调用不超过WithTraversalConcurrency指定的数量。
+// fetch返回错误或ctx被取消后不再开始新的调用，返回第一个错误
+func (s *DagService) fetchEach(ctx context.Context, keys []string, fetch func(key string) error) error {
+	if s.traversalConcurrency <= 1 || len(keys) <= 1 {
+		for _, key := range keys {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := fetch(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(s.traversalConcurrency)
+	for _, key := range keys {
+		if groupCtx.Err() != nil {
+			break
+		}
+		group.Go(func() error {
+			return fetch(key)
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// prefetchChildren 并发读取目录obj的子节点的数据块，放入g.blocks供随后的loadNode使用。
+// 已经重建的、已经读取的和内嵌的子节点不读取
+func (g *getter) prefetchChildren(obj *Object) error {
+	var keys []string
+	seen := make(map[string]bool)
+	for _, link := range obj.Links {
+		key := string(link.Hash)
+		if _, ok := inlineData(key); ok || seen[key] {
+			continue
+		}
+		if _, ok := g.cache[key]; ok {
+			continue
+		}
+		if _, ok := g.blocks[key]; ok {
+			continue
+		}
+		seen[key] = true
+		keys = append(keys, key)
+	}
+	return g.fetchBlocks(keys)
+}
+
+// prefetchSiblings 在Walk访问到的ref的数据块还没有读取时，并发读取它和栈中紧随其后的同一目录中的节点的数据块，
+// 最多WithTraversalConcurrency个，放入g.blocks；与CopyTree一样每层最多保留这么多数据块。
+// 并发数不大于1时不预读，每个数据块在访问到时才读取
+func (g *getter) prefetchSiblings(ref walkFrame, stack []walkFrame) error {
+	n := g.traversalConcurrency
+	if n <= 1 {
+		return nil
+	}
+	if _, ok := inlineData(ref.key); ok {
+		return nil
+	}
+	if _, ok := g.blocks[ref.key]; ok {
+		return nil
+	}
+	keys := []string{ref.key}
+	seen := map[string]bool{ref.key: true}
+	// 栈顶是下一个被访问的节点
+	for i := len(stack) - 1; i >= 0 && len(keys) < n && stack[i].parent == ref.parent; i-- {
+		key := stack[i].key
+		if _, ok := inlineData(key); ok || seen[key] {
+			continue
+		}
+		if _, ok := g.blocks[key]; ok {
+			continue
+		}
+		seen[key] = true
+		keys = append(keys, key)
+	}
+	return g.fetchBlocks(keys)
+}
+
+// fetchBlocks 并发读取keys对应的数据块放入g.blocks，只有一个键值时不读取，留给loadNode
+func (g *getter) fetchBlocks(keys []string) error {
+	if len(keys) <= 1 {
+		return nil
+	}
+	if g.blocks == nil {
+		g.blocks = make(map[string][]byte)
+	}
+	var mu sync.Mutex
+	return g.fetchEach(g.ctx, keys, func(key string) error {
+		data, err := g.getBlock(key)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		g.blocks[key] = data
+		return nil
+	})
+}
@@ -0,0 +1,170 @@
+package merkledag
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// inflightStore 记录同时进行的Get的最大数量
+type inflightStore struct {
+	KVStore
+	mu        sync.Mutex
+	cur, peak int
+}
+
+func (s *inflightStore) Get(key string) ([]byte, error) {
+	s.mu.Lock()
+	s.cur++
+	if s.cur > s.peak {
+		s.peak = s.cur
+	}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.cur--
+		s.mu.Unlock()
+	}()
+	// 拉长每次读取的时间，让并发的读取重叠
+	time.Sleep(200 * time.Microsecond)
+	return s.KVStore.Get(key)
+}
+
+func (s *inflightStore) resetPeak() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.peak = 0
+}
+
+func TestTraversalConcurrency(t *testing.T) {
+	mem := NewMemStore()
+	b := NewDirBuilder()
+	for i := 0; i < 30; i++ {
+		sub := NewDirBuilder()
+		for j := 0; j < 10; j++ {
+			sub.AddFile(fmt.Sprint("f", j), []byte(fmt.Sprint(i, "-", j, "-content")))
+		}
+		b.AddDir(fmt.Sprint("d", i), sub.Build())
+	}
+	root, err := NewDagService(mem).Add(b.Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int{1, 3, 8} {
+		st := &inflightStore{KVStore: mem}
+		s := NewDagService(st, WithTraversalConcurrency(n))
+		if err := s.Walk(root, func(string, Node) error { return nil }); err != nil {
+			t.Fatal(err)
+		}
+		if st.peak > n || (n > 1 && st.peak < 2) {
+			t.Fatalf("Walk with limit %d: %d Gets in flight", n, st.peak)
+		}
+		st.resetPeak()
+		dst := NewDagService(NewMemStore())
+		copied, err := CopyTree(dst, s, root)
+		if err != nil || copied != 1+30*11 {
+			t.Fatalf("CopyTree copied %d blocks, %v", copied, err)
+		}
+		if st.peak > n || (n > 1 && st.peak < 2) {
+			t.Fatalf("CopyTree with limit %d: %d Gets in flight", n, st.peak)
+		}
+		if eq, err := EqualAcross(s, root, dst, root); err != nil || !eq {
+			t.Fatalf("copy differs: %v, %v", eq, err)
+		}
+	}
+}
+
+func TestTraversalConcurrencyMissingBlock(t *testing.T) {
+	mem := NewMemStore()
+	b := NewDirBuilder()
+	for i := 0; i < 20; i++ {
+		b.AddFile(fmt.Sprint("f", i), []byte(fmt.Sprint("content ", i)))
+	}
+	root, err := NewDagService(mem).Add(b.Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := mem.Keys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range keys {
+		if strings.HasPrefix(key, "file_") {
+			if err := mem.Delete(key); err != nil {
+				t.Fatal(err)
+			}
+			break
+		}
+	}
+	s := NewDagService(mem, WithTraversalConcurrency(4))
+	if _, err := CopyTree(NewDagService(NewMemStore()), s, root); err == nil {
+		t.Fatal("CopyTree succeeded with a missing block")
+	}
+	if err := s.Walk(root, func(string, Node) error { return nil }); err == nil {
+		t.Fatal("Walk succeeded with a missing block")
+	}
+}
+
+// eventStore 把每次数据块的Get按顺序记录到events中
+type eventStore struct {
+	KVStore
+	mu     sync.Mutex
+	events []string
+}
+
+func (s *eventStore) Get(key string) ([]byte, error) {
+	if _, err := rootType(key); err == nil {
+		s.record("get")
+	}
+	return s.KVStore.Get(key)
+}
+
+func (s *eventStore) record(event string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+}
+
+func TestWalkFetchesOnVisit(t *testing.T) {
+	mem := NewMemStore()
+	b := NewDirBuilder()
+	for i := 0; i < 50; i++ {
+		b.AddFile(fmt.Sprint("f", i), []byte(fmt.Sprint("content ", i)))
+	}
+	root, err := NewDagService(mem).Add(b.Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int{1, 4} {
+		st := &eventStore{KVStore: mem}
+		s := NewDagService(st, WithTraversalConcurrency(n))
+		if err := s.Walk(root, func(string, Node) error {
+			st.record("visit")
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		got := strings.Join(st.events, " ")
+		if n == 1 {
+			// 每个数据块在访问到它时才读取
+			if want := strings.TrimSpace(strings.Repeat("get visit ", 51)); got != want {
+				t.Fatalf("n=1: events %q", got)
+			}
+			continue
+		}
+		// 每次最多预读n个数据块
+		gets := 0
+		for _, event := range st.events {
+			if event == "visit" {
+				gets = 0
+			} else if gets++; gets > n {
+				t.Fatalf("n=%d: %d Gets between visits: %q", n, gets, got)
+			}
+		}
+		if strings.Count(got, "get") != 51 {
+			t.Fatalf("n=%d: events %q", n, got)
+		}
+	}
+}
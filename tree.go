@@ -0,0 +1,103 @@
+package merkledag
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// treeFrame 是PrintTree中正在输出子节点的目录
+type treeFrame struct {
+	entries []treeEntry
+	next    int
+	// prefix 是这一层的子节点每行前的缩进
+	prefix string
+}
+
+// treeEntry 是PrintTree输出的一个目录项
+type treeEntry struct {
+	name string
+	ref  blockRef
+	size int64
+}
+
+// PrintTree 以类似tree命令的格式将root下的所有节点写入w，每行包含名字、类型和大小。
+// 同一目录中子目录在前，文件在后，各自按名字排序
+func (s *DagService) PrintTree(root string, w io.Writer) error {
+	objType, err := rootType(root)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(w, "."); err != nil {
+		return err
+	}
+	if objType != TREE {
+		return nil
+	}
+	frame, err := s.newTreeFrame(root, "")
+	if err != nil {
+		return err
+	}
+	stack := []*treeFrame{frame}
+	for len(stack) > 0 {
+		top := stack[len(stack)-1]
+		if top.next >= len(top.entries) {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		e := top.entries[top.next]
+		top.next++
+		last := top.next == len(top.entries)
+		connector, indent := "├── ", "│   "
+		if last {
+			connector, indent = "└── ", "    "
+		}
+		if _, err := fmt.Fprintf(w, "%s%s%s (%s, %d B)\n", top.prefix, connector, e.name, typeName(e.ref.objType), e.size); err != nil {
+			return err
+		}
+		if e.ref.objType == TREE {
+			child, err := s.newTreeFrame(e.ref.key, top.prefix+indent)
+			if err != nil {
+				return err
+			}
+			stack = append(stack, child)
+		}
+	}
+	return nil
+}
+
+// newTreeFrame 读取目录key的目录项，子目录排在文件之前
+func (s *DagService) newTreeFrame(key string, prefix string) (*treeFrame, error) {
+	obj, err := s.readDir(key)
+	if err != nil {
+		return nil, err
+	}
+	f := &treeFrame{prefix: prefix}
+	for i, link := range obj.Links {
+		f.entries = append(f.entries, treeEntry{
+			name: link.Name,
+			ref:  blockRef{key: string(link.Hash), objType: obj.linkType(i)},
+			size: link.Size,
+		})
+	}
+	sort.SliceStable(f.entries, func(i, j int) bool {
+		a, b := f.entries[i], f.entries[j]
+		if (a.ref.objType == TREE) != (b.ref.objType == TREE) {
+			return a.ref.objType == TREE
+		}
+		return a.name < b.name
+	})
+	return f, nil
+}
+
+// typeName 返回类型标记对应的节点类型的名字
+func typeName(objType string) string {
+	switch objType {
+	case TREE:
+		return "dir"
+	case LINK:
+		return "symlink"
+	default:
+		return "file"
+	}
+}
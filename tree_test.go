@@ -0,0 +1,42 @@
+package merkledag
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPrintTree(t *testing.T) {
+	// 目录z的目录项超过分片阈值，分片存储不影响输出
+	s := NewDagService(NewMemStore(), WithShardThreshold(3))
+	sub := NewDirBuilder().
+		AddFile("x", []byte("x")).
+		AddDir("e", NewDirBuilder().Build()).
+		AddFile("w", nil).
+		AddFile("v", nil).
+		Build()
+	tree := NewDirBuilder().
+		AddFile("b", []byte("bb")).
+		AddDir("z", sub).
+		AddFile("a", []byte("a")).
+		Build()
+	root, err := s.Add(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := s.PrintTree(root, &buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `.
+├── z (dir, 1 B)
+│   ├── e (dir, 0 B)
+│   ├── v (file, 0 B)
+│   ├── w (file, 0 B)
+│   └── x (file, 1 B)
+├── a (file, 1 B)
+└── b (file, 2 B)
+`
+	if got := buf.String(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
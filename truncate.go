@@ -0,0 +1,223 @@
+package merkledag
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+)
+
+// ErrInvalidSize 表示Truncate的目标大小不合法
+var ErrInvalidSize = errors.New("invalid size")
+
+// TruncateOption 用于配置Truncate
+type TruncateOption func(*truncateConfig)
+
+type truncateConfig struct {
+	zeroFill bool
+}
+
+// WithZeroFill 允许Truncate的目标大小超过文件的大小，多出的部分用0填充。
+// 默认返回ErrInvalidSize
+func WithZeroFill() TruncateOption {
+	return func(c *truncateConfig) {
+		c.zeroFill = true
+	}
+}
+
+// Truncate 将root下path处的文件截断为前size字节，返回新的root。
+// 分块的文件保留截断点之前的完整数据块，只重新保存截断点所在的块，
+// 再依次重新保存路径上的目录，其余数据块都被新的root直接引用。path为空时root本身是文件
+func (s *DagService) Truncate(root string, path string, size int64, opts ...TruncateOption) (string, error) {
+	var conf truncateConfig
+	for _, opt := range opts {
+		opt(&conf)
+	}
+	if size < 0 {
+		return "", fmt.Errorf("truncate to %d bytes: %w", size, ErrInvalidSize)
+	}
+	// dirs[i]为names[i]所在的目录
+	var dirs, names []string
+	key := root
+	objType, err := rootType(root)
+	if err != nil {
+		return "", err
+	}
+	walked := ""
+	for _, name := range strings.Split(path, "/") {
+		if name == "" {
+			continue
+		}
+		if objType != TREE {
+			return "", &ErrNotADirectory{Path: "/" + walked}
+		}
+		walked = joinPath(walked, name)
+		steps, err := s.lookup(key, name)
+		if err != nil {
+			return "", err
+		}
+		dirs, names = append(dirs, key), append(names, name)
+		last := steps[len(steps)-1]
+		key = string(last.obj.Links[last.index].Hash)
+		objType = last.obj.linkType(last.index)
+	}
+	if objType != BLOB && objType != LIST {
+		return "", ErrNotAFile
+	}
+	newRoot, _, err := s.run(context.Background(), false, func(a *adder) (string, error) {
+		key, err := a.truncateFile(key, objType, size, conf.zeroFill)
+		if err != nil {
+			return "", err
+		}
+		// 从最深的目录开始依次替换目录项，目录的大小为其目录项的大小之和
+		for i := len(dirs) - 1; i >= 0; i-- {
+			entries, meta, err := s.dirEntries(dirs[i])
+			if err != nil {
+				return "", err
+			}
+			childType, err := rootType(key)
+			if err != nil {
+				return "", err
+			}
+			entries = setEntry(entries, shardEntry{
+				link: Link{Name: names[i], Hash: []byte(key), Size: size},
+				tag:  childType,
+				hash: s.keyHash(key),
+			})
+			if key, err = a.putEntries(entries, meta); err != nil {
+				return "", err
+			}
+			size = 0
+			for _, e := range entries {
+				size += e.link.Size
+			}
+		}
+		return key, nil
+	})
+	return newRoot, err
+}
+
+// truncateFile 保存键值为key的文件截断或填充到size字节后的新文件，返回其键值
+func (s *adder) truncateFile(key string, objType string, size int64, zeroFill bool) (string, error) {
+	data, err := s.getBlock(key)
+	if err != nil {
+		return "", err
+	}
+	if objType == BLOB {
+		if int64(len(data)) < size {
+			if !zeroFill {
+				return "", fmt.Errorf("truncate %d bytes to %d bytes: %w", len(data), size, ErrInvalidSize)
+			}
+			data = append(data, make([]byte, size-int64(len(data)))...)
+		}
+		key, _, err := s.putFile(&file{data: data[:size]}, "")
+		return key, err
+	}
+	obj, err := s.decodeBlock(key, data)
+	if err != nil {
+		return "", err
+	}
+	if size == 0 {
+		// 没有链接的LIST不是空文件的规范形式，按空的File保存，带有元数据时仍保留
+		key, _, err := s.putFile(&file{data: []byte{}, meta: obj.Meta}, "")
+		return key, err
+	}
+	var current int64
+	for _, link := range obj.Links {
+		current += link.Size
+	}
+	if current < size && !zeroFill {
+		return "", fmt.Errorf("truncate %d bytes to %d bytes: %w", current, size, ErrInvalidSize)
+	}
+	// 设置了WithContentNormalization时LIST的Merkle Root由新的完整内容计算：原来的前size字节，再加上填充的0
+	var content hash.Hash
+	if s.contentNormalization {
+		content = s.hasher()
+		r := &listReader{s: s.DagService, stack: []*listCursor{{obj: obj}}}
+		if _, err := io.CopyN(content, r, min(current, size)); err != nil {
+			return "", err
+		}
+	}
+	if current > size {
+		obj, err = s.truncateList(obj, size)
+		if err != nil {
+			return "", err
+		}
+	}
+	// 填充的部分按块大小保存为新的数据块
+	for current < size {
+		n := min(int64(s.blockChunkSize()), size-current)
+		chunk := make([]byte, n)
+		chunkKey, _, err := s.putNode(&file{data: chunk}, chunk, []string{s.hashBytes(chunk)})
+		if err != nil {
+			return "", err
+		}
+		obj.Links = append(obj.Links, Link{Hash: []byte(chunkKey), Size: n})
+		obj.Data = append(obj.Data, BLOB...)
+		if content != nil {
+			content.Write(chunk)
+		}
+		current += n
+	}
+	var contentHash string
+	if content != nil {
+		contentHash = hex.EncodeToString(content.Sum(nil))
+	}
+	return s.putList(obj, contentHash)
+}
+
+// truncateList 返回只保留LIST obj的前size字节的Object，截断点所在的块重新保存，
+// 嵌套的LIST同样截断
+func (s *adder) truncateList(obj *Object, size int64) (*Object, error) {
+	out := &Object{Meta: obj.Meta}
+	var offset int64
+	for i, link := range obj.Links {
+		if offset >= size {
+			break
+		}
+		tag := obj.linkType(i)
+		if offset+link.Size > size {
+			keep := size - offset
+			key, err := s.truncateFile(string(link.Hash), tag, keep, false)
+			if err != nil {
+				return nil, err
+			}
+			if tag, err = rootType(key); err != nil {
+				return nil, err
+			}
+			link = Link{Name: link.Name, Hash: []byte(key), Size: keep}
+		}
+		out.Links = append(out.Links, link)
+		out.Data = append(out.Data, tag...)
+		offset += link.Size
+	}
+	return out, nil
+}
+
+// putList 保存LIST obj，叶子哈希与putChunkedFile相同，content为其完整内容的哈希，返回其键值
+func (s *adder) putList(obj *Object, content string) (string, error) {
+	data, err := s.serializer.Marshal(obj)
+	if err != nil {
+		return "", err
+	}
+	hashes := make([]string, 0, len(obj.Links)+1)
+	for _, link := range obj.Links {
+		hashes = append(hashes, s.keyHash(string(link.Hash)))
+	}
+	leaves, err := s.listLeaves(obj, data, hashes, content)
+	if err != nil {
+		return "", err
+	}
+	merkleRoot, err := s.treeRoot(leaves)
+	if err != nil {
+		return "", err
+	}
+	key := s.formatKey(LIST, merkleRoot)
+	if err := s.putBlock(key, data); err != nil {
+		return "", err
+	}
+	return key, nil
+}
@@ -0,0 +1,104 @@
+package merkledag
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// readPath 用Cat读取root下path处的文件的全部内容
+func readPath(t *testing.T, s *DagService, root string, path string) []byte {
+	t.Helper()
+	r, err := s.Cat(root, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestTruncateChunkedFile(t *testing.T) {
+	src := chunkedSource(10000)
+	s := NewDagService(NewMemStore(), WithChunkSize(1000))
+	dir, err := s.Add(NewDirBuilder().AddFile("other", []byte("x")).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := s.AddEntry(dir, "log", NewChunkedFile(bytes.NewReader(src), int64(len(src))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	newRoot, err := s.Truncate(root, "log", 4321)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := readPath(t, s, newRoot, "log")
+	if !bytes.Equal(got, src[:4321]) {
+		t.Fatalf("read back %d bytes, want the first 4321", len(got))
+	}
+	// 与直接保存截断后的内容得到相同的文件
+	want, err := s.Add(NewChunkedFile(bytes.NewReader(src[:4321]), 4321))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key, _, err := s.resolveKey(newRoot, "log"); err != nil || key != want {
+		t.Fatalf("truncated file %s, want %s (%v)", key, want, err)
+	}
+	if _, err := s.Truncate(root, "log", 20000); !errors.Is(err, ErrInvalidSize) {
+		t.Fatalf("growing without WithZeroFill: got %v", err)
+	}
+	grown, err := s.Truncate(root, "log", 12000, WithZeroFill())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = readPath(t, s, grown, "log")
+	if !bytes.Equal(got, append(append([]byte(nil), src...), make([]byte, 2000)...)) {
+		t.Fatal("zero-filled content differs")
+	}
+}
+
+func TestTruncateToZeroIsEmptyFile(t *testing.T) {
+	src := chunkedSource(5000)
+	s := NewDagService(NewMemStore(), WithChunkSize(1000))
+	plain, err := s.Add(NewChunkedFile(bytes.NewReader(src), int64(len(src))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty, err := s.Truncate(plain, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := s.Add(NewFile([]byte{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if empty != want {
+		t.Fatalf("truncated to %s, want the empty file %s", empty, want)
+	}
+
+	// 元数据在截断后保留
+	meta := &Metadata{Mode: 0o640, ModTime: time.Unix(1700000000, 0).UTC()}
+	f := NewChunkedFile(bytes.NewReader(src), int64(len(src)))
+	f.SetMetadata(meta)
+	withMeta, err := s.Add(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty, err = s.Truncate(withMeta, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err = s.Add(&file{data: []byte{}, meta: meta})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if empty != want {
+		t.Fatalf("truncated to %s, want %s", empty, want)
+	}
+}
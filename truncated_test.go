@@ -0,0 +1,83 @@
+package merkledag
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestTruncatedBlocks(t *testing.T) {
+	mem := NewMemStore()
+	opts := []Option{WithChunking(true), WithMaxBlockSize(1024), WithChunkSize(64)}
+	s := NewDagService(mem, opts...)
+	verified := NewDagService(mem, append(opts, WithVerifyOnGet(true))...)
+	meta := &Metadata{Mode: 0o640, Xattrs: []Xattr{{Name: "user.a", Value: []byte("b")}}}
+	tree, err := s.Add(NewDirBuilder().
+		AddFile("a", []byte("hello")).
+		AddFile("big", bytes.Repeat([]byte("x"), 200)).
+		add("m", &file{data: []byte("with metadata"), meta: meta}).
+		Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, err := s.Commit(tree, "", "msg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := mem.Keys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	truncated := 0
+	for _, key := range keys {
+		objType, err := rootType(key)
+		if err != nil || objType == BLOB {
+			continue
+		}
+		orig, err := mem.Get(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		read := func(s *DagService) error {
+			if key == commit {
+				_, err := s.ReadCommit(key)
+				return err
+			}
+			_, err := s.Get(key)
+			return err
+		}
+		for n := 0; n < len(orig); n++ {
+			if err := mem.Put(key, orig[:n]); err != nil {
+				t.Fatal(err)
+			}
+			// 截断处恰好在可选的元数据之前时数据块仍然能够解析，只有校验哈希才能发现
+			if err := read(verified); err == nil {
+				t.Fatalf("%s truncated to %d of %d bytes passed verification", key, n, len(orig))
+			}
+			err := read(s)
+			if err == nil {
+				continue
+			}
+			var corrupt *ErrCorruptBlock
+			if !errors.As(err, &corrupt) {
+				t.Fatalf("%s truncated to %d of %d bytes: got %v, want a corrupt block error", key, n, len(orig), err)
+			}
+			var short *ErrTruncatedBlock
+			if errors.As(err, &short) {
+				if short.Key != key {
+					t.Fatalf("ErrTruncatedBlock for %s, want %s", short.Key, key)
+				}
+				truncated++
+			}
+		}
+		if err := mem.Put(key, orig); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if truncated == 0 {
+		t.Fatal("no truncation reported as ErrTruncatedBlock")
+	}
+	if _, err := s.Get(tree); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,39 @@
+package merkledag
+
+// blockTypeBytes 是设置了WithTypePrefix时每个数据块开头的类型字节
+var blockTypeBytes = map[string]byte{
+	BLOB:     0x01,
+	TREE:     0x02,
+	LIST:     0x03,
+	LINK:     0x04,
+	SHARD:    0x05,
+	SNAPSHOT: 0x06,
+	MERKLE:   0x07,
+	COMMIT:   0x08,
+}
+
+// encodeBlock 返回键值为key、内容为data的数据块写入KVStore的字节。
+// 设置了WithTypePrefix时在内容前加上类型字节，键值和Merkle Root仍然只由内容计算
+func (s *DagService) encodeBlock(key string, data []byte) []byte {
+	if !s.typePrefix {
+		return data
+	}
+	objType, _ := rootType(key)
+	return append([]byte{blockTypeBytes[objType]}, data...)
+}
+
+// decodeStored 将从KVStore中读出的数据块还原为其内容。
+// 设置了WithTypePrefix时检查并去掉类型字节，与键值的类型不符时返回ErrCorruptBlock
+func (s *DagService) decodeStored(key string, stored []byte) ([]byte, error) {
+	if !s.typePrefix {
+		return stored, nil
+	}
+	objType, err := rootType(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(stored) == 0 || stored[0] != blockTypeBytes[objType] {
+		return nil, &ErrCorruptBlock{Key: key, Err: errMalformedObject}
+	}
+	return stored[1:], nil
+}
@@ -0,0 +1,67 @@
+package merkledag
+
+import "testing"
+
+func TestTypePrefixOnEveryBlock(t *testing.T) {
+	st := NewMemStore()
+	s := NewDagService(st, WithTypePrefix(true), WithVerifyOnGet(true), WithPersistInternalNodes(true), WithShardThreshold(3))
+	tree := NewDirBuilder().
+		AddFile("a", []byte("a")).
+		AddFile("b", []byte("bb")).
+		AddFile("c", []byte("c")).
+		AddDir("d", NewDirBuilder().AddFile("x", []byte("x")).Build()).
+		add("l", &symlink{target: "a"}).
+		Build()
+	root, err := s.Add(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, err := s.Commit(root, "", "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := st.Keys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		if key == headerKey {
+			continue
+		}
+		stored, err := st.Get(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		objType, err := rootType(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(stored) == 0 || stored[0] != blockTypeBytes[objType] {
+			t.Fatalf("%s starts with %x, want %x", key, stored[:1], blockTypeBytes[objType])
+		}
+		seen[objType] = true
+	}
+	for _, objType := range []string{BLOB, TREE, LINK, SHARD, MERKLE, COMMIT} {
+		if !seen[objType] {
+			t.Errorf("no %s block stored", objType)
+		}
+	}
+	if _, err := s.Get(commit); err != nil {
+		t.Fatal(err)
+	}
+	if got := readPath(t, s, root, "d/x"); string(got) != "x" {
+		t.Fatalf("d/x = %q", got)
+	}
+	if report, err := VerifyStore(s, []string{commit}); err != nil || !report.OK() {
+		t.Fatalf("VerifyStore: %+v, %v", report, err)
+	}
+	// 复制到不加类型字节的KVStore后，得到的树相同
+	plain := NewDagService(NewMemStore())
+	if _, err := CopyTree(plain, s, root); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := EqualAcross(s, root, plain, root); !ok || err != nil {
+		t.Fatalf("EqualAcross: %v, %v", ok, err)
+	}
+}
@@ -0,0 +1,114 @@
+package merkledag
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ValidateNode 在Add之前检查用户构造的node：类型可以识别，大小不为负，目录项的名字能通过checkName且不重复。发现问题时返回ErrInvalidNode，Path为出问题的节点的路径。
+// 目录直接或间接地包含自己时返回ErrCycleDetected。与Add一样使用显式的栈遍历整棵树
+func ValidateNode(node Node) error {
+	if err := validateOne(node, "/"); err != nil {
+		return err
+	}
+	root, ok := node.(Dir)
+	if !ok {
+		return nil
+	}
+	type frame struct {
+		it    DirIterator
+		path  string
+		id    any
+		names map[string]bool
+	}
+	// ancestors 记录栈中目录的标识，子目录是自己的祖先时说明存在环
+	ancestors := make(map[any]bool)
+	push := func(stack []frame, d Dir, path string) []frame {
+		id, ok := nodeID(d)
+		if ok {
+			ancestors[id] = true
+		}
+		return append(stack, frame{it: d.It(), path: path, id: id, names: make(map[string]bool)})
+	}
+	stack := push(nil, root, "")
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		if !top.it.Next() {
+			if top.id != nil {
+				delete(ancestors, top.id)
+			}
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		name, child := top.it.Name(), top.it.Node()
+		path := joinPath(top.path, name)
+		if reason := checkName(name); reason != "" {
+			return &ErrInvalidNode{Path: "/" + top.path, Reason: reason}
+		}
+		if top.names[name] {
+			return &ErrInvalidNode{Path: "/" + path, Reason: "duplicate name"}
+		}
+		top.names[name] = true
+		if err := validateOne(child, "/"+path); err != nil {
+			return err
+		}
+		if d, ok := child.(Dir); ok {
+			if id, ok := nodeID(d); ok && ancestors[id] {
+				return fmt.Errorf("/%s: %w", path, ErrCycleDetected)
+			}
+			stack = push(stack, d, path)
+		}
+	}
+	return nil
+}
+
+// validateOne 检查单个节点的类型和大小，不检查子节点
+func validateOne(node Node, path string) error {
+	if node == nil {
+		return &ErrInvalidNode{Path: path, Reason: "nil node"}
+	}
+	var ok bool
+	switch node.Type() {
+	case FILE:
+		_, ok = node.(File)
+		if !ok {
+			_, ok = node.(*ChunkedFile)
+		}
+	case DIR:
+		_, ok = node.(Dir)
+	case SYMLINK:
+		_, ok = node.(Symlink)
+	default:
+		_, ok = codecForNode(node)
+		if _, isDir := node.(Dir); isDir {
+			ok = false
+		}
+	}
+	if !ok {
+		return &ErrInvalidNode{Path: path, Reason: fmt.Sprintf("unsupported node type %d (%T)", node.Type(), node)}
+	}
+	if d, ok := node.(*dir); ok && d.err != nil {
+		return &ErrInvalidNode{Path: path, Reason: d.err.Error()}
+	}
+	if node.Size() < 0 {
+		return &ErrInvalidNode{Path: path, Reason: fmt.Sprintf("negative size %d", node.Size())}
+	}
+	return nil
+}
+
+// checkName 检查目录项的名字能否作为文件系统中的一级路径：不为空，不是"."或".."，
+// 不包含路径分隔符"/"或"\"，也不是绝对路径。不合法时返回原因，否则返回空字符串
+func checkName(name string) string {
+	switch {
+	case name == "":
+		return "empty name"
+	case name == "." || name == "..":
+		return fmt.Sprintf("name %q is not a file name", name)
+	case strings.ContainsAny(name, `/\`):
+		return fmt.Sprintf("name %q contains a path separator", name)
+	case filepath.IsAbs(name) || filepath.VolumeName(name) != "":
+		return fmt.Sprintf("name %q is an absolute path", name)
+	}
+	return ""
+}
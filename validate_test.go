@@ -0,0 +1,65 @@
+package merkledag
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// negativeFile 的大小为负数
+type negativeFile struct{}
+
+func (negativeFile) Size() int64   { return -1 }
+func (negativeFile) Type() int     { return FILE }
+func (negativeFile) Bytes() []byte { return nil }
+
+// rawDir 不经过DirBuilder的检查构造目录
+func rawDir(names []string, children ...Node) Dir {
+	d := &dir{}
+	for i, name := range names {
+		d.entries = append(d.entries, entry{name: name, node: children[i]})
+	}
+	return d
+}
+
+func TestValidateNode(t *testing.T) {
+	f := NewFile([]byte("x"))
+	sub := rawDir([]string{"a"}, f)
+	if err := ValidateNode(rawDir([]string{"a", "b", "c"}, f, sub, sub)); err != nil {
+		t.Fatalf("valid tree: %v", err)
+	}
+	cases := []struct {
+		reason string
+		node   Node
+	}{
+		{"empty name", rawDir([]string{"a", ""}, f, f)},
+		{"duplicate name", rawDir([]string{"b"}, rawDir([]string{"a", "a"}, f, f))},
+		{"contains a path separator", rawDir([]string{"a/b"}, f)},
+		{"negative size", rawDir([]string{"n"}, negativeFile{})},
+		{"unsupported node type", rawDir([]string{"d"}, rawDir([]string{"o"}, unknownNode{}))},
+	}
+	for _, c := range cases {
+		var invalid *ErrInvalidNode
+		err := ValidateNode(c.node)
+		if !errors.As(err, &invalid) || !strings.Contains(invalid.Reason, c.reason) {
+			t.Errorf("%s: got %v", c.reason, err)
+			continue
+		}
+		// WithValidate时Add在写入任何数据块之前失败
+		st := NewMemStore()
+		if _, err := NewDagService(st, WithValidate(true)).Add(c.node); !errors.As(err, &invalid) {
+			t.Errorf("%s: Add got %v, want ErrInvalidNode", c.reason, err)
+		}
+		if keys := storedKeys(t, st); len(keys) != 0 {
+			t.Errorf("%s: Add wrote %v", c.reason, keys)
+		}
+	}
+	if err := ValidateNode(negativeFile{}); err == nil {
+		t.Error("negative size at the root accepted")
+	}
+	self := &loopDir{}
+	self.children = []Dir{self}
+	if err := ValidateNode(self); !errors.Is(err, ErrCycleDetected) {
+		t.Errorf("cycle: got %v, want ErrCycleDetected", err)
+	}
+}
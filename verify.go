@@ -0,0 +1,132 @@
+package merkledag
+
+import "errors"
+
+// VerifyReport 是VerifyStore的检查结果
+type VerifyReport struct {
+	// Checked 为检查过的数据块数量
+	Checked int
+	// Missing 为KVStore中缺少的数据块的键值
+	Missing []string
+	// Corrupt 为内容与键值不符或无法解析的数据块的键值
+	Corrupt []string
+}
+
+// OK 判断检查中是否没有发现问题
+func (r *VerifyReport) OK() bool {
+	return len(r.Missing) == 0 && len(r.Corrupt) == 0
+}
+
+// VerifyStore 检查roots可达的每个数据块是否存在，且内容能重新计算出其键值。
+// 发现问题时记录下来并继续检查其余的数据块；缺失或损坏的数据块的子节点无法确定，不再检查。
+// 只有KVStore返回其他错误时才停止并返回错误
+func VerifyStore(service *DagService, roots []string) (VerifyReport, error) {
+	var report VerifyReport
+	checked := make(map[string]bool)
+	for _, root := range roots {
+		objType, err := rootType(root)
+		if err != nil {
+			return report, err
+		}
+		stack := []blockRef{{key: root, objType: objType}}
+		for len(stack) > 0 {
+			ref := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if checked[ref.key] {
+				continue
+			}
+			checked[ref.key] = true
+			report.Checked++
+			data, err := service.readBlock(ref.key)
+			if errors.Is(err, ErrNotFound) {
+				report.Missing = append(report.Missing, ref.key)
+				continue
+			}
+			if err != nil {
+				return report, err
+			}
+			if err := service.verifyBlock(ref.key, data); err != nil {
+				report.Corrupt = append(report.Corrupt, ref.key)
+				continue
+			}
+			obj, err := service.blockLinks(ref, data)
+			if err != nil {
+				report.Corrupt = append(report.Corrupt, ref.key)
+				continue
+			}
+			if obj == nil {
+				continue
+			}
+			for i, link := range obj.Links {
+				stack = append(stack, blockRef{key: string(link.Hash), objType: obj.linkType(i)})
+			}
+		}
+	}
+	return report, nil
+}
+
+// Repair 遍历primary中root可达的所有数据块，缺少的数据块从fallback中读取，
+// 验证其内容与键值相符后写入primary，返回修复的数据块数量。
+// fallback中也没有或内容不符时停止并返回错误，已经修复的数据块保留在primary中
+func Repair(primary *DagService, fallback KVStore, root string) (int, error) {
+	objType, err := rootType(root)
+	if err != nil {
+		return 0, err
+	}
+	repaired := 0
+	// fetch 读取验证LIST时需要的块，primary中没有时从fallback中读取
+	fetch := func(key string) ([]byte, error) {
+		data, err := primary.readBlock(key)
+		if !errors.Is(err, ErrNotFound) {
+			return data, err
+		}
+		stored, err := fallback.Get(key)
+		if err != nil {
+			return nil, err
+		}
+		return primary.decodeStored(key, stored)
+	}
+	visited := make(map[string]bool)
+	stack := []blockRef{{key: root, objType: objType}}
+	for len(stack) > 0 {
+		ref := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if visited[ref.key] {
+			continue
+		}
+		visited[ref.key] = true
+		data, err := primary.readBlock(ref.key)
+		if errors.Is(err, ErrNotFound) {
+			stored, err := fallback.Get(ref.key)
+			if errors.Is(err, ErrNotFound) {
+				return repaired, &ErrBlockNotFound{Key: ref.key}
+			}
+			if err != nil {
+				return repaired, err
+			}
+			if data, err = primary.decodeStored(ref.key, stored); err != nil {
+				return repaired, err
+			}
+			if err := primary.verifyBlockWith(ref.key, data, fetch); err != nil {
+				return repaired, err
+			}
+			if err := primary.store.Put(ref.key, stored); err != nil {
+				return repaired, err
+			}
+			repaired++
+		} else if err != nil {
+			return repaired, err
+		}
+		obj, err := primary.blockLinks(ref, data)
+		if err != nil {
+			return repaired, corruptBlock(ref.key, err)
+		}
+		if obj == nil {
+			continue
+		}
+		for i, link := range obj.Links {
+			stack = append(stack, blockRef{key: string(link.Hash), objType: obj.linkType(i)})
+		}
+	}
+	return repaired, nil
+}
@@ -0,0 +1,132 @@
+package merkledag
+
+import "testing"
+
+func TestVerifyStoreReportsAllProblems(t *testing.T) {
+	st := NewMemStore()
+	s := NewDagService(st)
+	root, err := s.Add(walkTree())
+	if err != nil {
+		t.Fatal(err)
+	}
+	clean, err := VerifyStore(s, []string{root})
+	if err != nil || !clean.OK() {
+		t.Fatalf("clean store: %+v, %v", clean, err)
+	}
+	// 3个子目录、12个文件和根目录
+	if clean.Checked != 16 {
+		t.Fatalf("checked %d blocks, want 16", clean.Checked)
+	}
+	files, err := s.ListFiles(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupt, missing := files[0].Key, files[5].Key
+	if err := st.Put(corrupt, []byte("junk")); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Delete(missing); err != nil {
+		t.Fatal(err)
+	}
+	// 重复的根只检查一次
+	report, err := VerifyStore(s, []string{root, root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.OK() || report.Checked != 16 {
+		t.Fatalf("report %+v", report)
+	}
+	if len(report.Corrupt) != 1 || report.Corrupt[0] != corrupt {
+		t.Fatalf("corrupt %v, want [%s]", report.Corrupt, corrupt)
+	}
+	if len(report.Missing) != 1 || report.Missing[0] != missing {
+		t.Fatalf("missing %v, want [%s]", report.Missing, missing)
+	}
+}
+
+// copyStore 返回m中除存储头以外所有数据块的副本
+func copyStore(t *testing.T, m *MemStore) *MemStore {
+	t.Helper()
+	keys, err := m.Keys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewMemStore()
+	for _, key := range keys {
+		if key == headerKey {
+			continue
+		}
+		data, err := m.Get(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Put(key, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return c
+}
+
+func TestRepair(t *testing.T) {
+	st := NewMemStore()
+	s := NewDagService(st)
+	root, err := s.Add(walkTree())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fallback := copyStore(t, st)
+	// 删除一个内部的目录块和另一个子目录中的文件
+	obj, err := s.readObject(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := s.ListFiles(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{string(obj.Links[0].Hash), files[len(files)-1].Key} {
+		if err := st.Delete(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ok, _ := s.HasComplete(root); ok {
+		t.Fatal("tree complete before repair")
+	}
+	repaired, err := Repair(s, fallback, root)
+	if err != nil || repaired != 2 {
+		t.Fatalf("repaired %d, %v; want 2", repaired, err)
+	}
+	if ok, err := s.HasComplete(root); !ok || err != nil {
+		t.Fatalf("HasComplete after repair: %v, %v", ok, err)
+	}
+	if repaired, err := Repair(s, fallback, root); repaired != 0 || err != nil {
+		t.Fatalf("second repair: %d, %v", repaired, err)
+	}
+}
+
+func TestRepairRejectsCorruptFallback(t *testing.T) {
+	st := NewMemStore()
+	s := NewDagService(st)
+	root, err := s.Add(walkTree())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fallback := copyStore(t, st)
+	files, err := s.ListFiles(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	missing := files[0].Key
+	if err := st.Delete(missing); err != nil {
+		t.Fatal(err)
+	}
+	if err := fallback.Put(missing, []byte("junk")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Repair(s, fallback, root); err == nil {
+		t.Fatal("corrupt fallback block accepted")
+	}
+	if ok, _ := st.Has(missing); ok {
+		t.Fatal("corrupt block written to primary")
+	}
+}
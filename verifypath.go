@@ -0,0 +1,175 @@
+package merkledag
+
+import (
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// fsPair 是VerifyAgainstPath中需要比较的DAG中的节点和文件系统中的文件，
+// size为DAG中记录的大小，info为文件系统中的文件信息
+type fsPair struct {
+	path   string
+	ref    blockRef
+	size   int64
+	fsPath string
+	info   os.FileInfo
+}
+
+// VerifyAgainstPath 比较service中的root与文件系统中的fsPath，返回从root到fsPath的变化，按路径排序，
+// 报告方式与Diff相同，但不导入fsPath。按大小和内容的哈希比较文件，大小相同时才边读边计算文件的哈希，
+// 不把整个文件读入内存；不比较权限和修改时间等元数据，只有元数据不同的文件不报告。
+// 与ImportPath相同，文件系统中既不是普通文件也不是目录的节点被忽略，符号链接与DAG中的符号链接比较其目标，
+// DAG中不是符号链接时按其指向的文件比较。root为快照或提交时与其指向的树比较
+func VerifyAgainstPath(service *DagService, root string, fsPath string) ([]Change, error) {
+	objType, err := rootType(root)
+	if err != nil {
+		return nil, err
+	}
+	ref, err := service.treeRoot(blockRef{key: root, objType: objType})
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(fsPath)
+	if err != nil {
+		return nil, err
+	}
+	size := int64(-1)
+	if ref.objType == BLOB || ref.objType == LIST {
+		st, err := service.Stat(ref.key)
+		if err != nil {
+			return nil, err
+		}
+		size = st.Size
+	}
+	var changes []Change
+	stack := []fsPair{{ref: ref, size: size, fsPath: fsPath, info: info}}
+	for len(stack) > 0 {
+		p := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if p.ref.objType != TREE || !p.info.IsDir() {
+			same, err := service.sameAsFile(p)
+			if err != nil {
+				return nil, err
+			}
+			if !same {
+				changes = append(changes, Change{Path: p.path, Kind: Modified})
+			}
+			continue
+		}
+		obj, err := service.readDir(p.ref.key)
+		if err != nil {
+			return nil, err
+		}
+		onDisk, err := service.listFSDir(p.fsPath)
+		if err != nil {
+			return nil, err
+		}
+		inDAG := make(map[string]bool, len(obj.Links))
+		for i, link := range obj.Links {
+			inDAG[link.Name] = true
+			childPath := joinPath(p.path, link.Name)
+			child, ok := onDisk[link.Name]
+			if !ok {
+				changes = append(changes, Change{Path: childPath, Kind: Removed})
+				continue
+			}
+			ref := blockRef{key: string(link.Hash), objType: obj.linkType(i)}
+			if child.info.Mode()&os.ModeSymlink != 0 && ref.objType != LINK {
+				// 导入时跟随了符号链接，与其指向的文件比较；链接已经失效时视为修改
+				if child.info, err = os.Stat(child.fsPath); err != nil {
+					changes = append(changes, Change{Path: childPath, Kind: Modified})
+					continue
+				}
+			}
+			stack = append(stack, fsPair{path: childPath, ref: ref, size: link.Size, fsPath: child.fsPath, info: child.info})
+		}
+		for name := range onDisk {
+			if !inDAG[name] {
+				changes = append(changes, Change{Path: joinPath(p.path, name), Kind: Added})
+			}
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
+
+// listFSDir 返回文件系统中目录dir下的普通文件、目录和符号链接，名字按ImportPath的方式规范化。
+// 返回的fsPair只有fsPath和info，符号链接的info为其本身的信息
+func (s *DagService) listFSDir(dir string) (map[string]fsPair, error) {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	entries := make(map[string]fsPair, len(dirEntries))
+	for _, de := range dirEntries {
+		info, err := de.Info()
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() && info.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+		entries[s.normalizeName(de.Name())] = fsPair{fsPath: filepath.Join(dir, de.Name()), info: info}
+	}
+	return entries, nil
+}
+
+// sameAsFile 判断p中DAG的节点与文件系统中的文件是否相同，至少有一边不是目录。
+// 类型不同时不相同，符号链接比较其目标，文件先比较大小，再比较内容的Merkle Root
+func (s *DagService) sameAsFile(p fsPair) (bool, error) {
+	if p.info.Mode()&os.ModeSymlink != 0 || p.ref.objType == LINK {
+		if p.info.Mode()&os.ModeSymlink == 0 || p.ref.objType != LINK {
+			return false, nil
+		}
+		stored, err := s.getBlock(p.ref.key)
+		if err != nil {
+			return false, err
+		}
+		target, err := os.Readlink(p.fsPath)
+		if err != nil {
+			return false, err
+		}
+		return string(stored) == target, nil
+	}
+	if p.info.IsDir() || (p.ref.objType != BLOB && p.ref.objType != LIST) {
+		return false, nil
+	}
+	if p.size != p.info.Size() {
+		return false, nil
+	}
+	stored, err := s.fileContentRoot(p.ref)
+	if err != nil {
+		return false, err
+	}
+	f, err := os.Open(p.fsPath)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	h := s.hasher()
+	if _, err := io.Copy(h, f); err != nil {
+		return false, err
+	}
+	onDisk, err := s.calculateMerkleRoot([]string{hex.EncodeToString(h.Sum(nil))})
+	if err != nil {
+		return false, err
+	}
+	return stored == onDisk, nil
+}
+
+// fileContentRoot 返回文件ref的内容整体保存时的Merkle Root。BLOB直接由键值得到，LIST需要读取
+func (s *DagService) fileContentRoot(ref blockRef) (string, error) {
+	if ref.objType == BLOB {
+		return s.keyHash(ref.key), nil
+	}
+	obj, err := s.readObject(ref.key)
+	if err != nil {
+		return "", err
+	}
+	return s.listContentRoot(obj, s.getBlock)
+}
@@ -0,0 +1,163 @@
+package merkledag
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeVerifyTree 在dir下创建VerifyAgainstPath测试用的树，返回大文件的内容
+func writeVerifyTree(t *testing.T, dir string) []byte {
+	t.Helper()
+	big := bytes.Repeat([]byte("0123456789"), 1000)
+	if err := os.MkdirAll(filepath.Join(dir, "a", "b"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for path, data := range map[string][]byte{
+		"a/small": []byte("small file"),
+		"a/b/big": big,
+		"top":     []byte("top"),
+	} {
+		if err := os.WriteFile(filepath.Join(dir, path), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("top", filepath.Join(dir, "ln")); err != nil {
+		t.Fatal(err)
+	}
+	return big
+}
+
+func TestVerifyAgainstPath(t *testing.T) {
+	dir := t.TempDir()
+	big := writeVerifyTree(t, dir)
+	s := NewDagService(NewMemStore(), WithChunkSize(1024))
+	root, err := ImportPath(s, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes, err := VerifyAgainstPath(s, root, dir); err != nil || len(changes) != 0 {
+		t.Fatalf("unchanged tree: %v, %v", changes, err)
+	}
+	// 快照和提交与其指向的树比较
+	node, err := s.Get(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := s.AddSnapshot(node, "import")
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, err := s.Commit(snapshot, "", "import")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{snapshot, commit} {
+		if changes, err := VerifyAgainstPath(s, key, dir); err != nil || len(changes) != 0 {
+			t.Fatalf("%s of an unchanged tree: %v, %v", key, changes, err)
+		}
+	}
+	// 大小不变的修改也能发现；只改变修改时间的文件不报告
+	big[5000] = 'x'
+	if err := os.WriteFile(filepath.Join(dir, "a", "b", "big"), big, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(filepath.Join(dir, "top"), time.Unix(1, 0), time.Unix(1, 0)); err != nil {
+		t.Fatal(err)
+	}
+	changes, err := VerifyAgainstPath(s, root, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0] != (Change{Path: "a/b/big", Kind: Modified}) {
+		t.Fatalf("changes = %v, want only a/b/big modified", changes)
+	}
+	if changes, err = VerifyAgainstPath(s, commit, dir); err != nil || len(changes) != 1 {
+		t.Fatalf("commit: changes = %v, %v", changes, err)
+	}
+}
+
+func TestVerifyAgainstPathMatchesDiff(t *testing.T) {
+	dir := t.TempDir()
+	writeVerifyTree(t, dir)
+	s := NewDagService(NewMemStore(), WithChunkSize(1024))
+	root, err := ImportPath(s, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a", "small"), []byte("smallfile"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "new"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "top")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "ln")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("other", filepath.Join(dir, "ln")); err != nil {
+		t.Fatal(err)
+	}
+	changes, err := VerifyAgainstPath(s, root, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reimported, err := ImportPath(s, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff, err := Diff(s, root, reimported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 4 || fmt.Sprint(changes) != fmt.Sprint(diff) {
+		t.Fatalf("VerifyAgainstPath = %v, Diff = %v", changes, diff)
+	}
+}
+
+func TestVerifyAgainstPathSingleFile(t *testing.T) {
+	dir := t.TempDir()
+	writeVerifyTree(t, dir)
+	s := NewDagService(NewMemStore(), WithChunkSize(1024))
+	path := filepath.Join(dir, "a", "b", "big")
+	root, err := ImportPath(s, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes, err := VerifyAgainstPath(s, root, path); err != nil || len(changes) != 0 {
+		t.Fatalf("unchanged file: %v, %v", changes, err)
+	}
+	changes, err := VerifyAgainstPath(s, root, filepath.Join(dir, "a", "small"))
+	if err != nil || len(changes) != 1 || changes[0].Kind != Modified {
+		t.Fatalf("different file: %v, %v", changes, err)
+	}
+}
+
+func TestVerifyAgainstPathFollowedSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	writeVerifyTree(t, dir)
+	s := NewDagService(NewMemStore())
+	root, err := ImportPath(s, dir, WithFollowSymlinks(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes, err := VerifyAgainstPath(s, root, dir); err != nil || len(changes) != 0 {
+		t.Fatalf("followed symlink reported: %v, %v", changes, err)
+	}
+	// 符号链接指向另一个内容不同的文件
+	if err := os.Remove(filepath.Join(dir, "ln")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("a/small", filepath.Join(dir, "ln")); err != nil {
+		t.Fatal(err)
+	}
+	changes, err := VerifyAgainstPath(s, root, dir)
+	if err != nil || len(changes) != 1 || changes[0] != (Change{Path: "ln", Kind: Modified}) {
+		t.Fatalf("retargeted symlink: %v, %v", changes, err)
+	}
+}
@@ -0,0 +1,125 @@
+package merkledag
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+)
+
+// walPrefix 是预写日志中各条记录的键值前缀，第n条记录的键值为walPrefix+n。
+// walCommitKey 记录已经提交的事务，它们都不是数据块，GC不会删除
+const (
+	walPrefix    = "wal_"
+	walCommitKey = "wal_commit"
+)
+
+// walEntry 是预写日志中的一条计划写入
+type walEntry struct {
+	key   string
+	value []byte
+}
+
+// walBatch 是设置了WithWAL且KVStore不支持BatchStore时使用的Batch。
+// Put先把每个写入追加到日志中，Commit写入提交记录后才写入真正的数据块，最后删除日志。
+// 提交记录写入之前崩溃时，数据块都还没有写入，Recover丢弃日志即可；之后崩溃时Recover重放日志
+type walBatch struct {
+	s       *DagService
+	entries []walEntry
+	// root 为本次事务的根节点，写入提交记录
+	root string
+}
+
+func (w *walBatch) Put(key string, value []byte) error {
+	record := binary.AppendUvarint(nil, uint64(len(key)))
+	record = append(record, key...)
+	record = append(record, value...)
+	if err := w.s.store.Put(walPrefix+strconv.Itoa(len(w.entries)), record); err != nil {
+		return err
+	}
+	w.entries = append(w.entries, walEntry{key: key, value: value})
+	return nil
+}
+
+func (w *walBatch) Commit() error {
+	record := binary.AppendUvarint(nil, uint64(len(w.entries)))
+	record = append(record, w.root...)
+	if err := w.s.store.Put(walCommitKey, record); err != nil {
+		return err
+	}
+	for _, e := range w.entries {
+		if err := w.s.store.Put(e.key, e.value); err != nil {
+			return err
+		}
+	}
+	return w.s.truncateWAL(len(w.entries))
+}
+
+// discard 删除没有提交的事务的日志
+func (w *walBatch) discard() error {
+	return w.s.truncateWAL(len(w.entries))
+}
+
+// truncateWAL 删除日志的前n条记录和提交记录。提交记录最先删除，
+// 删除到一半时崩溃，剩下的记录会被Recover当作未提交的事务丢弃
+func (s *DagService) truncateWAL(n int) error {
+	if err := s.store.Delete(walCommitKey); err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		if err := s.store.Delete(walPrefix + strconv.Itoa(i)); err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Recover 处理上次崩溃时留下的预写日志：事务已经提交时重放日志中的所有写入并返回其根节点，
+// 没有提交时丢弃日志，返回空字符串。之后store中不会有没有提交的事务写入的数据块。
+// 使用WithWAL时应在打开store后、写入之前调用
+func (s *DagService) Recover() (string, error) {
+	if err := s.checkOpen(); err != nil {
+		return "", err
+	}
+	s.walMu.Lock()
+	defer s.walMu.Unlock()
+	commit, err := s.store.Get(walCommitKey)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return "", err
+	}
+	if err != nil {
+		// 没有提交记录：丢弃日志中连续的记录
+		n := 0
+		for ; ; n++ {
+			ok, err := s.store.Has(walPrefix + strconv.Itoa(n))
+			if err != nil {
+				return "", err
+			}
+			if !ok {
+				break
+			}
+		}
+		return "", s.truncateWAL(n)
+	}
+	r := &blockReader{data: commit}
+	n := int(r.uvarint())
+	if r.err != nil {
+		return "", corruptBlock(walCommitKey, r.err)
+	}
+	root := string(r.data)
+	for i := 0; i < n; i++ {
+		key := walPrefix + strconv.Itoa(i)
+		record, err := s.store.Get(key)
+		if err != nil {
+			return "", err
+		}
+		r := &blockReader{data: record}
+		target := r.bytes()
+		if r.err != nil {
+			return "", corruptBlock(key, r.err)
+		}
+		if err := s.store.Put(string(target), r.data); err != nil {
+			return "", err
+		}
+	}
+	return root, s.truncateWAL(n)
+}
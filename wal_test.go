@@ -0,0 +1,99 @@
+package merkledag
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// crashingStore 的第failAt次Put失败，之后的Put和Delete也失败，模拟写到一半时进程崩溃。
+// failAt为0时不失败
+type crashingStore struct {
+	*MemStore
+	failAt int
+	puts   int
+}
+
+var errCrash = errors.New("crash")
+
+func (c *crashingStore) Put(key string, value []byte) error {
+	c.puts++
+	if c.failAt > 0 && c.puts >= c.failAt {
+		return errCrash
+	}
+	return c.MemStore.Put(key, value)
+}
+
+func (c *crashingStore) Delete(key string) error {
+	if c.failAt > 0 && c.puts >= c.failAt {
+		return errCrash
+	}
+	return c.MemStore.Delete(key)
+}
+
+// storedKeys 返回m中除存储头以外的所有键值，按字典序排列
+func storedKeys(t *testing.T, m *MemStore) []string {
+	t.Helper()
+	keys, err := m.Keys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []string
+	for _, key := range keys {
+		if key != headerKey {
+			out = append(out, key)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func walTree() Dir {
+	return NewDirBuilder().
+		AddFile("a", []byte("a")).
+		AddDir("d", NewDirBuilder().AddFile("b", []byte("bb")).Build()).
+		Build()
+}
+
+func TestWALRecoverAfterCrash(t *testing.T) {
+	ref := NewMemStore()
+	root, err := NewDagService(ref).Add(walTree())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join(storedKeys(t, ref), ",")
+	// 依次在每一次写入时崩溃：日志、提交记录、数据块或删除日志
+	for failAt := 1; ; failAt++ {
+		st := &crashingStore{MemStore: NewMemStore(), failAt: failAt}
+		_, addErr := NewDagService(st, WithWAL(true)).Add(walTree())
+		if addErr == nil {
+			if got := strings.Join(storedKeys(t, st.MemStore), ","); got != want {
+				t.Fatalf("no crash: stored %s, want %s", got, want)
+			}
+			break
+		}
+		st.failAt = 0
+		recovered, err := NewDagService(st, WithWAL(true)).Recover()
+		if err != nil {
+			t.Fatalf("failAt %d: Recover: %v", failAt, err)
+		}
+		got := strings.Join(storedKeys(t, st.MemStore), ",")
+		switch recovered {
+		case "":
+			// 没有提交的事务被丢弃，不留下任何数据块
+			if got != "" {
+				t.Fatalf("failAt %d: discarded transaction left %s", failAt, got)
+			}
+		case root:
+			if got != want {
+				t.Fatalf("failAt %d: replayed %s, want %s", failAt, got, want)
+			}
+			if ok, err := NewDagService(st).HasComplete(root); !ok || err != nil {
+				t.Fatalf("failAt %d: HasComplete: %v, %v", failAt, ok, err)
+			}
+		default:
+			t.Fatalf("failAt %d: recovered %s, want %s or nothing", failAt, recovered, root)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package merkledag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// SkipSubtree 由Walk的visit返回时，表示不再进入当前目录的子节点
+var SkipSubtree = errors.New("skip this subtree")
+
+// blockRef 是一个数据块的键值及其类型标记
+type blockRef struct {
+	key     string
+	objType string
+}
+
+// WalkItem 是WalkChan遍历到的一个节点，Path为其相对root的路径，root的Path为空
+type WalkItem struct {
+	Key  string
+	Node Node
+	Path string
+}
+
+// walkFrame 是walk中待访问的节点
+type walkFrame struct {
+	blockRef
+	path string
+	// parent 为父目录在本次遍历中被展开的序号，同一目录的子节点相同，根节点为0
+	parent int
+}
+
+// Walk 从root开始深度优先遍历DAG，对每个节点调用visit。目录节点的子节点都是Placeholder，
+// 子节点在之后单独访问；文件和符号链接在访问到时才读取其内容。visit返回SkipSubtree时
+// 跳过该目录的子节点，返回其他错误时停止遍历并返回该错误。
+// 同一目录的子节点总是按名字的字节序访问（与序列化的顺序相同，分片的目录也是如此），
+// 因此对同一个root的多次遍历以相同的顺序访问节点
+func (s *DagService) Walk(root string, visit func(key string, node Node) error) error {
+	return s.walk(context.Background(), root, false, func(key string, _ string, node Node) error {
+		return visit(key, node)
+	})
+}
+
+// WalkChan 在一个新的goroutine中按Walk的顺序遍历root，通过返回的第一个channel发送每个节点，
+// 遍历结束后关闭它。遍历出错或ctx被取消时停止，错误发送到第二个channel，之后两个channel都被关闭。
+// 调用方不再读取节点时应当取消ctx，否则goroutine无法退出
+func (s *DagService) WalkChan(ctx context.Context, root string) (<-chan WalkItem, <-chan error) {
+	items := make(chan WalkItem)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		err := s.walk(ctx, root, true, func(key string, path string, node Node) error {
+			select {
+			case items <- WalkItem{Key: key, Node: node, Path: path}:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		close(items)
+		if err != nil {
+			errc <- err
+		}
+	}()
+	return items, errc
+}
+
+// lightFrame 是WalkLight中待访问的节点，names为其相对root的路径中的各个名字
+type lightFrame struct {
+	blockRef
+	names []string
+}
+
+// WalkLight 与Walk一样深度优先遍历root，但只读取目录块，不重建节点，也不读取文件和符号链接的数据块。
+// visit得到每个节点的键值、Node类型（FILE、DIR或SYMLINK）和相对root的路径中的各个名字，root的names为空。
+// root为快照时从快照的根节点开始。visit返回SkipSubtree时跳过该目录的子节点，返回其他错误时停止遍历
+func (s *DagService) WalkLight(root string, visit func(key string, typ int, names []string) error) error {
+	objType, err := rootType(root)
+	if err != nil {
+		return err
+	}
+	ref, err := s.snapshotRoot(blockRef{key: root, objType: objType})
+	if err != nil {
+		return err
+	}
+	stack := []lightFrame{{blockRef: ref}}
+	for len(stack) > 0 {
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		typ := nodeKind(top.objType)
+		if typ < 0 {
+			return fmt.Errorf("%s: %w", top.key, ErrUnsupportedNodeType)
+		}
+		err := visit(top.key, typ, top.names)
+		if err == SkipSubtree {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if top.objType != TREE {
+			continue
+		}
+		obj, err := s.readDir(top.key)
+		if err != nil {
+			return err
+		}
+		// 逆序入栈，使子节点按目录中的顺序被访问。names截断容量，子节点追加名字时不会互相覆盖
+		names := top.names[:len(top.names):len(top.names)]
+		for i := len(obj.Links) - 1; i >= 0; i-- {
+			stack = append(stack, lightFrame{
+				blockRef: blockRef{key: string(obj.Links[i].Hash), objType: obj.linkType(i)},
+				names:    append(names, obj.Links[i].Name),
+			})
+		}
+	}
+	return nil
+}
+
+// walk 实现Walk，withPaths为true时计算每个节点的路径，否则path总是为空。
+// 每次只读取当前节点的数据块：目录交给visit的是子节点都为Placeholder的浅层节点，
+// visit没有跳过它时才把子节点入栈，因此跳过的子树不会被读取。root为快照或提交时从其指向的树开始
+func (s *DagService) walk(ctx context.Context, root string, withPaths bool, visit func(key string, path string, node Node) error) error {
+	objType, err := rootType(root)
+	if err != nil {
+		return err
+	}
+	ref, err := s.treeRoot(blockRef{key: root, objType: objType})
+	if err != nil {
+		return err
+	}
+	// 错误中不填写路径
+	g := s.newGetter(ctx)
+	g.trackPath = false
+	stack := []walkFrame{{blockRef: ref}}
+	dirs := 0
+	for len(stack) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		ref := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if err := g.prefetchSiblings(ref, stack); err != nil {
+			return err
+		}
+		if ref.objType != TREE {
+			// 不经过g.cache，访问过的文件不会一直留在内存中
+			node, err := g.loadNode(ref.key, ref.objType)
+			if err != nil {
+				return err
+			}
+			if err := visit(ref.key, ref.path, node); err != nil && err != SkipSubtree {
+				return err
+			}
+			continue
+		}
+		obj, err := g.walkDir(ref.key)
+		if err != nil {
+			return err
+		}
+		d := &dir{meta: obj.Meta}
+		for i, link := range obj.Links {
+			child := &Placeholder{Key: string(link.Hash), Kind: nodeKind(obj.linkType(i)), size: link.Size}
+			d.entries = append(d.entries, entry{name: link.Name, node: child})
+		}
+		err = visit(ref.key, ref.path, d)
+		if err == SkipSubtree {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		// 逆序入栈，使子节点按名字的顺序被访问
+		dirs++
+		for i := len(obj.Links) - 1; i >= 0; i-- {
+			child := walkFrame{blockRef: blockRef{key: string(obj.Links[i].Hash), objType: obj.linkType(i)}, parent: dirs}
+			if withPaths {
+				child.path = joinPath(ref.path, obj.Links[i].Name)
+			}
+			stack = append(stack, child)
+		}
+	}
+	return nil
+}
+
+// walkDir 读取key对应的目录块并展开分片，已经预读的数据块直接使用
+func (g *getter) walkDir(key string) (*Object, error) {
+	data, ok := g.blocks[key]
+	if ok {
+		delete(g.blocks, key)
+	} else {
+		var err error
+		if data, err = g.getBlock(key); err != nil {
+			return nil, err
+		}
+	}
+	obj, err := g.decodeBlock(key, data)
+	if err != nil {
+		return nil, err
+	}
+	return g.expandShards(obj)
+}
@@ -0,0 +1,384 @@
+package merkledag
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingStore 记录每个键值被Get的次数和写入数据的次数
+type countingStore struct {
+	*MemStore
+	mu   sync.Mutex
+	gets map[string]int
+	puts int
+}
+
+func newCountingStore() *countingStore {
+	return &countingStore{MemStore: NewMemStore(), gets: make(map[string]int)}
+}
+
+func (c *countingStore) Get(key string) ([]byte, error) {
+	c.mu.Lock()
+	c.gets[key]++
+	c.mu.Unlock()
+	return c.MemStore.Get(key)
+}
+
+func (c *countingStore) Put(key string, value []byte) error {
+	c.mu.Lock()
+	c.puts++
+	c.mu.Unlock()
+	return c.MemStore.Put(key, value)
+}
+
+func (c *countingStore) PutNoCopy(key string, value []byte) error {
+	c.mu.Lock()
+	c.puts++
+	c.mu.Unlock()
+	return c.MemStore.PutNoCopy(key, value)
+}
+
+// blockGets 返回数据块被Get的总次数，不包括存储头等其他键值
+func (c *countingStore) blockGets() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for key, count := range c.gets {
+		if _, err := rootType(key); err == nil {
+			n += count
+		}
+	}
+	return n
+}
+
+func (c *countingStore) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gets = make(map[string]int)
+	c.puts = 0
+}
+
+// walkTree 返回一个有3个子目录、每个子目录有4个文件的目录
+func walkTree() Dir {
+	b := NewDirBuilder()
+	for i := 0; i < 3; i++ {
+		sub := NewDirBuilder()
+		for j := 0; j < 4; j++ {
+			sub.AddFile(fmt.Sprint("f", j), []byte(fmt.Sprint(i, "/", j)))
+		}
+		b.AddDir(fmt.Sprint("d", i), sub.Build())
+	}
+	return b.Build()
+}
+
+func TestWalkCountsNodes(t *testing.T) {
+	st := newCountingStore()
+	s := NewDagService(st)
+	root, err := s.Add(walkTree())
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.reset()
+	var dirs, files int
+	err = s.Walk(root, func(key string, node Node) error {
+		switch node.Type() {
+		case DIR:
+			dirs++
+		case FILE:
+			files++
+			if string(node.(File).Bytes()) == "" {
+				t.Errorf("%s: empty content", key)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dirs != 4 || files != 12 {
+		t.Fatalf("visited %d dirs and %d files, want 4 and 12", dirs, files)
+	}
+	// 每个数据块只读取一次
+	for key, n := range st.gets {
+		if n != 1 {
+			t.Errorf("%s read %d times", key, n)
+		}
+	}
+}
+
+func TestWalkSkipSubtree(t *testing.T) {
+	st := newCountingStore()
+	s := NewDagService(st)
+	root, err := s.Add(walkTree())
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.reset()
+	visited := 0
+	err = s.Walk(root, func(key string, node Node) error {
+		visited++
+		return SkipSubtree
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if visited != 1 || st.blockGets() != 1 {
+		t.Fatalf("visited %d nodes reading %d blocks, want 1 and 1", visited, st.blockGets())
+	}
+
+	var paths []string
+	items, errc := s.WalkChan(context.Background(), root)
+	for item := range items {
+		paths = append(paths, item.Path)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 16 {
+		t.Fatalf("WalkChan visited %d nodes, want 16", len(paths))
+	}
+	// 第二个访问的节点是d0，跳过它的4个文件，其余子树照常访问
+	st.reset()
+	visited = 0
+	err = s.Walk(root, func(key string, node Node) error {
+		visited++
+		if visited == 2 {
+			return SkipSubtree
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if visited != 12 {
+		t.Fatalf("visited %d nodes, want 12", visited)
+	}
+	if st.blockGets() != 12 {
+		t.Fatalf("read %d blocks, want 12", st.blockGets())
+	}
+}
+
+func TestWalkStopsOnError(t *testing.T) {
+	s := NewDagService(NewMemStore())
+	root, err := s.Add(walkTree())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := errors.New("stop")
+	visited := 0
+	err = s.Walk(root, func(string, Node) error {
+		visited++
+		if visited == 3 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || visited != 3 {
+		t.Fatalf("got %v after %d nodes", err, visited)
+	}
+}
+
+func TestWalkChan(t *testing.T) {
+	s := NewDagService(NewMemStore())
+	root, err := s.Add(walkTree())
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, errc := s.WalkChan(context.Background(), root)
+	paths := make(map[string]bool)
+	for item := range items {
+		paths[item.Path] = true
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 16 || !paths["d1/f2"] {
+		t.Fatalf("walked %d paths", len(paths))
+	}
+}
+
+func TestWalkChanCancelDoesNotLeak(t *testing.T) {
+	s := NewDagService(NewMemStore())
+	root, err := s.Add(wideTree(50, 20, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	items, errc := s.WalkChan(ctx, root)
+	for i := 0; i < 5; i++ {
+		if _, ok := <-items; !ok {
+			t.Fatal("channel closed early")
+		}
+	}
+	// 取消后不再读取节点，goroutine也应当退出
+	cancel()
+	select {
+	case err := <-errc:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("got %v, want context.Canceled", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("WalkChan did not stop after cancel")
+	}
+	if _, ok := <-errc; ok {
+		t.Fatal("error channel not closed")
+	}
+	remaining := 0
+	for range items {
+		remaining++
+	}
+	if remaining != 0 {
+		t.Fatalf("%d items sent after cancel", remaining)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines, %d before WalkChan", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// largeFileTree 返回包含10个200K的文件和两层子目录的目录
+func largeFileTree() Dir {
+	b := NewDirBuilder()
+	for i := 0; i < 10; i++ {
+		b.AddFile(fmt.Sprint("f", i), bytes.Repeat([]byte{byte(i)}, 200*K))
+	}
+	deep := NewDirBuilder().AddFile("y", []byte("y")).Build()
+	b.AddDir("sub", NewDirBuilder().AddFile("x", []byte("x")).AddDir("deep", deep).Build())
+	return b.Build()
+}
+
+func TestWalkLightReadsOnlyDirectories(t *testing.T) {
+	st := newCountingStore()
+	s := NewDagService(st)
+	root, err := s.Add(largeFileTree())
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.reset()
+	var paths []string
+	err = s.WalkLight(root, func(key string, typ int, names []string) error {
+		paths = append(paths, strings.Join(names, "/"))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 根目录、sub、deep三个目录块和12个文件
+	if len(paths) != 15 {
+		t.Fatalf("visited %d nodes: %v", len(paths), paths)
+	}
+	if got := st.blockGets(); got != 3 {
+		t.Fatalf("read %d blocks, want only the 3 directories", got)
+	}
+}
+
+// reportRetainedHeap 再遍历一次，每访问一个节点就GC并用runtime.ReadMemStats读取存活的堆内存，
+// 把遍历过程中相对遍历前的最大增长报告为retained-B
+func reportRetainedHeap(b *testing.B, walk func(visited func()) error) {
+	b.StopTimer()
+	var ms runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&ms)
+	base, peak := ms.HeapAlloc, uint64(0)
+	err := walk(func() {
+		runtime.GC()
+		runtime.ReadMemStats(&ms)
+		if ms.HeapAlloc > base && ms.HeapAlloc-base > peak {
+			peak = ms.HeapAlloc - base
+		}
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportMetric(float64(peak), "retained-B")
+}
+
+func BenchmarkWalkLight(b *testing.B) {
+	s := NewDagService(NewMemStore())
+	root, err := s.Add(largeFileTree())
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("WalkLight", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := s.WalkLight(root, func(string, int, []string) error { return nil }); err != nil {
+				b.Fatal(err)
+			}
+		}
+		reportRetainedHeap(b, func(visited func()) error {
+			return s.WalkLight(root, func(string, int, []string) error {
+				visited()
+				return nil
+			})
+		})
+	})
+	b.Run("Walk", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := s.Walk(root, func(string, Node) error { return nil }); err != nil {
+				b.Fatal(err)
+			}
+		}
+		reportRetainedHeap(b, func(visited func()) error {
+			return s.Walk(root, func(string, Node) error {
+				visited()
+				return nil
+			})
+		})
+	})
+}
+
+func TestWalkOrderStable(t *testing.T) {
+	s := NewDagService(NewMemStore(), WithShardThreshold(4))
+	b := NewDirBuilder()
+	for i := 0; i < 40; i++ {
+		b.AddDir(fmt.Sprint("d", i), NewDirBuilder().AddFile("x", []byte(fmt.Sprint(i))).AddFile("a", nil).Build())
+	}
+	root, err := s.Add(b.Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := func() []string {
+		var seq []string
+		err := s.Walk(root, func(key string, node Node) error {
+			seq = append(seq, key)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return seq
+	}
+	first, second := record(), record()
+	if len(first) != 1+40*3 || strings.Join(first, ",") != strings.Join(second, ",") {
+		t.Fatalf("visit sequences differ: %d and %d nodes", len(first), len(second))
+	}
+
+	// WalkChan同样按名字有序访问，分片目录也不例外
+	var top []string
+	items, errc := s.WalkChan(context.Background(), root)
+	for item := range items {
+		if item.Path != "" && !strings.Contains(item.Path, "/") {
+			top = append(top, item.Path)
+		}
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if len(top) != 40 || !sort.StringsAreSorted(top) {
+		t.Fatalf("top-level entries out of order: %v", top)
+	}
+}
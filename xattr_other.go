@@ -0,0 +1,21 @@
+//go:build !linux && !darwin
+
+package merkledag
+
+import (
+	"errors"
+	"fmt"
+)
+
+// readXattrs 在此平台上不读取扩展属性
+func readXattrs(path string) ([]Xattr, error) {
+	return nil, nil
+}
+
+// writeXattrs 在此平台上无法写入扩展属性，xattrs不为空时返回错误
+func writeXattrs(path string, xattrs []Xattr) error {
+	if len(xattrs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("set xattrs of %s: %w", path, errors.ErrUnsupported)
+}
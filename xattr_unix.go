@@ -0,0 +1,76 @@
+//go:build linux || darwin
+
+package merkledag
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// readXattrs 读取path的所有扩展属性，path是符号链接时读取其指向的文件。
+// 文件系统不支持扩展属性时返回nil
+func readXattrs(path string) ([]Xattr, error) {
+	names, err := xattrCall(func(buf []byte) (int, error) {
+		return unix.Listxattr(path, buf)
+	})
+	if errors.Is(err, unix.ENOTSUP) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list xattrs of %s: %w", path, err)
+	}
+	var xattrs []Xattr
+	for _, name := range bytes.Split(names, []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+		value, err := xattrCall(func(buf []byte) (int, error) {
+			return unix.Getxattr(path, string(name), buf)
+		})
+		if errors.Is(err, unix.ENODATA) {
+			// 列出之后被删除
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("get xattr %s of %s: %w", name, path, err)
+		}
+		xattrs = append(xattrs, Xattr{Name: string(name), Value: value})
+	}
+	return sortedXattrs(xattrs), nil
+}
+
+// xattrCall 先以空缓冲区调用call得到所需的大小，再分配缓冲区读取。
+// 两次调用之间属性变大时重试
+func xattrCall(call func(buf []byte) (int, error)) ([]byte, error) {
+	for {
+		n, err := call(nil)
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return []byte{}, nil
+		}
+		buf := make([]byte, n)
+		n, err = call(buf)
+		if errors.Is(err, unix.ERANGE) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+}
+
+// writeXattrs 将xattrs写入path，已有的同名属性被覆盖
+func writeXattrs(path string, xattrs []Xattr) error {
+	for _, x := range xattrs {
+		if err := unix.Setxattr(path, x.Name, x.Value, 0); err != nil {
+			return fmt.Errorf("set xattr %s of %s: %w", x.Name, path, err)
+		}
+	}
+	return nil
+}
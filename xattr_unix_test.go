@@ -0,0 +1,89 @@
+//go:build linux || darwin
+
+package merkledag
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestXattrRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "f")
+	if err := os.WriteFile(path, []byte("content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "d"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	s := NewDagService(NewMemStore())
+	before, err := ImportPath(s, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := unix.Setxattr(path, "user.b", []byte("two"), 0); err != nil {
+		t.Skipf("extended attributes not supported: %v", err)
+	}
+	if err := unix.Setxattr(path, "user.a", []byte("one"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := unix.Setxattr(filepath.Join(dir, "d"), "user.dir", []byte("x"), 0); err != nil {
+		t.Fatal(err)
+	}
+	root, err := ImportPath(s, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root == before {
+		t.Fatal("xattrs did not change the root")
+	}
+	n, err := s.Resolve(root, "f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := metadataOf(n)
+	if meta == nil || len(meta.Xattrs) != 2 || meta.Xattrs[0].Name != "user.a" || string(meta.Xattrs[1].Value) != "two" {
+		t.Fatalf("xattrs not stored: %+v", meta)
+	}
+
+	out := t.TempDir()
+	if err := ExportPath(s, root, out); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readXattrs(filepath.Join(out, "f"))
+	if err != nil || fmt.Sprint(got) != fmt.Sprint(meta.Xattrs) {
+		t.Fatalf("exported xattrs %v, %v; want %v", got, err, meta.Xattrs)
+	}
+	got, err = readXattrs(filepath.Join(out, "d"))
+	if err != nil || len(got) != 1 || got[0].Name != "user.dir" {
+		t.Fatalf("exported directory xattrs %v, %v", got, err)
+	}
+}
+
+func TestXattrOrderIndependent(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithSerializer(JSONSerializer{})}} {
+		s := NewDagService(NewMemStore(), opts...)
+		k1, err := s.Add(&file{data: []byte("x"), meta: &Metadata{Mode: 0o600, Xattrs: []Xattr{{"user.z", []byte("1")}, {"user.a", []byte("2")}}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		k2, err := s.Add(&file{data: []byte("x"), meta: &Metadata{Mode: 0o600, Xattrs: []Xattr{{"user.a", []byte("2")}, {"user.z", []byte("1")}}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if k1 != k2 {
+			t.Fatalf("xattr order changed the key: %s != %s", k1, k2)
+		}
+		plain, err := s.Add(&file{data: []byte("x"), meta: &Metadata{Mode: 0o600}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if plain == k1 {
+			t.Fatal("xattrs not folded into the key")
+		}
+	}
+}